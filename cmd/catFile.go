@@ -4,49 +4,272 @@ Copyright © 2024 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/liuminhaw/mist-miner/cmd/mmerr"
+	"github.com/liuminhaw/mist-miner/shared"
 	"github.com/liuminhaw/mist-miner/shelf"
+	"github.com/mattn/go-isatty"
 	"github.com/spf13/cobra"
 )
 
 // catFileCmd represents the catFile command
 var catFileCmd = &cobra.Command{
-	Use:          "cat-file <group> <hash>",
-	Short:        "Display the content of given hash object",
-	Long:         ``,
+	Use:   "cat-file <group> [object]",
+	Short: "Display the content of given hash object",
+	Long: `Display the content of given hash object. Object can be given as a hash or
+unique hash prefix, or a label mark revision such as HEAD~1 or a tag name.
+
+With -t the object type is printed, with -p the object is rendered according to its
+type. Child object hashes of label marks, identifier maps, outlines, resource trees and
+diaries are printed as terminal hyperlinks to the object files when output is a terminal
+and the object file is stored in plain text, loose with compression none and no encryption.
+
+With --at the object is the newest label mark created at or before the given time,
+given as RFC3339 timestamp, date or duration before now, ex. 2026-09-01T00:00:00Z,
+2026-09-01 or 7d.`,
 	SilenceUsage: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		if len(args) != 2 {
+		if catFileAt != "" && len(args) != 1 {
+			return mmerr.NewArgsError(
+				mmerr.CatFileCmdType,
+				fmt.Sprintf("accepts 1 arg with --at, received %d", len(args)),
+			)
+		} else if catFileAt == "" && len(args) != 2 {
 			return mmerr.NewArgsError(
 				mmerr.CatFileCmdType,
 				fmt.Sprintf("accepts 2 args, received %d", len(args)),
 			)
 		}
+		if catFileType && catFilePretty {
+			return mmerr.NewArgsError(mmerr.CatFileCmdType, "-t and -p cannot be used together")
+		}
 		group := args[0]
-		hash := args[1]
 
-		content, err := shelf.NewObjectRecord(group, hash).RecordRead()
+		var hash string
+		var err error
+		if catFileAt != "" {
+			hash, err = shelf.ResolveMarkAt(group, catFileAt)
+		} else {
+			hash, err = shelf.ResolveObject(group, args[1])
+		}
 		if err != nil {
 			return fmt.Errorf("cat-file sub-command failed: %w", err)
 		}
-		fmt.Println(content)
+
+		switch {
+		case catFileType:
+			header, err := shelf.NewObjectRecord(group, hash).RecordHeader()
+			if err != nil {
+				return fmt.Errorf("cat-file sub-command failed: %w", err)
+			}
+			if header.Legacy {
+				return fmt.Errorf(
+					"cat-file sub-command failed: object %s has no type header, run shelf migrate to upgrade",
+					hash,
+				)
+			}
+			fmt.Println(header.Type)
+		case catFilePretty:
+			if err := printObject(group, hash); err != nil {
+				return fmt.Errorf("cat-file sub-command failed: %w", err)
+			}
+		default:
+			content, err := shelf.NewObjectRecord(group, hash).RecordRead()
+			if err != nil {
+				return fmt.Errorf("cat-file sub-command failed: %w", err)
+			}
+			fmt.Println(content)
+		}
 
 		return nil
 	},
 }
 
+var (
+	catFileType   bool
+	catFilePretty bool
+	catFileAt     string
+)
+
 func init() {
 	rootCmd.AddCommand(catFileCmd)
 
-	// Here you will define your flags and configuration settings.
+	catFileCmd.Flags().BoolVarP(&catFileType, "type", "t", false, "show object type")
+	catFileCmd.Flags().BoolVarP(&catFilePretty, "pretty", "p", false, "pretty print object content based on its type")
+	catFileCmd.Flags().StringVar(&catFileAt, "at", "", "show the label mark at given time, ex. 2026-09-01T00:00:00Z or 7d")
+}
 
-	// Cobra supports Persistent Flags which will work for this command
-	// and all subcommands, e.g.:
-	// catFileCmd.PersistentFlags().String("foo", "", "A help for foo")
+// printObject prints the object of given hash in a structured form based on its type,
+// legacy objects without type header are printed as is.
+func printObject(group, hash string) error {
+	header, err := shelf.NewObjectRecord(group, hash).RecordHeader()
+	if err != nil {
+		return fmt.Errorf("print object: %w", err)
+	}
+
+	switch header.Type {
+	case shelf.OBJECT_TYPE_MARK:
+		mark, err := shelf.ReadMark(group, hash)
+		if err != nil {
+			return fmt.Errorf("print object: %w", err)
+		}
+		fmt.Printf("type:      %s\n", header.Type)
+		fmt.Printf("timestamp: %s\n", mark.TimeStamp.Local().Format(time.RFC3339))
+		fmt.Printf("log type:  %s\n", mark.LogType)
+		if mark.Parent == "nil" {
+			fmt.Printf("parent:    %s\n", mark.Parent)
+		} else {
+			fmt.Printf("parent:    %s\n", objectLink(group, mark.Parent))
+		}
+		fmt.Println("mappings:")
+		for _, mapping := range mark.Mappings {
+			if mapping.Version == "" {
+				fmt.Printf("  %s  %s\n", objectLink(group, mapping.Hash), mapping.Module)
+			} else {
+				fmt.Printf("  %s  %s %s\n", objectLink(group, mapping.Hash), mapping.Module, mapping.Version)
+			}
+		}
+	case shelf.OBJECT_TYPE_IDMAPS:
+		idHashMaps, err := shelf.ReadIdentifierHashMaps(group, hash)
+		if err != nil {
+			return fmt.Errorf("print object: %w", err)
+		}
+		fmt.Printf("type: %s\n", header.Type)
+		fmt.Println("identifiers:")
+		for _, m := range idHashMaps.Maps {
+			if m.Alias != "" {
+				fmt.Printf("  %s  %s (%s)\n", objectLink(group, m.Hash), m.Identifier, m.Alias)
+			} else {
+				fmt.Printf("  %s  %s\n", objectLink(group, m.Hash), m.Identifier)
+			}
+		}
+	case shelf.OBJECT_TYPE_OUTLINE:
+		outline, err := shelf.ReadStuffOutline(group, hash)
+		if err != nil {
+			return fmt.Errorf("print object: %w", err)
+		}
+		fmt.Printf("type:     %s\n", header.Type)
+		fmt.Printf("resource: %s\n", objectLink(group, outline.ResourceHash))
+		fmt.Printf("diary:    %s\n", objectLink(group, outline.DiaryHash))
+	case shelf.OBJECT_TYPE_RESOURCE:
+		resource, err := shelf.ReadResource(group, hash)
+		if err != nil {
+			return fmt.Errorf("print object: %w", err)
+		}
+		printResource(header.Type, resource)
+	case shelf.OBJECT_TYPE_TREE:
+		tree, err := shelf.ReadResourceTree(group, hash)
+		if err != nil {
+			return fmt.Errorf("print object: %w", err)
+		}
+		fmt.Printf("type:       %s\n", header.Type)
+		fmt.Printf("identifier: %s\n", tree.Identifier)
+		if tree.Alias != "" {
+			fmt.Printf("alias:      %s\n", tree.Alias)
+		}
+		fmt.Println("properties:")
+		for _, prop := range tree.Properties {
+			unique := ""
+			if prop.Label.Unique {
+				unique = " (unique)"
+			}
+			fmt.Printf("  %s  %s / %s%s\n", objectLink(group, prop.Hash), prop.Type, prop.Label.Name, unique)
+		}
+	case shelf.OBJECT_TYPE_PROPERTY:
+		content, err := shelf.ReadPropertyContent(group, hash)
+		if err != nil {
+			return fmt.Errorf("print object: %w", err)
+		}
+		fmt.Printf("type:   %s\n", header.Type)
+		fmt.Printf("format: %s\n", content.Format)
+		fmt.Println("value:")
+		for _, line := range strings.Split(contentValue(content), "\n") {
+			fmt.Printf("  %s\n", line)
+		}
+	case shelf.OBJECT_TYPE_DIARY:
+		diary, err := shelf.ReadMinerDiary(group, hash)
+		if err != nil {
+			return fmt.Errorf("print object: %w", err)
+		}
+		fmt.Printf("type: %s\n", header.Type)
+		if diary.Hash != "" {
+			fmt.Printf("note: %s\n", objectLink(group, diary.Hash))
+		} else {
+			fmt.Println("note: (none)")
+		}
+		fmt.Printf("logs: prev %s, curr %s\n", markOrNone(diary.Logs.Prev), markOrNone(diary.Logs.Curr))
+	default:
+		// Diary notes and legacy objects are printed as is
+		content, err := shelf.NewObjectRecord(group, hash).RecordRead()
+		if err != nil {
+			return fmt.Errorf("print object: %w", err)
+		}
+		fmt.Println(content)
+	}
+
+	return nil
+}
+
+// printResource prints identifier, alias and properties of a resource,
+// json content values are indented.
+func printResource(objType string, resource *shared.MinerResource) {
+	fmt.Printf("type:       %s\n", objType)
+	fmt.Printf("identifier: %s\n", resource.Identifier)
+	if resource.Alias != "" {
+		fmt.Printf("alias:      %s\n", resource.Alias)
+	}
+	fmt.Println("properties:")
+	for _, prop := range resource.Properties {
+		unique := ""
+		if prop.Label.Unique {
+			unique = ", unique"
+		}
+		fmt.Printf("  %s / %s (%s%s)\n", prop.Type, prop.Label.Name, prop.Content.Format, unique)
+
+		for _, line := range strings.Split(contentValue(&prop.Content), "\n") {
+			fmt.Printf("    %s\n", line)
+		}
+	}
+}
+
+// contentValue returns the property content value, json content is indented.
+func contentValue(content *shared.MinerPropertyContent) string {
+	value := content.Value
+	if content.Format == shared.FormatJson {
+		var indented bytes.Buffer
+		if err := json.Indent(&indented, []byte(value), "", "  "); err == nil {
+			value = indented.String()
+		}
+	}
+	return value
+}
+
+// objectLink returns the hash as an OSC 8 terminal hyperlink to the object file
+// if stdout is a terminal and the file is readable as is, otherwise the hash itself.
+func objectLink(group, hash string) string {
+	if !isatty.IsTerminal(os.Stdout.Fd()) {
+		return hash
+	}
+
+	path, ok := shelf.NewObjectRecord(group, hash).PlainFile()
+	if !ok {
+		return hash
+	}
+	fileUrl := url.URL{Scheme: "file", Path: filepath.ToSlash(path)}
+	return fmt.Sprintf("\x1b]8;;%s\x1b\\%s\x1b]8;;\x1b\\", fileUrl.String(), hash)
+}
 
-	// Cobra supports local flags which will only run when this command
-	// is called directly, e.g.:
-	// catFileCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+func markOrNone(hash string) string {
+	if hash == "" {
+		return "(none)"
+	}
+	return hash
 }
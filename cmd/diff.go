@@ -0,0 +1,154 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/liuminhaw/mist-miner/cmd/mmerr"
+	"github.com/liuminhaw/mist-miner/shared"
+	"github.com/liuminhaw/mist-miner/shelf"
+	"github.com/spf13/cobra"
+)
+
+// diffCmd represents the diff command
+var diffCmd = &cobra.Command{
+	Use:   "diff <group> [markA] [markB]",
+	Short: "Show resource changes between two label marks",
+	Long: `Compare resources of two label marks in a group, reporting identifiers that were
+added, removed or modified in each plugin.
+
+With no mark given, HEAD is compared against its parent. With only markA given,
+markA is compared against HEAD. Marks can be given as a hash or unique hash prefix,
+a reference or tag name, optionally followed by ~N for the Nth ancestor, ex. HEAD~2.`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 1 || len(args) > 3 {
+			return mmerr.NewArgsError(
+				mmerr.DiffCmdType,
+				fmt.Sprintf("accepts between 1 and 3 args, received %d", len(args)),
+			)
+		}
+		group := args[0]
+
+		var from, to string
+		var err error
+		switch len(args) {
+		case 1:
+			to, err = shelf.ResolveMark(group, shelf.SHELF_MARK_FILE)
+			if err != nil {
+				return fmt.Errorf("diff sub-command failed: %w", err)
+			}
+			from, err = shelf.ParentMark(group, to)
+			if errors.Is(err, shelf.ErrNoParentMark) {
+				from = "nil"
+			} else if err != nil {
+				return fmt.Errorf("diff sub-command failed: %w", err)
+			}
+		case 2:
+			if from, err = shelf.ResolveMark(group, args[1]); err != nil {
+				return fmt.Errorf("diff sub-command failed: %w", err)
+			}
+			if to, err = shelf.ResolveMark(group, shelf.SHELF_MARK_FILE); err != nil {
+				return fmt.Errorf("diff sub-command failed: %w", err)
+			}
+		case 3:
+			if from, err = shelf.ResolveMark(group, args[1]); err != nil {
+				return fmt.Errorf("diff sub-command failed: %w", err)
+			}
+			if to, err = shelf.ResolveMark(group, args[2]); err != nil {
+				return fmt.Errorf("diff sub-command failed: %w", err)
+			}
+		}
+
+		diff, err := shelf.DiffMarks(group, from, to)
+		if err != nil {
+			return fmt.Errorf("diff sub-command failed: %w", err)
+		}
+		if err := printMarkDiff(diff, diffShowUnchanged, diffShowProperties); err != nil {
+			return fmt.Errorf("diff sub-command failed: %w", err)
+		}
+
+		return nil
+	},
+}
+
+var (
+	diffShowUnchanged  bool
+	diffShowProperties bool
+)
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+
+	diffCmd.Flags().BoolVarP(&diffShowUnchanged, "all", "a", false, "also list plugins without changes")
+	diffCmd.Flags().BoolVarP(&diffShowProperties, "properties", "p", false, "show property changes of modified resources")
+}
+
+// printMarkDiff prints the resource level difference of two label marks,
+// property level changes of modified resources are printed if showProperties is set.
+func printMarkDiff(diff *shelf.MarkDiff, showUnchanged, showProperties bool) error {
+	fmt.Printf("Group: %s\n", diff.Group)
+	fmt.Printf("From: %s\n", diff.From)
+	fmt.Printf("To:   %s\n", diff.To)
+
+	var added, removed, modified int
+	for _, plugin := range diff.Plugins {
+		if !plugin.Changed() && !showUnchanged {
+			continue
+		}
+
+		fmt.Printf("\nPlugin: %s (%s)\n", plugin.Plugin, plugin.Status)
+		for _, res := range plugin.Resources {
+			name := res.Identifier
+			if res.Alias != "" {
+				name = fmt.Sprintf("%s (%s)", res.Identifier, res.Alias)
+			}
+
+			switch res.Status {
+			case shelf.DIFF_STATUS_ADDED:
+				added++
+				fmt.Printf("  + %s\n", name)
+			case shelf.DIFF_STATUS_REMOVED:
+				removed++
+				fmt.Printf("  - %s\n", name)
+			case shelf.DIFF_STATUS_MODIFIED:
+				modified++
+				fmt.Printf("  ~ %s %s -> %s\n", name, res.FromHash[:12], res.ToHash[:12])
+				if showProperties {
+					propDiffs, err := shelf.DiffResources(diff.Group, res.FromHash, res.ToHash)
+					if err != nil {
+						return fmt.Errorf("print mark diff: %w", err)
+					}
+					printPropertyDiffs(propDiffs, "      ")
+				}
+			}
+		}
+	}
+
+	fmt.Printf("\n%d added, %d removed, %d modified\n", added, removed, modified)
+	return nil
+}
+
+// printPropertyDiffs prints property level changes with each line prefixed by indent.
+func printPropertyDiffs(diffs []shared.PropertyDiff, indent string) {
+	for _, diff := range diffs {
+		switch diff.Status {
+		case shared.DiffAdded:
+			fmt.Printf("%s+ %s %s: %s\n", indent, diff.Type, diff.Label, diff.To.Content.Value)
+		case shared.DiffRemoved:
+			fmt.Printf("%s- %s %s: %s\n", indent, diff.Type, diff.Label, diff.From.Content.Value)
+		case shared.DiffModified:
+			fmt.Printf("%s~ %s %s\n", indent, diff.Type, diff.Label)
+			if diff.JsonChanges == nil {
+				fmt.Printf("%s    - %s\n", indent, diff.From.Content.Value)
+				fmt.Printf("%s    + %s\n", indent, diff.To.Content.Value)
+			}
+			for _, change := range diff.JsonChanges {
+				fmt.Printf("%s    %s\n", indent, change)
+			}
+		}
+	}
+}
@@ -0,0 +1,58 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/liuminhaw/mist-miner/cmd/mmerr"
+	"github.com/liuminhaw/mist-miner/shelf"
+	"github.com/spf13/cobra"
+)
+
+// driftCmd represents the drift command
+var driftCmd = &cobra.Command{
+	Use:   "drift <group>",
+	Short: "Report resources and properties of HEAD differing from the approved baseline",
+	Long: `Compare HEAD against the baseline set by "baseline set", reporting added, removed
+and modified resources with their property changes, grouped by plugin.
+
+Exit code is 0 when HEAD matches the baseline, 2 when drift is found and 1 on errors.`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return mmerr.NewArgsError(
+				mmerr.DriftCmdType,
+				fmt.Sprintf("accepts 1 args, received %d", len(args)),
+			)
+		}
+		group := args[0]
+
+		baseline, err := shelf.Baseline(group)
+		if err != nil {
+			return fmt.Errorf("drift sub-command failed: %w", err)
+		}
+		head, err := shelf.ResolveMark(group, shelf.SHELF_MARK_FILE)
+		if err != nil {
+			return fmt.Errorf("drift sub-command failed: %w", err)
+		}
+
+		diff, err := shelf.DiffMarks(group, baseline, head)
+		if err != nil {
+			return fmt.Errorf("drift sub-command failed: %w", err)
+		}
+		if err := printMarkDiff(diff, false, true); err != nil {
+			return fmt.Errorf("drift sub-command failed: %w", err)
+		}
+
+		if diff.Changed() {
+			return mmerr.NewExitError(mmerr.ExitCodeDrift, "drift from baseline found")
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(driftCmd)
+}
@@ -0,0 +1,55 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/liuminhaw/mist-miner/cmd/mmerr"
+	"github.com/liuminhaw/mist-miner/shelf"
+	"github.com/spf13/cobra"
+)
+
+// fsckCmd represents the fsck command
+var fsckCmd = &cobra.Command{
+	Use:   "fsck <group>",
+	Short: "Verify integrity of objects and history records of a group",
+	Long: `Decompress and re-hash every object of a group, then walk from each reference to
+find dangling references, missing parent marks and history logger / pointer files
+disagreeing with the label mark chain.`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return mmerr.NewArgsError(
+				mmerr.FsckCmdType,
+				fmt.Sprintf("accepts 1 args, received %d", len(args)),
+			)
+		}
+		group := args[0]
+
+		report, err := shelf.Fsck(group)
+		if err != nil {
+			return fmt.Errorf("fsck sub-command failed: %w", err)
+		}
+
+		for _, issue := range report.Issues {
+			fmt.Println(issue)
+		}
+		fmt.Printf(
+			"%d objects checked, %d reachable, %d unreachable\n",
+			report.Objects,
+			report.Reachable,
+			report.Unreachable,
+		)
+
+		if len(report.Issues) > 0 {
+			return fmt.Errorf("fsck sub-command failed: %d issues found", len(report.Issues))
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(fsckCmd)
+}
@@ -0,0 +1,98 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/liuminhaw/mist-miner/cmd/mmerr"
+	"github.com/liuminhaw/mist-miner/shelf"
+	"github.com/spf13/cobra"
+)
+
+// gcCmd represents the gc command
+var gcCmd = &cobra.Command{
+	Use:   "gc <group>",
+	Short: "Remove objects unreachable from any reference of a group",
+	Long: `Remove objects which cannot be reached from any reference (ex. HEAD) of a group,
+such as superseded stuff outlines or unused diary records.
+
+Unreachable objects stored in packs are only removed with --repack, which writes all
+reachable objects into one new pack and removes the other packs. With --delta older
+versions of resources and properties are stored as delta of their next version.`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return mmerr.NewArgsError(
+				mmerr.GcCmdType,
+				fmt.Sprintf("accepts 1 args, received %d", len(args)),
+			)
+		}
+		if gcDelta && !gcRepack {
+			return mmerr.NewArgsError(mmerr.GcCmdType, "--delta can only be used with --repack")
+		}
+		group := args[0]
+
+		result, err := shelf.CollectGarbage(group, gcDryRun)
+		if err != nil {
+			return fmt.Errorf("gc sub-command failed: %w", err)
+		}
+
+		if gcDryRun {
+			for _, hash := range result.Removed {
+				fmt.Printf("Would remove: %s\n", hash)
+			}
+			fmt.Printf(
+				"%d reachable objects, %d objects would be removed, %d bytes would be freed\n",
+				result.Reachable,
+				len(result.Removed),
+				result.FreedBytes,
+			)
+		} else {
+			fmt.Printf(
+				"%d reachable objects, %d objects removed, %d bytes freed\n",
+				result.Reachable,
+				len(result.Removed),
+				result.FreedBytes,
+			)
+		}
+
+		if !gcRepack {
+			if len(result.Packed) > 0 {
+				fmt.Printf("%d unreachable objects in packs, run gc --repack to remove\n", len(result.Packed))
+			}
+			return nil
+		}
+
+		packResult, err := shelf.RepackObjects(group, gcDelta, gcDryRun)
+		if err != nil {
+			return fmt.Errorf("gc sub-command failed: %w", err)
+		}
+		if gcDryRun {
+			fmt.Printf(
+				"%d objects would be repacked, %d unreachable packed objects would be removed\n",
+				packResult.Objects,
+				len(result.Packed),
+			)
+		} else {
+			printPackResult(packResult)
+		}
+
+		return nil
+	},
+}
+
+var (
+	gcDryRun bool
+	gcRepack bool
+	gcDelta  bool
+)
+
+func init() {
+	rootCmd.AddCommand(gcCmd)
+
+	gcCmd.Flags().BoolVarP(&gcDryRun, "dry-run", "n", false, "only report objects to be removed")
+	gcCmd.Flags().BoolVar(&gcRepack, "repack", false, "write reachable objects into one pack and remove other packs")
+	gcCmd.Flags().BoolVar(&gcDelta, "delta", false, "store older versions of resources as delta when repacking")
+}
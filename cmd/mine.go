@@ -4,17 +4,26 @@ Copyright © 2024 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"log"
 	"os"
-	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 
 	"github.com/hashicorp/go-hclog"
-	"github.com/hashicorp/go-plugin"
 	"github.com/liuminhaw/mist-miner/cmd/mmerr"
 	"github.com/liuminhaw/mist-miner/locks"
+	"github.com/liuminhaw/mist-miner/paths"
 	"github.com/liuminhaw/mist-miner/shared"
 	"github.com/liuminhaw/mist-miner/shelf"
 	"github.com/spf13/cobra"
@@ -34,6 +43,10 @@ var mineCmd = &cobra.Command{
 			)
 		}
 
+		// Interrupt cancels running plugins, which are killed before returning
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
 		// Set logger
 		// Don't want to see the plugin logs.
 		log.SetOutput(io.Discard)
@@ -44,11 +57,21 @@ var mineCmd = &cobra.Command{
 		})
 
 		// Read the config file
+		configFile, err := paths.ConfigFile()
+		if err != nil {
+			return fmt.Errorf("failed to mine: %w", err)
+		}
 		hclConf, err := shared.ReadConfig(configFile)
 		if err != nil {
 			return fmt.Errorf("failed to mine: %w", err)
 		}
 
+		// Validate plugs against plugin descriptions before anything is written
+		versions, err := describePlugs(ctx, hclConf.Plugs, logger)
+		if err != nil {
+			return fmt.Errorf("failed to mine: %w", err)
+		}
+
 		// Create objects lock
 		objFileLock, err := locks.NewLock("", locks.OBJECTS_LOCKFILE)
 		if err != nil {
@@ -60,29 +83,72 @@ var mineCmd = &cobra.Command{
 			}
 			return fmt.Errorf("failed to mine: %w", err)
 		}
+		defer objFileLock.Unlock()
 
-		// Run plugins
-		gLabels := make(groupLabels)
+		// Finish the journal of an interrupted run before starting a new one
+		recovery, err := shelf.RecoverMineJournal()
+		if err != nil {
+			return fmt.Errorf("failed to mine: %w", err)
+		}
+		if recovery.Action != "" {
+			fmt.Printf("Interrupted mine %s, groups: %v\n", recovery.Action, recovery.Groups)
+		}
+
+		groups := []string{}
 		for _, plug := range hclConf.Plugs {
-			fmt.Printf("Plug Name: %s\n", plug.Name)
-			fmt.Printf("Plug Group: %s\n", plug.Group)
+			if !slices.Contains(groups, plug.Group) {
+				groups = append(groups, plug.Group)
+			}
+		}
+		journal, err := shelf.BeginMineJournal(groups)
+		if err != nil {
+			return fmt.Errorf("failed to mine: %w", err)
+		}
 
-			err := run(
-				pluginModule{name: plug.Name, group: plug.Group, config: plug.GenMinerConfig()},
-				&gLabels,
-				logger,
+		// Run plugins
+		parallel := mineParallel
+		if !cmd.Flags().Changed("parallel") && hclConf.Mine != nil && hclConf.Mine.Parallel > 0 {
+			parallel = hclConf.Mine.Parallel
+		}
+		modules := []pluginModule{}
+		for _, plug := range hclConf.Plugs {
+			// Validated by ReadConfig
+			timeout, _ := plug.MineTimeout()
+			checksum, _ := plug.Checksum()
+			modules = append(
+				modules,
+				pluginModule{
+					name:     plug.Name,
+					group:    plug.Group,
+					version:  versions[plug.Name],
+					config:   plug.GenMinerConfig(),
+					checksum: checksum,
+					timeout:  timeout,
+				},
 			)
-			if err != nil {
-				return fmt.Errorf("failed to mine: %w", err)
-			}
+		}
+		// No label mark is written if a plugin fails, the journal is closed as nothing to recover
+		mapHashes, err := runModules(ctx, modules, parallel, logger)
+		if err != nil {
+			journal.Close()
+			return fmt.Errorf("failed to mine: %w", err)
+		}
+		gLabels, err := newGroupLabels(modules, mapHashes)
+		if err != nil {
+			journal.Close()
+			return fmt.Errorf("failed to mine: %w", err)
 		}
 
+		// Write label marks of every group before moving any HEAD,
+		// HEADs are moved once the journal is committed
 		pointers := []shelf.HistoryPointer{}
+		marks := make(map[string]string)
 		for group, label := range gLabels {
 			fmt.Printf("Group: %s\n", group)
-			if err := label.Update(); err != nil {
+			if err := label.Write(); err != nil {
 				return fmt.Errorf("failed to mine: %w", err)
 			}
+			marks[group] = label.Hash
 
 			fmt.Printf("Hash: %s\n", label.Hash)
 			fmt.Printf("Parent: %s\n", label.Parent)
@@ -96,11 +162,27 @@ var mineCmd = &cobra.Command{
 				shelf.NewHistoryPointer(group, label.Parent, label.Hash),
 			)
 		}
+		if err := journal.Commit(marks); err != nil {
+			return fmt.Errorf("failed to mine: %w", err)
+		}
+		if err := journal.Apply(); err != nil {
+			return fmt.Errorf("failed to mine: %w", err)
+		}
 		if err := objFileLock.Unlock(); err != nil {
 			return fmt.Errorf("failed to mine: %w", err)
 		}
 
+		// Recovered groups are fully regenerated, which covers label marks of this run
+		for _, group := range recovery.Groups {
+			if err := reloadHistory(group); err != nil {
+				return fmt.Errorf("failed to mine: %w", err)
+			}
+		}
 		for _, pointer := range pointers {
+			if slices.Contains(recovery.Groups, pointer.Group) {
+				continue
+			}
+
 			// Update history logs record
 			if err := shelf.GenerateHistoryRecords(pointer.Group, shelf.SHELF_HISTORY_LOGS_PER_PAGE); err != nil {
 				return fmt.Errorf("failed to mine: %w", err)
@@ -111,8 +193,22 @@ var mineCmd = &cobra.Command{
 			if err := pointer.WriteNextMap(); err != nil {
 				return fmt.Errorf("failed to mine: %w", err)
 			}
+
+			// Update resource timeline index
+			if err := shelf.UpdateTimeline(pointer.Group, pointer.CurrentHash); err != nil {
+				return fmt.Errorf("failed to mine: %w", err)
+			}
+
+			// Update search index, label mark and HEAD are written already so a failed
+			// update is caught up by the next mine or log reload
+			if err := shelf.UpdateSearchIndex(pointer.Group, pointer.CurrentHash); err != nil {
+				fmt.Printf("Search index of group %s is not updated, caught up on next mine: %s\n", pointer.Group, err)
+			}
 		}
 
+		if err := journal.Close(); err != nil {
+			return fmt.Errorf("failed to mine: %w", err)
+		}
 		return nil
 	},
 }
@@ -120,12 +216,6 @@ var mineCmd = &cobra.Command{
 func init() {
 	rootCmd.AddCommand(mineCmd)
 
-	defaultConf, err := defaultConfigAbs()
-	if err != nil {
-		fmt.Printf("Error getting default config file: %s\n", err)
-		os.Exit(1)
-	}
-
 	// Here you will define your flags and configuration settings.
 
 	// Cobra supports Persistent Flags which will work for this command
@@ -135,138 +225,358 @@ func init() {
 	// Cobra supports local flags which will only run when this command
 	// is called directly, e.g.:
 	// mineCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
-	mineCmd.Flags().StringVarP(&configFile, "config", "c", defaultConf, "hcl.conf")
+	mineCmd.Flags().IntVarP(
+		&mineParallel,
+		"parallel",
+		"p",
+		1,
+		"number of plugins to run concurrently, overrides parallel of the mine block in config",
+	)
+}
+
+var mineParallel int
+
+// reloadHistory regenerates history records, pointers, timeline and search index of the group
+func reloadHistory(group string) error {
+	if err := shelf.GenerateHistoryRecords(group, shelf.SHELF_HISTORY_LOGS_PER_PAGE); err != nil {
+		return err
+	}
+	if err := shelf.GenerateHistoryPointers(group); err != nil {
+		return err
+	}
+	if err := shelf.GenerateTimeline(group); err != nil {
+		return err
+	}
+	if err := shelf.GenerateSearchIndex(group); err != nil {
+		return err
+	}
+	return nil
 }
 
 type pluginModule struct {
-	name   string
-	group  string
-	config shared.MinerConfig
+	name  string
+	group string
+	// Reported by Describe, empty if not supported by the plugin
+	version string
+	config  shared.MinerConfig
+	// Pinned sha256 of the plugin binary, nil if not pinned
+	checksum []byte
+	// Zero if the plugin has no time limit
+	timeout time.Duration
 }
 
 type groupLabels map[string]shelf.LabelMark
 
-func run(pMod pluginModule, gLabel *groupLabels, logger hclog.Logger) error {
-	pluginsBinDir, err := pluginsBinDirAbs()
+// describePlugs validates every plug against the description of its plugin, and returns
+// versions of plugins by name. Plugins not implementing Describe are not validated.
+func describePlugs(ctx context.Context, plugs []shared.Plug, logger hclog.Logger) (map[string]string, error) {
+	pluginsBinDir, err := paths.PluginsDir()
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	// Plugs of the same plugin may pin different checksums, every pin is checked
+	// before any binary is started
+	if err := checkPins(pluginsBinDir, plugs); err != nil {
+		return nil, err
+	}
+
+	descriptions := make(map[string]*shared.PluginDescription)
+	versions := make(map[string]string)
+	errs := []error{}
+	for _, plug := range plugs {
+		description, ok := descriptions[plug.Name]
+		if !ok {
+			// Validated by ReadConfig
+			checksum, _ := plug.Checksum()
+			d, err := shared.DescribePlugin(ctx, filepath.Join(pluginsBinDir, plug.Name), checksum, logger)
+			if errors.Is(err, shared.ErrDescribeUnsupported) {
+				fmt.Printf("Plugin %s does not support describe, config is not validated\n", plug.Name)
+			} else if err != nil {
+				return nil, fmt.Errorf("plug %s %s: %w", plug.Name, plug.Group, err)
+			} else {
+				description = &d
+				versions[plug.Name] = d.Version
+			}
+			descriptions[plug.Name] = description
+		}
+
+		if description != nil {
+			if err := description.Validate(plug); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, fmt.Errorf("invalid plug config:\n%w", err)
+	}
+	return versions, nil
+}
+
+// checkPins hashes the plugin binary of every plug pinning a sha256 without starting it,
+// and returns shared.ErrChecksumMismatch for each plug whose pin does not match.
+func checkPins(pluginsBinDir string, plugs []shared.Plug) error {
+	sums := make(map[string]string)
+	errs := []error{}
+	for _, plug := range plugs {
+		if plug.Sha256 == "" {
+			continue
+		}
+		sum, ok := sums[plug.Name]
+		if !ok {
+			var err error
+			sum, err = shared.FileSha256(filepath.Join(pluginsBinDir, plug.Name))
+			if err != nil {
+				return fmt.Errorf("plug %s %s: %w", plug.Name, plug.Group, err)
+			}
+			sums[plug.Name] = sum
+		}
+		if !strings.EqualFold(sum, plug.Sha256) {
+			errs = append(errs, fmt.Errorf("plug %s %s: %w", plug.Name, plug.Group, shared.ErrChecksumMismatch))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// runModules runs the plugin modules with at most parallel of them at once, and returns
+// the identifier hash maps hash written by each module in the order of modules, empty if
+// nothing is mined by the module. Output of a module is buffered and printed as a whole
+// once it finishes. Modules not started yet are skipped after a module fails or ctx is
+// cancelled, the error of the first failed module in order is returned.
+func runModules(
+	ctx context.Context,
+	modules []pluginModule,
+	parallel int,
+	logger hclog.Logger,
+) ([]string, error) {
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	hashes := make([]string, len(modules))
+	errs := make([]error, len(modules))
+	var failed atomic.Bool
+	var outMu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, parallel)
+	for i, pMod := range modules {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if failed.Load() || ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(i int, pMod pluginModule) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			var out bytes.Buffer
+			fmt.Fprintf(&out, "Plug Name: %s\n", pMod.name)
+			fmt.Fprintf(&out, "Plug Group: %s\n", pMod.group)
+			hashes[i], errs[i] = run(ctx, pMod, &out, logger)
+			if errs[i] != nil {
+				failed.Store(true)
+				errs[i] = fmt.Errorf("plug %s %s: %w", pMod.name, pMod.group, errs[i])
+			}
+
+			outMu.Lock()
+			defer outMu.Unlock()
+			os.Stdout.Write(out.Bytes())
+		}(i, pMod)
+	}
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("mine interrupted: %w", ctx.Err())
+	}
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return hashes, nil
+}
+
+// newGroupLabels creates a label mark for every group with the identifier hash maps
+// mined by its modules, regardless of which module finished first.
+func newGroupLabels(modules []pluginModule, mapHashes []string) (groupLabels, error) {
+	gLabels := make(groupLabels)
+	for i, pMod := range modules {
+		if mapHashes[i] == "" {
+			continue
+		}
+
+		// Check if label mark with plugId (group) exists
+		// If not exists, create a new label mark and update
+		// If exists, update the existence label mark
+		labelMark, ok := gLabels[pMod.group]
+		if !ok {
+			lm, err := shelf.NewMark(pMod.group, "mine")
+			if err != nil {
+				return nil, err
+			}
+			labelMark = *lm
+		}
+		labelMark.AddMapping(pMod.name, mapHashes[i], pMod.version)
+		gLabels[pMod.group] = labelMark
+	}
+	return gLabels, nil
+}
+
+// run runs the plugin module and writes the mined resources, output is written to out.
+// The hash of written identifier hash maps is returned, empty if no resource is mined.
+// The plugin process is killed when ctx is cancelled or the module times out.
+func run(ctx context.Context, pMod pluginModule, out io.Writer, logger hclog.Logger) (string, error) {
+	pluginsBinDir, err := paths.PluginsDir()
+	if err != nil {
+		return "", err
 	}
 	binaryPath := fmt.Sprintf("%s/%s", pluginsBinDir, pMod.name)
-	fmt.Printf("Binary Path: %s\n", binaryPath)
-
-	client := plugin.NewClient(&plugin.ClientConfig{
-		HandshakeConfig:  shared.Handshake,
-		Plugins:          shared.PluginMap,
-		Cmd:              exec.Command(binaryPath),
-		Logger:           logger,
-		AllowedProtocols: []plugin.Protocol{plugin.ProtocolGRPC},
-	})
+	fmt.Fprintf(out, "Binary Path: %s\n", binaryPath)
+
+	client := shared.NewPluginClient(binaryPath, pMod.checksum, logger)
 	defer client.Kill()
 
 	// Connect via RPC
-	rpcClient, err := client.Client()
+	rpcClient, err := shared.StartPlugin(client)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	// Request the plugin
 	raw, err := rpcClient.Dispense("miner_grpc")
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	// We should have a Greeter now
 	miner := raw.(shared.Miner)
+	if client.NegotiatedVersion() == shared.PROTOCOL_VERSION_LEGACY {
+		fmt.Fprintf(
+			out,
+			"Plugin %s speaks legacy protocol %d, timeout and interrupt kill it without notice\n",
+			pMod.name,
+			shared.PROTOCOL_VERSION_LEGACY,
+		)
+	}
 
-	resources, err := miner.Mine(pMod.config)
-	if err != nil {
-		return err
+	mineCtx := ctx
+	if pMod.timeout > 0 {
+		var cancel context.CancelFunc
+		mineCtx, cancel = context.WithTimeout(ctx, pMod.timeout)
+		defer cancel()
 	}
 
 	labelMap := shelf.IdentifierHashMaps{
 		Group: pMod.group,
 		Maps:  []shelf.IdentifierHashMap{},
 	}
-	for _, resource := range resources {
-		resource.Sort()
-
-		stuffResource, err := shelf.NewStuff(pMod.group, &resource)
+	write := func(resource shared.MinerResource) error {
+		idMap, err := writeResource(pMod, resource, out)
 		if err != nil {
 			return err
 		}
+		labelMap.Maps = append(labelMap.Maps, idMap)
+		return nil
+	}
 
-		var se *shelf.StuffAlreadyExistsError
-		if msg, err := stuffResource.Write(); errors.As(err, &se) {
-			fmt.Print(err.Error())
-		} else if err != nil {
-			return err
-		} else {
-			fmt.Print(msg)
-		}
-
-		diaryHash, err := shelf.HasDiary(pMod.group, pMod.name, resource.Identifier)
-		if err != nil {
-			if errors.Is(err, shelf.ErrDiaryNotFound) {
-				tempDiary := shared.MinerDiary{}
-				diaryResource, err := shelf.NewStuff(pMod.group, &tempDiary)
-				if err != nil {
-					return err
-				}
-				if msg, err := diaryResource.Write(); errors.As(err, &se) {
-					fmt.Print(err.Error())
-				} else if err != nil {
-					return err
-				} else {
-					fmt.Print(msg)
-				}
-				diaryHash = diaryResource.Hash
-			} else {
-				return err
-			}
-		}
-
-		outline := shelf.NewStuffOutline(pMod.group, stuffResource.Hash, diaryHash)
-		if err := outline.Write(); err != nil {
-			return err
+	// Resources are written as they arrive from plugins sending them one at a time
+	err = shared.ErrStreamUnsupported
+	if streamer, ok := raw.(shared.StreamMiner); ok {
+		err = streamer.MineStream(mineCtx, pMod.config, write)
+	}
+	if errors.Is(err, shared.ErrStreamUnsupported) {
+		var resources shared.MinerResources
+		resources, err = miner.Mine(mineCtx, pMod.config)
+		for i := 0; err == nil && i < len(resources); i++ {
+			err = write(resources[i])
 		}
-
-		labelMap.Maps = append(labelMap.Maps, shelf.IdentifierHashMap{
-			Identifier: resource.Identifier,
-			Alias:      resource.Alias,
-			Hash:       outline.Hash,
-		})
+	}
+	if mineCtx.Err() != nil {
+		// Report cancellation instead of the gRPC status
+		return "", fmt.Errorf("mine %s: %w", pMod.name, mineCtx.Err())
+	} else if err != nil {
+		return "", err
 	}
 
 	// Prevent from writing empty label map
 	if len(labelMap.Maps) == 0 {
-		fmt.Printf("No resources found in group %s with plugin %s\n", pMod.group, pMod.name)
-		return nil
+		fmt.Fprintf(out, "No resources found in group %s with plugin %s\n", pMod.group, pMod.name)
+		return "", nil
 	}
 
 	// TODO: Sort should be done within write to avoid forgetting
 	labelMap.Sort()
-	if err := labelMap.Write(); err != nil {
-		return err
+	msg, err := labelMap.Write()
+	if err != nil {
+		return "", err
 	}
+	fmt.Fprint(out, msg)
 
-	// Check if label mark with plugId (group) exists
-	// If not exists, create a new label mark and update
-	// If exists, update the existence label mark
-	var labelMark *shelf.LabelMark
-	if _, ok := (*gLabel)[pMod.group]; !ok {
-		(*gLabel)[pMod.group] = shelf.LabelMark{}
-		labelMark, err = shelf.NewMark(pMod.group, "mine")
-		if err != nil {
-			return err
-		}
+	return labelMap.Hash, nil
+}
+
+// writeResource writes the resource with its diary to the shelf and returns the mapping
+// of its identifier to the written stuff outline, output is written to out.
+func writeResource(
+	pMod pluginModule,
+	resource shared.MinerResource,
+	out io.Writer,
+) (shelf.IdentifierHashMap, error) {
+	resource.Sort()
+
+	stuffResource, err := shelf.NewStuff(pMod.group, &resource)
+	if err != nil {
+		return shelf.IdentifierHashMap{}, err
+	}
+
+	var se *shelf.StuffAlreadyExistsError
+	if msg, err := stuffResource.Write(); errors.As(err, &se) {
+		fmt.Fprint(out, err.Error())
+	} else if err != nil {
+		return shelf.IdentifierHashMap{}, err
 	} else {
-		lm := (*gLabel)[pMod.group]
-		labelMark = &lm
+		fmt.Fprint(out, msg)
+	}
+
+	diaryHash, err := shelf.HasDiary(pMod.group, pMod.name, resource.Identifier)
+	if err != nil {
+		if errors.Is(err, shelf.ErrDiaryNotFound) {
+			tempDiary := shared.MinerDiary{}
+			diaryResource, err := shelf.NewStuff(pMod.group, &tempDiary)
+			if err != nil {
+				return shelf.IdentifierHashMap{}, err
+			}
+			if msg, err := diaryResource.Write(); errors.As(err, &se) {
+				fmt.Fprint(out, err.Error())
+			} else if err != nil {
+				return shelf.IdentifierHashMap{}, err
+			} else {
+				fmt.Fprint(out, msg)
+			}
+			diaryHash = diaryResource.Hash
+		} else {
+			return shelf.IdentifierHashMap{}, err
+		}
 	}
 
-	// Update labelMark to the groupLabels
-	labelMark.AddMapping(pMod.name, labelMap.Hash)
-	(*gLabel)[pMod.group] = *labelMark
+	outline, err := shelf.NewStuffOutline(pMod.group, stuffResource.Hash, diaryHash)
+	if err != nil {
+		return shelf.IdentifierHashMap{}, err
+	}
+	msg, err := outline.Write()
+	if err != nil {
+		return shelf.IdentifierHashMap{}, err
+	}
+	fmt.Fprint(out, msg)
 
-	return nil
+	return shelf.IdentifierHashMap{
+		Identifier: resource.Identifier,
+		Alias:      resource.Alias,
+		Hash:       outline.Hash,
+	}, nil
 }
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/liuminhaw/mist-miner/shared"
+)
+
+func TestCheckPins(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "fake"), []byte("plugin"), 0o755); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	sum := sha256.Sum256([]byte("plugin"))
+	pin := hex.EncodeToString(sum[:])
+	wrong := strings.Repeat("0", len(pin))
+
+	ok := []shared.Plug{
+		{Name: "fake", Group: "g1"},
+		{Name: "fake", Group: "g2", Sha256: strings.ToUpper(pin)},
+		{Name: "missing", Group: "g3"},
+	}
+	if err := checkPins(dir, ok); err != nil {
+		t.Errorf("checkPins = %v, want every pin matched", err)
+	}
+
+	// A later plug of the same plugin pinning another checksum is caught
+	mismatched := []shared.Plug{
+		{Name: "fake", Group: "g1", Sha256: pin},
+		{Name: "fake", Group: "g2", Sha256: wrong},
+	}
+	err := checkPins(dir, mismatched)
+	if !errors.Is(err, shared.ErrChecksumMismatch) || !strings.Contains(err.Error(), "plug fake g2") {
+		t.Errorf("checkPins = %v, want %v of plug fake g2", err, shared.ErrChecksumMismatch)
+	}
+	if strings.Contains(err.Error(), "plug fake g1") {
+		t.Errorf("checkPins = %v, want plug fake g1 matched", err)
+	}
+
+	if err := checkPins(dir, []shared.Plug{{Name: "missing", Group: "g3", Sha256: pin}}); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("checkPins of missing binary = %v, want %v", err, os.ErrNotExist)
+	}
+}
@@ -0,0 +1,16 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package mmbaseline
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// BaselineCmd represents the baseline command
+var BaselineCmd = &cobra.Command{
+	Use:   "baseline",
+	Short: "Manage the approved baseline label mark of a group",
+	Long: `The baseline is the label mark approved as the expected state of a group,
+drift compares HEAD against it.`,
+}
@@ -0,0 +1,40 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package mmbaseline
+
+import (
+	"fmt"
+
+	"github.com/liuminhaw/mist-miner/cmd/mmerr"
+	"github.com/liuminhaw/mist-miner/shelf"
+	"github.com/spf13/cobra"
+)
+
+// ClearCmd represents the baseline clear command
+var ClearCmd = &cobra.Command{
+	Use:          "clear <group>",
+	Short:        "Remove the approved baseline of a group",
+	Long:         ``,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return mmerr.NewArgsError(
+				mmerr.BaselineClearCmdType,
+				fmt.Sprintf("accepts 1 args, received %d", len(args)),
+			)
+		}
+		group := args[0]
+
+		if err := shelf.ClearBaseline(group); err != nil {
+			return fmt.Errorf("baseline clear sub-command failed: %w", err)
+		}
+		fmt.Printf("Baseline of %s removed\n", group)
+
+		return nil
+	},
+}
+
+func init() {
+	BaselineCmd.AddCommand(ClearCmd)
+}
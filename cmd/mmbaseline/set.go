@@ -0,0 +1,48 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package mmbaseline
+
+import (
+	"fmt"
+
+	"github.com/liuminhaw/mist-miner/cmd/mmerr"
+	"github.com/liuminhaw/mist-miner/shelf"
+	"github.com/spf13/cobra"
+)
+
+// SetCmd represents the baseline set command
+var SetCmd = &cobra.Command{
+	Use:          "set <group> [mark]",
+	Short:        "Set the approved baseline of a group, default to HEAD",
+	Long:         ``,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 1 || len(args) > 2 {
+			return mmerr.NewArgsError(
+				mmerr.BaselineSetCmdType,
+				fmt.Sprintf("accepts between 1 and 2 args, received %d", len(args)),
+			)
+		}
+		group := args[0]
+		rev := shelf.SHELF_MARK_FILE
+		if len(args) == 2 {
+			rev = args[1]
+		}
+
+		mark, err := shelf.ResolveMark(group, rev)
+		if err != nil {
+			return fmt.Errorf("baseline set sub-command failed: %w", err)
+		}
+		if err := shelf.SetBaseline(group, mark); err != nil {
+			return fmt.Errorf("baseline set sub-command failed: %w", err)
+		}
+		fmt.Printf("Baseline of %s set to %s\n", group, mark)
+
+		return nil
+	},
+}
+
+func init() {
+	BaselineCmd.AddCommand(SetCmd)
+}
@@ -0,0 +1,49 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package mmbaseline
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/liuminhaw/mist-miner/cmd/mmerr"
+	"github.com/liuminhaw/mist-miner/shelf"
+	"github.com/spf13/cobra"
+)
+
+// ShowCmd represents the baseline show command
+var ShowCmd = &cobra.Command{
+	Use:          "show <group>",
+	Short:        "Show the approved baseline of a group",
+	Long:         ``,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return mmerr.NewArgsError(
+				mmerr.BaselineShowCmdType,
+				fmt.Sprintf("accepts 1 args, received %d", len(args)),
+			)
+		}
+		group := args[0]
+
+		hash, err := shelf.Baseline(group)
+		if err != nil {
+			return fmt.Errorf("baseline show sub-command failed: %w", err)
+		}
+		mark, err := shelf.ReadMark(group, hash)
+		if err != nil {
+			return fmt.Errorf("baseline show sub-command failed: %w", err)
+		}
+
+		fmt.Printf("Baseline: %s\n", hash)
+		fmt.Printf("Date:     %s\n", mark.TimeStamp.Local().Format(time.RFC3339))
+		fmt.Printf("Type:     %s\n", mark.LogType)
+
+		return nil
+	},
+}
+
+func init() {
+	BaselineCmd.AddCommand(ShowCmd)
+}
@@ -8,15 +8,20 @@ import (
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/liuminhaw/mist-miner/cmd/mmerr"
+	"github.com/liuminhaw/mist-miner/shelf"
 	"github.com/liuminhaw/mist-miner/tui"
 	"github.com/spf13/cobra"
 )
 
 // diaryCmd represents the diary command
 var DiaryCmd = &cobra.Command{
-	Use:          "diary <group> <plugin>",
-	Short:        "Show notes of resources in a group",
-	Long:         ``,
+	Use:   "diary <group> <plugin>",
+	Short: "Show notes of resources in a group",
+	Long: `Show notes of resources in a group.
+
+With --at, resources of the newest label mark created at or before the given time are
+shown read-only. Time is given as RFC3339 timestamp, date or duration before now,
+ex. 2026-09-01T00:00:00Z, 2026-09-01 or 7d.`,
 	SilenceUsage: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		if len(args) != 2 {
@@ -28,7 +33,18 @@ var DiaryCmd = &cobra.Command{
 		group := args[0]
 		plugin := args[1]
 
-		model, err := tui.InitDiaryModel(group, plugin)
+		var model tea.Model
+		var err error
+		if diaryAt != "" {
+			var markHash string
+			markHash, err = shelf.ResolveMarkAt(group, diaryAt)
+			if err != nil {
+				return fmt.Errorf("diary sub-command failed: %w", err)
+			}
+			model, err = tui.InitDiaryModelAt(group, plugin, markHash)
+		} else {
+			model, err = tui.InitDiaryModel(group, plugin)
+		}
 		if err != nil {
 			return fmt.Errorf("diary sub-command failed: %w", err)
 		}
@@ -53,4 +69,7 @@ func init() {
 	// Cobra supports local flags which will only run when this command
 	// is called directly, e.g.:
 	// diaryCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	DiaryCmd.Flags().StringVar(&diaryAt, "at", "", "show diary read-only at given time, ex. 2026-09-01T00:00:00Z or 7d")
 }
+
+var diaryAt string
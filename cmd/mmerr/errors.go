@@ -6,6 +6,27 @@ const (
 	LogCmdType       = "log"
 	LogReloadCmdType = "log reload"
 	DiaryCmdType     = "diary"
+	DiffCmdType      = "diff"
+	GcCmdType        = "gc"
+	FsckCmdType      = "fsck"
+	TagCmdType       = "tag"
+	DriftCmdType     = "drift"
+	ShowCmdType      = "show"
+	SearchCmdType    = "search"
+	QueryCmdType     = "query"
+	PackCmdType      = "pack"
+	VerifyCmdType    = "verify"
+
+	ShelfMigrateCmdType   = "shelf migrate"
+	ShelfUpgradeCmdType   = "shelf upgrade"
+	ShelfReencryptCmdType = "shelf reencrypt"
+	BaselineSetCmdType    = "baseline set"
+	BaselineShowCmdType   = "baseline show"
+	BaselineClearCmdType  = "baseline clear"
+
+	PluginsListCmdType     = "plugins list"
+	PluginsDescribeCmdType = "plugins describe"
+	PluginsVerifyCmdType   = "plugins verify"
 )
 
 type ArgsError struct {
@@ -20,3 +41,20 @@ func NewArgsError(cmdType, message string) ArgsError {
 func (e ArgsError) Error() string {
 	return e.Msg
 }
+
+// ExitError is returned by commands which need to exit with a specific code,
+// ex. drift exits with ExitCodeDrift when differences from baseline are found.
+type ExitError struct {
+	Code int
+	Msg  string
+}
+
+const ExitCodeDrift = 2
+
+func NewExitError(code int, message string) ExitError {
+	return ExitError{Code: code, Msg: message}
+}
+
+func (e ExitError) Error() string {
+	return e.Msg
+}
@@ -5,9 +5,13 @@ package mmlog
 
 import (
 	"fmt"
+	"strings"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/liuminhaw/mist-miner/cmd/mmerr"
+	"github.com/liuminhaw/mist-miner/shared"
+	"github.com/liuminhaw/mist-miner/shelf"
 	"github.com/liuminhaw/mist-miner/tui"
 
 	"github.com/spf13/cobra"
@@ -15,9 +19,18 @@ import (
 
 // logCmd represents the log command
 var LogCmd = &cobra.Command{
-	Use:          "log <group>",
-	Short:        "Show mining result log of a group",
-	Long:         ``,
+	Use:   "log <group>",
+	Short: "Show mining result log of a group",
+	Long: `Show mining result log of a group.
+
+With --resource, the timeline of a single resource is printed instead: the marks where
+it first appeared, where its resource or diary changed, and where it disappeared.
+Resources of every plugin with the identifier are shown unless --plugin is given.
+
+With --at, the log is opened at the newest label mark created at or before the given
+time, or only timeline entries up to the time are printed with --resource. Time is
+given as RFC3339 timestamp, date or duration before now, ex. 2026-09-01T00:00:00Z,
+2026-09-01 or 7d.`,
 	SilenceUsage: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		if len(args) != 1 {
@@ -28,7 +41,33 @@ var LogCmd = &cobra.Command{
 		}
 		group := args[0]
 
-		model, err := tui.InitLogModel(group, 0)
+		var at time.Time
+		if logAt != "" {
+			var err error
+			if at, err = shared.ParseTimeSpec(logAt, time.Now()); err != nil {
+				return fmt.Errorf("log sub-command failed: %w", err)
+			}
+		}
+
+		if logResource != "" {
+			if err := printTimelines(group, logResource, logPlugin, at); err != nil {
+				return fmt.Errorf("log sub-command failed: %w", err)
+			}
+			return nil
+		}
+
+		var model tea.Model
+		var err error
+		if logAt != "" {
+			var markHash string
+			markHash, err = shelf.MarkAt(group, at)
+			if err != nil {
+				return fmt.Errorf("log sub-command failed: %w", err)
+			}
+			model, err = tui.InitLogModelAt(group, markHash)
+		} else {
+			model, err = tui.InitLogModel(group, 0)
+		}
 		if err != nil {
 			return fmt.Errorf("log sub-command failed: %w", err)
 		}
@@ -53,4 +92,71 @@ func init() {
 	// Cobra supports local flags which will only run when this command
 	// is called directly, e.g.:
 	// logCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	LogCmd.Flags().StringVarP(&logResource, "resource", "r", "", "show timeline of the resource identifier")
+	LogCmd.Flags().StringVarP(&logPlugin, "plugin", "p", "", "only show resource of the plugin, used with --resource")
+	LogCmd.Flags().StringVar(&logAt, "at", "", "show log at given time, ex. 2026-09-01T00:00:00Z or 7d")
+}
+
+var (
+	logResource string
+	logPlugin   string
+	logAt       string
+)
+
+// printTimelines prints the timeline of resources with the identifier,
+// only the resource of given plugin is printed if plugin is not empty.
+// Entries of label marks created after at are skipped unless at is zero.
+func printTimelines(group, identifier, plugin string, at time.Time) error {
+	var timelines []shelf.Timeline
+	if plugin != "" {
+		timeline, err := shelf.ReadTimeline(group, plugin, identifier)
+		if err != nil {
+			return fmt.Errorf("print timelines: %w", err)
+		}
+		timelines = append(timelines, timeline)
+	} else {
+		var err error
+		if timelines, err = shelf.FindTimelines(group, identifier); err != nil {
+			return fmt.Errorf("print timelines: %w", err)
+		}
+	}
+
+	for i, timeline := range timelines {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("Plugin: %s\n", timeline.Plugin)
+		fmt.Printf("Identifier: %s\n\n", timeline.Identifier)
+
+		for _, entry := range timeline.Entries {
+			mark, err := shelf.ReadMark(group, entry.Mark)
+			if err != nil {
+				return fmt.Errorf("print timelines: %w", err)
+			}
+			if !at.IsZero() && mark.TimeStamp.After(at) {
+				continue
+			}
+			line := fmt.Sprintf(
+				"%s  %s  %-5s  %-15s",
+				mark.TimeStamp.Local().Format(time.RFC3339),
+				entry.Mark[:12],
+				mark.LogType,
+				strings.Join(entry.Events, ","),
+			)
+			if entry.Outline != "nil" {
+				outline, err := shelf.ReadStuffOutline(group, entry.Outline)
+				if err != nil {
+					return fmt.Errorf("print timelines: %w", err)
+				}
+				line += fmt.Sprintf(
+					"  resource %s  diary %s",
+					outline.ResourceHash[:12],
+					outline.DiaryHash[:12],
+				)
+			}
+			fmt.Println(strings.TrimRight(line, " "))
+		}
+	}
+
+	return nil
 }
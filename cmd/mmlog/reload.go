@@ -14,7 +14,7 @@ import (
 // reloadCmd represents the reload command
 var ReloadCmd = &cobra.Command{
 	Use:          "reload <group>",
-	Short:        "Reload history log of a group to recreate reference logger files and pointer files",
+	Short:        "Reload history log of a group to recreate reference logger, pointer, resource timeline and search index files",
 	Long:         ``,
 	SilenceUsage: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
@@ -34,6 +34,14 @@ var ReloadCmd = &cobra.Command{
 			return fmt.Errorf("log reload sub-command failed: %w", err)
 		}
 
+		if err := shelf.GenerateTimeline(group); err != nil {
+			return fmt.Errorf("log reload sub-command failed: %w", err)
+		}
+
+		if err := shelf.GenerateSearchIndex(group); err != nil {
+			return fmt.Errorf("log reload sub-command failed: %w", err)
+		}
+
 		return nil
 	},
 }
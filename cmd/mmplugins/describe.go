@@ -0,0 +1,118 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package mmplugins
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/liuminhaw/mist-miner/cmd/mmerr"
+	"github.com/liuminhaw/mist-miner/paths"
+	"github.com/liuminhaw/mist-miner/shared"
+	"github.com/spf13/cobra"
+)
+
+// DescribeCmd represents the plugins describe command
+var DescribeCmd = &cobra.Command{
+	Use:   "describe <name>",
+	Short: "Show version, accepted config and emitted properties of a plugin",
+	Long: `Describe starts the plugin binary of given name in the plugins bin directory
+and prints the description reported by the plugin: its version, the equipment
+types with their attributes and the authenticator keys it accepts, and the
+properties it can emit. Plugins without describe support are reported as such.`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return mmerr.NewArgsError(
+				mmerr.PluginsDescribeCmdType,
+				fmt.Sprintf("accepts 1 args, received %d", len(args)),
+			)
+		}
+		name := args[0]
+		if describeOutput != "text" && describeOutput != "json" {
+			return mmerr.NewArgsError(
+				mmerr.PluginsDescribeCmdType,
+				fmt.Sprintf("unknown output format %s, expect text or json", describeOutput),
+			)
+		}
+
+		pluginsBinDir, err := paths.PluginsDir()
+		if err != nil {
+			return fmt.Errorf("plugins describe sub-command failed: %w", err)
+		}
+		description, err := shared.DescribePlugin(
+			cmd.Context(),
+			filepath.Join(pluginsBinDir, name),
+			nil,
+			pluginLogger(),
+		)
+		if errors.Is(err, shared.ErrDescribeUnsupported) {
+			fmt.Printf("Plugin %s does not support describe\n", name)
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("plugins describe sub-command failed: %w", err)
+		}
+
+		if describeOutput == "json" {
+			content, err := json.MarshalIndent(description, "", "  ")
+			if err != nil {
+				return fmt.Errorf("plugins describe sub-command failed: %w", err)
+			}
+			fmt.Println(string(content))
+			return nil
+		}
+		printDescription(os.Stdout, description)
+		return nil
+	},
+}
+
+var describeOutput string
+
+func init() {
+	PluginsCmd.AddCommand(DescribeCmd)
+
+	DescribeCmd.Flags().StringVarP(&describeOutput, "output", "o", "text", "output format: text or json")
+}
+
+// pluginLogger returns the logger of plugin clients, logs are discarded
+// since failures of plugins are returned as errors.
+func pluginLogger() hclog.Logger {
+	log.SetOutput(io.Discard)
+	return hclog.New(&hclog.LoggerOptions{
+		Level:  hclog.Off,
+		Output: io.Discard,
+	})
+}
+
+func printDescription(w io.Writer, d shared.PluginDescription) {
+	fmt.Fprintf(w, "name:     %s\n", d.Name)
+	fmt.Fprintf(w, "version:  %s\n", d.Version)
+	fmt.Fprintf(w, "authenticator keys: %s\n", strings.Join(d.AuthenticatorKeys, ", "))
+	fmt.Fprintln(w, "equipments:")
+	for _, equipment := range d.Equipments {
+		fmt.Fprintf(w, "  %s\n", equipment.Type)
+		for _, attr := range equipment.Attributes {
+			requirement := "optional"
+			if attr.Required {
+				requirement = "required"
+			}
+			fmt.Fprintf(w, "    %-20s %-8s  %s\n", attr.Key, requirement, attr.Description)
+		}
+	}
+	fmt.Fprintln(w, "properties:")
+	for _, property := range d.Properties {
+		unique := ""
+		if property.Unique {
+			unique = "unique"
+		}
+		fmt.Fprintf(w, "  %-20s %-20s %s\n", property.Type, property.Label, unique)
+	}
+}
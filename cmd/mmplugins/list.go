@@ -0,0 +1,228 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package mmplugins
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/liuminhaw/mist-miner/cmd/mmerr"
+	"github.com/liuminhaw/mist-miner/paths"
+	"github.com/liuminhaw/mist-miner/shared"
+	"github.com/spf13/cobra"
+)
+
+// ListCmd represents the plugins list command
+var ListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List plugin binaries with their version and the plugs referencing them",
+	Long: `List shows every executable in the plugins bin directory with its sha256,
+the version reported by describe, and the plug blocks of the config referencing
+it. Plugs pinning a sha256 which does not match the binary are marked, and the
+binary is not started to describe it. Plugs referencing a missing binary are
+listed as missing. Use --no-describe to list without starting any binary.`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) > 0 {
+			return mmerr.NewArgsError(
+				mmerr.PluginsListCmdType,
+				fmt.Sprintf("accepts no args, received %d", len(args)),
+			)
+		}
+		if listOutput != "text" && listOutput != "json" {
+			return mmerr.NewArgsError(
+				mmerr.PluginsListCmdType,
+				fmt.Sprintf("unknown output format %s, expect text or json", listOutput),
+			)
+		}
+
+		pluginsBinDir, err := paths.PluginsDir()
+		if err != nil {
+			return fmt.Errorf("plugins list sub-command failed: %w", err)
+		}
+		plugs, err := configPlugs()
+		if err != nil {
+			return fmt.Errorf("plugins list sub-command failed: %w", err)
+		}
+
+		entries, err := listPlugins(pluginsBinDir, plugs)
+		if err != nil {
+			return fmt.Errorf("plugins list sub-command failed: %w", err)
+		}
+		for i, entry := range entries {
+			if listNoDescribe || entry.Missing || entry.mismatched() {
+				continue
+			}
+			description, err := shared.DescribePlugin(
+				cmd.Context(),
+				filepath.Join(pluginsBinDir, entry.Name),
+				entry.checksum,
+				pluginLogger(),
+			)
+			if errors.Is(err, shared.ErrDescribeUnsupported) {
+				continue
+			} else if err != nil {
+				entries[i].Error = err.Error()
+				continue
+			}
+			entries[i].Version = description.Version
+		}
+
+		if listOutput == "json" {
+			content, err := json.MarshalIndent(entries, "", "  ")
+			if err != nil {
+				return fmt.Errorf("plugins list sub-command failed: %w", err)
+			}
+			fmt.Println(string(content))
+			return nil
+		}
+
+		fmt.Printf("%-20s %-12s %-12s %s\n", "NAME", "VERSION", "SHA256", "PLUGS")
+		for _, entry := range entries {
+			version, sum := entry.Version, entry.Sha256
+			if entry.Missing {
+				version, sum = "missing", ""
+			} else if version == "" {
+				version = "-"
+			}
+			if len(sum) > 12 {
+				sum = sum[:12]
+			}
+			fmt.Printf("%-20s %-12s %-12s %s\n", entry.Name, version, sum, entry.plugsSummary())
+			if entry.Error != "" {
+				fmt.Printf("  error: %s\n", entry.Error)
+			}
+		}
+		return nil
+	},
+}
+
+var (
+	listOutput     string
+	listNoDescribe bool
+)
+
+func init() {
+	PluginsCmd.AddCommand(ListCmd)
+
+	ListCmd.Flags().StringVarP(&listOutput, "output", "o", "text", "output format: text or json")
+	ListCmd.Flags().BoolVar(&listNoDescribe, "no-describe", false, "do not start binaries to describe them")
+}
+
+type pluginEntry struct {
+	Name    string    `json:"name"`
+	Sha256  string    `json:"sha256,omitempty"`
+	Version string    `json:"version,omitempty"`
+	Missing bool      `json:"missing,omitempty"`
+	Error   string    `json:"error,omitempty"`
+	Plugs   []plugRef `json:"plugs"`
+	// Pinned checksum of matching plugs, used to describe the binary
+	checksum []byte
+}
+
+type plugRef struct {
+	Group  string `json:"group"`
+	Pinned bool   `json:"pinned"`
+	// Binary matches the pinned sha256, always true if not pinned
+	Match bool `json:"match"`
+}
+
+// mismatched reports whether any plug pins a sha256 not matching the binary
+func (e pluginEntry) mismatched() bool {
+	for _, plug := range e.Plugs {
+		if !plug.Match {
+			return true
+		}
+	}
+	return false
+}
+
+func (e pluginEntry) plugsSummary() string {
+	refs := []string{}
+	for _, plug := range e.Plugs {
+		switch {
+		case plug.Pinned && !plug.Match:
+			refs = append(refs, plug.Group+" (sha256 mismatch)")
+		case plug.Pinned:
+			refs = append(refs, plug.Group+" (pinned)")
+		default:
+			refs = append(refs, plug.Group)
+		}
+	}
+	return strings.Join(refs, ", ")
+}
+
+// listPlugins returns executables in the plugins bin directory with the plugs referencing
+// them, followed by plugs referencing missing binaries.
+func listPlugins(pluginsBinDir string, plugs []shared.Plug) ([]pluginEntry, error) {
+	files, err := os.ReadDir(pluginsBinDir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("list plugins: %w", err)
+	}
+
+	entries := []pluginEntry{}
+	found := make(map[string]int)
+	for _, file := range files {
+		if strings.HasPrefix(file.Name(), ".") || !file.Type().IsRegular() {
+			continue
+		}
+		info, err := file.Info()
+		if err != nil {
+			return nil, fmt.Errorf("list plugins: %w", err)
+		}
+		if info.Mode().Perm()&0111 == 0 {
+			continue
+		}
+
+		sum, err := shared.FileSha256(filepath.Join(pluginsBinDir, file.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("list plugins: %w", err)
+		}
+		found[file.Name()] = len(entries)
+		entries = append(entries, pluginEntry{Name: file.Name(), Sha256: sum, Plugs: []plugRef{}})
+	}
+
+	for _, plug := range plugs {
+		idx, ok := found[plug.Name]
+		if !ok {
+			found[plug.Name] = len(entries)
+			idx = len(entries)
+			entries = append(entries, pluginEntry{Name: plug.Name, Missing: true, Plugs: []plugRef{}})
+		}
+
+		// Validated by ReadConfig
+		checksum, _ := plug.Checksum()
+		ref := plugRef{Group: plug.Group, Pinned: checksum != nil, Match: true}
+		if checksum != nil && !entries[idx].Missing {
+			ref.Match = strings.EqualFold(plug.Sha256, entries[idx].Sha256)
+			if ref.Match {
+				entries[idx].checksum = checksum
+			}
+		}
+		entries[idx].Plugs = append(entries[idx].Plugs, ref)
+	}
+
+	return entries, nil
+}
+
+// configPlugs returns plugs of the config file, none if the file does not exist
+func configPlugs() ([]shared.Plug, error) {
+	configFile, err := paths.ConfigFile()
+	if err != nil {
+		return nil, fmt.Errorf("config plugs: %w", err)
+	}
+	if _, err := os.Stat(configFile); errors.Is(err, fs.ErrNotExist) {
+		return []shared.Plug{}, nil
+	}
+	hclConf, err := shared.ReadConfig(configFile)
+	if err != nil {
+		return nil, fmt.Errorf("config plugs: %w", err)
+	}
+	return hclConf.Plugs, nil
+}
@@ -0,0 +1,132 @@
+package mmplugins
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/liuminhaw/mist-miner/shared"
+)
+
+// writeTestPlugins writes plugin binaries of given content to a new plugins bin
+// directory, and returns the directory with sha256 of every binary by name.
+func writeTestPlugins(t *testing.T, binaries map[string]string) (string, map[string]string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	sums := make(map[string]string)
+	for name, content := range binaries {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o755); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+		sum := sha256.Sum256([]byte(content))
+		sums[name] = hex.EncodeToString(sum[:])
+	}
+	return dir, sums
+}
+
+func TestListPlugins(t *testing.T) {
+	dir, sums := writeTestPlugins(t, map[string]string{"aws-iam": "iam", "aws-s3": "s3"})
+	// Hidden files, non executables and directories are not plugins
+	if err := os.WriteFile(filepath.Join(dir, ".hidden"), []byte("x"), 0o755); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "README"), []byte("x"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0o755); err != nil {
+		t.Fatalf("Mkdir: %v", err)
+	}
+
+	plugs := []shared.Plug{
+		{Name: "aws-iam", Group: "pinned", Sha256: strings.ToUpper(sums["aws-iam"])},
+		{Name: "aws-iam", Group: "mismatch", Sha256: sums["aws-s3"]},
+		{Name: "aws-iam", Group: "unpinned"},
+		{Name: "aws-ec2", Group: "missing", Sha256: sums["aws-s3"]},
+	}
+	entries, err := listPlugins(dir, plugs)
+	if err != nil {
+		t.Fatalf("listPlugins: %v", err)
+	}
+
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, entry.Name)
+	}
+	if want := []string{"aws-iam", "aws-s3", "aws-ec2"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("listPlugins names = %v, want %v", names, want)
+	}
+
+	iam := entries[0]
+	if iam.Sha256 != sums["aws-iam"] || iam.Missing || !iam.mismatched() {
+		t.Errorf("aws-iam entry = %+v, want found with a mismatched plug", iam)
+	}
+	wantRefs := []plugRef{
+		{Group: "pinned", Pinned: true, Match: true},
+		{Group: "mismatch", Pinned: true, Match: false},
+		{Group: "unpinned", Pinned: false, Match: true},
+	}
+	if !reflect.DeepEqual(iam.Plugs, wantRefs) {
+		t.Errorf("aws-iam plugs = %+v, want %+v", iam.Plugs, wantRefs)
+	}
+	if want := "pinned (pinned), mismatch (sha256 mismatch), unpinned"; iam.plugsSummary() != want {
+		t.Errorf("plugsSummary = %q, want %q", iam.plugsSummary(), want)
+	}
+	if hex.EncodeToString(iam.checksum) != sums["aws-iam"] {
+		t.Errorf("aws-iam checksum = %x, want the matching pin", iam.checksum)
+	}
+
+	if s3 := entries[1]; s3.Sha256 != sums["aws-s3"] || len(s3.Plugs) != 0 || s3.mismatched() {
+		t.Errorf("aws-s3 entry = %+v, want found without plugs", s3)
+	}
+	if ec2 := entries[2]; !ec2.Missing || ec2.Sha256 != "" || ec2.mismatched() {
+		t.Errorf("aws-ec2 entry = %+v, want missing", ec2)
+	}
+}
+
+func TestListPluginsNoDir(t *testing.T) {
+	plugs := []shared.Plug{{Name: "aws-iam", Group: "grp"}}
+	entries, err := listPlugins(filepath.Join(t.TempDir(), "bin"), plugs)
+	if err != nil {
+		t.Fatalf("listPlugins: %v", err)
+	}
+	if len(entries) != 1 || !entries[0].Missing {
+		t.Errorf("listPlugins = %+v, want aws-iam missing", entries)
+	}
+}
+
+func TestVerifyPlugins(t *testing.T) {
+	dir, sums := writeTestPlugins(t, map[string]string{"aws-iam": "iam", "aws-s3": "s3"})
+
+	var out strings.Builder
+	ok := []shared.Plug{
+		{Name: "aws-iam", Group: "grp", Sha256: sums["aws-iam"]},
+		{Name: "aws-s3", Group: "grp"},
+		{Name: "aws-ec2", Group: "grp"},
+	}
+	if failed, err := verifyPlugins(&out, dir, ok); err != nil || failed != 0 {
+		t.Errorf("verifyPlugins = %d, %v, want no failure\n%s", failed, err, out.String())
+	}
+	if !strings.Contains(out.String(), "1 pinned plugs checked, 0 failed") {
+		t.Errorf("verifyPlugins output %q, want only the pinned plug checked", out.String())
+	}
+
+	out.Reset()
+	bad := []shared.Plug{
+		{Name: "aws-iam", Group: "grp", Sha256: sums["aws-iam"]},
+		{Name: "aws-s3", Group: "grp", Sha256: sums["aws-iam"]},
+		{Name: "aws-ec2", Group: "grp", Sha256: sums["aws-iam"]},
+	}
+	if failed, err := verifyPlugins(&out, dir, bad); err != nil || failed != 2 {
+		t.Errorf("verifyPlugins = %d, %v, want 2 failures\n%s", failed, err, out.String())
+	}
+	for _, want := range []string{"sha256 mismatch, binary " + sums["aws-s3"], "missing"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("verifyPlugins output %q does not contain %q", out.String(), want)
+		}
+	}
+}
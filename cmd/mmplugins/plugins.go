@@ -0,0 +1,15 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package mmplugins
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// PluginsCmd represents the plugins command
+var PluginsCmd = &cobra.Command{
+	Use:   "plugins",
+	Short: "Inspect plugins in the plugins bin directory",
+	Long:  ``,
+}
@@ -0,0 +1,88 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package mmplugins
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/liuminhaw/mist-miner/cmd/mmerr"
+	"github.com/liuminhaw/mist-miner/paths"
+	"github.com/liuminhaw/mist-miner/shared"
+	"github.com/spf13/cobra"
+)
+
+// VerifyCmd represents the plugins verify command
+var VerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Check plugin binaries against the sha256 pinned by plugs",
+	Long: `Verify hashes the plugin binary of every plug pinning a sha256 in the config
+and compares it with the pin. Binaries are only read, never started. Fails if
+any pinned binary does not match or is missing, plugs without sha256 are not
+checked.`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) > 0 {
+			return mmerr.NewArgsError(
+				mmerr.PluginsVerifyCmdType,
+				fmt.Sprintf("accepts no args, received %d", len(args)),
+			)
+		}
+
+		pluginsBinDir, err := paths.PluginsDir()
+		if err != nil {
+			return fmt.Errorf("plugins verify sub-command failed: %w", err)
+		}
+		plugs, err := configPlugs()
+		if err != nil {
+			return fmt.Errorf("plugins verify sub-command failed: %w", err)
+		}
+
+		failed, err := verifyPlugins(os.Stdout, pluginsBinDir, plugs)
+		if err != nil {
+			return fmt.Errorf("plugins verify sub-command failed: %w", err)
+		}
+		if failed > 0 {
+			return fmt.Errorf("plugins verify sub-command failed: %d pinned plugs do not verify", failed)
+		}
+		return nil
+	},
+}
+
+func init() {
+	PluginsCmd.AddCommand(VerifyCmd)
+}
+
+// verifyPlugins writes the result of every plug pinning a sha256 to w, and returns
+// the number of plugs whose binary is missing or does not match the pin.
+func verifyPlugins(w io.Writer, pluginsBinDir string, plugs []shared.Plug) (int, error) {
+	entries, err := listPlugins(pluginsBinDir, plugs)
+	if err != nil {
+		return 0, fmt.Errorf("verify plugins: %w", err)
+	}
+
+	checked, failed := 0, 0
+	for _, entry := range entries {
+		for _, plug := range entry.Plugs {
+			if !plug.Pinned {
+				continue
+			}
+			checked++
+			switch {
+			case entry.Missing:
+				fmt.Fprintf(w, "%-20s %-20s missing\n", entry.Name, plug.Group)
+				failed++
+			case !plug.Match:
+				fmt.Fprintf(w, "%-20s %-20s sha256 mismatch, binary %s\n", entry.Name, plug.Group, entry.Sha256)
+				failed++
+			default:
+				fmt.Fprintf(w, "%-20s %-20s ok\n", entry.Name, plug.Group)
+			}
+		}
+	}
+	fmt.Fprintf(w, "%d pinned plugs checked, %d failed\n", checked, failed)
+
+	return failed, nil
+}
@@ -0,0 +1,73 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package mmshelf
+
+import (
+	"fmt"
+
+	"github.com/liuminhaw/mist-miner/cmd/mmerr"
+	"github.com/liuminhaw/mist-miner/shelf"
+	"github.com/spf13/cobra"
+)
+
+// MigrateCmd represents the shelf migrate command
+var MigrateCmd = &cobra.Command{
+	Use:   "migrate <group>",
+	Short: "Rewrite objects of a group written by older versions",
+	Long: `Objects written by older versions are raw payloads without a type header.
+Migrate decides the type of each legacy object by walking from references and
+rewrites it with a typed header, object hashes stay the same. Legacy objects not
+reachable from any reference are left untouched.
+
+Resources written by older versions are stored as one json blob, migrate splits
+them into resource trees with one property object per property content, so
+identical property values are stored once across resources and label marks.`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return mmerr.NewArgsError(
+				mmerr.ShelfMigrateCmdType,
+				fmt.Sprintf("accepts 1 args, received %d", len(args)),
+			)
+		}
+		group := args[0]
+
+		result, err := shelf.MigrateObjects(group, migrateDryRun)
+		if err != nil {
+			return fmt.Errorf("shelf migrate sub-command failed: %w", err)
+		}
+
+		action := "Migrated"
+		if migrateDryRun {
+			action = "Would migrate"
+		}
+		for _, hash := range result.Migrated {
+			fmt.Printf("%s %s\n", action, hash)
+		}
+		for _, hash := range result.Trees {
+			fmt.Printf("%s %s to resource tree\n", action, hash)
+		}
+		for _, hash := range result.Skipped {
+			fmt.Printf("Skipped unreachable %s\n", hash)
+		}
+		fmt.Printf(
+			"%s %d objects, %d resources to tree, %d already typed, %d unreachable skipped\n",
+			action,
+			len(result.Migrated),
+			len(result.Trees),
+			result.Typed,
+			len(result.Skipped),
+		)
+
+		return nil
+	},
+}
+
+var migrateDryRun bool
+
+func init() {
+	ShelfCmd.AddCommand(MigrateCmd)
+
+	MigrateCmd.Flags().BoolVarP(&migrateDryRun, "dry-run", "n", false, "only report objects to be migrated")
+}
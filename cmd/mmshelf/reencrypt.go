@@ -0,0 +1,81 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package mmshelf
+
+import (
+	"fmt"
+
+	"github.com/liuminhaw/mist-miner/cmd/mmerr"
+	"github.com/liuminhaw/mist-miner/shelf"
+	"github.com/spf13/cobra"
+)
+
+// ReencryptCmd represents the shelf reencrypt command
+var ReencryptCmd = &cobra.Command{
+	Use:   "reencrypt <group>",
+	Short: "Rewrite objects of a group with the current encryption key",
+	Long: `Objects and history records are encrypted with the key set by key_file of
+the shelf config block or the MIST_MINER_SHELF_KEY environment variable.
+Reencrypt rewrites content not encrypted with the current key, decrypting
+content of previous keys given by --old-key-file, so a key can be rotated:
+
+  1. set the new key in the config or environment
+  2. run reencrypt with the previous key file
+  3. retire the previous key
+
+Without a current key, encrypted content is rewritten as plaintext. Object
+hashes stay the same since they are calculated from plaintext.`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return mmerr.NewArgsError(
+				mmerr.ShelfReencryptCmdType,
+				fmt.Sprintf("accepts 1 args, received %d", len(args)),
+			)
+		}
+		group := args[0]
+
+		for _, path := range reencryptOldKeyFiles {
+			key, err := shelf.ReadKeyFile(path)
+			if err != nil {
+				return fmt.Errorf("shelf reencrypt sub-command failed: %w", err)
+			}
+			if err := shelf.AddDecryptionKey(key); err != nil {
+				return fmt.Errorf("shelf reencrypt sub-command failed: %w", err)
+			}
+		}
+
+		result, err := shelf.ReencryptObjects(group, reencryptDryRun)
+		if err != nil {
+			return fmt.Errorf("shelf reencrypt sub-command failed: %w", err)
+		}
+
+		action := "Reencrypted"
+		if reencryptDryRun {
+			action = "Would reencrypt"
+		}
+		fmt.Printf(
+			"%s %d objects, %d packs, %d history records, %d objects already with current key\n",
+			action,
+			result.Objects,
+			result.Packs,
+			result.Refs,
+			result.Current,
+		)
+
+		return nil
+	},
+}
+
+var (
+	reencryptOldKeyFiles []string
+	reencryptDryRun      bool
+)
+
+func init() {
+	ShelfCmd.AddCommand(ReencryptCmd)
+
+	ReencryptCmd.Flags().StringSliceVar(&reencryptOldKeyFiles, "old-key-file", []string{}, "file of a previous key to decrypt objects with, can be repeated")
+	ReencryptCmd.Flags().BoolVarP(&reencryptDryRun, "dry-run", "n", false, "only report objects to be reencrypted")
+}
@@ -0,0 +1,15 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package mmshelf
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// ShelfCmd represents the shelf command
+var ShelfCmd = &cobra.Command{
+	Use:   "shelf",
+	Short: "Maintain the storage format of shelf records",
+	Long:  ``,
+}
@@ -0,0 +1,104 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package mmshelf
+
+import (
+	"fmt"
+
+	"github.com/liuminhaw/mist-miner/cmd/mmerr"
+	"github.com/liuminhaw/mist-miner/shelf"
+	"github.com/spf13/cobra"
+)
+
+// UpgradeCmd represents the shelf upgrade command
+var UpgradeCmd = &cobra.Command{
+	Use:   "upgrade <group>",
+	Short: "Rewrite objects of a group in another compression or hash algorithm",
+	Long: `Each group records its format version, compression and hash algorithm in FORMAT.
+Upgrade rewrites every object reachable from references in the target format,
+starting from the oldest label mark so the rewritten marks keep their timestamps,
+log types and parents. References are moved to the rewritten label marks, then
+unreachable and packed objects are removed and history records, timelines and the
+search index are regenerated. Groups with signed label marks are only upgraded if
+every signature verifies against the trusted keys and no object is altered.
+
+Compression and hash algorithm not given are kept from the current format, upgrade
+without flags only records the current format version.`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return mmerr.NewArgsError(
+				mmerr.ShelfUpgradeCmdType,
+				fmt.Sprintf("accepts 1 args, received %d", len(args)),
+			)
+		}
+		group := args[0]
+
+		current, err := shelf.ReadFormat(group)
+		if err != nil {
+			return fmt.Errorf("shelf upgrade sub-command failed: %w", err)
+		}
+		compression, hash := upgradeCompression, upgradeHash
+		if compression == "" {
+			compression = current.Compression
+		}
+		if hash == "" {
+			hash = current.Hash
+		}
+		target, err := shelf.NewFormat(compression, hash)
+		if err != nil {
+			return mmerr.NewArgsError(mmerr.ShelfUpgradeCmdType, err.Error())
+		}
+
+		result, err := shelf.UpgradeShelf(group, target, upgradeDryRun)
+		if err != nil {
+			return fmt.Errorf("shelf upgrade sub-command failed: %w", err)
+		}
+
+		action := "Upgraded"
+		if upgradeDryRun {
+			action = "Would upgrade"
+		}
+		fmt.Printf("%s %s from %s to %s\n", action, group, result.From, result.To)
+		fmt.Printf("%s %d objects, %d objects removed, %d references moved\n",
+			action,
+			result.Rewritten,
+			result.Removed,
+			len(result.Refs),
+		)
+		if upgradeDryRun || result.Rewritten == 0 {
+			return nil
+		}
+
+		// Regenerate after the objects lock taken by upgrade is released
+		if err := shelf.GenerateHistoryRecords(group, shelf.SHELF_HISTORY_LOGS_PER_PAGE); err != nil {
+			return fmt.Errorf("shelf upgrade sub-command failed: %w", err)
+		}
+		if err := shelf.GenerateHistoryPointers(group); err != nil {
+			return fmt.Errorf("shelf upgrade sub-command failed: %w", err)
+		}
+		if err := shelf.GenerateTimeline(group); err != nil {
+			return fmt.Errorf("shelf upgrade sub-command failed: %w", err)
+		}
+		if err := shelf.GenerateSearchIndex(group); err != nil {
+			return fmt.Errorf("shelf upgrade sub-command failed: %w", err)
+		}
+
+		return nil
+	},
+}
+
+var (
+	upgradeCompression string
+	upgradeHash        string
+	upgradeDryRun      bool
+)
+
+func init() {
+	ShelfCmd.AddCommand(UpgradeCmd)
+
+	UpgradeCmd.Flags().StringVar(&upgradeCompression, "compression", "", "compression of rewritten objects: zlib, zstd or none")
+	UpgradeCmd.Flags().StringVar(&upgradeHash, "hash", "", "hash algorithm of rewritten objects: sha256 or blake3")
+	UpgradeCmd.Flags().BoolVarP(&upgradeDryRun, "dry-run", "n", false, "only report objects to be rewritten")
+}
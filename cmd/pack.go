@@ -0,0 +1,69 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/liuminhaw/mist-miner/cmd/mmerr"
+	"github.com/liuminhaw/mist-miner/shelf"
+	"github.com/spf13/cobra"
+)
+
+// packCmd represents the pack command
+var packCmd = &cobra.Command{
+	Use:   "pack <group>",
+	Short: "Consolidate loose objects of a group into a packfile",
+	Long: `Write loose objects of a group into a new packfile with an index under
+objects/pack, then remove the loose object files. Packed objects are read the same
+way as loose objects.
+
+With --delta older versions of resources and properties along the HEAD label mark
+chain are stored as delta of their next version in the same pack. Legacy objects
+without type header are left loose, run shelf migrate before packing them.
+Run gc --repack to merge packs and drop unreachable packed objects.`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return mmerr.NewArgsError(
+				mmerr.PackCmdType,
+				fmt.Sprintf("accepts 1 args, received %d", len(args)),
+			)
+		}
+		group := args[0]
+
+		result, err := shelf.PackObjects(group, packDelta)
+		if err != nil {
+			return fmt.Errorf("pack sub-command failed: %w", err)
+		}
+		printPackResult(result)
+
+		return nil
+	},
+}
+
+var packDelta bool
+
+func init() {
+	rootCmd.AddCommand(packCmd)
+
+	packCmd.Flags().BoolVar(&packDelta, "delta", false, "store older versions of resources as delta")
+}
+
+func printPackResult(result shelf.PackResult) {
+	for _, hash := range result.Skipped {
+		fmt.Printf("Skipped legacy %s\n", hash)
+	}
+	if result.Pack == "" {
+		fmt.Println("Nothing to pack")
+		return
+	}
+	fmt.Printf(
+		"Packed %d objects (%d deltas) into %s, %d bytes\n",
+		result.Objects,
+		result.Deltas,
+		result.Pack,
+		result.Size,
+	)
+}
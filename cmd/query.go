@@ -0,0 +1,308 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/liuminhaw/mist-miner/cmd/mmerr"
+	"github.com/liuminhaw/mist-miner/query"
+	"github.com/liuminhaw/mist-miner/shared"
+	"github.com/liuminhaw/mist-miner/shelf"
+	"github.com/spf13/cobra"
+)
+
+// queryCmd represents the query command
+var queryCmd = &cobra.Command{
+	Use:   "query <group> [expression]",
+	Short: "List resources matching an expression over their properties",
+	Long: `List resources of a group whose properties match the expression, all resources
+are listed if no expression is given. Resources are read from HEAD unless another label
+mark revision is given by --mark, or --at selects the newest label mark created at or
+before the given time.
+
+Properties are selected by label, followed by a JSONPath into json content:
+
+  UserDetail                      whole content of the property
+  UserDetail$.Tags[0].Value       json value by path, [*] and .* select every element
+  ["User Detail"]$.Arn            labels which are not simple words
+  @plugin, @identifier, @alias    resource fields
+
+A property with unique label is addressed directly as one value, a non unique label
+selects the values of every property with the label. Selectors are combined with
+==, !=, <, <=, >, >=, =~ (regular expression), and, or, not, parentheses, literals
+("text", 90, true, null) and functions ago(90d), now(), date("2026-09-01"), exists(x),
+count(x), len(x) and lower(x). A comparison is true if any selected value satisfies it.
+
+  mist-miner query aws 'UserDetail$.PasswordLastUsed < ago(90d)' -s UserDetail$.PasswordLastUsed
+  mist-miner query aws --count-by 'UserDetail$.Tags[*].Value'`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 && len(args) != 2 {
+			return mmerr.NewArgsError(
+				mmerr.QueryCmdType,
+				fmt.Sprintf("accepts 1 or 2 args, received %d", len(args)),
+			)
+		}
+		if queryOutput != query_output_table && queryOutput != query_output_json {
+			return mmerr.NewArgsError(
+				mmerr.QueryCmdType,
+				fmt.Sprintf("unknown output format %s, expect table or json", queryOutput),
+			)
+		}
+		group := args[0]
+
+		expression := "true"
+		if len(args) == 2 {
+			expression = args[1]
+		}
+		q, err := query.Compile(expression, time.Now())
+		if err != nil {
+			return fmt.Errorf("query sub-command failed: %w", err)
+		}
+		selectors := []query.Selector{}
+		for _, src := range querySelect {
+			sel, err := query.ParseSelector(src)
+			if err != nil {
+				return fmt.Errorf("query sub-command failed: %w", err)
+			}
+			selectors = append(selectors, sel)
+		}
+		var countBy *query.Selector
+		if queryCountBy != "" {
+			sel, err := query.ParseSelector(queryCountBy)
+			if err != nil {
+				return fmt.Errorf("query sub-command failed: %w", err)
+			}
+			countBy = &sel
+		}
+
+		var markHash string
+		if queryAt != "" {
+			markHash, err = shelf.ResolveMarkAt(group, queryAt)
+		} else {
+			markHash, err = shelf.ResolveMark(group, queryMark)
+		}
+		if err != nil {
+			return fmt.Errorf("query sub-command failed: %w", err)
+		}
+
+		resources, err := readQueryResources(group, markHash, queryPlugin)
+		if err != nil {
+			return fmt.Errorf("query sub-command failed: %w", err)
+		}
+		matched := []queryResource{}
+		for _, res := range resources {
+			ok, err := q.Match(res.plugin, res.resource)
+			if err != nil {
+				return fmt.Errorf("query sub-command failed: %w", err)
+			}
+			if ok {
+				matched = append(matched, res)
+			}
+		}
+
+		if countBy != nil {
+			err = printQueryCounts(matched, *countBy, queryOutput)
+		} else {
+			err = printQueryResults(matched, selectors, queryOutput)
+		}
+		if err != nil {
+			return fmt.Errorf("query sub-command failed: %w", err)
+		}
+
+		return nil
+	},
+}
+
+const (
+	query_output_table = "table"
+	query_output_json  = "json"
+)
+
+var (
+	queryMark    string
+	queryAt      string
+	queryPlugin  string
+	queryOutput  string
+	querySelect  []string
+	queryCountBy string
+)
+
+func init() {
+	rootCmd.AddCommand(queryCmd)
+
+	queryCmd.Flags().StringVarP(&queryMark, "mark", "m", shelf.SHELF_MARK_FILE, "label mark revision to query")
+	queryCmd.Flags().StringVar(&queryAt, "at", "", "query the label mark at given time, ex. 2026-09-01T00:00:00Z or 7d")
+	queryCmd.Flags().StringVarP(&queryPlugin, "plugin", "p", "", "only query resources of the plugin")
+	queryCmd.Flags().StringVarP(&queryOutput, "output", "o", query_output_table, "output format: table or json")
+	queryCmd.Flags().StringArrayVarP(&querySelect, "select", "s", nil, "selector of values to show in results, can be repeated")
+	queryCmd.Flags().StringVar(&queryCountBy, "count-by", "", "count matching resources by values of the selector")
+	queryCmd.MarkFlagsMutuallyExclusive("mark", "at")
+	queryCmd.MarkFlagsMutuallyExclusive("select", "count-by")
+}
+
+type queryResource struct {
+	plugin   string
+	resource *shared.MinerResource
+}
+
+// readQueryResources reads resources of the label mark sorted by plugin and identifier,
+// only resources of the plugin are read if plugin is not empty.
+func readQueryResources(group, markHash, plugin string) ([]queryResource, error) {
+	mark, err := shelf.ReadMark(group, markHash)
+	if err != nil {
+		return nil, fmt.Errorf("read query resources: %w", err)
+	}
+
+	plugins := []string{}
+	for _, mapping := range mark.Mappings {
+		if (plugin == "" || mapping.Module == plugin) && !slices.Contains(plugins, mapping.Module) {
+			plugins = append(plugins, mapping.Module)
+		}
+	}
+	sort.Strings(plugins)
+
+	resources := []queryResource{}
+	for _, p := range plugins {
+		hashes, err := shelf.MarkResources(group, markHash, p)
+		if err != nil {
+			return nil, fmt.Errorf("read query resources: %w", err)
+		}
+		identifiers := make([]string, 0, len(hashes))
+		for id := range hashes {
+			identifiers = append(identifiers, id)
+		}
+		sort.Strings(identifiers)
+
+		for _, id := range identifiers {
+			resource, err := shelf.ReadResource(group, hashes[id])
+			if err != nil {
+				return nil, fmt.Errorf("read query resources: %w", err)
+			}
+			resources = append(resources, queryResource{plugin: p, resource: resource})
+		}
+	}
+
+	return resources, nil
+}
+
+// printQueryResults prints plugin, identifier, alias and values of the selectors of
+// each resource. Multiple values of a selector are joined by comma in table output
+// and listed in an array in json output.
+func printQueryResults(resources []queryResource, selectors []query.Selector, output string) error {
+	if output == query_output_json {
+		type jsonResult struct {
+			Plugin     string         `json:"plugin"`
+			Identifier string         `json:"identifier"`
+			Alias      string         `json:"alias"`
+			Values     map[string]any `json:"values,omitempty"`
+		}
+		results := []jsonResult{}
+		for _, res := range resources {
+			result := jsonResult{
+				Plugin:     res.plugin,
+				Identifier: res.resource.Identifier,
+				Alias:      res.resource.Alias,
+			}
+			if len(selectors) > 0 {
+				result.Values = make(map[string]any)
+			}
+			for _, sel := range selectors {
+				result.Values[sel.String()] = jsonSelectorValue(sel.Values(res.plugin, res.resource))
+			}
+			results = append(results, result)
+		}
+
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(results)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	header := []string{"PLUGIN", "IDENTIFIER", "ALIAS"}
+	for _, sel := range selectors {
+		header = append(header, sel.String())
+	}
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	for _, res := range resources {
+		row := []string{res.plugin, res.resource.Identifier, res.resource.Alias}
+		for _, sel := range selectors {
+			row = append(row, tableSelectorValue(sel.Values(res.plugin, res.resource)))
+		}
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("print query results: %w", err)
+	}
+	fmt.Printf("\n%d resources\n", len(resources))
+
+	return nil
+}
+
+// printQueryCounts prints the number of resources by each value of the selector,
+// sorted by count. A resource is counted once for each distinct value it has,
+// resources without value are counted as (none) in table output and null in json output.
+func printQueryCounts(resources []queryResource, sel query.Selector, output string) error {
+	counter := query.NewCounter(sel)
+	for _, res := range resources {
+		counter.Add(res.plugin, res.resource)
+	}
+	counts := counter.Counts()
+
+	if output == query_output_json {
+		type jsonCount struct {
+			Value any `json:"value"`
+			Count int `json:"count"`
+		}
+		results := []jsonCount{}
+		for _, c := range counts {
+			results = append(results, jsonCount{Value: c.Value, Count: c.Count})
+		}
+
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(results)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "%s\tCOUNT\n", sel.String())
+	for _, c := range counts {
+		fmt.Fprintf(w, "%s\t%d\n", c.Key, c.Count)
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("print query counts: %w", err)
+	}
+
+	return nil
+}
+
+func jsonSelectorValue(values []any) any {
+	switch len(values) {
+	case 0:
+		return nil
+	case 1:
+		return values[0]
+	default:
+		return values
+	}
+}
+
+func tableSelectorValue(values []any) string {
+	if len(values) == 0 {
+		return "-"
+	}
+	formatted := make([]string, 0, len(values))
+	for _, value := range values {
+		formatted = append(formatted, query.FormatValue(value))
+	}
+	return strings.Join(formatted, ",")
+}
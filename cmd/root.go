@@ -22,23 +22,28 @@ THE SOFTWARE.
 package cmd
 
 import (
+	"crypto/ed25519"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 
 	"github.com/spf13/cobra"
 
+	"github.com/liuminhaw/mist-miner/cmd/mmbaseline"
 	"github.com/liuminhaw/mist-miner/cmd/mmdiary"
 	"github.com/liuminhaw/mist-miner/cmd/mmerr"
 	"github.com/liuminhaw/mist-miner/cmd/mmlog"
+	"github.com/liuminhaw/mist-miner/cmd/mmplugins"
+	"github.com/liuminhaw/mist-miner/cmd/mmshelf"
+	"github.com/liuminhaw/mist-miner/paths"
+	"github.com/liuminhaw/mist-miner/shared"
+	"github.com/liuminhaw/mist-miner/shelf"
+	"github.com/liuminhaw/mist-miner/store"
 )
 
-const (
-	defaultConfigFile    = "config.hcl"
-	defaultPluginsBinDir = "plugins/bin"
-)
-
-var configFile string
+var locations paths.Settings
 
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
@@ -48,6 +53,125 @@ var rootCmd = &cobra.Command{
 	// Uncomment the following line if your bare application
 	// has an action associated with it:
 	// Run: func(cmd *cobra.Command, args []string) { },
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		// Locations are resolved once by setShelf and cached for the command
+		paths.Set(locations)
+		if err := setShelf(); err != nil {
+			return err
+		}
+		return nil
+	},
+}
+
+// setShelf sets the storage backend, the object format of new groups and the encryption
+// key selected by the shelf block of the config file, and the label mark signing keys of
+// the signing block. The shelf directory with zlib and sha256 is used if the block or
+// the file is absent.
+func setShelf() error {
+	loc, err := paths.Resolve()
+	if err != nil {
+		return fmt.Errorf("set shelf: %w", err)
+	}
+	hclConf := &shared.HclConfig{}
+	if _, err := os.Stat(loc.ConfigFile); !errors.Is(err, fs.ErrNotExist) {
+		if hclConf, err = shared.ReadConfig(loc.ConfigFile); err != nil {
+			return fmt.Errorf("set shelf: %w", err)
+		}
+	}
+
+	if err := setSigningKeys(hclConf.Signing, loc.ConfigFile); err != nil {
+		return err
+	}
+	if hclConf.Shelf == nil {
+		return setShelfKey("", loc.ConfigFile)
+	}
+	if err := setShelfKey(hclConf.Shelf.KeyFile, loc.ConfigFile); err != nil {
+		return err
+	}
+
+	format, err := shelf.NewFormat(hclConf.Shelf.Compression, hclConf.Shelf.Hash)
+	if err != nil {
+		return fmt.Errorf("set shelf: %w", err)
+	}
+	shelf.SetDefaultFormat(format)
+
+	cfg := store.Config{Backend: hclConf.Shelf.Backend, Dir: loc.ShelfDir}
+	if s3 := hclConf.Shelf.S3; s3 != nil {
+		cfg.S3 = store.S3Config{
+			Endpoint:  s3.Endpoint,
+			Region:    s3.Region,
+			Bucket:    s3.Bucket,
+			Prefix:    s3.Prefix,
+			AccessKey: s3.AccessKey,
+			SecretKey: s3.SecretKey,
+		}
+	}
+	s, err := store.New(cfg)
+	if err != nil {
+		return fmt.Errorf("set shelf: %w", err)
+	}
+	shelf.SetStore(s)
+
+	return nil
+}
+
+// setShelfKey sets the key encrypting shelf objects from the MIST_MINER_SHELF_KEY
+// environment variable, or the key file resolved from the config file directory.
+// Objects are written as plaintext if neither is set.
+func setShelfKey(keyFile, configFile string) error {
+	var key []byte
+	var err error
+	if encoded, ok := os.LookupEnv(shelf.SHELF_KEY_ENV); ok {
+		key, err = shelf.DecodeKey(encoded)
+	} else if keyFile != "" {
+		key, err = shelf.ReadKeyFile(configRelativePath(keyFile, configFile))
+	} else {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("set shelf key: %w", err)
+	}
+
+	if err := shelf.SetEncryptionKey(key); err != nil {
+		return fmt.Errorf("set shelf key: %w", err)
+	}
+	return nil
+}
+
+// setSigningKeys sets the key signing label marks and the trusted public keys
+// of the signing block, label marks are not signed if the block is absent.
+func setSigningKeys(conf *shared.SigningConfig, configFile string) error {
+	if conf == nil {
+		return nil
+	}
+
+	if conf.KeyFile != "" {
+		key, err := shelf.ReadSigningKeyFile(configRelativePath(conf.KeyFile, configFile))
+		if err != nil {
+			return fmt.Errorf("set signing keys: %w", err)
+		}
+		shelf.SetSigningKey(key)
+	}
+
+	trusted := []ed25519.PublicKey{}
+	for _, keyFile := range conf.TrustedKeyFiles {
+		key, err := shelf.ReadPublicKeyFile(configRelativePath(keyFile, configFile))
+		if err != nil {
+			return fmt.Errorf("set signing keys: %w", err)
+		}
+		trusted = append(trusted, key)
+	}
+	shelf.SetTrustedKeys(trusted)
+
+	return nil
+}
+
+// configRelativePath resolves a path given in the config file from its directory
+func configRelativePath(path, configFile string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(filepath.Dir(configFile), path)
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
@@ -68,7 +192,47 @@ func Execute() {
 				mmlog.ReloadCmd.Usage()
 			case mmerr.DiaryCmdType:
 				mmdiary.DiaryCmd.Usage()
+			case mmerr.DiffCmdType:
+				diffCmd.Usage()
+			case mmerr.GcCmdType:
+				gcCmd.Usage()
+			case mmerr.FsckCmdType:
+				fsckCmd.Usage()
+			case mmerr.TagCmdType:
+				tagCmd.Usage()
+			case mmerr.ShowCmdType:
+				showCmd.Usage()
+			case mmerr.SearchCmdType:
+				searchCmd.Usage()
+			case mmerr.QueryCmdType:
+				queryCmd.Usage()
+			case mmerr.PackCmdType:
+				packCmd.Usage()
+			case mmerr.DriftCmdType:
+				driftCmd.Usage()
+			case mmerr.VerifyCmdType:
+				verifyCmd.Usage()
+			case mmerr.ShelfMigrateCmdType:
+				mmshelf.MigrateCmd.Usage()
+			case mmerr.ShelfUpgradeCmdType:
+				mmshelf.UpgradeCmd.Usage()
+			case mmerr.ShelfReencryptCmdType:
+				mmshelf.ReencryptCmd.Usage()
+			case mmerr.BaselineSetCmdType:
+				mmbaseline.SetCmd.Usage()
+			case mmerr.BaselineShowCmdType:
+				mmbaseline.ShowCmd.Usage()
+			case mmerr.BaselineClearCmdType:
+				mmbaseline.ClearCmd.Usage()
+			case mmerr.PluginsListCmdType:
+				mmplugins.ListCmd.Usage()
+			case mmerr.PluginsDescribeCmdType:
+				mmplugins.DescribeCmd.Usage()
+			case mmerr.PluginsVerifyCmdType:
+				mmplugins.VerifyCmd.Usage()
 			}
+		case mmerr.ExitError:
+			os.Exit(v.Code)
 		default:
 			fmt.Printf("Failed to execute command: %+v\n", err)
 		}
@@ -79,35 +243,40 @@ func Execute() {
 func init() {
 	rootCmd.AddCommand(mmlog.LogCmd)
 	rootCmd.AddCommand(mmdiary.DiaryCmd)
+	rootCmd.AddCommand(mmshelf.ShelfCmd)
+	rootCmd.AddCommand(mmbaseline.BaselineCmd)
+	rootCmd.AddCommand(mmplugins.PluginsCmd)
 	// Here you will define your flags and configuration settings.
 	// Cobra supports persistent flags, which, if defined here,
 	// will be global for your application.
 
-	// rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.mist-miner.yaml)")
+	rootCmd.PersistentFlags().StringVar(
+		&locations.Home,
+		"home",
+		"",
+		fmt.Sprintf("mist-miner home directory holding .miner, plugins/bin and config.hcl (env %s)", paths.ENV_HOME),
+	)
+	rootCmd.PersistentFlags().StringVar(
+		&locations.ShelfDir,
+		"shelf-dir",
+		"",
+		fmt.Sprintf("shelf directory, overrides home layout (env %s)", paths.ENV_SHELF_DIR),
+	)
+	rootCmd.PersistentFlags().StringVar(
+		&locations.PluginsDir,
+		"plugins-dir",
+		"",
+		fmt.Sprintf("plugin binaries directory, overrides home layout (env %s)", paths.ENV_PLUGINS_DIR),
+	)
+	rootCmd.PersistentFlags().StringVarP(
+		&locations.ConfigFile,
+		"config",
+		"c",
+		"",
+		fmt.Sprintf("hcl config file, overrides home layout (env %s)", paths.ENV_CONFIG_FILE),
+	)
 
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
 	rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 }
-
-// defaultConfigAbs returns the absolute path of the default config file,
-// and an error if any occurs.
-func defaultConfigAbs() (string, error) {
-	execPath, err := os.Executable()
-	if err != nil {
-		return "", fmt.Errorf("default config abs: %w", err)
-	}
-
-	execDir := filepath.Dir(execPath)
-	return filepath.Join(execDir, defaultConfigFile), nil
-}
-
-// pluginsBinDirAbs returns the absolute path of the plugins bin directory
-func pluginsBinDirAbs() (string, error) {
-	execPath, err := os.Executable()
-	if err != nil {
-		return "", fmt.Errorf("plugins bin dir abs: %w", err)
-	}
-
-	return filepath.Join(filepath.Dir(execPath), defaultPluginsBinDir), nil
-}
@@ -0,0 +1,80 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/liuminhaw/mist-miner/cmd/mmerr"
+	"github.com/liuminhaw/mist-miner/shelf"
+	"github.com/spf13/cobra"
+)
+
+// searchCmd represents the search command
+var searchCmd = &cobra.Command{
+	Use:   "search <group> <query>",
+	Short: "Search resources by identifiers, aliases, property values and diary notes",
+	Long: `Search resources of a group in the search index. Words of the query are matched
+case-insensitively against identifiers, aliases, property labels, json leaf values and
+diary notes, every word must match. A word ending with * matches by prefix, ex. admin*.
+Values like 10.0.0.0/8 or ARNs can be searched as a whole or by their parts.
+
+Resources in HEAD are searched by default, use --all to search every version of resources
+in history. Each result shows the plugin, identifier, the newest label mark containing the
+resource and the matching property paths.
+
+The index is updated by mine and diary commit, run log reload to generate it for shelves
+mined by older versions.`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 2 {
+			return mmerr.NewArgsError(
+				mmerr.SearchCmdType,
+				fmt.Sprintf("accepts at least 2 args, received %d", len(args)),
+			)
+		}
+		group := args[0]
+		query := strings.Join(args[1:], " ")
+
+		results, err := shelf.Search(group, query, searchAll)
+		if err != nil {
+			return fmt.Errorf("search sub-command failed: %w", err)
+		}
+
+		for _, result := range results {
+			name := result.Identifier
+			if result.Alias != "" {
+				name = fmt.Sprintf("%s (%s)", result.Identifier, result.Alias)
+			}
+			mark, err := shelf.ReadMark(group, result.Mark)
+			if err != nil {
+				return fmt.Errorf("search sub-command failed: %w", err)
+			}
+			fmt.Printf(
+				"%s  %s  mark %s  %s\n",
+				result.Plugin,
+				name,
+				result.Mark[:12],
+				mark.TimeStamp.Local().Format("2006-01-02 15:04:05 -0700"),
+			)
+			for _, path := range result.Paths {
+				fmt.Printf("    %s\n", path)
+			}
+		}
+		if len(results) == 0 {
+			fmt.Println("No matching resources")
+		}
+
+		return nil
+	},
+}
+
+var searchAll bool
+
+func init() {
+	rootCmd.AddCommand(searchCmd)
+
+	searchCmd.Flags().BoolVarP(&searchAll, "all", "a", false, "search every version of resources in history")
+}
@@ -0,0 +1,165 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/liuminhaw/mist-miner/cmd/mmerr"
+	"github.com/liuminhaw/mist-miner/shared"
+	"github.com/liuminhaw/mist-miner/shelf"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// showCmd represents the show command
+var showCmd = &cobra.Command{
+	Use:   "show <group> <plugin> <identifier>",
+	Short: "Print a resource and its diary at a label mark",
+	Long: `Print the resource of given plugin and identifier together with its diary note.
+The resource is looked up in HEAD unless another label mark revision is given by --mark,
+or --at selects the newest label mark created at or before the given time.
+
+Output format is markdown by default, json and yaml are available for scripting.`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 3 {
+			return mmerr.NewArgsError(
+				mmerr.ShowCmdType,
+				fmt.Sprintf("accepts 3 args, received %d", len(args)),
+			)
+		}
+		switch showOutput {
+		case show_output_markdown, show_output_json, show_output_yaml:
+		default:
+			return mmerr.NewArgsError(
+				mmerr.ShowCmdType,
+				fmt.Sprintf("unknown output format %s, expect markdown, json or yaml", showOutput),
+			)
+		}
+		group, plugin, identifier := args[0], args[1], args[2]
+
+		var markHash string
+		var err error
+		if showAt != "" {
+			markHash, err = shelf.ResolveMarkAt(group, showAt)
+		} else {
+			markHash, err = shelf.ResolveMark(group, showMark)
+		}
+		if err != nil {
+			return fmt.Errorf("show sub-command failed: %w", err)
+		}
+
+		resource, err := shelf.LookupResource(group, markHash, plugin, identifier)
+		if err != nil {
+			return fmt.Errorf("show sub-command failed: %w", err)
+		}
+
+		switch showOutput {
+		case show_output_json:
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			if err := encoder.Encode(newShowDocument(group, resource)); err != nil {
+				return fmt.Errorf("show sub-command failed: %w", err)
+			}
+		case show_output_yaml:
+			encoder := yaml.NewEncoder(os.Stdout)
+			encoder.SetIndent(2)
+			if err := encoder.Encode(newShowDocument(group, resource)); err != nil {
+				return fmt.Errorf("show sub-command failed: %w", err)
+			}
+			if err := encoder.Close(); err != nil {
+				return fmt.Errorf("show sub-command failed: %w", err)
+			}
+		default:
+			content, err := renderResourceMarkdown(resource)
+			if err != nil {
+				return fmt.Errorf("show sub-command failed: %w", err)
+			}
+			fmt.Print(content)
+		}
+
+		return nil
+	},
+}
+
+const (
+	show_output_markdown = "markdown"
+	show_output_json     = "json"
+	show_output_yaml     = "yaml"
+)
+
+var (
+	showMark   string
+	showAt     string
+	showOutput string
+)
+
+func init() {
+	rootCmd.AddCommand(showCmd)
+
+	showCmd.Flags().StringVarP(&showMark, "mark", "m", shelf.SHELF_MARK_FILE, "label mark revision to look up the resource")
+	showCmd.Flags().StringVar(&showAt, "at", "", "look up the resource at given time, ex. 2026-09-01T00:00:00Z or 7d")
+	showCmd.Flags().StringVarP(&showOutput, "output", "o", show_output_markdown, "output format: markdown, json or yaml")
+	showCmd.MarkFlagsMutuallyExclusive("mark", "at")
+}
+
+// showDocument is the json and yaml output of show command
+type showDocument struct {
+	Group    string               `json:"group" yaml:"group"`
+	Plugin   string               `json:"plugin" yaml:"plugin"`
+	Mark     string               `json:"mark" yaml:"mark"`
+	Hash     string               `json:"hash" yaml:"hash"`
+	Resource shared.MinerResource `json:"resource" yaml:"resource"`
+	Diary    showDiary            `json:"diary" yaml:"diary"`
+}
+
+type showDiary struct {
+	Hash     string `json:"hash" yaml:"hash"`
+	NoteHash string `json:"noteHash" yaml:"noteHash"`
+	Prev     string `json:"prev" yaml:"prev"`
+	Curr     string `json:"curr" yaml:"curr"`
+	Note     string `json:"note" yaml:"note"`
+}
+
+func newShowDocument(group string, resource *shelf.MarkedResource) showDocument {
+	return showDocument{
+		Group:    group,
+		Plugin:   resource.Plugin,
+		Mark:     resource.Mark,
+		Hash:     resource.ResourceHash,
+		Resource: *resource.Resource,
+		Diary: showDiary{
+			Hash:     resource.DiaryHash,
+			NoteHash: resource.Diary.Hash,
+			Prev:     resource.Diary.Logs.Prev,
+			Curr:     resource.Diary.Logs.Curr,
+			Note:     resource.Note,
+		},
+	}
+}
+
+// renderResourceMarkdown renders the resource by MinerResource.RenderMarkdown
+// followed by the diary note.
+func renderResourceMarkdown(resource *shelf.MarkedResource) (string, error) {
+	content, err := resource.Resource.RenderMarkdown()
+	if err != nil {
+		return "", fmt.Errorf("render resource markdown: %w", err)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(content)
+	fmt.Fprintf(&sb, "\n## Diary\n\n")
+	if resource.Note == "" {
+		sb.WriteString("_No diary note_\n")
+	} else {
+		sb.WriteString(strings.TrimRight(resource.Note, "\n") + "\n")
+	}
+	fmt.Fprintf(&sb, "\n---\nplugin: %s, mark: %s\n", resource.Plugin, resource.Mark)
+
+	return sb.String(), nil
+}
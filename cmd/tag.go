@@ -0,0 +1,89 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/liuminhaw/mist-miner/cmd/mmerr"
+	"github.com/liuminhaw/mist-miner/shelf"
+	"github.com/spf13/cobra"
+)
+
+// tagCmd represents the tag command
+var tagCmd = &cobra.Command{
+	Use:   "tag <group> <name> [mark]",
+	Short: "Create, list or delete tags of label marks",
+	Long: `Create a tag pointing to a label mark, HEAD is tagged if mark is not given.
+Tags are stored under refs/tags and can be used wherever a label mark is accepted.
+
+  tag <group> --list
+  tag <group> --delete <name>`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		switch {
+		case tagList:
+			if len(args) != 1 {
+				return mmerr.NewArgsError(
+					mmerr.TagCmdType,
+					fmt.Sprintf("accepts 1 args with --list, received %d", len(args)),
+				)
+			}
+			tags, err := shelf.ListTags(args[0])
+			if err != nil {
+				return fmt.Errorf("tag sub-command failed: %w", err)
+			}
+			for _, tag := range tags {
+				fmt.Printf("%s  %s\n", tag.Mark, tag.Name)
+			}
+		case tagDelete:
+			if len(args) != 2 {
+				return mmerr.NewArgsError(
+					mmerr.TagCmdType,
+					fmt.Sprintf("accepts 2 args with --delete, received %d", len(args)),
+				)
+			}
+			if err := shelf.DeleteTag(args[0], args[1]); err != nil {
+				return fmt.Errorf("tag sub-command failed: %w", err)
+			}
+			fmt.Printf("Deleted tag %s\n", args[1])
+		default:
+			if len(args) < 2 || len(args) > 3 {
+				return mmerr.NewArgsError(
+					mmerr.TagCmdType,
+					fmt.Sprintf("accepts between 2 and 3 args, received %d", len(args)),
+				)
+			}
+			group, name := args[0], args[1]
+			rev := shelf.SHELF_MARK_FILE
+			if len(args) == 3 {
+				rev = args[2]
+			}
+
+			mark, err := shelf.ResolveMark(group, rev)
+			if err != nil {
+				return fmt.Errorf("tag sub-command failed: %w", err)
+			}
+			if err := shelf.CreateTag(group, name, mark); err != nil {
+				return fmt.Errorf("tag sub-command failed: %w", err)
+			}
+			fmt.Printf("Tagged %s as %s\n", mark, name)
+		}
+
+		return nil
+	},
+}
+
+var (
+	tagList   bool
+	tagDelete bool
+)
+
+func init() {
+	rootCmd.AddCommand(tagCmd)
+
+	tagCmd.Flags().BoolVarP(&tagList, "list", "l", false, "list tags of the group")
+	tagCmd.Flags().BoolVarP(&tagDelete, "delete", "d", false, "delete the tag of given name")
+	tagCmd.MarkFlagsMutuallyExclusive("list", "delete")
+}
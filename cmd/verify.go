@@ -0,0 +1,82 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/liuminhaw/mist-miner/cmd/mmerr"
+	"github.com/liuminhaw/mist-miner/shelf"
+	"github.com/spf13/cobra"
+)
+
+// verifyCmd represents the verify command
+var verifyCmd = &cobra.Command{
+	Use:   "verify <group> [mark]",
+	Short: "Verify signatures of a label mark and its ancestors",
+	Long: `Check the ed25519 signature of a label mark (default HEAD) and every ancestor
+against the trusted keys of the signing config block, then re-hash every object
+reachable from the label mark.
+
+Label marks are signed when written if key_file of the signing block is set. A
+snapshot is proved unaltered when every label mark of the chain is signed by a
+trusted key and all reachable objects hash to their hashes. Marks written before
+signing was enabled are reported unsigned, use --allow-unsigned to accept them.`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 1 || len(args) > 2 {
+			return mmerr.NewArgsError(
+				mmerr.VerifyCmdType,
+				fmt.Sprintf("accepts between 1 and 2 args, received %d", len(args)),
+			)
+		}
+		group := args[0]
+		rev := shelf.SHELF_MARK_FILE
+		if len(args) == 2 {
+			rev = args[1]
+		}
+
+		hash, err := shelf.ResolveMark(group, rev)
+		if err != nil {
+			return fmt.Errorf("verify sub-command failed: %w", err)
+		}
+		report, err := shelf.VerifyMark(group, hash)
+		if err != nil {
+			return fmt.Errorf("verify sub-command failed: %w", err)
+		}
+
+		failed := len(report.Issues)
+		for _, mark := range report.Marks {
+			switch mark.Status {
+			case shelf.MARK_SIGNED:
+				fmt.Printf("%s  %s by %s\n", mark.Hash, mark.Status, mark.Signer)
+			case shelf.MARK_UNSIGNED:
+				fmt.Printf("%s  %s\n", mark.Hash, mark.Status)
+				if !verifyAllowUnsigned {
+					failed++
+				}
+			default:
+				fmt.Printf("%s  %s %s\n", mark.Hash, mark.Status, mark.Signer)
+				failed++
+			}
+		}
+		for _, issue := range report.Issues {
+			fmt.Println(issue)
+		}
+		fmt.Printf("%d label marks, %d objects checked\n", len(report.Marks), report.Objects)
+
+		if failed > 0 {
+			return fmt.Errorf("verify sub-command failed: %d issues found", failed)
+		}
+		return nil
+	},
+}
+
+var verifyAllowUnsigned bool
+
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+
+	verifyCmd.Flags().BoolVar(&verifyAllowUnsigned, "allow-unsigned", false, "accept label marks without signature")
+}
@@ -1,12 +1,16 @@
 package locks
 
 const (
+	// Lock files are kept under a directory named by the hash of the shelf directory,
+	// so shelves of different homes do not block each other.
 	LINUX_DIR_PATH = "/var/lock/mist-miner"
 
-	OBJECTS_LOCKFILE         = "mm-objects.lock"
-	HISTORY_LOCKFILE         = "mm-history-logger.lock"
-	HISTORY_POINTER_LOCKFILE = "mm-history-pointer.lock"
-	REF_MARK_LOCKFILE        = "mm-refmark.lock"
+	OBJECTS_LOCKFILE          = "mm-objects.lock"
+	HISTORY_LOCKFILE          = "mm-history-logger.lock"
+	HISTORY_POINTER_LOCKFILE  = "mm-history-pointer.lock"
+	HISTORY_TIMELINE_LOCKFILE = "mm-history-timeline.lock"
+	HISTORY_SEARCH_LOCKFILE   = "mm-history-search.lock"
+	REF_MARK_LOCKFILE         = "mm-refmark.lock"
 
 	lock_retry_max_interval = 500
 	lock_retry_max_count    = 5
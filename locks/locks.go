@@ -1,26 +1,52 @@
 package locks
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io/fs"
 	"math/rand"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/gofrs/flock"
+	"github.com/liuminhaw/mist-miner/paths"
+	"github.com/liuminhaw/mist-miner/store"
 )
 
 type Lock struct {
 	Flock *flock.Flock
 	Path  string
+	// Name of the lock in the remote store
+	name string
+	// Owner written to the remote store while the lock is held
+	owner string
+	// Owner of the remote lock when it was last found held
+	holder string
+}
+
+// remote takes exclusive locks in the shelf store as well when it is shared
+// between machines, nil if the shelf is local.
+var remote store.Locker
+
+// SetRemote sets the store shared between machines in which exclusive locks are
+// also taken, nil for a local shelf.
+func SetRemote(l store.Locker) {
+	remote = l
 }
 
 // NewLock creates a new Lock object with the given group and filename.
 // the lock file will be created in the appropriate directory based on the OS.
-// Example of lock file path will be like: OS_DIR_PATH/GROUP/FILENAME
-// If group is empty, the lock file will be created in the root directory.
-// Example of lock file path will be like: OS_DIR_PATH/FILENAME
+// Example of lock file path will be like: OS_DIR_PATH/SHELF/GROUP/FILENAME
+// If group is empty, the lock file will be created in the shelf directory.
+// Example of lock file path will be like: OS_DIR_PATH/SHELF/FILENAME
+// SHELF is derived from the resolved shelf directory path.
 func NewLock(group, filename string) (Lock, error) {
 	filepath, err := lockPath(group, filename)
 	if err != nil {
@@ -28,11 +54,17 @@ func NewLock(group, filename string) (Lock, error) {
 	}
 
 	f := flock.New(filepath)
+	name := filename
+	if group != "" {
+		name = group + "/" + filename
+	}
 
-	return Lock{Flock: f, Path: filepath}, nil
+	return Lock{Flock: f, Path: filepath, name: name}, nil
 }
 
 // TryLock acquires an exclusive lock on the file. Is a wrapper for flock.Lock().
+// The lock is also taken in the remote store if set, so other machines sharing
+// the shelf are kept out.
 func (l *Lock) TryLock() error {
 	r := rand.New(rand.NewSource(time.Now().UnixNano()))
 
@@ -42,12 +74,23 @@ func (l *Lock) TryLock() error {
 			return fmt.Errorf("lock.TryLock failed: %w", err)
 		}
 		if locked {
-			return nil
+			remoteLocked, err := l.tryRemoteLock()
+			if err != nil {
+				l.Flock.Unlock()
+				return fmt.Errorf("lock.TryLock failed: %w", err)
+			}
+			if remoteLocked {
+				return nil
+			}
+			l.Flock.Unlock()
 		}
 
 		time.Sleep(time.Duration(r.Intn(lock_retry_max_interval)) * time.Millisecond)
 	}
 
+	if l.holder != "" {
+		return fmt.Errorf("%w Held by %s.", ErrIsLocked, l.holder)
+	}
 	return ErrIsLocked
 }
 
@@ -71,21 +114,92 @@ func (l *Lock) TryRLock() error {
 }
 
 // Unlock releases the lock on the file. Is a wrapper for flock.Unlock().
+// The remote lock taken by TryLock is released first.
 func (l *Lock) Unlock() error {
+	if l.owner != "" {
+		err := remote.Unlock(l.name, l.owner)
+		l.owner = ""
+		if err != nil {
+			l.Flock.Unlock()
+			return fmt.Errorf("lock.Unlock failed: %w", err)
+		}
+	}
 	return l.Flock.Unlock()
 }
 
+// tryRemoteLock takes the lock in the remote store, a lock left by a process of
+// this host which no longer runs is taken over.
+func (l *Lock) tryRemoteLock() (bool, error) {
+	if remote == nil {
+		return true, nil
+	}
+
+	owner := lockOwner()
+	holder, err := remote.TryLock(l.name, owner)
+	if errors.Is(err, store.ErrLocked) && staleOwner(holder) {
+		if err := remote.Unlock(l.name, holder); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return false, fmt.Errorf("release stale lock of %s: %w", holder, err)
+		}
+		holder, err = remote.TryLock(l.name, owner)
+	}
+	if errors.Is(err, store.ErrLocked) {
+		l.holder = holder
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	l.owner = owner
+	return true, nil
+}
+
+// lockOwner describes the current process as owner of remote locks by host name,
+// process id and time, ex. "host-a 1234 2026-09-01T00:00:00Z".
+func lockOwner() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s %d %s", host, os.Getpid(), time.Now().UTC().Format(time.RFC3339))
+}
+
+// staleOwner reports whether the owner is a process of this host which has exited.
+func staleOwner(owner string) bool {
+	fields := strings.Fields(owner)
+	if len(fields) < 2 {
+		return false
+	}
+	host, err := os.Hostname()
+	if err != nil || fields[0] != host {
+		return false
+	}
+	pid, err := strconv.Atoi(fields[1])
+	if err != nil || pid == os.Getpid() {
+		return false
+	}
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return true
+	}
+	return errors.Is(process.Signal(syscall.Signal(0)), os.ErrProcessDone)
+}
+
 // lockPath returns the full path of a lock file based on the OS and given filename.
 func lockPath(group, filename string) (string, error) {
 	osType := runtime.GOOS
 
+	namespace, err := shelfNamespace()
+	if err != nil {
+		return "", fmt.Errorf("lockPath(%s, %s): %w", group, filename, err)
+	}
+
 	var path string
 	switch osType {
 	case "linux":
 		if group == "" {
-			path = filepath.Join(LINUX_DIR_PATH, filename)
+			path = filepath.Join(LINUX_DIR_PATH, namespace, filename)
 		} else {
-			path = filepath.Join(LINUX_DIR_PATH, group, filename)
+			path = filepath.Join(LINUX_DIR_PATH, namespace, group, filename)
 		}
 	default:
 		return "", fmt.Errorf("lockPath(%s, %s): unsupported OS: %s", group, filename, osType)
@@ -95,3 +209,13 @@ func lockPath(group, filename string) (string, error) {
 
 	return path, nil
 }
+
+// shelfNamespace returns the lock directory name of the resolved shelf directory
+func shelfNamespace() (string, error) {
+	shelfDir, err := paths.ShelfDir()
+	if err != nil {
+		return "", fmt.Errorf("shelf namespace: %w", err)
+	}
+	sum := sha256.Sum256([]byte(shelfDir))
+	return hex.EncodeToString(sum[:8]), nil
+}
@@ -0,0 +1,125 @@
+package locks
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/liuminhaw/mist-miner/paths"
+	"github.com/liuminhaw/mist-miner/store"
+)
+
+// fakeLocker keeps remote locks in memory
+type fakeLocker struct {
+	mu    sync.Mutex
+	locks map[string]string
+}
+
+func (f *fakeLocker) TryLock(name, owner string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if holder, ok := f.locks[name]; ok {
+		return holder, store.ErrLocked
+	}
+	f.locks[name] = owner
+	return "", nil
+}
+
+func (f *fakeLocker) Unlock(name, owner string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.locks[name] != owner {
+		return store.ErrLocked
+	}
+	delete(f.locks, name)
+	return nil
+}
+
+func useRemote(t *testing.T) *fakeLocker {
+	t.Helper()
+
+	if _, err := lockPath("", OBJECTS_LOCKFILE); err != nil {
+		t.Skipf("file locks unsupported: %v", err)
+	}
+	paths.Set(paths.Settings{ShelfDir: t.TempDir()})
+	remote := &fakeLocker{locks: make(map[string]string)}
+	SetRemote(remote)
+	t.Cleanup(func() {
+		SetRemote(nil)
+		paths.Set(paths.Settings{})
+	})
+	return remote
+}
+
+func TestRemoteLock(t *testing.T) {
+	remote := useRemote(t)
+
+	lock, err := NewLock("grp", REF_MARK_LOCKFILE)
+	if err != nil {
+		t.Fatalf("NewLock: %v", err)
+	}
+	if err := lock.TryLock(); err != nil {
+		t.Fatalf("TryLock: %v", err)
+	}
+	host, _ := os.Hostname()
+	if owner := remote.locks["grp/"+REF_MARK_LOCKFILE]; !strings.HasPrefix(owner, fmt.Sprintf("%s %d ", host, os.Getpid())) {
+		t.Errorf("remote lock owner = %q, want this process", owner)
+	}
+	if err := lock.Unlock(); err != nil {
+		t.Fatalf("Unlock: %v", err)
+	}
+	if len(remote.locks) != 0 {
+		t.Errorf("remote locks after unlock = %v, want none", remote.locks)
+	}
+
+	// Shared locks only guard the local host
+	remote.locks[OBJECTS_LOCKFILE] = "other-host 1 2026-09-01T00:00:00Z"
+	lock, err = NewLock("", OBJECTS_LOCKFILE)
+	if err != nil {
+		t.Fatalf("NewLock: %v", err)
+	}
+	if err := lock.TryRLock(); err != nil {
+		t.Fatalf("TryRLock: %v", err)
+	}
+	lock.Unlock()
+
+	err = lock.TryLock()
+	if !errors.Is(err, ErrIsLocked) || !strings.Contains(err.Error(), "other-host 1") {
+		t.Errorf("TryLock held by other host error = %v, want %v naming the holder", err, ErrIsLocked)
+	}
+	if locked, _ := lock.Flock.TryLock(); !locked {
+		t.Errorf("file lock is kept after failing to take the remote lock")
+	}
+	lock.Flock.Unlock()
+}
+
+func TestRemoteLockStale(t *testing.T) {
+	remote := useRemote(t)
+
+	// Process of this host which has exited
+	cmd := exec.Command("true")
+	if err := cmd.Run(); err != nil {
+		t.Skipf("run true: %v", err)
+	}
+	host, _ := os.Hostname()
+	stale := fmt.Sprintf("%s %d 2026-09-01T00:00:00Z", host, cmd.Process.Pid)
+	remote.locks[OBJECTS_LOCKFILE] = stale
+
+	lock, err := NewLock("", OBJECTS_LOCKFILE)
+	if err != nil {
+		t.Fatalf("NewLock: %v", err)
+	}
+	if err := lock.TryLock(); err != nil {
+		t.Fatalf("TryLock over stale lock: %v", err)
+	}
+	defer lock.Unlock()
+	if owner := remote.locks[OBJECTS_LOCKFILE]; owner == stale {
+		t.Errorf("stale remote lock %q is not taken over", owner)
+	}
+}
@@ -0,0 +1,211 @@
+package paths
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	ENV_HOME        = "MIST_MINER_HOME"
+	ENV_SHELF_DIR   = "MIST_MINER_SHELF_DIR"
+	ENV_PLUGINS_DIR = "MIST_MINER_PLUGINS_DIR"
+	ENV_CONFIG_FILE = "MIST_MINER_CONFIG"
+
+	app_name = "mist-miner"
+
+	// Layout under a mist-miner home directory
+	home_shelf_dir   = ".miner"
+	home_plugins_dir = "plugins/bin"
+	home_config_file = "config.hcl"
+
+	// Layout under XDG base directories
+	xdg_shelf_dir   = "shelf"
+	xdg_plugins_dir = "plugins"
+	xdg_config_file = "config.hcl"
+)
+
+// Settings are the location overrides given by the user, empty fields are
+// resolved from environment variables or defaults.
+type Settings struct {
+	Home       string
+	ShelfDir   string
+	PluginsDir string
+	ConfigFile string
+}
+
+// Locations are the resolved absolute paths used by mist-miner.
+type Locations struct {
+	ShelfDir   string
+	PluginsDir string
+	ConfigFile string
+}
+
+var (
+	settings Settings
+
+	// Locations resolved from settings, nil until first resolved
+	resolved   *Locations
+	resolvedMu sync.Mutex
+)
+
+// Set sets the location overrides used by the resolver, locations are resolved
+// again on next use.
+func Set(s Settings) {
+	resolvedMu.Lock()
+	defer resolvedMu.Unlock()
+
+	settings = s
+	resolved = nil
+}
+
+// Resolve resolves locations of the shelf, plugins and config file.
+// Each location is decided in order of:
+//   - the specific override setting or environment variable
+//     (ex. --shelf-dir / MIST_MINER_SHELF_DIR)
+//   - the home setting or MIST_MINER_HOME, using the layout
+//     <home>/.miner, <home>/plugins/bin and <home>/config.hcl
+//   - the executable directory if it already contains a shelf or config file,
+//     which is where older versions kept them
+//   - XDG base directories: $XDG_DATA_HOME/mist-miner/shelf,
+//     $XDG_DATA_HOME/mist-miner/plugins and $XDG_CONFIG_HOME/mist-miner/config.hcl
+//
+// Locations are resolved once and cached until Set is called.
+func Resolve() (Locations, error) {
+	resolvedMu.Lock()
+	defer resolvedMu.Unlock()
+
+	if resolved != nil {
+		return *resolved, nil
+	}
+	loc, err := resolve()
+	if err != nil {
+		return Locations{}, err
+	}
+	resolved = &loc
+	return loc, nil
+}
+
+func resolve() (Locations, error) {
+	home, err := resolveHome()
+	if err != nil {
+		return Locations{}, fmt.Errorf("resolve locations: %w", err)
+	}
+
+	var loc Locations
+	if home != "" {
+		loc = Locations{
+			ShelfDir:   filepath.Join(home, home_shelf_dir),
+			PluginsDir: filepath.Join(home, home_plugins_dir),
+			ConfigFile: filepath.Join(home, home_config_file),
+		}
+	} else {
+		dataHome, err := xdgDir("XDG_DATA_HOME", ".local/share")
+		if err != nil {
+			return Locations{}, fmt.Errorf("resolve locations: %w", err)
+		}
+		configHome, err := xdgDir("XDG_CONFIG_HOME", ".config")
+		if err != nil {
+			return Locations{}, fmt.Errorf("resolve locations: %w", err)
+		}
+		loc = Locations{
+			ShelfDir:   filepath.Join(dataHome, app_name, xdg_shelf_dir),
+			PluginsDir: filepath.Join(dataHome, app_name, xdg_plugins_dir),
+			ConfigFile: filepath.Join(configHome, app_name, xdg_config_file),
+		}
+	}
+
+	if dir := override(settings.ShelfDir, ENV_SHELF_DIR); dir != "" {
+		loc.ShelfDir = dir
+	}
+	if dir := override(settings.PluginsDir, ENV_PLUGINS_DIR); dir != "" {
+		loc.PluginsDir = dir
+	}
+	if file := override(settings.ConfigFile, ENV_CONFIG_FILE); file != "" {
+		loc.ConfigFile = file
+	}
+
+	for _, p := range []*string{&loc.ShelfDir, &loc.PluginsDir, &loc.ConfigFile} {
+		if *p, err = filepath.Abs(*p); err != nil {
+			return Locations{}, fmt.Errorf("resolve locations: %w", err)
+		}
+	}
+
+	return loc, nil
+}
+
+// ShelfDir returns the directory path storing shelf records of all groups.
+func ShelfDir() (string, error) {
+	loc, err := Resolve()
+	if err != nil {
+		return "", err
+	}
+	return loc.ShelfDir, nil
+}
+
+// PluginsDir returns the directory path of plugin binaries.
+func PluginsDir() (string, error) {
+	loc, err := Resolve()
+	if err != nil {
+		return "", err
+	}
+	return loc.PluginsDir, nil
+}
+
+// ConfigFile returns the path of the config file.
+func ConfigFile() (string, error) {
+	loc, err := Resolve()
+	if err != nil {
+		return "", err
+	}
+	return loc.ConfigFile, nil
+}
+
+// resolveHome returns the mist-miner home directory from setting, environment variable
+// or executable directory with existing shelf or config, empty string is returned
+// if no home directory is found.
+func resolveHome() (string, error) {
+	if home := override(settings.Home, ENV_HOME); home != "" {
+		return home, nil
+	}
+
+	execPath, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("resolve home: get executable: %w", err)
+	}
+	execDir := filepath.Dir(execPath)
+	for _, name := range []string{home_shelf_dir, home_config_file} {
+		if _, err := os.Stat(filepath.Join(execDir, name)); err == nil {
+			return execDir, nil
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return "", fmt.Errorf("resolve home: %w", err)
+		}
+	}
+
+	return "", nil
+}
+
+// xdgDir returns the XDG base directory from environment variable env,
+// or fallback under user home directory if not set.
+func xdgDir(env, fallback string) (string, error) {
+	if dir := os.Getenv(env); dir != "" {
+		return dir, nil
+	}
+
+	userHome, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("xdg dir %s: %w", env, err)
+	}
+	return filepath.Join(userHome, fallback), nil
+}
+
+// override returns value if set, otherwise value of the environment variable env.
+func override(value, env string) string {
+	if value != "" {
+		return value
+	}
+	return os.Getenv(env)
+}
@@ -19,7 +19,9 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	MinerService_Mine_FullMethodName = "/proto.MinerService/Mine"
+	MinerService_Mine_FullMethodName       = "/proto.MinerService/Mine"
+	MinerService_MineStream_FullMethodName = "/proto.MinerService/MineStream"
+	MinerService_Describe_FullMethodName   = "/proto.MinerService/Describe"
 )
 
 // MinerServiceClient is the client API for MinerService service.
@@ -27,6 +29,10 @@ const (
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type MinerServiceClient interface {
 	Mine(ctx context.Context, in *MinerConfig, opts ...grpc.CallOption) (*MinerResources, error)
+	// MineStream sends mined resources one at a time
+	MineStream(ctx context.Context, in *MinerConfig, opts ...grpc.CallOption) (grpc.ServerStreamingClient[MinerResource], error)
+	// Describe reports the plugin version, the config it accepts and the properties it emits
+	Describe(ctx context.Context, in *NoParam, opts ...grpc.CallOption) (*PluginDescription, error)
 }
 
 type minerServiceClient struct {
@@ -47,11 +53,44 @@ func (c *minerServiceClient) Mine(ctx context.Context, in *MinerConfig, opts ...
 	return out, nil
 }
 
+func (c *minerServiceClient) MineStream(ctx context.Context, in *MinerConfig, opts ...grpc.CallOption) (grpc.ServerStreamingClient[MinerResource], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &MinerService_ServiceDesc.Streams[0], MinerService_MineStream_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[MinerConfig, MinerResource]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type MinerService_MineStreamClient = grpc.ServerStreamingClient[MinerResource]
+
+func (c *minerServiceClient) Describe(ctx context.Context, in *NoParam, opts ...grpc.CallOption) (*PluginDescription, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PluginDescription)
+	err := c.cc.Invoke(ctx, MinerService_Describe_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // MinerServiceServer is the server API for MinerService service.
 // All implementations should embed UnimplementedMinerServiceServer
 // for forward compatibility.
 type MinerServiceServer interface {
 	Mine(context.Context, *MinerConfig) (*MinerResources, error)
+	// MineStream sends mined resources one at a time
+	MineStream(*MinerConfig, grpc.ServerStreamingServer[MinerResource]) error
+	// Describe reports the plugin version, the config it accepts and the properties it emits
+	Describe(context.Context, *NoParam) (*PluginDescription, error)
 }
 
 // UnimplementedMinerServiceServer should be embedded to have
@@ -64,6 +103,12 @@ type UnimplementedMinerServiceServer struct{}
 func (UnimplementedMinerServiceServer) Mine(context.Context, *MinerConfig) (*MinerResources, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Mine not implemented")
 }
+func (UnimplementedMinerServiceServer) MineStream(*MinerConfig, grpc.ServerStreamingServer[MinerResource]) error {
+	return status.Errorf(codes.Unimplemented, "method MineStream not implemented")
+}
+func (UnimplementedMinerServiceServer) Describe(context.Context, *NoParam) (*PluginDescription, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Describe not implemented")
+}
 func (UnimplementedMinerServiceServer) testEmbeddedByValue() {}
 
 // UnsafeMinerServiceServer may be embedded to opt out of forward compatibility for this service.
@@ -102,6 +147,35 @@ func _MinerService_Mine_Handler(srv interface{}, ctx context.Context, dec func(i
 	return interceptor(ctx, in, info, handler)
 }
 
+func _MinerService_MineStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(MinerConfig)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(MinerServiceServer).MineStream(m, &grpc.GenericServerStream[MinerConfig, MinerResource]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type MinerService_MineStreamServer = grpc.ServerStreamingServer[MinerResource]
+
+func _MinerService_Describe_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(NoParam)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MinerServiceServer).Describe(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MinerService_Describe_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MinerServiceServer).Describe(ctx, req.(*NoParam))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // MinerService_ServiceDesc is the grpc.ServiceDesc for MinerService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -113,7 +187,17 @@ var MinerService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Mine",
 			Handler:    _MinerService_Mine_Handler,
 		},
+		{
+			MethodName: "Describe",
+			Handler:    _MinerService_Describe_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "MineStream",
+			Handler:       _MinerService_MineStream_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "proto/miner.proto",
 }
@@ -0,0 +1,71 @@
+package query
+
+import (
+	"sort"
+
+	"github.com/liuminhaw/mist-miner/shared"
+)
+
+// Key of resources without any value selected
+const count_none_key = "(none)"
+
+// ValueCount is the number of resources having a selected value, Key is the value
+// formatted by FormatValue.
+type ValueCount struct {
+	Value any
+	Key   string
+	Count int
+}
+
+// Counter counts resources by values of a selector, a resource counts once for
+// each distinct value it has.
+type Counter struct {
+	sel    Selector
+	counts []*ValueCount
+	byKey  map[string]*ValueCount
+}
+
+func NewCounter(sel Selector) *Counter {
+	return &Counter{sel: sel, byKey: make(map[string]*ValueCount)}
+}
+
+// Add counts the values selected from the resource of the plugin.
+func (c *Counter) Add(plugin string, resource *shared.MinerResource) {
+	values := c.sel.Values(plugin, resource)
+	if len(values) == 0 {
+		c.count(nil, count_none_key)
+		return
+	}
+
+	seen := make(map[string]bool)
+	for _, value := range values {
+		key := FormatValue(value)
+		if !seen[key] {
+			seen[key] = true
+			c.count(value, key)
+		}
+	}
+}
+
+// Counts returns the counts in descending order, ties are ordered by key.
+func (c *Counter) Counts() []ValueCount {
+	counts := make([]ValueCount, 0, len(c.counts))
+	for _, count := range c.counts {
+		counts = append(counts, *count)
+	}
+	sort.SliceStable(counts, func(i, j int) bool {
+		if counts[i].Count == counts[j].Count {
+			return counts[i].Key < counts[j].Key
+		}
+		return counts[i].Count > counts[j].Count
+	})
+	return counts
+}
+
+func (c *Counter) count(value any, key string) {
+	if _, ok := c.byKey[key]; !ok {
+		c.byKey[key] = &ValueCount{Value: value, Key: key}
+		c.counts = append(c.counts, c.byKey[key])
+	}
+	c.byKey[key].Count++
+}
@@ -0,0 +1,58 @@
+package query
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/liuminhaw/mist-miner/shared"
+)
+
+func TestCounter(t *testing.T) {
+	tagged := func(values ...string) *shared.MinerResource {
+		resource := &shared.MinerResource{Identifier: "res"}
+		if values == nil {
+			return resource
+		}
+		tags := []string{}
+		for _, value := range values {
+			tags = append(tags, fmt.Sprintf(`{"Value":%q}`, value))
+		}
+		resource.Properties = []shared.MinerProperty{{
+			Type:  "detail",
+			Label: shared.MinerPropertyLabel{Name: "Detail", Unique: true},
+			Content: shared.MinerPropertyContent{
+				Format: shared.FormatJson,
+				Value:  fmt.Sprintf(`{"Tags":[%s]}`, strings.Join(tags, ",")),
+			},
+		}}
+		return resource
+	}
+
+	sel, err := ParseSelector("Detail$.Tags[*].Value")
+	if err != nil {
+		t.Fatalf("ParseSelector: %v", err)
+	}
+	counter := NewCounter(sel)
+	for _, resource := range []*shared.MinerResource{
+		tagged("ops", "prod"),
+		tagged("dev", "dev"),
+		tagged("ops"),
+		tagged(),
+		tagged("prod"),
+		tagged("dev"),
+	} {
+		counter.Add("aws-iam", resource)
+	}
+
+	want := []ValueCount{
+		{Value: "dev", Key: "dev", Count: 2},
+		{Value: "ops", Key: "ops", Count: 2},
+		{Value: "prod", Key: "prod", Count: 2},
+		{Value: nil, Key: "(none)", Count: 1},
+	}
+	if got := counter.Counts(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Counts() = %+v, want %+v", got, want)
+	}
+}
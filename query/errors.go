@@ -0,0 +1,8 @@
+package query
+
+import "errors"
+
+var (
+	ErrSyntax          = errors.New("query syntax error")
+	ErrInvalidArgument = errors.New("invalid query argument")
+)
@@ -0,0 +1,359 @@
+package query
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/liuminhaw/mist-miner/shared"
+)
+
+// env is the evaluation environment of a resource
+type env struct {
+	plugin   string
+	resource *shared.MinerResource
+	now      time.Time
+	regexps  map[string]*regexp.Regexp
+}
+
+// functions maps function names to their implementation, arguments are evaluated
+// before calling.
+var functions = map[string]func(e *env, args [][]any) ([]any, error){
+	// ago(90d) is the time 90 days before the query is compiled
+	"ago": func(e *env, args [][]any) ([]any, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("ago accepts 1 argument: %w", ErrInvalidArgument)
+		}
+		spec, err := stringArg("ago", args, 0)
+		if err != nil {
+			return nil, err
+		}
+		d, err := shared.ParseDuration(spec)
+		if err != nil {
+			return nil, fmt.Errorf("ago(%s): %w", spec, ErrInvalidArgument)
+		}
+		return []any{e.now.Add(-d)}, nil
+	},
+	// now() is the time the query is compiled
+	"now": func(e *env, args [][]any) ([]any, error) {
+		return []any{e.now}, nil
+	},
+	// date("2026-09-01") is a time given in RFC3339, date or duration before now
+	"date": func(e *env, args [][]any) ([]any, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("date accepts 1 argument: %w", ErrInvalidArgument)
+		}
+		spec, err := stringArg("date", args, 0)
+		if err != nil {
+			return nil, err
+		}
+		t, err := shared.ParseTimeSpec(spec, e.now)
+		if err != nil {
+			return nil, fmt.Errorf("date(%s): %w", spec, ErrInvalidArgument)
+		}
+		return []any{t}, nil
+	},
+	// exists(x) is true if x selects any value
+	"exists": func(e *env, args [][]any) ([]any, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("exists accepts 1 argument: %w", ErrInvalidArgument)
+		}
+		return []any{len(args[0]) > 0}, nil
+	},
+	// count(x) is the number of values selected by x
+	"count": func(e *env, args [][]any) ([]any, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("count accepts 1 argument: %w", ErrInvalidArgument)
+		}
+		return []any{float64(len(args[0]))}, nil
+	},
+	// len(x) is the length of each string, array or object selected by x
+	"len": func(e *env, args [][]any) ([]any, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("len accepts 1 argument: %w", ErrInvalidArgument)
+		}
+		values := []any{}
+		for _, value := range args[0] {
+			switch v := value.(type) {
+			case string:
+				values = append(values, float64(len(v)))
+			case []any:
+				values = append(values, float64(len(v)))
+			case map[string]any:
+				values = append(values, float64(len(v)))
+			}
+		}
+		return values, nil
+	},
+	// lower(x) is each string selected by x in lower case
+	"lower": func(e *env, args [][]any) ([]any, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("lower accepts 1 argument: %w", ErrInvalidArgument)
+		}
+		values := []any{}
+		for _, value := range args[0] {
+			if s, ok := value.(string); ok {
+				values = append(values, strings.ToLower(s))
+			}
+		}
+		return values, nil
+	},
+}
+
+func stringArg(name string, args [][]any, i int) (string, error) {
+	if len(args) <= i || len(args[i]) != 1 {
+		return "", fmt.Errorf("%s expects a single argument: %w", name, ErrInvalidArgument)
+	}
+	s, ok := args[i][0].(string)
+	if !ok {
+		return "", fmt.Errorf("%s expects a string argument: %w", name, ErrInvalidArgument)
+	}
+	return s, nil
+}
+
+func (n literalNode) eval(e *env) ([]any, error) {
+	return []any{n.value}, nil
+}
+
+func (n selectorNode) eval(e *env) ([]any, error) {
+	return n.sel.Values(e.plugin, e.resource), nil
+}
+
+func (n callNode) eval(e *env) ([]any, error) {
+	args := make([][]any, 0, len(n.args))
+	for _, arg := range n.args {
+		values, err := arg.eval(e)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, values)
+	}
+	return functions[n.name](e, args)
+}
+
+// eval of comparison is true if any pair of left and right values satisfies the operator,
+// so comparisons against missing values are false.
+func (n compareNode) eval(e *env) ([]any, error) {
+	left, err := n.left.eval(e)
+	if err != nil {
+		return nil, err
+	}
+	right, err := n.right.eval(e)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, l := range left {
+		for _, r := range right {
+			ok, err := compare(e, n.op, l, r)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				return []any{true}, nil
+			}
+		}
+	}
+	return []any{false}, nil
+}
+
+func (n logicalNode) eval(e *env) ([]any, error) {
+	left, err := n.left.eval(e)
+	if err != nil {
+		return nil, err
+	}
+	if n.op == "and" && !truthy(left) {
+		return []any{false}, nil
+	}
+	if n.op == "or" && truthy(left) {
+		return []any{true}, nil
+	}
+
+	right, err := n.right.eval(e)
+	if err != nil {
+		return nil, err
+	}
+	return []any{truthy(right)}, nil
+}
+
+func (n notNode) eval(e *env) ([]any, error) {
+	values, err := n.operand.eval(e)
+	if err != nil {
+		return nil, err
+	}
+	return []any{!truthy(values)}, nil
+}
+
+// truthy is true if any value is true, a non zero number, a non empty string,
+// array or object, or a time.
+func truthy(values []any) bool {
+	for _, value := range values {
+		switch v := value.(type) {
+		case bool:
+			if v {
+				return true
+			}
+		case float64:
+			if v != 0 {
+				return true
+			}
+		case string:
+			if v != "" {
+				return true
+			}
+		case []any:
+			if len(v) > 0 {
+				return true
+			}
+		case map[string]any:
+			if len(v) > 0 {
+				return true
+			}
+		case time.Time:
+			return true
+		}
+	}
+	return false
+}
+
+// compare compares two values by the operator. Times are compared with strings parsed
+// as time, numbers with strings parsed as number, otherwise values of different types
+// are only unequal.
+func compare(e *env, op string, left, right any) (bool, error) {
+	if op == "=~" {
+		pattern, ok := right.(string)
+		if !ok {
+			return false, fmt.Errorf("=~ expects a string pattern: %w", ErrInvalidArgument)
+		}
+		re, ok := e.regexps[pattern]
+		if !ok {
+			var err error
+			if re, err = regexp.Compile(pattern); err != nil {
+				return false, fmt.Errorf("=~ %q: %w", pattern, ErrInvalidArgument)
+			}
+			e.regexps[pattern] = re
+		}
+		return re.MatchString(FormatValue(left)), nil
+	}
+
+	var order int
+	switch {
+	case isTime(left) || isTime(right):
+		l, lok := toTime(left)
+		r, rok := toTime(right)
+		if !lok || !rok {
+			return op == "!=", nil
+		}
+		order = l.Compare(r)
+	case isNumber(left) || isNumber(right):
+		l, lok := toNumber(left)
+		r, rok := toNumber(right)
+		if !lok || !rok {
+			return op == "!=", nil
+		}
+		switch {
+		case l < r:
+			order = -1
+		case l > r:
+			order = 1
+		}
+	default:
+		l, lok := left.(string)
+		r, rok := right.(string)
+		if !lok || !rok {
+			equal := reflect.DeepEqual(left, right)
+			switch op {
+			case "==":
+				return equal, nil
+			case "!=":
+				return !equal, nil
+			}
+			return false, nil
+		}
+		order = strings.Compare(l, r)
+	}
+
+	switch op {
+	case "==":
+		return order == 0, nil
+	case "!=":
+		return order != 0, nil
+	case "<":
+		return order < 0, nil
+	case "<=":
+		return order <= 0, nil
+	case ">":
+		return order > 0, nil
+	case ">=":
+		return order >= 0, nil
+	}
+	return false, fmt.Errorf("unknown operator %s: %w", op, ErrSyntax)
+}
+
+func isTime(value any) bool {
+	_, ok := value.(time.Time)
+	return ok
+}
+
+func isNumber(value any) bool {
+	_, ok := value.(float64)
+	return ok
+}
+
+func toTime(value any) (time.Time, bool) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, true
+	case string:
+		if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			return t, true
+		}
+		if t, err := time.ParseInLocation(time.DateOnly, v, time.Local); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func toNumber(value any) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case string:
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return f, true
+		}
+	}
+	return 0, false
+}
+
+// FormatValue formats a selected value for display, strings are printed as is,
+// times in RFC3339 and other values in json.
+func FormatValue(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case time.Time:
+		return v.Format(time.RFC3339)
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprintf("%v", v)
+		}
+		return string(b)
+	}
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
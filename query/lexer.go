@@ -0,0 +1,213 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	token_eof tokenKind = iota
+	token_selector
+	token_ident
+	token_string
+	token_number
+	token_duration
+	token_op
+	token_lparen
+	token_rparen
+	token_comma
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	// Position of the token in source
+	pos int
+}
+
+// lex splits the query source into tokens. Selectors are lexed as a whole,
+// ex. UserDetail$.Tags[0].Value, ["User Detail"]$.Arn or @identifier.
+func lex(src string) ([]token, error) {
+	tokens := []token{}
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			tokens = append(tokens, token{kind: token_lparen, text: "(", pos: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{kind: token_rparen, text: ")", pos: i})
+			i++
+		case c == ',':
+			tokens = append(tokens, token{kind: token_comma, text: ",", pos: i})
+			i++
+		case c == '"' || c == '\'':
+			text, end, err := lexString(src, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: token_string, text: text, pos: i})
+			i = end
+		case strings.ContainsRune("=!<>&|", rune(c)):
+			end := i + 1
+			if end < len(src) && strings.ContainsRune("=~&|", rune(src[end])) {
+				end++
+			}
+			op := src[i:end]
+			switch op {
+			case "==", "!=", "<", "<=", ">", ">=", "=~", "&&", "||", "!":
+			case "=":
+				op = "=="
+			default:
+				return nil, fmt.Errorf("position %d: unknown operator %s: %w", i, op, ErrSyntax)
+			}
+			tokens = append(tokens, token{kind: token_op, text: op, pos: i})
+			i = end
+		case c == '-' || c >= '0' && c <= '9':
+			end := i + 1
+			for end < len(src) && (src[end] >= '0' && src[end] <= '9' || src[end] == '.' ||
+				src[end] == 'e' || src[end] == 'E') {
+				end++
+			}
+			kind := token_number
+			if end < len(src) && strings.ContainsRune("smhdw", rune(src[end])) &&
+				(end+1 == len(src) || !isIdentByte(src[end+1])) {
+				end++
+				kind = token_duration
+			}
+			if src[i:end] == "-" {
+				return nil, fmt.Errorf("position %d: unexpected -: %w", i, ErrSyntax)
+			}
+			// Labels may start with a digit, ex. 2FA$.Enabled
+			if c != '-' && end < len(src) && (isIdentByte(src[end]) || src[end] == '$') {
+				tok, labelEnd, err := lexLabel(src, i)
+				if err != nil {
+					return nil, err
+				}
+				if labelEnd <= end {
+					return nil, fmt.Errorf("position %d: unexpected character %q: %w", end, src[end], ErrSyntax)
+				}
+				tokens = append(tokens, tok)
+				i = labelEnd
+				continue
+			}
+			tokens = append(tokens, token{kind: kind, text: src[i:end], pos: i})
+			i = end
+		case c == '@':
+			end := i + 1
+			for end < len(src) && isIdentByte(src[end]) {
+				end++
+			}
+			tokens = append(tokens, token{kind: token_selector, text: src[i:end], pos: i})
+			i = end
+		case c == '[' || isIdentByte(c):
+			tok, end, err := lexLabel(src, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, tok)
+			i = end
+		default:
+			return nil, fmt.Errorf("position %d: unexpected character %q: %w", i, c, ErrSyntax)
+		}
+	}
+
+	return append(tokens, token{kind: token_eof, pos: len(src)}), nil
+}
+
+// lexLabel returns the selector starting at i and its end position, a label
+// without JSONPath is an ident as it may also be a keyword or function name.
+func lexLabel(src string, i int) (token, int, error) {
+	end, err := lexSelector(src, i)
+	if err != nil {
+		return token{}, 0, err
+	}
+	text := src[i:end]
+	kind := token_selector
+	if src[i] != '[' && !strings.Contains(text, "$") {
+		kind = token_ident
+	}
+	return token{kind: kind, text: text, pos: i}, end, nil
+}
+
+// lexString returns the unquoted content of the string starting at i and the end position,
+// backslash escapes the next character.
+func lexString(src string, i int) (string, int, error) {
+	if i >= len(src) || src[i] != '"' && src[i] != '\'' {
+		return "", 0, fmt.Errorf("position %d: expect quoted string: %w", i, ErrSyntax)
+	}
+	quote := src[i]
+	var sb strings.Builder
+	for j := i + 1; j < len(src); j++ {
+		switch src[j] {
+		case '\\':
+			if j+1 < len(src) {
+				j++
+				sb.WriteByte(src[j])
+			}
+		case quote:
+			return sb.String(), j + 1, nil
+		default:
+			sb.WriteByte(src[j])
+		}
+	}
+	return "", 0, fmt.Errorf("position %d: unterminated string: %w", i, ErrSyntax)
+}
+
+// lexSelector returns the end position of a label, optionally in bracket form,
+// followed by a JSONPath starting with $.
+func lexSelector(src string, i int) (int, error) {
+	end := i
+	if src[i] == '[' {
+		if i+1 >= len(src) || src[i+1] != '"' && src[i+1] != '\'' {
+			return 0, fmt.Errorf("position %d: expect quoted label in brackets: %w", i, ErrSyntax)
+		}
+		_, strEnd, err := lexString(src, i+1)
+		if err != nil {
+			return 0, err
+		}
+		if strEnd >= len(src) || src[strEnd] != ']' {
+			return 0, fmt.Errorf("position %d: expect ] after label: %w", i, ErrSyntax)
+		}
+		end = strEnd + 1
+	} else {
+		for end < len(src) && isIdentByte(src[end]) {
+			end++
+		}
+	}
+	if end >= len(src) || src[end] != '$' {
+		return end, nil
+	}
+
+	// JSONPath continues until a space or an operator outside brackets
+	depth := 0
+	for end < len(src) {
+		c := src[end]
+		switch {
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+		case c == '"' || c == '\'':
+			_, strEnd, err := lexString(src, end)
+			if err != nil {
+				return 0, err
+			}
+			end = strEnd
+			continue
+		case depth == 0 && (unicode.IsSpace(rune(c)) || strings.ContainsRune("=!<>&|(),", rune(c))):
+			return end, nil
+		}
+		end++
+	}
+	return end, nil
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c == '-' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
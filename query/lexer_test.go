@@ -0,0 +1,121 @@
+package query
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestLex(t *testing.T) {
+	tests := []struct {
+		src  string
+		want []token
+	}{
+		{
+			src: `UserDetail$.PasswordLastUsed < ago(90d)`,
+			want: []token{
+				{kind: token_selector, text: "UserDetail$.PasswordLastUsed", pos: 0},
+				{kind: token_op, text: "<", pos: 29},
+				{kind: token_ident, text: "ago", pos: 31},
+				{kind: token_lparen, text: "(", pos: 34},
+				{kind: token_duration, text: "90d", pos: 35},
+				{kind: token_rparen, text: ")", pos: 38},
+			},
+		},
+		{
+			src: `["User Detail"]$.Tags["a b"] = 'x\'y'`,
+			want: []token{
+				{kind: token_selector, text: `["User Detail"]$.Tags["a b"]`, pos: 0},
+				{kind: token_op, text: "==", pos: 29},
+				{kind: token_string, text: "x'y", pos: 31},
+			},
+		},
+		{
+			src: `@alias != "" && count(Policy) >= -1.5e2`,
+			want: []token{
+				{kind: token_selector, text: "@alias", pos: 0},
+				{kind: token_op, text: "!=", pos: 7},
+				{kind: token_string, text: "", pos: 10},
+				{kind: token_op, text: "&&", pos: 13},
+				{kind: token_ident, text: "count", pos: 16},
+				{kind: token_lparen, text: "(", pos: 21},
+				{kind: token_ident, text: "Policy", pos: 22},
+				{kind: token_rparen, text: ")", pos: 28},
+				{kind: token_op, text: ">=", pos: 30},
+				{kind: token_number, text: "-1.5e2", pos: 33},
+			},
+		},
+		{
+			src: `2FA$.Enabled or 1Password == 7days`,
+			want: []token{
+				{kind: token_selector, text: "2FA$.Enabled", pos: 0},
+				{kind: token_ident, text: "or", pos: 13},
+				{kind: token_ident, text: "1Password", pos: 16},
+				{kind: token_op, text: "==", pos: 26},
+				{kind: token_ident, text: "7days", pos: 29},
+			},
+		},
+		{
+			src: `90d$.x,1e5,3w`,
+			want: []token{
+				{kind: token_selector, text: "90d$.x", pos: 0},
+				{kind: token_comma, text: ",", pos: 6},
+				{kind: token_number, text: "1e5", pos: 7},
+				{kind: token_comma, text: ",", pos: 10},
+				{kind: token_duration, text: "3w", pos: 11},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := lex(tt.src)
+		if err != nil {
+			t.Errorf("lex(%s): %v", tt.src, err)
+			continue
+		}
+		want := append(tt.want, token{kind: token_eof, pos: len(tt.src)})
+		if len(got) != len(want) {
+			t.Errorf("lex(%s) = %+v, want %+v", tt.src, got, want)
+			continue
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("lex(%s) token %d = %+v, want %+v", tt.src, i, got[i], want[i])
+			}
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		src string
+		// Expected error message prefix
+		want string
+	}{
+		{src: `Policy == "x`, want: "position 10: unterminated string"},
+		{src: `Policy = = 1`, want: "position 9: unexpected =="},
+		{src: `Policy ~ 1`, want: "position 7: unexpected character '~'"},
+		{src: `Policy & 1`, want: "position 7: unknown operator &"},
+		{src: `Policy == - 1`, want: "position 10: unexpected -"},
+		{src: `1.2a == 1`, want: "position 3: unexpected character 'a'"},
+		{src: `(Policy == 1`, want: "position 12: expect )"},
+		{src: `Policy ==`, want: "position 9: unexpected end of query"},
+		{src: `Policy 1`, want: "position 7: unexpected 1"},
+		{src: `foo(1)`, want: "position 0: unknown function foo"},
+		{src: `ago(90d 1)`, want: "position 8: expect , or )"},
+		{src: `@owner == ""`, want: "position 0: unknown field @owner"},
+		{src: `Detail$.a[x] == 1`, want: "position 0: selector Detail$.a[x]: invalid index [x]"},
+		{src: `['Detail' == 1`, want: "position 0: expect ] after label"},
+	}
+
+	for _, tt := range tests {
+		_, err := parse(tt.src)
+		if !errors.Is(err, ErrSyntax) {
+			t.Errorf("parse(%s) error = %v, want %v", tt.src, err, ErrSyntax)
+			continue
+		}
+		if !strings.HasPrefix(err.Error(), tt.want) {
+			t.Errorf("parse(%s) error = %q, want prefix %q", tt.src, err, tt.want)
+		}
+	}
+}
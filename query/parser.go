@@ -0,0 +1,242 @@
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// node is an expression node evaluating to a list of values for a resource,
+// comparisons and logical operators evaluate to a single bool.
+type node interface {
+	eval(env *env) ([]any, error)
+}
+
+type literalNode struct {
+	value any
+}
+
+type selectorNode struct {
+	sel Selector
+}
+
+type callNode struct {
+	name string
+	args []node
+}
+
+type compareNode struct {
+	op          string
+	left, right node
+}
+
+type logicalNode struct {
+	op          string
+	left, right node
+}
+
+type notNode struct {
+	operand node
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+// parse parses the tokens into an expression tree by the grammar
+//
+//	expr    = and { ("or" | "||") and }
+//	and     = not { ("and" | "&&") not }
+//	not     = ("not" | "!") not | compare
+//	compare = operand [ ("==" | "!=" | "<" | "<=" | ">" | ">=" | "=~") operand ]
+//	operand = selector | literal | call | "(" expr ")"
+//	call    = ident "(" [ expr { "," expr } ] ")"
+func parse(src string) (node, error) {
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != token_eof {
+		return nil, fmt.Errorf("position %d: unexpected %s: %w", tok.pos, tok.text, ErrSyntax)
+	}
+
+	return expr, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != token_eof {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) isKeyword(tok token, keywords ...string) bool {
+	for _, keyword := range keywords {
+		if (tok.kind == token_ident || tok.kind == token_op) && strings.EqualFold(tok.text, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword(p.peek(), "or", "||") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalNode{op: "or", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword(p.peek(), "and", "&&") {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalNode{op: "and", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (node, error) {
+	if p.isKeyword(p.peek(), "not", "!") {
+		p.next()
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{operand: operand}, nil
+	}
+	return p.parseCompare()
+}
+
+func (p *parser) parseCompare() (node, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	tok := p.peek()
+	if tok.kind != token_op {
+		return left, nil
+	}
+	switch tok.text {
+	case "==", "!=", "<", "<=", ">", ">=", "=~":
+	default:
+		return left, nil
+	}
+	p.next()
+
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	return compareNode{op: tok.text, left: left, right: right}, nil
+}
+
+func (p *parser) parseOperand() (node, error) {
+	tok := p.next()
+	switch tok.kind {
+	case token_lparen:
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != token_rparen {
+			return nil, fmt.Errorf("position %d: expect ): %w", closing.pos, ErrSyntax)
+		}
+		return expr, nil
+	case token_string:
+		return literalNode{value: tok.text}, nil
+	case token_number:
+		value, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("position %d: invalid number %s: %w", tok.pos, tok.text, ErrSyntax)
+		}
+		return literalNode{value: value}, nil
+	case token_duration:
+		// Durations are only meaningful as arguments of ago
+		return literalNode{value: tok.text}, nil
+	case token_selector:
+		sel, err := ParseSelector(tok.text)
+		if err != nil {
+			return nil, fmt.Errorf("position %d: %w", tok.pos, err)
+		}
+		return selectorNode{sel: sel}, nil
+	case token_ident:
+		switch strings.ToLower(tok.text) {
+		case "true":
+			return literalNode{value: true}, nil
+		case "false":
+			return literalNode{value: false}, nil
+		case "null":
+			return literalNode{value: nil}, nil
+		}
+		if p.peek().kind == token_lparen {
+			return p.parseCall(tok)
+		}
+		// A label without JSONPath selects the whole content value
+		sel, err := ParseSelector(tok.text)
+		if err != nil {
+			return nil, fmt.Errorf("position %d: %w", tok.pos, err)
+		}
+		return selectorNode{sel: sel}, nil
+	case token_eof:
+		return nil, fmt.Errorf("position %d: unexpected end of query: %w", tok.pos, ErrSyntax)
+	default:
+		return nil, fmt.Errorf("position %d: unexpected %s: %w", tok.pos, tok.text, ErrSyntax)
+	}
+}
+
+func (p *parser) parseCall(name token) (node, error) {
+	p.next() // (
+	call := callNode{name: strings.ToLower(name.text), args: []node{}}
+	if _, ok := functions[call.name]; !ok {
+		return nil, fmt.Errorf("position %d: unknown function %s: %w", name.pos, name.text, ErrSyntax)
+	}
+
+	if p.peek().kind == token_rparen {
+		p.next()
+		return call, nil
+	}
+	for {
+		arg, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		call.args = append(call.args, arg)
+
+		tok := p.next()
+		if tok.kind == token_rparen {
+			return call, nil
+		}
+		if tok.kind != token_comma {
+			return nil, fmt.Errorf("position %d: expect , or ): %w", tok.pos, ErrSyntax)
+		}
+	}
+}
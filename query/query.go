@@ -0,0 +1,60 @@
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/liuminhaw/mist-miner/shared"
+)
+
+// Query is a compiled query expression evaluated over resources. An expression combines
+// selectors (see Selector), literals ("text", 90, true, null) and functions
+// (ago, now, date, exists, count, len, lower) with comparison operators
+// ==, !=, <, <=, >, >=, =~ (regular expression) and logical operators and, or, not.
+//
+// Selectors may select multiple values, a comparison is true if any of the values
+// satisfies it. Examples:
+//
+//	UserDetail$.PasswordLastUsed < ago(90d)
+//	Policy =~ "Admin" and not exists(UserDetail$.Tags)
+//	UserDetail$.Tags[*].Key == "team" or @alias == ""
+type Query struct {
+	src  string
+	root node
+	// Time used by ago and now functions, fixed at compile time
+	now time.Time
+	// Compiled patterns of =~ operator
+	regexps map[string]*regexp.Regexp
+}
+
+// Compile parses the query expression, now is used as current time by time functions.
+func Compile(src string, now time.Time) (*Query, error) {
+	root, err := parse(src)
+	if err != nil {
+		return nil, fmt.Errorf("Compile(%s): %w", src, err)
+	}
+	return &Query{src: src, root: root, now: now, regexps: make(map[string]*regexp.Regexp)}, nil
+}
+
+// Match evaluates the query over the resource of the plugin.
+func (q *Query) Match(plugin string, resource *shared.MinerResource) (bool, error) {
+	values, err := q.root.eval(q.env(plugin, resource))
+	if err != nil {
+		return false, fmt.Errorf("Match(%s, %s): %w", plugin, resource.Identifier, err)
+	}
+	return truthy(values), nil
+}
+
+func (q *Query) String() string {
+	return q.src
+}
+
+func (q *Query) env(plugin string, resource *shared.MinerResource) *env {
+	return &env{
+		plugin:   plugin,
+		resource: resource,
+		now:      q.now,
+		regexps:  q.regexps,
+	}
+}
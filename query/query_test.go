@@ -0,0 +1,84 @@
+package query
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+var testNow = time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		src  string
+		want bool
+	}{
+		// Times
+		{src: "UserDetail$.PasswordLastUsed < ago(90d)", want: true},
+		{src: "UserDetail$.PasswordLastUsed < ago(20w)", want: false},
+		{src: "UserDetail$.PasswordLastUsed >= ago(3000h)", want: true},
+		{src: `UserDetail$.PasswordLastUsed == date("2026-05-01T00:00:00Z")`, want: true},
+		{src: `UserDetail$.PasswordLastUsed > "2026-04-30T23:59:59Z"`, want: true},
+		{src: `date("123d") < now()`, want: true},
+		{src: "UserDetail$.Age < ago(1d)", want: false},
+		// Numbers
+		{src: "UserDetail$.Age == 42", want: true},
+		{src: "UserDetail$.Age > 4.2e1", want: false},
+		{src: "UserDetail$.Age >= -1", want: true},
+		{src: `UserDetail$.Age < "100"`, want: true},
+		{src: `UserDetail$.Age == "forty"`, want: false},
+		{src: `UserDetail$.Age != "forty"`, want: true},
+		{src: "count(Policy) == 2", want: true},
+		{src: "len(UserDetail$.Tags) = 2", want: true},
+		// Strings and regular expressions
+		{src: `Policy == "ReadOnlyAccess"`, want: true},
+		{src: `Policy =~ "^Admin"`, want: true},
+		{src: `lower(@identifier) == "ALICE"`, want: false},
+		{src: `@plugin == 'aws-iam' && @alias =~ "user/"`, want: true},
+		{src: `UserDetail$.Tags[*].Value == "prod"`, want: true},
+		{src: `UserDetail$.Missing != "x"`, want: false},
+		// Others
+		{src: "2FA$.Enabled == false", want: true},
+		{src: "exists(UserDetail$.Tags) and not exists(UserDetail$.Missing)", want: true},
+		{src: `UserDetail$["a b"].c`, want: true},
+		{src: "UserDetail$.Missing == null or (Policy and !2FA$.Enabled)", want: true},
+		{src: "NOT Policy OR false", want: false},
+	}
+
+	for _, tt := range tests {
+		q, err := Compile(tt.src, testNow)
+		if err != nil {
+			t.Errorf("Compile(%s): %v", tt.src, err)
+			continue
+		}
+		got, err := q.Match("aws-iam", testResource())
+		if err != nil {
+			t.Errorf("Match(%s): %v", tt.src, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Match(%s) = %t, want %t", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestMatchErrors(t *testing.T) {
+	for _, src := range []string{
+		"UserDetail$.Age < ago(90)",
+		`UserDetail$.Age < ago("90x")`,
+		`UserDetail$.Age < date("yesterday")`,
+		"UserDetail$.Age < ago(1d, 2d)",
+		"Policy =~ 1",
+		`Policy =~ "("`,
+		"count(Policy, Policy) > 0",
+	} {
+		q, err := Compile(src, testNow)
+		if err != nil {
+			t.Errorf("Compile(%s): %v", src, err)
+			continue
+		}
+		if _, err := q.Match("aws-iam", testResource()); !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("Match(%s) error = %v, want %v", src, err, ErrInvalidArgument)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package query
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/liuminhaw/mist-miner/shared"
+)
+
+// Resource fields selectable with @ prefix
+const (
+	FIELD_PLUGIN     = "plugin"
+	FIELD_IDENTIFIER = "identifier"
+	FIELD_ALIAS      = "alias"
+)
+
+// Selector selects values from a resource. It is either a resource field, ex. @identifier,
+// or the content values of properties with a label, optionally followed by a JSONPath
+// into json content, ex. UserDetail$.PasswordLastUsed. Labels which are not simple words
+// are written in brackets, ex. ["User Detail"]$.Arn.
+//
+// A property with unique label is addressed directly as a single value, properties
+// sharing a non unique label select the values of every one of them.
+type Selector struct {
+	Field string
+	Label string
+	// Steps of JSONPath after $, nil selects the whole content value
+	Path []pathStep
+	src  string
+}
+
+type pathStep struct {
+	key      string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+// ParseSelector parses a selector, see Selector for the syntax.
+func ParseSelector(src string) (Selector, error) {
+	sel := Selector{src: src}
+
+	if field, ok := strings.CutPrefix(src, "@"); ok {
+		switch field {
+		case FIELD_PLUGIN, FIELD_IDENTIFIER, FIELD_ALIAS:
+			sel.Field = field
+			return sel, nil
+		default:
+			return Selector{}, fmt.Errorf("unknown field %s: %w", src, ErrSyntax)
+		}
+	}
+
+	var label, path string
+	var hasPath bool
+	if strings.HasPrefix(src, "[") {
+		unquoted, end, err := lexString(src, 1)
+		if err != nil || end >= len(src) || src[end] != ']' {
+			return Selector{}, fmt.Errorf("invalid label %s: %w", src, ErrSyntax)
+		}
+		label = unquoted
+		rest := src[end+1:]
+		if path, hasPath = strings.CutPrefix(rest, "$"); !hasPath && rest != "" {
+			return Selector{}, fmt.Errorf("expect JSONPath after label in %s: %w", src, ErrSyntax)
+		}
+	} else {
+		label, path, hasPath = strings.Cut(src, "$")
+	}
+	if label == "" {
+		return Selector{}, fmt.Errorf("selector %s has no label: %w", src, ErrSyntax)
+	}
+	sel.Label = label
+
+	if hasPath {
+		steps, err := parsePath(path)
+		if err != nil {
+			return Selector{}, fmt.Errorf("selector %s: %w", src, err)
+		}
+		sel.Path = steps
+	}
+
+	return sel, nil
+}
+
+func (s Selector) String() string {
+	return s.src
+}
+
+// Values returns the values selected from the resource of the plugin, values of
+// json content are decoded as by encoding/json. Properties with text content
+// are only selected without JSONPath.
+func (s Selector) Values(plugin string, resource *shared.MinerResource) []any {
+	switch s.Field {
+	case FIELD_PLUGIN:
+		return []any{plugin}
+	case FIELD_IDENTIFIER:
+		return []any{resource.Identifier}
+	case FIELD_ALIAS:
+		return []any{resource.Alias}
+	}
+
+	values := []any{}
+	for _, prop := range resource.Properties {
+		if prop.Label.Name != s.Label {
+			continue
+		}
+
+		var content any = prop.Content.Value
+		if prop.Content.Format == shared.FormatJson || s.Path != nil {
+			if err := json.Unmarshal([]byte(prop.Content.Value), &content); err != nil {
+				continue
+			}
+		}
+		values = append(values, selectPath(content, s.Path)...)
+
+		if prop.Label.Unique {
+			break
+		}
+	}
+
+	return values
+}
+
+// parsePath parses JSONPath steps following $: .key, ["key"], [n], [-n], .* and [*]
+func parsePath(path string) ([]pathStep, error) {
+	steps := []pathStep{}
+	for i := 0; i < len(path); {
+		switch path[i] {
+		case '.':
+			end := i + 1
+			if end < len(path) && path[end] == '*' {
+				steps = append(steps, pathStep{wildcard: true})
+				i = end + 1
+				continue
+			}
+			for end < len(path) && path[end] != '.' && path[end] != '[' {
+				end++
+			}
+			if end == i+1 {
+				return nil, fmt.Errorf("empty key in path $%s: %w", path, ErrSyntax)
+			}
+			steps = append(steps, pathStep{key: path[i+1 : end]})
+			i = end
+		case '[':
+			closing := strings.IndexByte(path[i:], ']')
+			if i+1 < len(path) && (path[i+1] == '"' || path[i+1] == '\'') {
+				key, end, err := lexString(path, i+1)
+				if err != nil || end >= len(path) || path[end] != ']' {
+					return nil, fmt.Errorf("invalid key in path $%s: %w", path, ErrSyntax)
+				}
+				steps = append(steps, pathStep{key: key})
+				i = end + 1
+				continue
+			}
+			if closing < 0 {
+				return nil, fmt.Errorf("missing ] in path $%s: %w", path, ErrSyntax)
+			}
+			inner := path[i+1 : i+closing]
+			if inner == "*" {
+				steps = append(steps, pathStep{wildcard: true})
+			} else {
+				index, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, fmt.Errorf("invalid index [%s] in path $%s: %w", inner, path, ErrSyntax)
+				}
+				steps = append(steps, pathStep{index: index, isIndex: true})
+			}
+			i += closing + 1
+		default:
+			return nil, fmt.Errorf("unexpected %q in path $%s: %w", path[i], path, ErrSyntax)
+		}
+	}
+
+	return steps, nil
+}
+
+// selectPath returns values reached by the path steps from data,
+// wildcards select every element of arrays or objects.
+func selectPath(data any, steps []pathStep) []any {
+	if len(steps) == 0 {
+		return []any{data}
+	}
+
+	step := steps[0]
+	switch {
+	case step.wildcard:
+		values := []any{}
+		switch v := data.(type) {
+		case []any:
+			for _, elem := range v {
+				values = append(values, selectPath(elem, steps[1:])...)
+			}
+		case map[string]any:
+			for _, key := range sortedKeys(v) {
+				values = append(values, selectPath(v[key], steps[1:])...)
+			}
+		}
+		return values
+	case step.isIndex:
+		arr, ok := data.([]any)
+		if !ok {
+			return nil
+		}
+		index := step.index
+		if index < 0 {
+			index += len(arr)
+		}
+		if index < 0 || index >= len(arr) {
+			return nil
+		}
+		return selectPath(arr[index], steps[1:])
+	default:
+		obj, ok := data.(map[string]any)
+		if !ok {
+			return nil
+		}
+		value, ok := obj[step.key]
+		if !ok {
+			return nil
+		}
+		return selectPath(value, steps[1:])
+	}
+}
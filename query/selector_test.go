@@ -0,0 +1,92 @@
+package query
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/liuminhaw/mist-miner/shared"
+)
+
+func testResource() *shared.MinerResource {
+	property := func(label string, unique bool, format, value string) shared.MinerProperty {
+		return shared.MinerProperty{
+			Type:    "detail",
+			Label:   shared.MinerPropertyLabel{Name: label, Unique: unique},
+			Content: shared.MinerPropertyContent{Format: format, Value: value},
+		}
+	}
+
+	return &shared.MinerResource{
+		Identifier: "alice",
+		Alias:      "arn:aws:iam::1:user/alice",
+		Properties: []shared.MinerProperty{
+			property("UserDetail", true, shared.FormatJson, `{
+				"PasswordLastUsed": "2026-05-01T00:00:00Z",
+				"Age": 42,
+				"Tags": [{"Key": "team", "Value": "ops"}, {"Key": "env", "Value": "prod"}],
+				"a b": {"c": true}
+			}`),
+			property("Policy", false, shared.FormatText, "AdministratorAccess"),
+			property("Policy", false, shared.FormatText, "ReadOnlyAccess"),
+			property("2FA", true, shared.FormatJson, `{"Enabled": false}`),
+			property("User Detail", true, shared.FormatJson, `{"Arn": "arn"}`),
+		},
+	}
+}
+
+func TestSelectorValues(t *testing.T) {
+	tests := []struct {
+		src  string
+		want []any
+	}{
+		{src: "@plugin", want: []any{"aws-iam"}},
+		{src: "@identifier", want: []any{"alice"}},
+		{src: "@alias", want: []any{"arn:aws:iam::1:user/alice"}},
+		{src: "Policy", want: []any{"AdministratorAccess", "ReadOnlyAccess"}},
+		{src: "UserDetail$.Age", want: []any{float64(42)}},
+		{src: "UserDetail$.Tags[0].Key", want: []any{"team"}},
+		{src: "UserDetail$.Tags[-1].Value", want: []any{"prod"}},
+		{src: "UserDetail$.Tags[*].Value", want: []any{"ops", "prod"}},
+		{src: "UserDetail$.Tags[2]", want: []any{}},
+		{src: `UserDetail$["a b"].c`, want: []any{true}},
+		{src: "UserDetail$.Tags[0].*", want: []any{"team", "ops"}},
+		{src: "UserDetail$.Missing", want: []any{}},
+		{src: "UserDetail$.Age.x", want: []any{}},
+		{src: "Policy$.x", want: []any{}},
+		{src: "2FA$.Enabled", want: []any{false}},
+		{src: `["User Detail"]$.Arn`, want: []any{"arn"}},
+		{src: "Missing", want: []any{}},
+	}
+
+	for _, tt := range tests {
+		sel, err := ParseSelector(tt.src)
+		if err != nil {
+			t.Errorf("ParseSelector(%s): %v", tt.src, err)
+			continue
+		}
+		got := sel.Values("aws-iam", testResource())
+		if len(got) == 0 && len(tt.want) == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s selects %v, want %v", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestParseSelectorErrors(t *testing.T) {
+	for _, src := range []string{
+		"@owner",
+		"$.a",
+		`[""]$.a`,
+		`["a"]x`,
+		"a$.",
+		"a$.b[x]",
+		"a$.b[1",
+		"a$x",
+	} {
+		if _, err := ParseSelector(src); err == nil {
+			t.Errorf("ParseSelector(%s) succeeded, want error", src)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package shared
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/go-plugin"
+)
+
+// ErrChecksumMismatch is returned when the plugin binary does not match the sha256
+// pinned by the plug, the binary is not started.
+var ErrChecksumMismatch = errors.New("plugin binary does not match pinned sha256")
+
+// NewPluginClient returns the client starting the plugin binary of given path, speaking
+// every plugin protocol version supported by the host. The binary is refused to start
+// if it does not match the sha256 checksum, which is not checked if nil.
+func NewPluginClient(path string, checksum []byte, logger hclog.Logger) *plugin.Client {
+	config := plugin.ClientConfig{
+		HandshakeConfig:  Handshake,
+		VersionedPlugins: VersionedPlugins,
+		Cmd:              exec.Command(path),
+		Logger:           logger,
+		AllowedProtocols: []plugin.Protocol{plugin.ProtocolGRPC},
+	}
+	if checksum != nil {
+		config.SecureConfig = &plugin.SecureConfig{Checksum: checksum, Hash: sha256.New()}
+	}
+	return plugin.NewClient(&config)
+}
+
+// StartPlugin starts the plugin process of the client and connects to it,
+// ErrChecksumMismatch is returned if the binary does not match the pinned checksum.
+func StartPlugin(client *plugin.Client) (plugin.ClientProtocol, error) {
+	rpcClient, err := client.Client()
+	if errors.Is(err, plugin.ErrChecksumsDoNotMatch) {
+		return nil, ErrChecksumMismatch
+	} else if err != nil {
+		return nil, err
+	}
+	return rpcClient, nil
+}
+
+// FileSha256 returns the hex encoded sha256 checksum of the file of given path
+func FileSha256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("FileSha256(%s): %w", path, err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("FileSha256(%s): %w", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// DescribePlugin starts the plugin binary of given path and returns its description,
+// ErrDescribeUnsupported is returned if the plugin does not implement Describe.
+func DescribePlugin(
+	ctx context.Context,
+	path string,
+	checksum []byte,
+	logger hclog.Logger,
+) (PluginDescription, error) {
+	client := NewPluginClient(path, checksum, logger)
+	defer client.Kill()
+
+	rpcClient, err := StartPlugin(client)
+	if err != nil {
+		return PluginDescription{}, fmt.Errorf("DescribePlugin(%s): %w", path, err)
+	}
+	raw, err := rpcClient.Dispense("miner_grpc")
+	if err != nil {
+		return PluginDescription{}, fmt.Errorf("DescribePlugin(%s): %w", path, err)
+	}
+
+	describer, ok := raw.(Describer)
+	if !ok {
+		return PluginDescription{}, ErrDescribeUnsupported
+	}
+	description, err := describer.Describe(ctx)
+	if err != nil {
+		return PluginDescription{}, fmt.Errorf("DescribePlugin(%s): %w", path, err)
+	}
+	return description, nil
+}
@@ -3,4 +3,10 @@ package shared
 const (
 	FormatJson = "json"
 	FormatText = "text"
+
+	DiffAdded    = "added"
+	DiffRemoved  = "removed"
+	DiffModified = "modified"
+	// Json arrays with the same elements in another order
+	DiffReordered = "reordered"
 )
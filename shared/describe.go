@@ -0,0 +1,122 @@
+package shared
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Describer is optionally implemented by plugins to report their version, the config
+// they accept and the properties they emit. Config of plugs is validated against the
+// description before mining.
+type Describer interface {
+	Describe(ctx context.Context) (PluginDescription, error)
+}
+
+// ErrDescribeUnsupported is returned by GRPCClient.Describe if the plugin does not
+// implement Describe, config of the plugin is not validated.
+var ErrDescribeUnsupported = errors.New("plugin does not support describe")
+
+type PluginDescription struct {
+	Name    string `json:"name" yaml:"name"`
+	Version string `json:"version" yaml:"version"`
+	// Supported equipment types with their attributes
+	Equipments []EquipmentDescription `json:"equipments" yaml:"equipments"`
+	// Keys required in authenticator of the plug
+	AuthenticatorKeys []string `json:"authenticator_keys" yaml:"authenticator_keys"`
+	// Properties the plugin can emit for resources
+	Properties []PropertyDescription `json:"properties" yaml:"properties"`
+}
+
+type EquipmentDescription struct {
+	Type       string               `json:"type" yaml:"type"`
+	Attributes []EquipmentAttribute `json:"attributes" yaml:"attributes"`
+}
+
+type EquipmentAttribute struct {
+	Key         string `json:"key" yaml:"key"`
+	Required    bool   `json:"required" yaml:"required"`
+	Description string `json:"description" yaml:"description"`
+}
+
+type PropertyDescription struct {
+	Type   string `json:"type" yaml:"type"`
+	Label  string `json:"label" yaml:"label"`
+	Unique bool   `json:"unique" yaml:"unique"`
+}
+
+// Validate checks the equipments and authenticator of the plug against the description,
+// every problem found is returned joined in one error.
+func (d PluginDescription) Validate(plug Plug) error {
+	errs := []error{}
+	if strings.ContainsFunc(d.Version, unicode.IsSpace) {
+		errs = append(errs, fmt.Errorf("plugin version %q contains spaces", d.Version))
+	}
+
+	for _, key := range d.AuthenticatorKeys {
+		if _, ok := plug.Authenticator[key]; !ok {
+			errs = append(errs, fmt.Errorf("authenticator: missing required key %s", key))
+		}
+	}
+
+	for _, equipment := range plug.Equipments {
+		idx := slices.IndexFunc(d.Equipments, func(e EquipmentDescription) bool {
+			return e.Type == equipment.Type
+		})
+		if idx < 0 {
+			errs = append(errs, fmt.Errorf(
+				"equipment %s %s: unsupported type, expect one of %s",
+				equipment.Type,
+				equipment.Name,
+				strings.Join(d.equipmentTypes(), ", "),
+			))
+			continue
+		}
+
+		attributes := d.Equipments[idx].Attributes
+		for _, attr := range attributes {
+			if _, ok := equipment.Attributes[attr.Key]; attr.Required && !ok {
+				errs = append(errs, fmt.Errorf(
+					"equipment %s %s: missing required attribute %s",
+					equipment.Type,
+					equipment.Name,
+					attr.Key,
+				))
+			}
+		}
+		keys := make([]string, 0, len(equipment.Attributes))
+		for key := range equipment.Attributes {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		for _, key := range keys {
+			known := slices.ContainsFunc(attributes, func(attr EquipmentAttribute) bool {
+				return attr.Key == key
+			})
+			if !known {
+				errs = append(errs, fmt.Errorf(
+					"equipment %s %s: unknown attribute %s",
+					equipment.Type,
+					equipment.Name,
+					key,
+				))
+			}
+		}
+	}
+
+	for i, err := range errs {
+		errs[i] = fmt.Errorf("plug %s %s: %w", plug.Name, plug.Group, err)
+	}
+	return errors.Join(errs...)
+}
+
+func (d PluginDescription) equipmentTypes() []string {
+	types := []string{}
+	for _, equipment := range d.Equipments {
+		types = append(types, equipment.Type)
+	}
+	return types
+}
@@ -0,0 +1,339 @@
+package shared
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type PropertyDiff struct {
+	Type   string
+	Label  string
+	Status string
+	From   *MinerProperty
+	To     *MinerProperty
+	// Structural changes of the content value, only available when both
+	// sides of a modified property are valid json in json format and differ
+	// structurally. Otherwise the property is rendered as plain text of both sides.
+	JsonChanges []JsonChange
+}
+
+type JsonChange struct {
+	Path   string
+	Status string
+	From   any
+	To     any
+}
+
+// DiffProperties compares properties of two MinerResource, matching them by
+// property type and label name. Properties sharing the same type and label
+// (non unique labels) are compared by content, unmatched ones are reported
+// as added or removed. A json property whose value does not parse, or which only
+// differs in formatting, is compared as plain text.
+func DiffProperties(from, to MinerResource) []PropertyDiff {
+	fromGroups, keys := groupProperties(from.Properties, nil)
+	toGroups, keys := groupProperties(to.Properties, keys)
+	sort.Strings(keys)
+
+	diffs := []PropertyDiff{}
+	for _, key := range keys {
+		fromProps := unmatchedProperties(fromGroups[key], toGroups[key])
+		toProps := unmatchedProperties(toGroups[key], fromGroups[key])
+
+		// Pair leftover properties in order as modified, the rest are added or removed
+		for i := 0; i < len(fromProps) || i < len(toProps); i++ {
+			switch {
+			case i >= len(toProps):
+				diffs = append(diffs, newPropertyDiff(DiffRemoved, &fromProps[i], nil))
+			case i >= len(fromProps):
+				diffs = append(diffs, newPropertyDiff(DiffAdded, nil, &toProps[i]))
+			default:
+				diff := newPropertyDiff(DiffModified, &fromProps[i], &toProps[i])
+				if fromProps[i].Content.Format == FormatJson &&
+					toProps[i].Content.Format == FormatJson {
+					// Invalid json falls back to plain text, as does json only differing
+					// in formatting or key order which has no change to show
+					changes, err := JsonDiff(fromProps[i].Content.Value, toProps[i].Content.Value)
+					if err == nil && len(changes) > 0 {
+						diff.JsonChanges = changes
+					}
+				}
+				diffs = append(diffs, diff)
+			}
+		}
+	}
+
+	return diffs
+}
+
+// JsonDiff compares two JSON strings structurally and returns the added, removed
+// and modified paths. Paths are written in JSONPath form, ex. $.Tags[0].Value.
+// A modified array element which moved is written with both indexes, ex. $.Tags[2->1].
+// Arrays only differing in element order are ignored, unless there is no other change
+// in which case they are returned as reordered.
+func JsonDiff(from, to string) ([]JsonChange, error) {
+	var fromObj, toObj any
+	if err := json.Unmarshal([]byte(from), &fromObj); err != nil {
+		return nil, fmt.Errorf("JsonDiff: json unmarshal: %w", err)
+	}
+	if err := json.Unmarshal([]byte(to), &toObj); err != nil {
+		return nil, fmt.Errorf("JsonDiff: json unmarshal: %w", err)
+	}
+
+	changes, reorders := []JsonChange{}, []JsonChange{}
+	jsonDiff("$", fromObj, toObj, &changes, &reorders)
+	if len(changes) == 0 {
+		return reorders, nil
+	}
+	return changes, nil
+}
+
+// RenderDiffMarkdown renders property differences in markdown format.
+func RenderDiffMarkdown(diffs []PropertyDiff) (string, error) {
+	var sb strings.Builder
+
+	if len(diffs) == 0 {
+		if _, err := sb.WriteString("No property changes\n"); err != nil {
+			return "", fmt.Errorf("RenderDiffMarkdown(): %w", err)
+		}
+		return sb.String(), nil
+	}
+
+	for _, diff := range diffs {
+		if _, err := sb.WriteString(fmt.Sprintf("### %s (%s)\n", diff.Type, diff.Status)); err != nil {
+			return "", fmt.Errorf("RenderDiffMarkdown(): %w", err)
+		}
+		if _, err := sb.WriteString(fmt.Sprintf("- **Label:** %s\n", diff.Label)); err != nil {
+			return "", fmt.Errorf("RenderDiffMarkdown(): %w", err)
+		}
+
+		if len(diff.JsonChanges) > 0 {
+			if _, err := sb.WriteString("- **Changes:**\n"); err != nil {
+				return "", fmt.Errorf("RenderDiffMarkdown(): %w", err)
+			}
+			for _, change := range diff.JsonChanges {
+				if _, err := sb.WriteString(fmt.Sprintf("  - `%s`\n", change.String())); err != nil {
+					return "", fmt.Errorf("RenderDiffMarkdown(): %w", err)
+				}
+			}
+			continue
+		}
+
+		if diff.From != nil {
+			input := indentString(diff.From.Content.Value, "  ")
+			if _, err := sb.WriteString(fmt.Sprintf("- **From:**\n  ```\n%s\n  ```\n", input)); err != nil {
+				return "", fmt.Errorf("RenderDiffMarkdown(): %w", err)
+			}
+		}
+		if diff.To != nil {
+			input := indentString(diff.To.Content.Value, "  ")
+			if _, err := sb.WriteString(fmt.Sprintf("- **To:**\n  ```\n%s\n  ```\n", input)); err != nil {
+				return "", fmt.Errorf("RenderDiffMarkdown(): %w", err)
+			}
+		}
+	}
+
+	return sb.String(), nil
+}
+
+// String returns the change in a single line, ex. "~ $.Tags[0].Value: "x" -> "y"".
+func (c JsonChange) String() string {
+	switch c.Status {
+	case DiffAdded:
+		return fmt.Sprintf("+ %s: %s", c.Path, jsonValueString(c.To))
+	case DiffRemoved:
+		return fmt.Sprintf("- %s: %s", c.Path, jsonValueString(c.From))
+	case DiffReordered:
+		return fmt.Sprintf(
+			"~ %s: reordered %s -> %s",
+			c.Path,
+			jsonValueString(c.From),
+			jsonValueString(c.To),
+		)
+	default:
+		return fmt.Sprintf(
+			"~ %s: %s -> %s",
+			c.Path,
+			jsonValueString(c.From),
+			jsonValueString(c.To),
+		)
+	}
+}
+
+func newPropertyDiff(status string, from, to *MinerProperty) PropertyDiff {
+	diff := PropertyDiff{Status: status, From: from, To: to}
+	if from != nil {
+		diff.Type, diff.Label = from.Type, from.Label.Name
+	} else {
+		diff.Type, diff.Label = to.Type, to.Label.Name
+	}
+	return diff
+}
+
+// groupProperties groups properties by type and label name, keys not yet in
+// the given keys slice are appended to it.
+func groupProperties(
+	props []MinerProperty,
+	keys []string,
+) (map[string][]MinerProperty, []string) {
+	groups := make(map[string][]MinerProperty)
+	for _, prop := range props {
+		key := prop.Type + "\x00" + prop.Label.Name
+		if _, ok := groups[key]; !ok && !slices.Contains(keys, key) {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], prop)
+	}
+	return groups, keys
+}
+
+// unmatchedProperties returns properties in props which have no identical
+// property in others, each property in others can only be matched once.
+func unmatchedProperties(props, others []MinerProperty) []MinerProperty {
+	matched := make([]bool, len(others))
+	result := []MinerProperty{}
+
+propsLoop:
+	for _, prop := range props {
+		for i, other := range others {
+			if !matched[i] && prop.Content == other.Content {
+				matched[i] = true
+				continue propsLoop
+			}
+		}
+		result = append(result, prop)
+	}
+
+	return result
+}
+
+// jsonDiff appends changes from from to to, arrays only differing in element order
+// are appended to reorders.
+func jsonDiff(path string, from, to any, changes, reorders *[]JsonChange) {
+	switch fromVal := from.(type) {
+	case map[string]any:
+		toVal, ok := to.(map[string]any)
+		if !ok {
+			break
+		}
+
+		keys := []string{}
+		for key := range fromVal {
+			keys = append(keys, key)
+		}
+		for key := range toVal {
+			if _, ok := fromVal[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			childPath := jsonChildPath(path, key)
+			fromChild, inFrom := fromVal[key]
+			toChild, inTo := toVal[key]
+			switch {
+			case !inFrom:
+				*changes = append(*changes, JsonChange{Path: childPath, Status: DiffAdded, To: toChild})
+			case !inTo:
+				*changes = append(*changes, JsonChange{Path: childPath, Status: DiffRemoved, From: fromChild})
+			default:
+				jsonDiff(childPath, fromChild, toChild, changes, reorders)
+			}
+		}
+		return
+	case []any:
+		toVal, ok := to.([]any)
+		if !ok {
+			break
+		}
+
+		// Elements existing on both sides are ignored regardless of their position,
+		// leftovers are compared in order. Arrays with no leftovers only differ in order.
+		fromLeft, fromIdx := unmatchedElements(fromVal, toVal)
+		toLeft, toIdx := unmatchedElements(toVal, fromVal)
+		if len(fromLeft) == 0 && len(toLeft) == 0 {
+			if !reflect.DeepEqual(fromVal, toVal) {
+				*reorders = append(*reorders, JsonChange{Path: path, Status: DiffReordered, From: from, To: to})
+			}
+			return
+		}
+		for i := 0; i < len(fromLeft) || i < len(toLeft); i++ {
+			switch {
+			case i >= len(toLeft):
+				*changes = append(*changes, JsonChange{
+					Path:   fmt.Sprintf("%s[%d]", path, fromIdx[i]),
+					Status: DiffRemoved,
+					From:   fromLeft[i],
+				})
+			case i >= len(fromLeft):
+				*changes = append(*changes, JsonChange{
+					Path:   fmt.Sprintf("%s[%d]", path, toIdx[i]),
+					Status: DiffAdded,
+					To:     toLeft[i],
+				})
+			default:
+				elemPath := fmt.Sprintf("%s[%d]", path, toIdx[i])
+				if fromIdx[i] != toIdx[i] {
+					elemPath = fmt.Sprintf("%s[%d->%d]", path, fromIdx[i], toIdx[i])
+				}
+				jsonDiff(elemPath, fromLeft[i], toLeft[i], changes, reorders)
+			}
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(from, to) {
+		*changes = append(*changes, JsonChange{Path: path, Status: DiffModified, From: from, To: to})
+	}
+}
+
+// unmatchedElements returns elements in values which have no equal element in others
+// together with their original indexes.
+func unmatchedElements(values, others []any) ([]any, []int) {
+	matched := make([]bool, len(others))
+	result := []any{}
+	indexes := []int{}
+
+valuesLoop:
+	for i, value := range values {
+		for j, other := range others {
+			if !matched[j] && reflect.DeepEqual(value, other) {
+				matched[j] = true
+				continue valuesLoop
+			}
+		}
+		result = append(result, value)
+		indexes = append(indexes, i)
+	}
+
+	return result, indexes
+}
+
+// jsonChildPath returns the JSONPath of key under path, keys which are not
+// simple identifiers are written in bracket notation.
+func jsonChildPath(path, key string) string {
+	simple := key != ""
+	for i, r := range key {
+		if !(r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || i > 0 && r >= '0' && r <= '9') {
+			simple = false
+			break
+		}
+	}
+	if simple {
+		return path + "." + key
+	}
+	return fmt.Sprintf("%s[%s]", path, strconv.Quote(key))
+}
+
+func jsonValueString(value any) string {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(b)
+}
@@ -0,0 +1,102 @@
+package shared
+
+import (
+	"strings"
+	"testing"
+)
+
+func jsonResource(values ...string) MinerResource {
+	resource := MinerResource{Identifier: "res"}
+	for _, value := range values {
+		resource.Properties = append(resource.Properties, MinerProperty{
+			Type:    "detail",
+			Label:   MinerPropertyLabel{Name: "Detail", Unique: true},
+			Content: MinerPropertyContent{Format: FormatJson, Value: value},
+		})
+	}
+	return resource
+}
+
+func TestDiffPropertiesJson(t *testing.T) {
+	diffs := DiffProperties(jsonResource(`{"a":1,"b":[1,2]}`), jsonResource(`{"a":2,"b":[2,1],"c":true}`))
+	if len(diffs) != 1 || diffs[0].Status != DiffModified {
+		t.Fatalf("got diffs %+v, want one modified property", diffs)
+	}
+
+	got := []string{}
+	for _, change := range diffs[0].JsonChanges {
+		got = append(got, change.String())
+	}
+	want := []string{"~ $.a: 1 -> 2", "+ $.c: true"}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got changes %q, want %q", got, want)
+	}
+}
+
+func TestDiffPropertiesInvalidJson(t *testing.T) {
+	diffs := DiffProperties(jsonResource(`{"a":1}`), jsonResource(`not json`))
+	if len(diffs) != 1 || diffs[0].Status != DiffModified {
+		t.Fatalf("got diffs %+v, want one modified property", diffs)
+	}
+	if diffs[0].JsonChanges != nil {
+		t.Errorf("got json changes %+v, want plain text fallback", diffs[0].JsonChanges)
+	}
+
+	out, err := RenderDiffMarkdown(diffs)
+	if err != nil {
+		t.Fatalf("RenderDiffMarkdown: %v", err)
+	}
+	for _, want := range []string{`{"a":1}`, "not json"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("rendered diff %q does not contain %q", out, want)
+		}
+	}
+}
+
+func TestJsonDiffArrays(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     []string
+	}{
+		{`{"a":[1,2,3]}`, `{"a":[3,1,2]}`, []string{"~ $.a: reordered [1,2,3] -> [3,1,2]"}},
+		{`{"a":[1,2]}`, `{"a":[1,2]}`, []string{}},
+		// from[1] is paired with to[2], both sides hold 1 and 2 at other indexes
+		{`{"a":[1,1,2]}`, `{"a":[1,2,2]}`, []string{"~ $.a[1->2]: 1 -> 2"}},
+		{`{"a":[{"k":"x"},1]}`, `{"a":[1,{"k":"y"}]}`, []string{`~ $.a[0->1].k: "x" -> "y"`}},
+		{`{"a":[1,2]}`, `{"a":[1,3]}`, []string{"~ $.a[1]: 2 -> 3"}},
+		{`{"a":[1]}`, `{"a":[1,2]}`, []string{"+ $.a[1]: 2"}},
+	}
+	for _, tt := range tests {
+		changes, err := JsonDiff(tt.from, tt.to)
+		if err != nil {
+			t.Fatalf("JsonDiff(%s, %s): %v", tt.from, tt.to, err)
+		}
+		got := []string{}
+		for _, change := range changes {
+			got = append(got, change.String())
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+			t.Errorf("JsonDiff(%s, %s) = %q, want %q", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestDiffPropertiesReordered(t *testing.T) {
+	diffs := DiffProperties(jsonResource(`{"a":[1,2]}`), jsonResource(`{"a":[2,1]}`))
+	if len(diffs) != 1 || len(diffs[0].JsonChanges) != 1 || diffs[0].JsonChanges[0].Status != DiffReordered {
+		t.Fatalf("got diffs %+v, want one property with the array reordered", diffs)
+	}
+
+	// Same json written differently has no structural change to show
+	diffs = DiffProperties(jsonResource(`{"a":1,"b":2}`), jsonResource(`{"b":2, "a":1}`))
+	if len(diffs) != 1 || diffs[0].Status != DiffModified || diffs[0].JsonChanges != nil {
+		t.Fatalf("got diffs %+v, want one modified property in plain text", diffs)
+	}
+	out, err := RenderDiffMarkdown(diffs)
+	if err != nil {
+		t.Fatalf("RenderDiffMarkdown: %v", err)
+	}
+	if !strings.Contains(out, `{"b":2, "a":1}`) {
+		t.Errorf("rendered diff %q does not contain the plain text value", out)
+	}
+}
@@ -2,9 +2,12 @@ package shared
 
 import (
 	"context"
-	"fmt"
+	"errors"
+	"io"
 
 	"github.com/liuminhaw/mist-miner/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // GRPCClient is an implementation of Greeter that talks over RPC
@@ -13,9 +16,8 @@ type GRPCClient struct {
 	client proto.MinerServiceClient
 }
 
-func (m *GRPCClient) Mine(config MinerConfig) (MinerResources, error) {
-	fmt.Printf("GRPCClient Mine: %+v\n", config)
-	resources, err := m.client.Mine(context.Background(), toProtoMinerConfig(config))
+func (m *GRPCClient) Mine(ctx context.Context, config MinerConfig) (MinerResources, error) {
+	resources, err := m.client.Mine(ctx, toProtoMinerConfig(config))
 	if err != nil {
 		return nil, err
 	}
@@ -23,28 +25,61 @@ func (m *GRPCClient) Mine(config MinerConfig) (MinerResources, error) {
 	// Convert proto resources to shared resources
 	minerResources := MinerResources{}
 	for _, resource := range resources.Resources {
-		minerResource := MinerResource{
-			Identifier: resource.Identifier,
-			Alias:      resource.Alias,
-			Properties: []MinerProperty{},
+		minerResources = append(minerResources, toSharedMinerResource(resource))
+	}
+
+	return minerResources, nil
+}
+
+// MineStream calls send with each resource sent by the plugin, ErrStreamUnsupported is
+// returned before any resource is sent if the plugin does not implement MineStream.
+func (m *GRPCClient) MineStream(
+	ctx context.Context,
+	config MinerConfig,
+	send func(MinerResource) error,
+) error {
+	stream, err := m.client.MineStream(ctx, toProtoMinerConfig(config))
+	if status.Code(err) == codes.Unimplemented {
+		return ErrStreamUnsupported
+	} else if err != nil {
+		return err
+	}
+
+	for received := false; ; received = true {
+		resource, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if !received && status.Code(err) == codes.Unimplemented {
+			return ErrStreamUnsupported
+		} else if err != nil {
+			return err
 		}
-		for _, data := range resource.Properties {
-			minerResource.Properties = append(minerResource.Properties, MinerProperty{
-				Type: data.Type,
-				Label: MinerPropertyLabel{
-					Name:   data.Label.Name,
-					Unique: data.Label.Unique,
-				},
-				Content: MinerPropertyContent{
-					Format: data.Content.Format,
-					Value:  data.Content.Value,
-				},
-			})
+		if err := send(toSharedMinerResource(resource)); err != nil {
+			return err
 		}
-		minerResources = append(minerResources, minerResource)
 	}
+}
 
-	return minerResources, nil
+func toSharedMinerResource(resource *proto.MinerResource) MinerResource {
+	minerResource := MinerResource{
+		Identifier: resource.Identifier,
+		Alias:      resource.Alias,
+		Properties: []MinerProperty{},
+	}
+	for _, data := range resource.Properties {
+		minerResource.Properties = append(minerResource.Properties, MinerProperty{
+			Type: data.Type,
+			Label: MinerPropertyLabel{
+				Name:   data.Label.Name,
+				Unique: data.Label.Unique,
+			},
+			Content: MinerPropertyContent{
+				Format: data.Content.Format,
+				Value:  data.Content.Value,
+			},
+		})
+	}
+	return minerResource
 }
 
 func toProtoMinerConfig(config MinerConfig) *proto.MinerConfig {
@@ -77,30 +112,11 @@ func (m *GRPCServer) Mine(
 	// func (m *GRPCServer) Mine(ctx context.Context, req *proto.NoParam) (*proto.MinerResources, error) {
 	protoResources := []*proto.MinerResource{}
 
-	resources, err := m.Impl.Mine(toSharedMinerConfig(req))
-	fmt.Printf("Resources: %+v\n", resources)
+	resources, err := m.Impl.Mine(ctx, toSharedMinerConfig(req))
 
 	// Convert shared resources to proto resources
 	for _, resource := range resources {
-		protoResource := proto.MinerResource{
-			Identifier: resource.Identifier,
-			Alias:      resource.Alias,
-			Properties: []*proto.MinerProperty{},
-		}
-		for _, data := range resource.Properties {
-			protoResource.Properties = append(protoResource.Properties, &proto.MinerProperty{
-				Type: data.Type,
-				Label: &proto.MinerPropertyLabel{
-					Name:   data.Label.Name,
-					Unique: data.Label.Unique,
-				},
-				Content: &proto.MinerPropertyContent{
-					Format: data.Content.Format,
-					Value:  data.Content.Value,
-				},
-			})
-		}
-		protoResources = append(protoResources, &protoResource)
+		protoResources = append(protoResources, toProtoMinerResource(resource))
 	}
 
 	return &proto.MinerResources{
@@ -108,6 +124,48 @@ func (m *GRPCServer) Mine(
 	}, err
 }
 
+// MineStream sends resources of the plugin one at a time if it implements StreamMiner,
+// otherwise the host is told to fall back to Mine.
+func (m *GRPCServer) MineStream(
+	req *proto.MinerConfig,
+	stream proto.MinerService_MineStreamServer,
+) error {
+	streamer, ok := m.Impl.(StreamMiner)
+	if !ok {
+		return status.Error(codes.Unimplemented, "plugin does not implement MineStream")
+	}
+
+	return streamer.MineStream(
+		stream.Context(),
+		toSharedMinerConfig(req),
+		func(resource MinerResource) error {
+			return stream.Send(toProtoMinerResource(resource))
+		},
+	)
+}
+
+func toProtoMinerResource(resource MinerResource) *proto.MinerResource {
+	protoResource := proto.MinerResource{
+		Identifier: resource.Identifier,
+		Alias:      resource.Alias,
+		Properties: []*proto.MinerProperty{},
+	}
+	for _, data := range resource.Properties {
+		protoResource.Properties = append(protoResource.Properties, &proto.MinerProperty{
+			Type: data.Type,
+			Label: &proto.MinerPropertyLabel{
+				Name:   data.Label.Name,
+				Unique: data.Label.Unique,
+			},
+			Content: &proto.MinerPropertyContent{
+				Format: data.Content.Format,
+				Value:  data.Content.Value,
+			},
+		})
+	}
+	return &protoResource
+}
+
 func toSharedMinerConfig(config *proto.MinerConfig) MinerConfig {
 	equipments := []MinerConfigEquipment{}
 	for _, equipment := range config.Equipments {
@@ -123,3 +181,88 @@ func toSharedMinerConfig(config *proto.MinerConfig) MinerConfig {
 		Equipments: equipments,
 	}
 }
+
+// Describe returns the description reported by the plugin, ErrDescribeUnsupported is
+// returned if the plugin does not implement Describe.
+func (m *GRPCClient) Describe(ctx context.Context) (PluginDescription, error) {
+	desc, err := m.client.Describe(ctx, &proto.NoParam{})
+	if status.Code(err) == codes.Unimplemented {
+		return PluginDescription{}, ErrDescribeUnsupported
+	} else if err != nil {
+		return PluginDescription{}, err
+	}
+
+	description := PluginDescription{
+		Name:              desc.Name,
+		Version:           desc.Version,
+		Equipments:        []EquipmentDescription{},
+		AuthenticatorKeys: desc.AuthenticatorKeys,
+		Properties:        []PropertyDescription{},
+	}
+	for _, equipment := range desc.Equipments {
+		equipmentDescription := EquipmentDescription{
+			Type:       equipment.Type,
+			Attributes: []EquipmentAttribute{},
+		}
+		for _, attr := range equipment.Attributes {
+			equipmentDescription.Attributes = append(equipmentDescription.Attributes, EquipmentAttribute{
+				Key:         attr.Key,
+				Required:    attr.Required,
+				Description: attr.Description,
+			})
+		}
+		description.Equipments = append(description.Equipments, equipmentDescription)
+	}
+	for _, property := range desc.Properties {
+		description.Properties = append(description.Properties, PropertyDescription{
+			Type:   property.Type,
+			Label:  property.Label,
+			Unique: property.Unique,
+		})
+	}
+
+	return description, nil
+}
+
+// Describe reports the description of the plugin if it implements Describer
+func (m *GRPCServer) Describe(ctx context.Context, req *proto.NoParam) (*proto.PluginDescription, error) {
+	describer, ok := m.Impl.(Describer)
+	if !ok {
+		return nil, status.Error(codes.Unimplemented, "plugin does not implement Describe")
+	}
+	description, err := describer.Describe(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	desc := proto.PluginDescription{
+		Name:              description.Name,
+		Version:           description.Version,
+		Equipments:        []*proto.EquipmentDescription{},
+		AuthenticatorKeys: description.AuthenticatorKeys,
+		Properties:        []*proto.PropertyDescription{},
+	}
+	for _, equipment := range description.Equipments {
+		equipmentDesc := proto.EquipmentDescription{
+			Type:       equipment.Type,
+			Attributes: []*proto.EquipmentAttribute{},
+		}
+		for _, attr := range equipment.Attributes {
+			equipmentDesc.Attributes = append(equipmentDesc.Attributes, &proto.EquipmentAttribute{
+				Key:         attr.Key,
+				Required:    attr.Required,
+				Description: attr.Description,
+			})
+		}
+		desc.Equipments = append(desc.Equipments, &equipmentDesc)
+	}
+	for _, property := range description.Properties {
+		desc.Properties = append(desc.Properties, &proto.PropertyDescription{
+			Type:   property.Type,
+			Label:  property.Label,
+			Unique: property.Unique,
+		})
+	}
+
+	return &desc, nil
+}
@@ -38,6 +38,45 @@ func JsonMarshal(data any) ([]byte, error) {
 	return buffer.Bytes(), nil
 }
 
+type JsonLeaf struct {
+	// JSONPath of the leaf, ex. $.Tags[0].Value
+	Path  string
+	Value any
+}
+
+// JsonLeaves returns every leaf value (string, number, bool or null) of a JSON string
+// with its JSONPath, in the order of sorted object keys and array indexes.
+func JsonLeaves(jsonStr string) ([]JsonLeaf, error) {
+	var jsonObj any
+	if err := json.Unmarshal([]byte(jsonStr), &jsonObj); err != nil {
+		return nil, fmt.Errorf("JsonLeaves: json unmarshal: %w", err)
+	}
+
+	leaves := []JsonLeaf{}
+	jsonLeaves("$", jsonObj, &leaves)
+	return leaves, nil
+}
+
+func jsonLeaves(path string, data any, leaves *[]JsonLeaf) {
+	switch v := data.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			jsonLeaves(jsonChildPath(path, key), v[key], leaves)
+		}
+	case []any:
+		for i, elem := range v {
+			jsonLeaves(fmt.Sprintf("%s[%d]", path, i), elem, leaves)
+		}
+	default:
+		*leaves = append(*leaves, JsonLeaf{Path: path, Value: v})
+	}
+}
+
 // normalize recursively normalizes a JSON object by sorting the keys of each object.
 func normalize(data interface{}) interface{} {
 	switch v := data.(type) {
@@ -2,15 +2,24 @@ package shared
 
 import (
 	"context"
+	"errors"
 
 	"github.com/hashicorp/go-plugin"
 	"github.com/liuminhaw/mist-miner/proto"
 	"google.golang.org/grpc"
 )
 
+// Plugin protocol versions. Plugins of PROTOCOL_VERSION_LEGACY implement Mine without
+// context, they are still served over the same gRPC service but do not see deadlines
+// or cancellation of the host, the host kills them instead.
+const (
+	PROTOCOL_VERSION_LEGACY = 1
+	PROTOCOL_VERSION        = 2
+)
+
 // Handshake is a common handshake that is shared by pluginlugin and host.
 var Handshake = plugin.HandshakeConfig{
-	ProtocolVersion:  1,
+	ProtocolVersion:  PROTOCOL_VERSION,
 	MagicCookieKey:   "MINER",
 	MagicCookieValue: "mining-elf",
 }
@@ -20,11 +29,29 @@ var PluginMap = map[string]plugin.Plugin{
 	"miner_grpc": &MinerGRPCPlugin{},
 }
 
-// Miner is the interface that we're exposing as a plugin
+// VersionedPlugins are the plugin maps of every protocol version supported by the host,
+// the version of a plugin is negotiated when it starts.
+var VersionedPlugins = map[int]plugin.PluginSet{
+	PROTOCOL_VERSION_LEGACY: PluginMap,
+	PROTOCOL_VERSION:        PluginMap,
+}
+
+// Miner is the interface that we're exposing as a plugin, ctx is cancelled when the
+// plug times out or mining is interrupted.
 type Miner interface {
-	Mine(MinerConfig) (MinerResources, error)
+	Mine(ctx context.Context, config MinerConfig) (MinerResources, error)
 }
 
+// StreamMiner is optionally implemented by plugins to send resources one at a time
+// instead of returning all of them from Mine, which is used by the host otherwise.
+type StreamMiner interface {
+	MineStream(ctx context.Context, config MinerConfig, send func(MinerResource) error) error
+}
+
+// ErrStreamUnsupported is returned by GRPCClient.MineStream if the plugin does not
+// implement MineStream, the host falls back to Mine.
+var ErrStreamUnsupported = errors.New("plugin does not support streaming resources")
+
 type MinerGRPCPlugin struct {
 	plugin.Plugin
 	// Miner concreate implementation
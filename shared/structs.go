@@ -2,10 +2,13 @@ package shared
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/hcl/v2/gohcl"
 	"github.com/hashicorp/hcl/v2/hclparse"
@@ -23,19 +26,19 @@ type MinerConfig struct {
 }
 
 type MinerPropertyLabel struct {
-	Name   string `json:"name"`
-	Unique bool   `json:"unique"`
+	Name   string `json:"name" yaml:"name"`
+	Unique bool   `json:"unique" yaml:"unique"`
 }
 
 type MinerPropertyContent struct {
-	Format string `json:"format"`
-	Value  string `json:"value"`
+	Format string `json:"format" yaml:"format"`
+	Value  string `json:"value" yaml:"value"`
 }
 
 type MinerProperty struct {
-	Type    string               `json:"type"`
-	Label   MinerPropertyLabel   `json:"label"`
-	Content MinerPropertyContent `json:"content"`
+	Type    string               `json:"type" yaml:"type"`
+	Label   MinerPropertyLabel   `json:"label" yaml:"label"`
+	Content MinerPropertyContent `json:"content" yaml:"content"`
 }
 
 // FormatContentValue set input data to desired property content value based on the content format.
@@ -88,10 +91,10 @@ func NewMinerDiary(hash, currLog, prevLog string) MinerDiary {
 }
 
 type MinerResource struct {
-	Identifier string          `json:"identifier"`
-	Alias      string          `json:"alias"`
-	LogType    string          `json:"logType"`
-	Properties []MinerProperty `json:"properties"`
+	Identifier string          `json:"identifier" yaml:"identifier"`
+	Alias      string          `json:"alias" yaml:"alias"`
+	LogType    string          `json:"logType" yaml:"logType"`
+	Properties []MinerProperty `json:"properties" yaml:"properties"`
 }
 
 func (m *MinerResource) Sort() {
@@ -171,12 +174,55 @@ type MinerResources []MinerResource
 
 // HCL config structure
 type HclConfig struct {
-	Plugs []Plug `hcl:"plug,block"`
+	Shelf   *ShelfConfig   `hcl:"shelf,block"`
+	Signing *SigningConfig `hcl:"signing,block"`
+	Mine    *MineConfig    `hcl:"mine,block"`
+	Plugs   []Plug         `hcl:"plug,block"`
+}
+
+// MineConfig sets how plugins are run by mine, Parallel is the number of plugins
+// running concurrently, 1 if not set.
+type MineConfig struct {
+	Parallel int `hcl:"parallel,optional"`
+}
+
+// ShelfConfig selects the storage backend of the shelf, the shelf directory on the
+// local filesystem is used if backend is empty or "fs". Compression (zlib, zstd, none)
+// and Hash (sha256, blake3) are the object format of new groups, zlib and sha256 if empty.
+// KeyFile holds the base64 encoded key encrypting objects at rest, relative paths are
+// resolved from the config file directory.
+type ShelfConfig struct {
+	Backend     string         `hcl:"backend,optional"`
+	Compression string         `hcl:"compression,optional"`
+	Hash        string         `hcl:"hash,optional"`
+	KeyFile     string         `hcl:"key_file,optional"`
+	S3          *ShelfS3Config `hcl:"s3,block"`
+}
+
+// SigningConfig sets the ed25519 key signing label marks and the public keys trusted
+// when verifying signatures, both stored as PEM files. Relative paths are resolved
+// from the config file directory.
+type SigningConfig struct {
+	KeyFile         string   `hcl:"key_file,optional"`
+	TrustedKeyFiles []string `hcl:"trusted_key_files,optional"`
+}
+
+// ShelfS3Config configures the S3 compatible storage backend, credentials are read
+// from AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY if not set.
+type ShelfS3Config struct {
+	Endpoint  string `hcl:"endpoint,attr"`
+	Bucket    string `hcl:"bucket,attr"`
+	Region    string `hcl:"region,optional"`
+	Prefix    string `hcl:"prefix,optional"`
+	AccessKey string `hcl:"access_key,optional"`
+	SecretKey string `hcl:"secret_key,optional"`
 }
 
 type Plug struct {
 	Name          string            `hcl:"name,label"`
 	Group         string            `hcl:"group,label"`
+	Timeout       string            `hcl:"timeout,optional"`
+	Sha256        string            `hcl:"sha256,optional"`
 	Authenticator map[string]string `hcl:"authenticator,attr"`
 	Diaries       []PlugDiary       `hcl:"diary,block"`
 	Equipments    []PlugEquipment   `hcl:"equipment,block"`
@@ -198,6 +244,35 @@ func (p Plug) GenMinerConfig() MinerConfig {
 	}
 }
 
+// MineTimeout returns the duration the plugin is given to mine, zero if the plug
+// has no timeout. Timeout is a duration like 90s or 10m.
+func (p Plug) MineTimeout() (time.Duration, error) {
+	if p.Timeout == "" {
+		return 0, nil
+	}
+	timeout, err := time.ParseDuration(p.Timeout)
+	if err != nil {
+		return 0, fmt.Errorf("plug %s %s timeout: %w", p.Name, p.Group, err)
+	}
+	if timeout <= 0 {
+		return 0, fmt.Errorf("plug %s %s timeout: %s is not positive", p.Name, p.Group, p.Timeout)
+	}
+	return timeout, nil
+}
+
+// Checksum returns the pinned sha256 of the plugin binary, nil if the plug does not
+// pin the binary. Sha256 is hex encoded, ex. output of sha256sum.
+func (p Plug) Checksum() ([]byte, error) {
+	if p.Sha256 == "" {
+		return nil, nil
+	}
+	checksum, err := hex.DecodeString(p.Sha256)
+	if err != nil || len(checksum) != sha256.Size {
+		return nil, fmt.Errorf("plug %s %s sha256: expect %d hex encoded bytes: %s", p.Name, p.Group, sha256.Size, p.Sha256)
+	}
+	return checksum, nil
+}
+
 type PlugEquipment struct {
 	Type       string            `hcl:"type,label"`
 	Name       string            `hcl:"name,label"`
@@ -237,6 +312,14 @@ func ReadConfig(path string) (*HclConfig, error) {
 
 		return nil, fmt.Errorf("read config: decode body: %s", combinedErr)
 	}
+	for _, plug := range config.Plugs {
+		if _, err := plug.MineTimeout(); err != nil {
+			return nil, fmt.Errorf("read config: %w", err)
+		}
+		if _, err := plug.Checksum(); err != nil {
+			return nil, fmt.Errorf("read config: %w", err)
+		}
+	}
 
 	return &config, nil
 }
@@ -0,0 +1,31 @@
+package shared
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestPlugChecksum(t *testing.T) {
+	sum := sha256.Sum256([]byte("plugin"))
+	pin := hex.EncodeToString(sum[:])
+
+	for _, pinned := range []string{pin, strings.ToUpper(pin)} {
+		checksum, err := Plug{Name: "aws-iam", Group: "grp", Sha256: pinned}.Checksum()
+		if err != nil || !bytes.Equal(checksum, sum[:]) {
+			t.Errorf("Checksum(%s) = %x, %v, want %x", pinned, checksum, err, sum)
+		}
+	}
+
+	if checksum, err := (Plug{Name: "aws-iam", Group: "grp"}).Checksum(); checksum != nil || err != nil {
+		t.Errorf("Checksum without sha256 = %x, %v, want not pinned", checksum, err)
+	}
+
+	for _, invalid := range []string{"not hex", pin[:62], pin + "00", "zz" + pin[2:]} {
+		if _, err := (Plug{Name: "aws-iam", Group: "grp", Sha256: invalid}).Checksum(); err == nil {
+			t.Errorf("Checksum(%s) succeeded, want error", invalid)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package shared
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidTimeSpec = errors.New("invalid time, expect RFC3339, date or duration like 7d")
+
+// ParseTimeSpec parses a point in time given as
+//   - RFC3339 timestamp, ex. 2026-09-01T00:00:00Z
+//   - date in local time zone, ex. 2026-09-01 is the start of the day
+//   - duration before now with unit s, m, h, d or w, ex. 90m, 12h, 7d, 2w
+func ParseTimeSpec(spec string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, spec); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, spec, time.Local); err == nil {
+		return t, nil
+	}
+
+	d, err := ParseDuration(spec)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("ParseTimeSpec(%s): %w", spec, ErrInvalidTimeSpec)
+	}
+	return now.Add(-d), nil
+}
+
+// ParseDuration parses a duration of a non negative integer followed by unit
+// s (seconds), m (minutes), h (hours), d (days) or w (weeks), ex. 7d.
+func ParseDuration(spec string) (time.Duration, error) {
+	units := map[string]time.Duration{
+		"s": time.Second,
+		"m": time.Minute,
+		"h": time.Hour,
+		"d": 24 * time.Hour,
+		"w": 7 * 24 * time.Hour,
+	}
+
+	spec = strings.TrimSpace(spec)
+	if len(spec) < 2 {
+		return 0, fmt.Errorf("ParseDuration(%s): %w", spec, ErrInvalidTimeSpec)
+	}
+	unit, ok := units[spec[len(spec)-1:]]
+	if !ok {
+		return 0, fmt.Errorf("ParseDuration(%s): %w", spec, ErrInvalidTimeSpec)
+	}
+	n, err := strconv.ParseUint(spec[:len(spec)-1], 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("ParseDuration(%s): %w", spec, ErrInvalidTimeSpec)
+	}
+
+	return time.Duration(n) * unit, nil
+}
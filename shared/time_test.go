@@ -0,0 +1,40 @@
+package shared
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseTimeSpec(t *testing.T) {
+	now := time.Date(2026, 9, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{spec: "2026-08-01T10:00:00Z", want: time.Date(2026, 8, 1, 10, 0, 0, 0, time.UTC)},
+		{spec: "2026-08-01", want: time.Date(2026, 8, 1, 0, 0, 0, 0, time.Local)},
+		{spec: "30s", want: now.Add(-30 * time.Second)},
+		{spec: "90m", want: now.Add(-90 * time.Minute)},
+		{spec: "12h", want: now.Add(-12 * time.Hour)},
+		{spec: "7d", want: now.AddDate(0, 0, -7)},
+		{spec: "2w", want: now.AddDate(0, 0, -14)},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseTimeSpec(tt.spec, now)
+		if err != nil {
+			t.Errorf("ParseTimeSpec(%s): %v", tt.spec, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("ParseTimeSpec(%s) = %s, want %s", tt.spec, got, tt.want)
+		}
+	}
+
+	for _, spec := range []string{"", "d", "7", "7y", "-7d", "1.5d", "yesterday", "2026-13-01"} {
+		if _, err := ParseTimeSpec(spec, now); !errors.Is(err, ErrInvalidTimeSpec) {
+			t.Errorf("ParseTimeSpec(%s) error = %v, want %v", spec, err, ErrInvalidTimeSpec)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package shelf
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/liuminhaw/mist-miner/shared"
+)
+
+// MarkAt returns the newest label mark in the chain of HEAD whose timestamp is
+// at or before t, return ErrNoMarkAt if every mark is newer than t.
+func MarkAt(group string, t time.Time) (string, error) {
+	head, err := NewRefMark(SHELF_MARK_FILE, group)
+	if err != nil {
+		return "", fmt.Errorf("MarkAt(%s, %s): %w", group, t.Format(time.RFC3339), err)
+	}
+
+	for hash := string(head.Reference); hash != "nil"; {
+		mark, err := ReadMark(group, hash)
+		if err != nil {
+			return "", fmt.Errorf("MarkAt(%s, %s): %w", group, t.Format(time.RFC3339), err)
+		}
+		if !mark.TimeStamp.After(t) {
+			return hash, nil
+		}
+		hash = mark.Parent
+	}
+
+	return "", fmt.Errorf("MarkAt(%s, %s): %w", group, t.Format(time.RFC3339), ErrNoMarkAt)
+}
+
+// ResolveMarkAt returns the label mark of the group at the time given by spec,
+// spec is parsed by shared.ParseTimeSpec relative to current time.
+func ResolveMarkAt(group, spec string) (string, error) {
+	t, err := shared.ParseTimeSpec(spec, time.Now())
+	if err != nil {
+		return "", fmt.Errorf("ResolveMarkAt(%s, %s): %w", group, spec, err)
+	}
+
+	hash, err := MarkAt(group, t)
+	if err != nil {
+		return "", fmt.Errorf("ResolveMarkAt(%s, %s): %w", group, spec, err)
+	}
+	return hash, nil
+}
@@ -0,0 +1,177 @@
+package shelf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+
+	"github.com/liuminhaw/mist-miner/locks"
+	"github.com/liuminhaw/mist-miner/paths"
+	"github.com/liuminhaw/mist-miner/store"
+)
+
+// backend is the storage backend of objects and references, the filesystem shelf
+// directory is used if not set.
+var backend store.Store
+
+// SetStore sets the storage backend used by the shelf.
+// Exclusive file locks are also taken in backends shared between machines.
+func SetStore(s store.Store) {
+	backend = s
+	locker, _ := s.(store.Locker)
+	locks.SetRemote(locker)
+}
+
+// shelfStore returns the storage backend of the shelf
+func shelfStore() (store.Store, error) {
+	if backend != nil {
+		return backend, nil
+	}
+
+	dir, err := paths.ShelfDir()
+	if err != nil {
+		return nil, fmt.Errorf("shelfStore(): %w", err)
+	}
+	return store.NewFS(dir), nil
+}
+
+// deleteObject removes the loose object from the storage backend
+func deleteObject(group, hash string) error {
+	s, err := shelfStore()
+	if err != nil {
+		return fmt.Errorf("deleteObject(%s, %s): %w", group, hash, err)
+	}
+	if err := s.DeleteObject(group, hash); err != nil {
+		return fmt.Errorf("deleteObject(%s, %s): %w", group, hash, err)
+	}
+	return nil
+}
+
+// readRef returns the content of the reference, missing references are reported
+// by errors wrapping fs.ErrNotExist.
+func readRef(group, name string) ([]byte, error) {
+	s, err := shelfStore()
+	if err != nil {
+		return nil, fmt.Errorf("readRef(%s, %s): %w", group, name, err)
+	}
+	content, err := s.ReadRef(group, name)
+	if err != nil {
+		return nil, fmt.Errorf("readRef(%s, %s): %w", group, name, err)
+	}
+	return content, nil
+}
+
+// writeRef writes the reference content, replacing existing content.
+func writeRef(group, name string, content []byte) error {
+	s, err := shelfStore()
+	if err != nil {
+		return fmt.Errorf("writeRef(%s, %s): %w", group, name, err)
+	}
+	if err := s.WriteRef(group, name, content); err != nil {
+		return fmt.Errorf("writeRef(%s, %s): %w", group, name, err)
+	}
+	return nil
+}
+
+// readCompressedRef returns the decrypted and decompressed content of a zlib compressed
+// history record.
+func readCompressedRef(group, name string) ([]byte, error) {
+	content, err := readRef(group, name)
+	if err != nil {
+		return nil, fmt.Errorf("readCompressedRef(%s, %s): %w", group, name, err)
+	}
+	if content, err = unseal(content); err != nil {
+		return nil, fmt.Errorf("readCompressedRef(%s, %s): %w", group, name, err)
+	}
+
+	zr, err := zlib.NewReader(bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("readCompressedRef(%s, %s): %w", group, name, err)
+	}
+	defer zr.Close()
+
+	decompressed, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("readCompressedRef(%s, %s): %w", group, name, err)
+	}
+	return decompressed, nil
+}
+
+// writeCompressedRef writes the history record content compressed with zlib,
+// and encrypted if an encryption key is set.
+func writeCompressedRef(group, name string, content []byte) error {
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	if _, err := w.Write(content); err != nil {
+		return fmt.Errorf("writeCompressedRef(%s, %s): %w", group, name, err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("writeCompressedRef(%s, %s): %w", group, name, err)
+	}
+
+	sealed, err := seal(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("writeCompressedRef(%s, %s): %w", group, name, err)
+	}
+	if err := writeRef(group, name, sealed); err != nil {
+		return fmt.Errorf("writeCompressedRef(%s, %s): %w", group, name, err)
+	}
+	return nil
+}
+
+// refExist checks if the reference exists
+func refExist(group, name string) (bool, error) {
+	s, err := shelfStore()
+	if err != nil {
+		return false, fmt.Errorf("refExist(%s, %s): %w", group, name, err)
+	}
+	if _, err := s.ReadRef(group, name); errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("refExist(%s, %s): %w", group, name, err)
+	}
+	return true, nil
+}
+
+// deleteRef removes the reference, missing references are reported by errors
+// wrapping fs.ErrNotExist.
+func deleteRef(group, name string) error {
+	s, err := shelfStore()
+	if err != nil {
+		return fmt.Errorf("deleteRef(%s, %s): %w", group, name, err)
+	}
+	if err := s.DeleteRef(group, name); err != nil {
+		return fmt.Errorf("deleteRef(%s, %s): %w", group, name, err)
+	}
+	return nil
+}
+
+// listRefs returns names of references under the prefix directory
+func listRefs(group, prefix string) ([]string, error) {
+	s, err := shelfStore()
+	if err != nil {
+		return nil, fmt.Errorf("listRefs(%s, %s): %w", group, prefix, err)
+	}
+	names, err := s.ListRefs(group, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("listRefs(%s, %s): %w", group, prefix, err)
+	}
+	return names, nil
+}
+
+// deleteRefs removes all references under the prefix directory
+func deleteRefs(group, prefix string) error {
+	names, err := listRefs(group, prefix)
+	if err != nil {
+		return fmt.Errorf("deleteRefs(%s, %s): %w", group, prefix, err)
+	}
+	for _, name := range names {
+		if err := deleteRef(group, name); err != nil {
+			return fmt.Errorf("deleteRefs(%s, %s): %w", group, prefix, err)
+		}
+	}
+	return nil
+}
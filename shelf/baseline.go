@@ -0,0 +1,77 @@
+package shelf
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"github.com/liuminhaw/mist-miner/locks"
+)
+
+// SetBaseline sets the approved baseline of the group to the label mark hash,
+// the baseline is stored as the BASELINE reference.
+// Will use flock on references to prevent concurrent writing,
+// return locks.ErrIsLocked if file lock is not acquired.
+func SetBaseline(group, markHash string) error {
+	if _, err := ReadMark(group, markHash); err != nil {
+		return fmt.Errorf("SetBaseline(%s, %s): %w", group, markHash, err)
+	}
+
+	fileLock, err := locks.NewLock(group, locks.REF_MARK_LOCKFILE)
+	if err != nil {
+		return fmt.Errorf("SetBaseline(%s, %s): %w", group, markHash, err)
+	}
+	if err := fileLock.TryLock(); err != nil {
+		if errors.Is(err, locks.ErrIsLocked) {
+			return err
+		}
+		return fmt.Errorf("SetBaseline(%s, %s): %w", group, markHash, err)
+	}
+	defer fileLock.Unlock()
+
+	baseline := RefMark{Name: SHELF_BASELINE_FILE, Group: group, Reference: []byte(markHash)}
+	if err := baseline.write(); err != nil {
+		return fmt.Errorf("SetBaseline(%s, %s): %w", group, markHash, err)
+	}
+
+	return nil
+}
+
+// Baseline returns the label mark hash of the approved baseline of the group,
+// return ErrNoBaseline if baseline is not set.
+func Baseline(group string) (string, error) {
+	baseline, err := NewRefMark(SHELF_BASELINE_FILE, group)
+	if errors.Is(err, ErrRefHeadNotFound) {
+		return "", ErrNoBaseline
+	} else if err != nil {
+		return "", fmt.Errorf("Baseline(%s): %w", group, err)
+	}
+
+	return string(baseline.Reference), nil
+}
+
+// ClearBaseline removes the approved baseline of the group,
+// return ErrNoBaseline if baseline is not set.
+// Will use flock on references to prevent concurrent writing,
+// return locks.ErrIsLocked if file lock is not acquired.
+func ClearBaseline(group string) error {
+	fileLock, err := locks.NewLock(group, locks.REF_MARK_LOCKFILE)
+	if err != nil {
+		return fmt.Errorf("ClearBaseline(%s): %w", group, err)
+	}
+	if err := fileLock.TryLock(); err != nil {
+		if errors.Is(err, locks.ErrIsLocked) {
+			return err
+		}
+		return fmt.Errorf("ClearBaseline(%s): %w", group, err)
+	}
+	defer fileLock.Unlock()
+
+	if err := deleteRef(group, SHELF_BASELINE_FILE); errors.Is(err, fs.ErrNotExist) {
+		return ErrNoBaseline
+	} else if err != nil {
+		return fmt.Errorf("ClearBaseline(%s): %w", group, err)
+	}
+
+	return nil
+}
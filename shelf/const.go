@@ -4,21 +4,31 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+
+	"github.com/liuminhaw/mist-miner/paths"
 )
 
 const (
-	shelf_dir = ".miner"
-
-	shelf_ref_dir             = "refs"
-	shelf_object_dir          = "objects"
-	shelf_diary_dir           = "diaries"
-	shelf_history_dir         = "history"
-	shelf_history_logger_dir  = "logger"
-	shelf_history_pointer_dir = "pointer"
+	shelf_ref_dir              = "refs"
+	shelf_tag_dir              = "tags"
+	shelf_object_dir           = "objects"
+	shelf_diary_dir            = "diaries"
+	shelf_history_dir          = "history"
+	shelf_history_logger_dir   = "logger"
+	shelf_history_pointer_dir  = "pointer"
+	shelf_history_timeline_dir = "timeline"
+	shelf_history_search_dir   = "search"
 
 	SHELF_MARK_FILE            = "HEAD"
+	SHELF_BASELINE_FILE        = "BASELINE"
 	SHELF_HISTORY_FILE         = "logger"
 	shelf_history_pointer_file = "next.map"
+	shelf_search_segment_file  = "segment"
+	shelf_search_manifest_file = "manifest"
+
+	// Newest search index segments are merged while the older one holds less than
+	// search_merge_factor times the documents of the newer one
+	search_merge_factor = 4
 
 	SHELF_HISTORY_LOGS_PREV = "<<<..."
 	SHELF_HISTORY_LOGS_NEXT = "...>>>"
@@ -31,15 +41,14 @@ const (
 	LOG_TYPE_DIARY = "diary"
 )
 
-// RefFile returns the file path to store the reference to the latest record mark
-// with the given file name
-func RefFile(group, name string) (string, error) {
-	execPath, err := os.Executable()
+// groupDir returns the directory path storing the shelf records of the given group
+func groupDir(group string) (string, error) {
+	dir, err := paths.ShelfDir()
 	if err != nil {
-		return "", fmt.Errorf("RefFile(%s, %s): get executable: %w", group, name, err)
+		return "", fmt.Errorf("groupDir(%s): %w", group, err)
 	}
 
-	return filepath.Join(filepath.Dir(execPath), shelf_dir, group, shelf_ref_dir, name), nil
+	return filepath.Join(dir, group), nil
 }
 
 func ShelfTempDiary() string {
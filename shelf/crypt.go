@@ -0,0 +1,228 @@
+package shelf
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Objects and history records are optionally encrypted at rest with AES-256-GCM after
+// compression. Encrypted content is laid out as
+//
+//	"\x00MMENC" <version> <key id> <nonce> <ciphertext>
+//
+// key id is the first bytes of the sha256 of the key, so content encrypted with previous
+// keys is decrypted with the matching key after rotation. Hashes of objects are
+// calculated on the plaintext payload, encryption does not change object hashes.
+const (
+	SHELF_KEY_ENV = "MIST_MINER_SHELF_KEY"
+
+	encryption_magic   = "\x00MMENC"
+	encryption_version = 1
+	encryption_key_len = 32
+	encryption_id_len  = 8
+)
+
+// objectKey is an AES-GCM key of the shelf identified by id
+type objectKey struct {
+	id   []byte
+	aead cipher.AEAD
+}
+
+// Key encrypting content written from now on and keys decrypting content by key id
+var keys = struct {
+	sync.RWMutex
+	current *objectKey
+	ring    map[string]*objectKey
+}{
+	ring: make(map[string]*objectKey),
+}
+
+func newObjectKey(key []byte) (*objectKey, error) {
+	if len(key) != encryption_key_len {
+		return nil, fmt.Errorf("key of %d bytes: %w", len(key), ErrInvalidKey)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("new object key: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("new object key: %w", err)
+	}
+	sum := sha256.Sum256(key)
+	return &objectKey{id: sum[:encryption_id_len], aead: aead}, nil
+}
+
+// SetEncryptionKey sets the key encrypting objects and history records written from
+// now on, the key also decrypts content encrypted with it. A nil key writes plaintext.
+func SetEncryptionKey(key []byte) error {
+	if key == nil {
+		keys.Lock()
+		defer keys.Unlock()
+		keys.current = nil
+		return nil
+	}
+
+	k, err := newObjectKey(key)
+	if err != nil {
+		return fmt.Errorf("SetEncryptionKey(): %w", err)
+	}
+	keys.Lock()
+	defer keys.Unlock()
+	keys.current = k
+	keys.ring[string(k.id)] = k
+	return nil
+}
+
+// AddDecryptionKey adds a previous key to decrypt content encrypted before key rotation.
+func AddDecryptionKey(key []byte) error {
+	k, err := newObjectKey(key)
+	if err != nil {
+		return fmt.Errorf("AddDecryptionKey(): %w", err)
+	}
+	keys.Lock()
+	defer keys.Unlock()
+	keys.ring[string(k.id)] = k
+	return nil
+}
+
+// DecodeKey decodes a base64 encoded key of 32 bytes, ex. generated by
+// head -c 32 /dev/urandom | base64
+func DecodeKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("DecodeKey(): %w: %v", ErrInvalidKey, err)
+	}
+	if len(key) != encryption_key_len {
+		return nil, fmt.Errorf("DecodeKey(): key of %d bytes: %w", len(key), ErrInvalidKey)
+	}
+	return key, nil
+}
+
+// ReadKeyFile reads the base64 encoded key stored in the file of given path
+func ReadKeyFile(path string) ([]byte, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("ReadKeyFile(%s): %w", path, err)
+	}
+	key, err := DecodeKey(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("ReadKeyFile(%s): %w", path, err)
+	}
+	return key, nil
+}
+
+// seal encrypts the content with the current key, content is returned as is if
+// no key is set.
+func seal(content []byte) ([]byte, error) {
+	keys.RLock()
+	k := keys.current
+	keys.RUnlock()
+	if k == nil {
+		return content, nil
+	}
+
+	prefix := append([]byte(encryption_magic), encryption_version)
+	prefix = append(prefix, k.id...)
+	nonce := make([]byte, k.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("seal: %w", err)
+	}
+
+	sealed := make([]byte, 0, len(prefix)+len(nonce)+len(content)+k.aead.Overhead())
+	sealed = append(append(sealed, prefix...), nonce...)
+	return k.aead.Seal(sealed, nonce, content, prefix), nil
+}
+
+// unseal decrypts content encrypted by seal with the key of its key id,
+// plaintext content is returned as is.
+func unseal(content []byte) ([]byte, error) {
+	if !isSealed(content) {
+		return content, nil
+	}
+	headerLen := len(encryption_magic) + 1 + encryption_id_len
+	if len(content) < headerLen {
+		return nil, fmt.Errorf("unseal: %w", ErrCorruptEncryption)
+	}
+	if content[len(encryption_magic)] != encryption_version {
+		return nil, fmt.Errorf("unseal: version %d: %w", content[len(encryption_magic)], ErrCorruptEncryption)
+	}
+	id := content[len(encryption_magic)+1 : headerLen]
+
+	keys.RLock()
+	k, ok := keys.ring[string(id)]
+	noKey := keys.current == nil && len(keys.ring) == 0
+	keys.RUnlock()
+	if noKey {
+		return nil, fmt.Errorf("unseal: %w", ErrEncryptionKeyNotSet)
+	} else if !ok {
+		return nil, fmt.Errorf("unseal: key id %s: %w", hex.EncodeToString(id), ErrUnknownKey)
+	}
+
+	nonceSize := k.aead.NonceSize()
+	if len(content) < headerLen+nonceSize {
+		return nil, fmt.Errorf("unseal: %w", ErrCorruptEncryption)
+	}
+	nonce := content[headerLen : headerLen+nonceSize]
+	plain, err := k.aead.Open(nil, nonce, content[headerLen+nonceSize:], content[:headerLen])
+	if err != nil {
+		return nil, fmt.Errorf("unseal: %w: %v", ErrCorruptEncryption, err)
+	}
+	return plain, nil
+}
+
+// isSealed reports whether the content is encrypted by seal
+func isSealed(content []byte) bool {
+	return bytes.HasPrefix(content, []byte(encryption_magic))
+}
+
+// sealedByCurrent reports whether the content is encrypted with the current key,
+// or is plaintext when no key is set.
+func sealedByCurrent(content []byte) bool {
+	keys.RLock()
+	k := keys.current
+	keys.RUnlock()
+	if k == nil {
+		return !isSealed(content)
+	}
+
+	offset := len(encryption_magic) + 1
+	return isSealed(content) &&
+		len(content) >= offset+encryption_id_len &&
+		bytes.Equal(content[offset:offset+encryption_id_len], k.id)
+}
+
+// openReader returns a reader of the decrypted and decompressed content of r.
+// Encrypted content is read as a whole for authentication before decompression.
+func openReader(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	peek, err := br.Peek(len(encryption_magic))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("open reader: %w", err)
+	}
+	if !isSealed(peek) {
+		return decompressReader(br)
+	}
+
+	content, err := io.ReadAll(br)
+	if err != nil {
+		return nil, fmt.Errorf("open reader: %w", err)
+	}
+	plain, err := unseal(content)
+	if err != nil {
+		return nil, fmt.Errorf("open reader: %w", err)
+	}
+	return decompressReader(bytes.NewReader(plain))
+}
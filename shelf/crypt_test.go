@@ -0,0 +1,168 @@
+package shelf
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"testing"
+)
+
+// testKey returns a random encryption key, the key ring is emptied after the test.
+func testKey(t *testing.T) []byte {
+	t.Helper()
+
+	key := make([]byte, encryption_key_len)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatalf("rand: %v", err)
+	}
+	t.Cleanup(resetKeys)
+	return key
+}
+
+func resetKeys() {
+	keys.Lock()
+	defer keys.Unlock()
+	keys.current = nil
+	keys.ring = make(map[string]*objectKey)
+}
+
+func TestSealUnseal(t *testing.T) {
+	key := testKey(t)
+	plain := []byte("compressed object content")
+
+	if sealed, err := seal(plain); err != nil || !bytes.Equal(sealed, plain) {
+		t.Errorf("seal without key = %q, %v, want plaintext", sealed, err)
+	}
+
+	if err := SetEncryptionKey(key); err != nil {
+		t.Fatalf("SetEncryptionKey: %v", err)
+	}
+	sealed, err := seal(plain)
+	if err != nil {
+		t.Fatalf("seal: %v", err)
+	}
+	if !isSealed(sealed) || bytes.Contains(sealed, plain) || !sealedByCurrent(sealed) {
+		t.Errorf("seal = %q, want content encrypted with the current key", sealed)
+	}
+	again, _ := seal(plain)
+	if bytes.Equal(sealed, again) {
+		t.Errorf("seal twice gives the same ciphertext, want a fresh nonce")
+	}
+	if got, err := unseal(sealed); err != nil || !bytes.Equal(got, plain) {
+		t.Errorf("unseal = %q, %v, want %q", got, err, plain)
+	}
+	if got, err := unseal(plain); err != nil || !bytes.Equal(got, plain) {
+		t.Errorf("unseal plaintext = %q, %v, want it as is", got, err)
+	}
+
+	// Rotated key still decrypts content of the previous key added to the ring
+	if err := SetEncryptionKey(testKey(t)); err != nil {
+		t.Fatalf("SetEncryptionKey: %v", err)
+	}
+	if sealedByCurrent(sealed) {
+		t.Errorf("content of the previous key is reported as sealed by the current key")
+	}
+	if got, err := unseal(sealed); err != nil || !bytes.Equal(got, plain) {
+		t.Errorf("unseal after rotation = %q, %v, want %q", got, err, plain)
+	}
+}
+
+func TestUnsealErrors(t *testing.T) {
+	key := testKey(t)
+	if err := SetEncryptionKey(key); err != nil {
+		t.Fatalf("SetEncryptionKey: %v", err)
+	}
+	sealed, err := seal([]byte("compressed object content"))
+	if err != nil {
+		t.Fatalf("seal: %v", err)
+	}
+	tamper := func(i int) []byte {
+		content := bytes.Clone(sealed)
+		content[i] ^= 0x01
+		return content
+	}
+	headerLen := len(encryption_magic) + 1 + encryption_id_len
+
+	tests := []struct {
+		name    string
+		content []byte
+		want    error
+	}{
+		{name: "tampered ciphertext", content: tamper(len(sealed) - 1), want: ErrCorruptEncryption},
+		{name: "tampered nonce", content: tamper(headerLen), want: ErrCorruptEncryption},
+		{name: "truncated", content: sealed[:headerLen+4], want: ErrCorruptEncryption},
+		{name: "unknown version", content: tamper(len(encryption_magic)), want: ErrCorruptEncryption},
+		{name: "unknown key id", content: tamper(headerLen - 1), want: ErrUnknownKey},
+	}
+	for _, tt := range tests {
+		if _, err := unseal(tt.content); !errors.Is(err, tt.want) {
+			t.Errorf("unseal %s error = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	// Wrong key
+	resetKeys()
+	if err := SetEncryptionKey(testKey(t)); err != nil {
+		t.Fatalf("SetEncryptionKey: %v", err)
+	}
+	if _, err := unseal(sealed); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("unseal with wrong key error = %v, want %v", err, ErrUnknownKey)
+	}
+	// Wrong key with the id of the right one, ex. a corrupted key ring
+	wrong, err := newObjectKey(testKey(t))
+	if err != nil {
+		t.Fatalf("newObjectKey: %v", err)
+	}
+	keys.ring[string(sealed[len(encryption_magic)+1:headerLen])] = wrong
+	if _, err := unseal(sealed); !errors.Is(err, ErrCorruptEncryption) {
+		t.Errorf("unseal with wrong key of same id error = %v, want %v", err, ErrCorruptEncryption)
+	}
+
+	// No key
+	resetKeys()
+	if _, err := unseal(sealed); !errors.Is(err, ErrEncryptionKeyNotSet) {
+		t.Errorf("unseal without key error = %v, want %v", err, ErrEncryptionKeyNotSet)
+	}
+
+	if err := SetEncryptionKey(make([]byte, 16)); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("SetEncryptionKey of 16 bytes error = %v, want %v", err, ErrInvalidKey)
+	}
+	if _, err := DecodeKey("c2hvcnQ="); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("DecodeKey short key error = %v, want %v", err, ErrInvalidKey)
+	}
+}
+
+func TestEncryptedShelf(t *testing.T) {
+	s := useMemoryStore(t)
+	if err := SetEncryptionKey(testKey(t)); err != nil {
+		t.Fatalf("SetEncryptionKey: %v", err)
+	}
+
+	mark := writeTestMark(t, "grp", "aws-iam", testResource("alice", `{"secret":"value"}`))
+	objects, err := s.ListObjects("grp")
+	if err != nil {
+		t.Fatalf("ListObjects: %v", err)
+	}
+	for _, object := range objects {
+		r, err := s.GetObject("grp", object.Hash)
+		if err != nil {
+			t.Fatalf("GetObject: %v", err)
+		}
+		content, _ := io.ReadAll(r)
+		r.Close()
+		if !isSealed(content) {
+			t.Errorf("object %s is stored as plaintext", object.Hash)
+		}
+	}
+
+	found, err := LookupResource("grp", mark, "aws-iam", "alice")
+	if err != nil || found.Resource.Properties[0].Content.Value != `{"secret":"value"}` {
+		t.Errorf("LookupResource = %+v, %v, want the decrypted resource", found, err)
+	}
+
+	resetKeys()
+	if _, err := LookupResource("grp", mark, "aws-iam", "alice"); !errors.Is(err, ErrEncryptionKeyNotSet) {
+		t.Errorf("LookupResource without key error = %v, want %v", err, ErrEncryptionKeyNotSet)
+	}
+}
@@ -0,0 +1,107 @@
+package shelf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Delta instructions rebuilding a target from a base, each instruction is an op byte
+// followed by uvarint arguments:
+//
+//	c <offset> <length>       copy length bytes of base starting at offset
+//	i <length> <data>         insert length bytes of data following the instruction
+const (
+	delta_op_copy   = 'c'
+	delta_op_insert = 'i'
+
+	// Size of base blocks indexed for matching, shorter matches are inserted as is
+	delta_block_size = 16
+)
+
+var errInvalidDelta = errors.New("invalid delta")
+
+// encodeDelta returns instructions rebuilding target from base. Blocks of base are
+// indexed and matched against target, matches are extended in both directions.
+func encodeDelta(base, target []byte) []byte {
+	index := make(map[string]int)
+	for off := 0; off+delta_block_size <= len(base); off += delta_block_size {
+		block := string(base[off : off+delta_block_size])
+		if _, ok := index[block]; !ok {
+			index[block] = off
+		}
+	}
+
+	var delta bytes.Buffer
+	insert := func(data []byte) {
+		if len(data) == 0 {
+			return
+		}
+		delta.WriteByte(delta_op_insert)
+		delta.Write(binary.AppendUvarint(nil, uint64(len(data))))
+		delta.Write(data)
+	}
+
+	pending := 0
+	for i := 0; i+delta_block_size <= len(target); {
+		off, ok := index[string(target[i:i+delta_block_size])]
+		if !ok {
+			i++
+			continue
+		}
+
+		n := delta_block_size
+		for off+n < len(base) && i+n < len(target) && base[off+n] == target[i+n] {
+			n++
+		}
+		for off > 0 && i > pending && base[off-1] == target[i-1] {
+			off--
+			i--
+			n++
+		}
+
+		insert(target[pending:i])
+		delta.WriteByte(delta_op_copy)
+		delta.Write(binary.AppendUvarint(nil, uint64(off)))
+		delta.Write(binary.AppendUvarint(nil, uint64(n)))
+		i += n
+		pending = i
+	}
+	insert(target[pending:])
+
+	return delta.Bytes()
+}
+
+// applyDelta rebuilds the target from base by the delta instructions.
+func applyDelta(base, delta []byte) ([]byte, error) {
+	var target bytes.Buffer
+	r := bytes.NewReader(delta)
+	for r.Len() > 0 {
+		op, _ := r.ReadByte()
+		switch op {
+		case delta_op_copy:
+			off, err := binary.ReadUvarint(r)
+			if err != nil {
+				return nil, fmt.Errorf("apply delta: copy offset: %w", errInvalidDelta)
+			}
+			n, err := binary.ReadUvarint(r)
+			if err != nil || off+n > uint64(len(base)) {
+				return nil, fmt.Errorf("apply delta: copy length: %w", errInvalidDelta)
+			}
+			target.Write(base[off : off+n])
+		case delta_op_insert:
+			n, err := binary.ReadUvarint(r)
+			if err != nil || n > uint64(r.Len()) {
+				return nil, fmt.Errorf("apply delta: insert length: %w", errInvalidDelta)
+			}
+			data := make([]byte, n)
+			r.Read(data)
+			target.Write(data)
+		default:
+			return nil, fmt.Errorf("apply delta: unknown op %q: %w", op, errInvalidDelta)
+		}
+	}
+
+	return target.Bytes(), nil
+}
@@ -1,8 +1,6 @@
 package shelf
 
 import (
-	"compress/zlib"
-	"crypto/sha256"
 	"encoding/base64"
 	"errors"
 	"fmt"
@@ -281,13 +279,12 @@ func (d *DiaryStaticTempFile) CalcHash() (string, error) {
 		return "", fmt.Errorf("DiaryStaticTempFile CalcHash: %w", err)
 	}
 
-	h := sha256.New()
-	_, err = h.Write([]byte(content))
+	hash, err := objectHash(d.Meta.Group, []byte(content))
 	if err != nil {
 		return "", fmt.Errorf("DiaryStaticTempFile CalcHash: %w", err)
 	}
 
-	return fmt.Sprintf("%x", h.Sum(nil)), nil
+	return hash, nil
 }
 
 func (d *DiaryStaticTempFile) WriteDiary() (Diary, error) {
@@ -299,35 +296,22 @@ func (d *DiaryStaticTempFile) WriteDiary() (Diary, error) {
 	if err != nil {
 		return Diary{}, fmt.Errorf("diaryStaticTempFile WriteDiary: %w", err)
 	}
-	diaryFile, err := NewObjectRecord(d.Meta.Group, hash).RecordFile()
+	record := NewObjectRecord(d.Meta.Group, hash)
+	diaryFile, err := record.RecordFile()
 	if err != nil {
 		return Diary{}, fmt.Errorf("diaryStaticTempFile WriteDiary: %w", err)
 	}
 
-	if _, err := os.Stat(diaryFile); !errors.Is(err, os.ErrNotExist) {
+	if record.Exist() {
 		return Diary{}, fmt.Errorf(
 			"diaryStaticTempFile WriteDiary: diary file already exists: %s",
 			diaryFile,
 		)
 	}
 
-	err = os.MkdirAll(filepath.Dir(diaryFile), os.ModePerm)
-	if err != nil {
-		return Diary{}, fmt.Errorf("diaryStaticTempFile WriteDiary: mkdir: %w", err)
-	}
-
-	f, err := os.Create(diaryFile)
-	if err != nil {
-		return Diary{}, fmt.Errorf("diaryStaticTempFile WriteDiary: create file: %w", err)
-	}
-	defer f.Close()
-
-	w := zlib.NewWriter(f)
-	_, err = w.Write([]byte(content))
-	if err != nil {
-		return Diary{}, fmt.Errorf("diaryStaticTempFile WriteDiary: write file: %w", err)
+	if err := writeObject(d.Meta.Group, hash, OBJECT_TYPE_NOTE, []byte(content)); err != nil {
+		return Diary{}, fmt.Errorf("diaryStaticTempFile WriteDiary: %w", err)
 	}
-	defer w.Close()
 
 	return NewDiary(d.Meta.Group, d.Meta.Plugin, d.Meta.Identifier, d.Meta.Alias, hash), nil
 }
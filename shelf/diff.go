@@ -0,0 +1,232 @@
+package shelf
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/liuminhaw/mist-miner/shared"
+)
+
+const (
+	DIFF_STATUS_ADDED     = "added"
+	DIFF_STATUS_REMOVED   = "removed"
+	DIFF_STATUS_MODIFIED  = "modified"
+	DIFF_STATUS_UNCHANGED = "unchanged"
+)
+
+type ResourceChange struct {
+	Identifier string
+	Alias      string
+	Status     string
+	// Resource hashes of the stuff outline pointed in each side of the diff,
+	// empty if the resource does not exist on that side.
+	FromHash string
+	ToHash   string
+}
+
+type PluginDiff struct {
+	Plugin    string
+	Status    string
+	Resources []ResourceChange
+}
+
+// Changed reports whether there is any added, removed or modified resource in the plugin.
+func (pd PluginDiff) Changed() bool {
+	return len(pd.Resources) > 0
+}
+
+type MarkDiff struct {
+	Group   string
+	From    string
+	To      string
+	Plugins []PluginDiff
+}
+
+// Changed reports whether there is any resource difference between the two marks.
+func (md MarkDiff) Changed() bool {
+	for _, p := range md.Plugins {
+		if p.Changed() {
+			return true
+		}
+	}
+	return false
+}
+
+// DiffMarks compares two label marks of the given group at resource level.
+// Resources are matched by plugin name and identifier, and are considered modified
+// when the resource hash of their stuff outline differs.
+// fromHash can be "nil" or empty to compare against an empty mark.
+func DiffMarks(group, fromHash, toHash string) (*MarkDiff, error) {
+	from, err := readMarkResources(group, fromHash)
+	if err != nil {
+		return nil, fmt.Errorf("DiffMarks(%s): %w", group, err)
+	}
+	to, err := readMarkResources(group, toHash)
+	if err != nil {
+		return nil, fmt.Errorf("DiffMarks(%s): %w", group, err)
+	}
+
+	plugins := []string{}
+	for plugin := range from {
+		plugins = append(plugins, plugin)
+	}
+	for plugin := range to {
+		if _, ok := from[plugin]; !ok {
+			plugins = append(plugins, plugin)
+		}
+	}
+	slices.Sort(plugins)
+
+	diff := MarkDiff{Group: group, From: fromHash, To: toHash, Plugins: []PluginDiff{}}
+	for _, plugin := range plugins {
+		fromRes, inFrom := from[plugin]
+		toRes, inTo := to[plugin]
+
+		pluginDiff := PluginDiff{Plugin: plugin, Resources: []ResourceChange{}}
+		switch {
+		case !inFrom:
+			pluginDiff.Status = DIFF_STATUS_ADDED
+		case !inTo:
+			pluginDiff.Status = DIFF_STATUS_REMOVED
+		}
+
+		for id, res := range fromRes {
+			if other, ok := toRes[id]; !ok {
+				pluginDiff.Resources = append(pluginDiff.Resources, ResourceChange{
+					Identifier: id,
+					Alias:      res.alias,
+					Status:     DIFF_STATUS_REMOVED,
+					FromHash:   res.resourceHash,
+				})
+			} else if other.resourceHash != res.resourceHash {
+				pluginDiff.Resources = append(pluginDiff.Resources, ResourceChange{
+					Identifier: id,
+					Alias:      other.alias,
+					Status:     DIFF_STATUS_MODIFIED,
+					FromHash:   res.resourceHash,
+					ToHash:     other.resourceHash,
+				})
+			}
+		}
+		for id, res := range toRes {
+			if _, ok := fromRes[id]; !ok {
+				pluginDiff.Resources = append(pluginDiff.Resources, ResourceChange{
+					Identifier: id,
+					Alias:      res.alias,
+					Status:     DIFF_STATUS_ADDED,
+					ToHash:     res.resourceHash,
+				})
+			}
+		}
+		slices.SortStableFunc(pluginDiff.Resources, func(a, b ResourceChange) int {
+			return strings.Compare(a.Identifier, b.Identifier)
+		})
+
+		if pluginDiff.Status == "" {
+			if pluginDiff.Changed() {
+				pluginDiff.Status = DIFF_STATUS_MODIFIED
+			} else {
+				pluginDiff.Status = DIFF_STATUS_UNCHANGED
+			}
+		}
+		diff.Plugins = append(diff.Plugins, pluginDiff)
+	}
+
+	return &diff, nil
+}
+
+// ParentMark returns the parent hash of the label mark with the given hash.
+// ErrNoParentMark is returned if the mark is the first mark of the group.
+func ParentMark(group, hash string) (string, error) {
+	mark, err := ReadMark(group, hash)
+	if err != nil {
+		return "", fmt.Errorf("ParentMark(%s, %s): %w", group, hash, err)
+	}
+	if mark.Parent == "" || mark.Parent == "nil" {
+		return "", ErrNoParentMark
+	}
+
+	return mark.Parent, nil
+}
+
+type markResource struct {
+	alias        string
+	outlineHash  string
+	resourceHash string
+	diaryHash    string
+}
+
+// readMarkResources reads all the resources referenced by the label mark and returns
+// them in a map of plugin name to identifier to resource.
+// Mappings of the same plugin in one mark are merged together.
+func readMarkResources(group, hash string) (map[string]map[string]markResource, error) {
+	resources := make(map[string]map[string]markResource)
+	if hash == "" || hash == "nil" {
+		return resources, nil
+	}
+
+	mark, err := ReadMark(group, hash)
+	if err != nil {
+		return nil, fmt.Errorf("readMarkResources(%s): %w", hash, err)
+	}
+
+	for _, mapping := range mark.Mappings {
+		if _, ok := resources[mapping.Module]; !ok {
+			resources[mapping.Module] = make(map[string]markResource)
+		}
+
+		idHashMaps, err := ReadIdentifierHashMaps(group, mapping.Hash)
+		if err != nil {
+			return nil, fmt.Errorf("readMarkResources(%s): %w", hash, err)
+		}
+		for _, idHashMap := range idHashMaps.Maps {
+			outline, err := ReadStuffOutline(group, idHashMap.Hash)
+			if err != nil {
+				return nil, fmt.Errorf("readMarkResources(%s): %w", hash, err)
+			}
+			resources[mapping.Module][idHashMap.Identifier] = markResource{
+				alias:        idHashMap.Alias,
+				outlineHash:  idHashMap.Hash,
+				resourceHash: outline.ResourceHash,
+				diaryHash:    outline.DiaryHash,
+			}
+		}
+	}
+
+	return resources, nil
+}
+
+// DiffResources compares the MinerResource objects of the given hashes at property level.
+// Either hash can be empty to compare against a resource without properties.
+func DiffResources(group, fromHash, toHash string) ([]shared.PropertyDiff, error) {
+	from, to := &shared.MinerResource{}, &shared.MinerResource{}
+	var err error
+	if fromHash != "" {
+		if from, err = ReadResource(group, fromHash); err != nil {
+			return nil, fmt.Errorf("DiffResources(%s): %w", group, err)
+		}
+	}
+	if toHash != "" {
+		if to, err = ReadResource(group, toHash); err != nil {
+			return nil, fmt.Errorf("DiffResources(%s): %w", group, err)
+		}
+	}
+
+	return shared.DiffProperties(*from, *to), nil
+}
+
+// MarkResources returns the resource hashes of the given plugin in the label mark,
+// keyed by resource identifier.
+func MarkResources(group, hash, plugin string) (map[string]string, error) {
+	resources, err := readMarkResources(group, hash)
+	if err != nil {
+		return nil, fmt.Errorf("MarkResources(%s, %s): %w", group, plugin, err)
+	}
+
+	hashes := make(map[string]string)
+	for id, res := range resources[plugin] {
+		hashes[id] = res.resourceHash
+	}
+	return hashes, nil
+}
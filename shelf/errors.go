@@ -1,8 +1,42 @@
 package shelf
 
-import "errors"
+import (
+	"errors"
+
+	"github.com/liuminhaw/mist-miner/shared"
+)
 
 var (
-	ErrRefHeadNotFound = errors.New("reference head not found")
-	ErrDiaryNotFound   = errors.New("diary not found")
+	ErrRefHeadNotFound  = errors.New("reference head not found")
+	ErrDiaryNotFound    = errors.New("diary not found")
+	ErrNoParentMark     = errors.New("label mark has no parent")
+	ErrTagExists        = errors.New("tag already exists")
+	ErrTagNotFound      = errors.New("tag not found")
+	ErrInvalidTagName   = errors.New("invalid tag name")
+	ErrRevNotFound      = errors.New("revision not found")
+	ErrAmbiguousRev     = errors.New("ambiguous revision")
+	ErrNoBaseline       = errors.New("baseline not set")
+	ErrTimelineNotFound = errors.New("timeline not found")
+	ErrInvalidTimeSpec  = shared.ErrInvalidTimeSpec
+	ErrNoMarkAt         = errors.New("no label mark at or before given time")
+	ErrResourceNotFound = errors.New("resource not found")
+	ErrPacksUnsupported = errors.New("packs are only supported by the fs storage backend")
+
+	ErrUnknownCompression   = errors.New("unknown compression")
+	ErrUnknownHashAlgorithm = errors.New("unknown hash algorithm")
+	ErrUnsupportedFormat    = errors.New("unsupported shelf format version, upgrade mist-miner")
+
+	ErrInvalidKey          = errors.New("invalid encryption key, expect 32 bytes encoded in base64")
+	ErrEncryptionKeyNotSet = errors.New("content is encrypted, set the shelf encryption key")
+	ErrUnknownKey          = errors.New("content is encrypted with an unknown key")
+	ErrCorruptEncryption   = errors.New("encrypted content cannot be decrypted")
+
+	ErrInvalidSigningKey = errors.New("invalid signing key, expect ed25519 key in PEM")
+	ErrUnverifiedMark    = errors.New("signed label mark does not verify, check trusted keys or run verify")
+
+	ErrJournalPending = errors.New("mine journal of an interrupted run is pending recovery")
+	ErrCorruptJournal = errors.New("mine journal cannot be read, inspect and remove it to continue")
+
+	ErrSearchIndexNotFound = errors.New("search index not found, run log reload to generate")
+	ErrEmptySearchQuery    = errors.New("empty search query")
 )
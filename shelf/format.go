@@ -0,0 +1,337 @@
+package shelf
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/zeebo/blake3"
+)
+
+// Format of the objects of a group, recorded in the FORMAT reference as lines of
+// "<key> <value>", ex.
+//
+//	version 2
+//	compression zstd
+//	hash blake3
+//
+// Groups without FORMAT were written before format versioning and are read as
+// format_legacy_version with zlib and sha256. Objects are decoded by detecting the
+// compression from their content, so the recorded compression only decides how new
+// objects are written. The hash algorithm decides object hashes of the group.
+const (
+	SHELF_FORMAT_FILE    = "FORMAT"
+	SHELF_FORMAT_VERSION = 2
+
+	COMPRESSION_ZLIB = "zlib"
+	COMPRESSION_ZSTD = "zstd"
+	COMPRESSION_NONE = "none"
+
+	HASH_SHA256 = "sha256"
+	HASH_BLAKE3 = "blake3"
+
+	format_legacy_version = 1
+
+	format_key_version     = "version"
+	format_key_compression = "compression"
+	format_key_hash        = "hash"
+)
+
+var (
+	compressions    = []string{COMPRESSION_ZLIB, COMPRESSION_ZSTD, COMPRESSION_NONE}
+	hashAlgorithms  = []string{HASH_SHA256, HASH_BLAKE3}
+	zstdFrameMagic  = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	errInvalidValue = errors.New("invalid format value")
+)
+
+type Format struct {
+	Version     int
+	Compression string
+	Hash        string
+}
+
+// NewFormat returns the current format version with given compression and hash
+// algorithm, empty values default to zlib and sha256.
+func NewFormat(compression, hashAlgorithm string) (Format, error) {
+	f := Format{Version: SHELF_FORMAT_VERSION, Compression: compression, Hash: hashAlgorithm}
+	if f.Compression == "" {
+		f.Compression = COMPRESSION_ZLIB
+	}
+	if f.Hash == "" {
+		f.Hash = HASH_SHA256
+	}
+	if err := f.validate(); err != nil {
+		return Format{}, fmt.Errorf("NewFormat(%s, %s): %w", compression, hashAlgorithm, err)
+	}
+	return f, nil
+}
+
+func (f Format) String() string {
+	return fmt.Sprintf("version %d, %s compression, %s hash", f.Version, f.Compression, f.Hash)
+}
+
+func (f Format) validate() error {
+	if !slices.Contains(compressions, f.Compression) {
+		return fmt.Errorf("compression %q: %w", f.Compression, ErrUnknownCompression)
+	}
+	if !slices.Contains(hashAlgorithms, f.Hash) {
+		return fmt.Errorf("hash %q: %w", f.Hash, ErrUnknownHashAlgorithm)
+	}
+	if f.Version > SHELF_FORMAT_VERSION {
+		return fmt.Errorf("version %d: %w", f.Version, ErrUnsupportedFormat)
+	}
+	return nil
+}
+
+// newHash returns a new hash.Hash of the format hash algorithm
+func (f Format) newHash() hash.Hash {
+	if f.Hash == HASH_BLAKE3 {
+		return blake3.New()
+	}
+	return sha256.New()
+}
+
+// sum returns the hex encoded hash of data
+func (f Format) sum(data []byte) string {
+	h := f.newHash()
+	h.Write(data)
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// compress returns data compressed by the format compression
+func (f Format) compress(data []byte) ([]byte, error) {
+	switch f.Compression {
+	case COMPRESSION_NONE:
+		return bytes.Clone(data), nil
+	case COMPRESSION_ZSTD:
+		enc, err := zstdEncoder()
+		if err != nil {
+			return nil, fmt.Errorf("compress: %w", err)
+		}
+		return enc.EncodeAll(data, nil), nil
+	default:
+		var buf bytes.Buffer
+		w := zlib.NewWriter(&buf)
+		if _, err := w.Write(data); err != nil {
+			return nil, fmt.Errorf("compress: %w", err)
+		}
+		if err := w.Close(); err != nil {
+			return nil, fmt.Errorf("compress: %w", err)
+		}
+		return buf.Bytes(), nil
+	}
+}
+
+// zstdEncoder returns the shared zstd encoder, EncodeAll is safe for concurrent use
+var zstdEncoder = sync.OnceValues(func() (*zstd.Encoder, error) {
+	return zstd.NewWriter(nil)
+})
+
+// decompressReader returns a reader of the decompressed content of r, the compression
+// is detected from the first bytes: zstd frame magic, zlib header, otherwise the
+// content is read as is. Object headers start with a type name which is never a
+// valid zlib header.
+func decompressReader(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	peek, err := br.Peek(len(zstdFrameMagic))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("decompress: %w", err)
+	}
+
+	switch {
+	case bytes.Equal(peek, zstdFrameMagic):
+		dec, err := zstd.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("decompress: zstd: %w", err)
+		}
+		return dec.IOReadCloser(), nil
+	case len(peek) >= 2 && peek[0]&0x0f == 8 && (uint16(peek[0])<<8|uint16(peek[1]))%31 == 0:
+		zr, err := zlib.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("decompress: zlib: %w", err)
+		}
+		return zr, nil
+	default:
+		return io.NopCloser(br), nil
+	}
+}
+
+// Default format of new groups and recorded formats of groups loaded in this process
+var formats = struct {
+	sync.Mutex
+	def    Format
+	groups map[string]groupFormatState
+}{
+	def:    Format{Version: SHELF_FORMAT_VERSION, Compression: COMPRESSION_ZLIB, Hash: HASH_SHA256},
+	groups: make(map[string]groupFormatState),
+}
+
+type groupFormatState struct {
+	format Format
+	// recorded is set if the format is stored in FORMAT or the group is legacy
+	recorded bool
+}
+
+// SetDefaultFormat sets the format of groups created from now on, existing groups
+// keep their recorded format until upgraded.
+func SetDefaultFormat(f Format) {
+	formats.Lock()
+	defer formats.Unlock()
+	formats.def = f
+}
+
+// ReadFormat returns the format of the group. Groups without FORMAT are of the
+// legacy format if they have a HEAD reference, otherwise they are new groups of
+// the default format.
+func ReadFormat(group string) (Format, error) {
+	state, err := groupFormat(group)
+	if err != nil {
+		return Format{}, fmt.Errorf("ReadFormat(%s): %w", group, err)
+	}
+	return state.format, nil
+}
+
+func groupFormat(group string) (groupFormatState, error) {
+	formats.Lock()
+	defer formats.Unlock()
+	if state, ok := formats.groups[group]; ok {
+		return state, nil
+	}
+
+	content, err := readRef(group, SHELF_FORMAT_FILE)
+	if errors.Is(err, fs.ErrNotExist) {
+		legacy, err := refExist(group, SHELF_MARK_FILE)
+		if err != nil {
+			return groupFormatState{}, fmt.Errorf("group format: %w", err)
+		}
+		if legacy {
+			state := groupFormatState{
+				format: Format{
+					Version:     format_legacy_version,
+					Compression: COMPRESSION_ZLIB,
+					Hash:        HASH_SHA256,
+				},
+				recorded: true,
+			}
+			formats.groups[group] = state
+			return state, nil
+		}
+		// New group, not cached so a FORMAT written by another process is read next time
+		return groupFormatState{format: formats.def}, nil
+	} else if err != nil {
+		return groupFormatState{}, fmt.Errorf("group format: %w", err)
+	}
+
+	f, err := parseFormat(content)
+	if err != nil {
+		return groupFormatState{}, fmt.Errorf("group format: %w", err)
+	}
+	state := groupFormatState{format: f, recorded: true}
+	formats.groups[group] = state
+	return state, nil
+}
+
+// objectFormat returns the format to write objects of the group with, the default
+// format is recorded in FORMAT for new groups before their first object is written.
+func objectFormat(group string) (Format, error) {
+	state, err := groupFormat(group)
+	if err != nil {
+		return Format{}, fmt.Errorf("object format: %w", err)
+	}
+	if state.recorded {
+		return state.format, nil
+	}
+
+	if err := writeFormat(group, state.format); err != nil {
+		return Format{}, fmt.Errorf("object format: %w", err)
+	}
+	return state.format, nil
+}
+
+// objectHash returns the hex encoded hash of data by the hash algorithm of the group
+func objectHash(group string, data []byte) (string, error) {
+	state, err := groupFormat(group)
+	if err != nil {
+		return "", fmt.Errorf("object hash: %w", err)
+	}
+	return state.format.sum(data), nil
+}
+
+// writeFormat records the format of the group in FORMAT
+func writeFormat(group string, f Format) error {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s %d\n", format_key_version, f.Version)
+	fmt.Fprintf(&buf, "%s %s\n", format_key_compression, f.Compression)
+	fmt.Fprintf(&buf, "%s %s\n", format_key_hash, f.Hash)
+	if err := writeRef(group, SHELF_FORMAT_FILE, buf.Bytes()); err != nil {
+		return fmt.Errorf("write format: %w", err)
+	}
+
+	formats.Lock()
+	defer formats.Unlock()
+	formats.groups[group] = groupFormatState{format: f, recorded: true}
+	return nil
+}
+
+// useFormat sets the format used by this process for the group without recording it,
+// objects written afterwards are encoded and hashed by f.
+func useFormat(group string, f Format) {
+	formats.Lock()
+	defer formats.Unlock()
+	formats.groups[group] = groupFormatState{format: f, recorded: true}
+}
+
+// ReloadFormat drops the format of the group loaded in this process, so FORMAT is read
+// again before the next object is written. Long running writers call it once they hold
+// the objects lock, since the group may have been upgraded in the meantime.
+func ReloadFormat(group string) {
+	forgetFormat(group)
+}
+
+// forgetFormat drops the format of the group loaded in this process
+func forgetFormat(group string) {
+	formats.Lock()
+	defer formats.Unlock()
+	delete(formats.groups, group)
+}
+
+func parseFormat(content []byte) (Format, error) {
+	f := Format{}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), " ")
+		if !ok {
+			continue
+		}
+		switch key {
+		case format_key_version:
+			version, err := strconv.Atoi(value)
+			if err != nil {
+				return Format{}, fmt.Errorf("parse format: version %q: %w", value, errInvalidValue)
+			}
+			f.Version = version
+		case format_key_compression:
+			f.Compression = value
+		case format_key_hash:
+			f.Hash = value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Format{}, fmt.Errorf("parse format: %w", err)
+	}
+	if err := f.validate(); err != nil {
+		return Format{}, fmt.Errorf("parse format: %w", err)
+	}
+	return f, nil
+}
@@ -0,0 +1,553 @@
+package shelf
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/liuminhaw/mist-miner/locks"
+)
+
+const (
+	FSCK_CORRUPT_OBJECT     = "corrupt object"
+	FSCK_HASH_MISMATCH      = "hash mismatch"
+	FSCK_SIZE_MISMATCH      = "size mismatch"
+	FSCK_TYPE_MISMATCH      = "type mismatch"
+	FSCK_LEGACY_OBJECT      = "legacy object"
+	FSCK_NON_CANONICAL      = "non canonical content"
+	FSCK_BROKEN_OBJECT      = "broken object"
+	FSCK_BROKEN_REF         = "broken reference"
+	FSCK_DANGLING_REFERENCE = "dangling reference"
+	FSCK_MISSING_PARENT     = "missing parent mark"
+	FSCK_HISTORY_LOGGER     = "history logger mismatch"
+	FSCK_HISTORY_POINTER    = "history pointer mismatch"
+	FSCK_HISTORY_TIMELINE   = "history timeline mismatch"
+)
+
+type FsckIssue struct {
+	// Hash of the problematic object, or name of the problematic file
+	Target  string
+	Problem string
+	Detail  string
+}
+
+func (i FsckIssue) String() string {
+	return fmt.Sprintf("%s: %s: %s", i.Problem, i.Target, i.Detail)
+}
+
+type FsckReport struct {
+	Objects     int
+	Reachable   int
+	Unreachable int
+	Issues      []FsckIssue
+}
+
+// Fsck checks integrity of the shelf of given group.
+// Every loose and packed object is decompressed and its payload is hashed again to compare with
+// the object hash, header size and type are checked against the payload and
+// the way the object is referenced. Objects reachable from references are also parsed and
+// re-encoded the same way as they are written to verify their hash.
+// References to missing objects and history logger / pointer / timeline files
+// disagreeing with the label mark chain are reported as well.
+// Will use flock on objects to prevent mining while checking,
+// return locks.ErrIsLocked if file lock is not acquired.
+func Fsck(group string) (FsckReport, error) {
+	objFileLock, err := locks.NewLock("", locks.OBJECTS_LOCKFILE)
+	if err != nil {
+		return FsckReport{}, fmt.Errorf("Fsck(%s): %w", group, err)
+	}
+	if err := objFileLock.TryRLock(); err != nil {
+		if errors.Is(err, locks.ErrIsLocked) {
+			return FsckReport{}, err
+		}
+		return FsckReport{}, fmt.Errorf("Fsck(%s): %w", group, err)
+	}
+	defer objFileLock.Unlock()
+
+	report := FsckReport{Issues: []FsckIssue{}}
+
+	// Check content hash of every object, packed objects are read from packs
+	// only if there is no loose object of the same hash
+	objects, err := listLooseObjects(group)
+	if err != nil {
+		return report, fmt.Errorf("Fsck(%s): %w", group, err)
+	}
+	packed, err := listPackedObjects(group)
+	if err != nil {
+		return report, fmt.Errorf("Fsck(%s): %w", group, err)
+	}
+	hashes := []string{}
+	loose := make(map[string]bool)
+	for _, object := range objects {
+		hashes = append(hashes, object.Hash)
+		loose[object.Hash] = true
+	}
+	for _, object := range packed {
+		if !loose[object.Hash] {
+			hashes = append(hashes, object.Hash)
+		}
+	}
+	corrupted := make(map[string]bool)
+	headers := make(map[string]ObjectHeader)
+	for _, hash := range hashes {
+		report.Objects++
+		header, issue, ok := checkObjectContent(group, hash)
+		if !ok {
+			corrupted[hash] = true
+			report.Issues = append(report.Issues, issue)
+			continue
+		}
+		headers[hash] = header
+		if header.Legacy {
+			report.Issues = append(report.Issues, FsckIssue{
+				Target:  hash,
+				Problem: FSCK_LEGACY_OBJECT,
+				Detail:  "object has no type header, run shelf migrate to upgrade",
+			})
+		}
+	}
+
+	// Walk from references to find dangling references and re-encode reachable objects
+	walker := newObjectWalker(group)
+	walker.missing = func(hash, kind, referrer string) error {
+		problem := FSCK_DANGLING_REFERENCE
+		if kind == OBJECT_TYPE_MARK && !strings.HasPrefix(referrer, "ref ") {
+			problem = FSCK_MISSING_PARENT
+		}
+		report.Issues = append(report.Issues, FsckIssue{
+			Target:  hash,
+			Problem: problem,
+			Detail:  fmt.Sprintf("%s object referenced by %s does not exist", kind, referrer),
+		})
+		return nil
+	}
+	walker.broken = func(hash, kind string, err error) error {
+		if !corrupted[hash] {
+			report.Issues = append(report.Issues, FsckIssue{
+				Target:  hash,
+				Problem: FSCK_BROKEN_OBJECT,
+				Detail:  fmt.Sprintf("cannot parse %s object: %s", kind, err),
+			})
+		}
+		return nil
+	}
+
+	refs, err := ListRefs(group)
+	if err != nil {
+		return report, fmt.Errorf("Fsck(%s): %w", group, err)
+	}
+	for _, ref := range refs {
+		mark, err := NewRefMark(ref, group)
+		if err != nil {
+			report.Issues = append(report.Issues, FsckIssue{
+				Target:  ref,
+				Problem: FSCK_BROKEN_REF,
+				Detail:  err.Error(),
+			})
+			continue
+		}
+		if err := walker.walkMarks(string(mark.Reference), "ref "+ref); err != nil {
+			return report, fmt.Errorf("Fsck(%s): %w", group, err)
+		}
+	}
+
+	report.Reachable = len(walker.seen)
+	report.Unreachable = report.Objects - report.Reachable
+	for hash, kind := range walker.seen {
+		if corrupted[hash] {
+			continue
+		}
+		if header, ok := headers[hash]; ok && !header.Legacy && header.Type != kind {
+			report.Issues = append(report.Issues, FsckIssue{
+				Target:  hash,
+				Problem: FSCK_TYPE_MISMATCH,
+				Detail:  fmt.Sprintf("%s object is referenced as %s", header.Type, kind),
+			})
+			continue
+		}
+		if issue, ok := checkObjectEncoding(group, hash, kind); !ok {
+			report.Issues = append(report.Issues, issue)
+		}
+	}
+
+	// Check history records against the label mark chain from HEAD
+	historyIssues, err := checkHistory(group)
+	if err != nil {
+		return report, fmt.Errorf("Fsck(%s): %w", group, err)
+	}
+	report.Issues = append(report.Issues, historyIssues...)
+
+	timelineIssues, err := checkTimeline(group)
+	if err != nil {
+		return report, fmt.Errorf("Fsck(%s): %w", group, err)
+	}
+	report.Issues = append(report.Issues, timelineIssues...)
+
+	return report, nil
+}
+
+// checkObjectContent decompresses the object and compares the hash of its payload
+// with the object hash and the payload size with the header, the object header is
+// returned. False is returned with the issue found if the check fails.
+func checkObjectContent(group, hash string) (ObjectHeader, FsckIssue, bool) {
+	format, err := ReadFormat(group)
+	if err != nil {
+		return ObjectHeader{}, FsckIssue{Target: hash, Problem: FSCK_CORRUPT_OBJECT, Detail: err.Error()}, false
+	}
+	header, r, err := NewObjectRecord(group, hash).ObjectReadCloser()
+	if err != nil {
+		return header, FsckIssue{Target: hash, Problem: FSCK_CORRUPT_OBJECT, Detail: err.Error()}, false
+	}
+	defer r.Close()
+
+	h := format.newHash()
+	var payload bytes.Buffer
+	size, err := io.Copy(io.MultiWriter(h, &payload), r)
+	if err != nil {
+		return header, FsckIssue{Target: hash, Problem: FSCK_CORRUPT_OBJECT, Detail: err.Error()}, false
+	}
+	sum := fmt.Sprintf("%x", h.Sum(nil))
+	if header.Type == OBJECT_TYPE_TREE {
+		// Trees are stored under the hash of the resource json they represent,
+		// missing property objects are reported when walking from references
+		sum = hash
+		if content, err := loadResourceTree(group, &payload); err == nil {
+			sum = format.sum(content)
+		}
+	}
+	if sum != hash {
+		return header, FsckIssue{
+			Target:  hash,
+			Problem: FSCK_HASH_MISMATCH,
+			Detail:  fmt.Sprintf("content hashes to %s", sum),
+		}, false
+	}
+	if !header.Legacy && size != header.Size {
+		return header, FsckIssue{
+			Target:  hash,
+			Problem: FSCK_SIZE_MISMATCH,
+			Detail:  fmt.Sprintf("header size %d, payload size %d", header.Size, size),
+		}, false
+	}
+
+	return header, FsckIssue{}, true
+}
+
+// checkObjectEncoding parses the object as the given kind and encodes it again
+// the same way it is written, false is returned with the issue found if the
+// hash of encoded content differs from the object hash.
+func checkObjectEncoding(group, hash, kind string) (FsckIssue, bool) {
+	var calculated string
+	switch kind {
+	case OBJECT_TYPE_MARK:
+		mark, err := ReadMark(group, hash)
+		if err != nil {
+			return FsckIssue{Target: hash, Problem: FSCK_BROKEN_OBJECT, Detail: err.Error()}, false
+		}
+		encoded := LabelMark{
+			TimeStamp: mark.TimeStamp,
+			LogType:   mark.LogType,
+			Parent:    mark.Parent,
+			Mappings:  mark.Mappings,
+			Group:     group,
+		}
+		if err := encoded.calcHash(); err != nil {
+			return FsckIssue{Target: hash, Problem: FSCK_BROKEN_OBJECT, Detail: err.Error()}, false
+		}
+		calculated = encoded.Hash
+	case OBJECT_TYPE_IDMAPS:
+		idHashMaps, err := ReadIdentifierHashMaps(group, hash)
+		if err != nil {
+			return FsckIssue{Target: hash, Problem: FSCK_BROKEN_OBJECT, Detail: err.Error()}, false
+		}
+		encoded := IdentifierHashMaps{Group: group, Maps: idHashMaps.Maps}
+		if err := encoded.calcHash(); err != nil {
+			return FsckIssue{Target: hash, Problem: FSCK_BROKEN_OBJECT, Detail: err.Error()}, false
+		}
+		calculated = encoded.Hash
+	case OBJECT_TYPE_OUTLINE:
+		outline, err := ReadStuffOutline(group, hash)
+		if err != nil {
+			return FsckIssue{Target: hash, Problem: FSCK_BROKEN_OBJECT, Detail: err.Error()}, false
+		}
+		encoded, err := NewStuffOutline(group, outline.ResourceHash, outline.DiaryHash)
+		if err != nil {
+			return FsckIssue{Target: hash, Problem: FSCK_BROKEN_OBJECT, Detail: err.Error()}, false
+		}
+		calculated = encoded.Hash
+	case OBJECT_TYPE_RESOURCE, OBJECT_TYPE_TREE:
+		resource, err := ReadResource(group, hash)
+		if err != nil {
+			return FsckIssue{Target: hash, Problem: FSCK_BROKEN_OBJECT, Detail: err.Error()}, false
+		}
+		stuff, err := NewStuff(group, resource)
+		if err != nil {
+			return FsckIssue{Target: hash, Problem: FSCK_BROKEN_OBJECT, Detail: err.Error()}, false
+		}
+		calculated = stuff.Hash
+	case OBJECT_TYPE_PROPERTY:
+		content, err := ReadPropertyContent(group, hash)
+		if err != nil {
+			return FsckIssue{Target: hash, Problem: FSCK_BROKEN_OBJECT, Detail: err.Error()}, false
+		}
+		b, err := json.Marshal(content)
+		if err != nil {
+			return FsckIssue{Target: hash, Problem: FSCK_BROKEN_OBJECT, Detail: err.Error()}, false
+		}
+		if calculated, err = objectHash(group, b); err != nil {
+			return FsckIssue{Target: hash, Problem: FSCK_BROKEN_OBJECT, Detail: err.Error()}, false
+		}
+	case OBJECT_TYPE_DIARY:
+		diary, err := ReadMinerDiary(group, hash)
+		if err != nil {
+			return FsckIssue{Target: hash, Problem: FSCK_BROKEN_OBJECT, Detail: err.Error()}, false
+		}
+		stuff, err := NewStuff(group, diary)
+		if err != nil {
+			return FsckIssue{Target: hash, Problem: FSCK_BROKEN_OBJECT, Detail: err.Error()}, false
+		}
+		calculated = stuff.Hash
+	default:
+		// Diary notes are stored as is, content hash check is sufficient
+		return FsckIssue{}, true
+	}
+
+	if calculated != hash {
+		return FsckIssue{
+			Target:  hash,
+			Problem: FSCK_NON_CANONICAL,
+			Detail:  fmt.Sprintf("%s object encodes to %s", kind, calculated),
+		}, false
+	}
+	return FsckIssue{}, true
+}
+
+// checkHistory compares history logger and pointer files with the ones generated
+// from the label mark chain starting from HEAD.
+func checkHistory(group string) ([]FsckIssue, error) {
+	issues := []FsckIssue{}
+
+	head, err := NewRefMark(SHELF_MARK_FILE, group)
+	if errors.Is(err, ErrRefHeadNotFound) {
+		return issues, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("checkHistory: %w", err)
+	}
+
+	// Marks in chain can be broken, issues are already reported in objects walk
+	pages, err := historyPages(group, string(head.Reference), SHELF_HISTORY_LOGS_PER_PAGE)
+	if err != nil {
+		return issues, nil
+	}
+
+	histFileLock, err := locks.NewLock(group, locks.HISTORY_LOCKFILE)
+	if err != nil {
+		return nil, fmt.Errorf("checkHistory: %w", err)
+	}
+	if err := histFileLock.TryRLock(); err != nil {
+		if errors.Is(err, locks.ErrIsLocked) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("checkHistory: %w", err)
+	}
+	defer histFileLock.Unlock()
+
+	for index := 0; ; index++ {
+		record, err := NewHistoryRecord(group, index)
+		if err != nil {
+			return nil, fmt.Errorf("checkHistory: %w", err)
+		}
+		name := fmt.Sprintf("%s.%d", SHELF_HISTORY_FILE, index)
+
+		content, err := readHistoryRecord(&record)
+		if errors.Is(err, fs.ErrNotExist) {
+			if index < len(pages) {
+				issues = append(issues, FsckIssue{
+					Target:  name,
+					Problem: FSCK_HISTORY_LOGGER,
+					Detail:  "history logger file is missing",
+				})
+				continue
+			}
+			break
+		} else if err != nil {
+			issues = append(issues, FsckIssue{Target: name, Problem: FSCK_HISTORY_LOGGER, Detail: err.Error()})
+			continue
+		}
+
+		if index >= len(pages) {
+			issues = append(issues, FsckIssue{
+				Target:  name,
+				Problem: FSCK_HISTORY_LOGGER,
+				Detail:  "history logger file does not belong to the label mark chain",
+			})
+		} else if !bytes.Equal(content, pages[index]) {
+			issues = append(issues, FsckIssue{
+				Target:  name,
+				Problem: FSCK_HISTORY_LOGGER,
+				Detail:  "history logger content disagrees with the label mark chain",
+			})
+		}
+	}
+
+	// Each mark with parent should have a pointer from its parent,
+	// the first mark may also have a pointer from nil written by mining.
+	expected := make(map[string]bool)
+	var rootPointer string
+	reference := string(head.Reference)
+	for {
+		mark, err := ReadMark(group, reference)
+		if err != nil {
+			break
+		}
+		if mark.Parent == "nil" {
+			rootPointer = fmt.Sprintf("%s %s", mark.Parent, reference)
+			break
+		}
+		expected[fmt.Sprintf("%s %s", mark.Parent, reference)] = true
+		reference = mark.Parent
+	}
+
+	pointers, err := readHistoryPointers(group)
+	if err != nil {
+		return nil, fmt.Errorf("checkHistory: %w", err)
+	}
+	for pointer := range pointers {
+		if !expected[pointer] && pointer != rootPointer {
+			issues = append(issues, FsckIssue{
+				Target:  pointer,
+				Problem: FSCK_HISTORY_POINTER,
+				Detail:  "history pointer does not belong to the label mark chain",
+			})
+		}
+	}
+	for pointer := range expected {
+		if !pointers[pointer] {
+			issues = append(issues, FsckIssue{
+				Target:  pointer,
+				Problem: FSCK_HISTORY_POINTER,
+				Detail:  "history pointer is missing",
+			})
+		}
+	}
+
+	return issues, nil
+}
+
+// checkTimeline compares timeline index files with the ones generated
+// from the label mark chain starting from HEAD.
+func checkTimeline(group string) ([]FsckIssue, error) {
+	issues := []FsckIssue{}
+
+	head, err := NewRefMark(SHELF_MARK_FILE, group)
+	if errors.Is(err, ErrRefHeadNotFound) {
+		return issues, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("checkTimeline: %w", err)
+	}
+
+	// Marks in chain can be broken, issues are already reported in objects walk
+	timelines, err := buildTimelines(group, string(head.Reference))
+	if err != nil {
+		return issues, nil
+	}
+
+	timelineLock, err := locks.NewLock(group, locks.HISTORY_TIMELINE_LOCKFILE)
+	if err != nil {
+		return nil, fmt.Errorf("checkTimeline: %w", err)
+	}
+	if err := timelineLock.TryRLock(); err != nil {
+		if errors.Is(err, locks.ErrIsLocked) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("checkTimeline: %w", err)
+	}
+	defer timelineLock.Unlock()
+
+	files, err := readTimelineFiles(group)
+	if errors.Is(err, fs.ErrNotExist) {
+		return append(issues, FsckIssue{
+			Target:  shelf_history_timeline_dir,
+			Problem: FSCK_HISTORY_TIMELINE,
+			Detail:  "timeline index does not exist, run log reload to generate",
+		}), nil
+	} else if err != nil {
+		return nil, fmt.Errorf("checkTimeline: %w", err)
+	}
+
+	for _, key := range sortedTimelineKeys(timelines) {
+		name := key.plugin + "/" + timelineFileName(key.identifier)
+		target := fmt.Sprintf("%s %s", key.plugin, key.identifier)
+		content, ok := files[name]
+		delete(files, name)
+		if !ok {
+			issues = append(issues, FsckIssue{
+				Target:  target,
+				Problem: FSCK_HISTORY_TIMELINE,
+				Detail:  "timeline file is missing",
+			})
+		} else if !bytes.Equal(content, encodeTimeline(key.identifier, timelines[key])) {
+			issues = append(issues, FsckIssue{
+				Target:  target,
+				Problem: FSCK_HISTORY_TIMELINE,
+				Detail:  "timeline content disagrees with the label mark chain",
+			})
+		}
+	}
+	for name := range files {
+		issues = append(issues, FsckIssue{
+			Target:  name,
+			Problem: FSCK_HISTORY_TIMELINE,
+			Detail:  "timeline file does not belong to the label mark chain",
+		})
+	}
+
+	return issues, nil
+}
+
+// readHistoryRecord returns the decompressed content of the history record file.
+func readHistoryRecord(record *HistoryRecord) ([]byte, error) {
+	r, err := record.Read()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return io.ReadAll(r)
+}
+
+// readHistoryPointers returns all "<parent sha> <current sha>" entries in the
+// history pointer files of the group.
+func readHistoryPointers(group string) (map[string]bool, error) {
+	names, err := listRefs(group, pointerDir())
+	if err != nil {
+		return nil, fmt.Errorf("readHistoryPointers(%s): %w", group, err)
+	}
+
+	pointers := make(map[string]bool)
+	for _, name := range names {
+		if path.Base(name) != shelf_history_pointer_file {
+			continue
+		}
+		content, err := readCompressedRef(group, name)
+		if err != nil {
+			return nil, fmt.Errorf("readHistoryPointers(%s): %w", group, err)
+		}
+
+		scanner := bufio.NewScanner(bytes.NewReader(content))
+		for scanner.Scan() {
+			pointers[scanner.Text()] = true
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("readHistoryPointers(%s): %w", group, err)
+		}
+	}
+
+	return pointers, nil
+}
@@ -0,0 +1,127 @@
+package shelf
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/liuminhaw/mist-miner/locks"
+)
+
+type GCResult struct {
+	Reachable int
+	// Unreachable objects which are removed, or to be removed in dry run
+	Removed    []string
+	FreedBytes int64
+	// Unreachable objects stored in packs, only removed by repacking
+	Packed []string
+}
+
+// CollectGarbage removes objects of the group which cannot be reached from any reference.
+// Reachable objects are marked by walking from each reference through
+// LabelMark -> IdentifierHashMaps -> StuffOutline -> resource / diary objects.
+// Unreachable objects in packs are left for RepackObjects, signatures of unreachable label marks
+// are removed. Nothing is removed if dryRun is set.
+// Will use flock on objects to prevent racing with mining and diary commits,
+// return locks.ErrIsLocked if file lock is not acquired.
+func CollectGarbage(group string, dryRun bool) (GCResult, error) {
+	objFileLock, err := locks.NewLock("", locks.OBJECTS_LOCKFILE)
+	if err != nil {
+		return GCResult{}, fmt.Errorf("CollectGarbage(%s): %w", group, err)
+	}
+	if err := objFileLock.TryLock(); err != nil {
+		if errors.Is(err, locks.ErrIsLocked) {
+			return GCResult{}, err
+		}
+		return GCResult{}, fmt.Errorf("CollectGarbage(%s): %w", group, err)
+	}
+	defer objFileLock.Unlock()
+
+	// Any missing object stops the walk, sweeping a broken shelf may remove
+	// objects which are still needed.
+	walker := newObjectWalker(group)
+	if err := walker.walkRefs(); err != nil {
+		return GCResult{}, fmt.Errorf("CollectGarbage(%s): %w", group, err)
+	}
+
+	objects, err := listLooseObjects(group)
+	if err != nil {
+		return GCResult{}, fmt.Errorf("CollectGarbage(%s): %w", group, err)
+	}
+
+	result := GCResult{Reachable: len(walker.seen), Removed: []string{}}
+	for _, object := range objects {
+		if _, ok := walker.seen[object.Hash]; ok {
+			continue
+		}
+
+		if !dryRun {
+			if err := deleteObject(group, object.Hash); err != nil {
+				return result, fmt.Errorf("CollectGarbage(%s): %w", group, err)
+			}
+		}
+		result.Removed = append(result.Removed, object.Hash)
+		result.FreedBytes += object.Size
+	}
+
+	if !dryRun {
+		reachable := func(hash string) bool {
+			_, ok := walker.seen[hash]
+			return ok
+		}
+		if err := pruneSignatures(group, reachable); err != nil {
+			return result, fmt.Errorf("CollectGarbage(%s): %w", group, err)
+		}
+	}
+
+	packed, err := listPackedObjects(group)
+	if err != nil {
+		return result, fmt.Errorf("CollectGarbage(%s): %w", group, err)
+	}
+	result.Packed = []string{}
+	for _, object := range packed {
+		if _, ok := walker.seen[object.Hash]; !ok {
+			result.Packed = append(result.Packed, object.Hash)
+		}
+	}
+
+	return result, nil
+}
+
+// RepackObjects writes all objects of the group reachable from any reference into one
+// new pack, then removes the other packs and the packed loose objects, so unreachable
+// packed objects are dropped. Older versions of resources and properties are stored as
+// delta if delta is set, see PackObjects. Nothing is written if dryRun is set.
+// Will use flock on objects to prevent racing with mining,
+// return locks.ErrIsLocked if file lock is not acquired.
+func RepackObjects(group string, delta, dryRun bool) (PackResult, error) {
+	objFileLock, err := locks.NewLock("", locks.OBJECTS_LOCKFILE)
+	if err != nil {
+		return PackResult{}, fmt.Errorf("RepackObjects(%s): %w", group, err)
+	}
+	if err := objFileLock.TryLock(); err != nil {
+		if errors.Is(err, locks.ErrIsLocked) {
+			return PackResult{}, err
+		}
+		return PackResult{}, fmt.Errorf("RepackObjects(%s): %w", group, err)
+	}
+	defer objFileLock.Unlock()
+
+	if _, err := packDir(group); err != nil {
+		return PackResult{}, fmt.Errorf("RepackObjects(%s): %w", group, err)
+	}
+
+	walker := newObjectWalker(group)
+	if err := walker.walkRefs(); err != nil {
+		return PackResult{}, fmt.Errorf("RepackObjects(%s): %w", group, err)
+	}
+	hashes := make([]string, 0, len(walker.seen))
+	for hash := range walker.seen {
+		hashes = append(hashes, hash)
+	}
+
+	result, err := repackObjects(group, hashes, delta, dryRun)
+	if err != nil {
+		return result, fmt.Errorf("RepackObjects(%s): %w", group, err)
+	}
+	return result, nil
+}
@@ -1,99 +1,45 @@
 package shelf
 
 import (
-	"compress/zlib"
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
-	"os"
-	"path/filepath"
+	"io/fs"
+	"path"
 	"time"
 
 	"github.com/liuminhaw/mist-miner/locks"
 )
 
 type HistoryRecord struct {
-	Dir   string
-	File  io.ReadWriteCloser
+	Group string
 	Index int
 }
 
 func NewHistoryRecord(group string, index int) (HistoryRecord, error) {
-	dir, err := historyDir(group)
-	if err != nil {
-		return HistoryRecord{}, fmt.Errorf("NewHistoryRecord(%s): %w", group, err)
-	}
-
-	return HistoryRecord{Dir: dir, Index: index}, nil
-}
-
-// NewFile creates a new history file with the given group and index number
-// and assigns it to the HistoryRecord struct File field.
-func (hr *HistoryRecord) NewFile() error {
-	file, err := os.Create(
-		filepath.Join(hr.Dir, fmt.Sprintf("%s.%d", SHELF_HISTORY_FILE, hr.Index)),
-	)
-	if err != nil {
-		return fmt.Errorf("NewHistoryFile(%d): %w", hr.Index, err)
-	}
-	hr.File = file
-
-	return nil
+	return HistoryRecord{Group: group, Index: index}, nil
 }
 
-// ReadFile reads the file from the group and index set in the HistoryRecord struct,
+// Read reads the history record of the group and index set in the HistoryRecord struct,
 // extract with zlib and return the result as a io.ReadCloser
 func (hr *HistoryRecord) Read() (io.ReadCloser, error) {
-	if err := hr.openFile(); err != nil {
-		return nil, fmt.Errorf("Read(): %w", err)
-	}
-
-	r, err := zlib.NewReader(hr.File)
+	content, err := readCompressedRef(hr.Group, hr.name())
 	if err != nil {
 		return nil, fmt.Errorf("Read(): %w", err)
 	}
-	defer r.Close()
 
-	return r, nil
+	return io.NopCloser(bytes.NewReader(content)), nil
 }
 
-// CloseFile closes the file from the HistoryRecord struct
-func (hr *HistoryRecord) CloseFile() error {
-	if hr.File != nil {
-		return hr.File.Close()
-	}
-
-	return nil
+// name returns the reference name of the history record
+func (hr *HistoryRecord) name() string {
+	return path.Join(historyDir(), fmt.Sprintf("%s.%d", SHELF_HISTORY_FILE, hr.Index))
 }
 
-// openFile opens the history file from the group and index set in the HistoryRecord struct
-func (hr *HistoryRecord) openFile() error {
-	file, err := os.Open(
-		filepath.Join(hr.Dir, fmt.Sprintf("%s.%d", SHELF_HISTORY_FILE, hr.Index)),
-	)
-	if err != nil {
-		return fmt.Errorf("OpenHistoryFile(%d): %w", hr.Index, err)
-	}
-	hr.File = file
-
-	return nil
-}
-
-// historyDir returns the directory path to store the history records for the given group
-func historyDir(group string) (string, error) {
-	execPath, err := os.Executable()
-	if err != nil {
-		return "", fmt.Errorf("HistoryDir(%s): %w", group, err)
-	}
-
-	return filepath.Join(
-		filepath.Dir(execPath),
-		shelf_dir,
-		group,
-		shelf_ref_dir,
-		shelf_history_dir,
-		shelf_history_logger_dir,
-	), nil
+// historyDir returns the reference directory storing the history records
+func historyDir() string {
+	return path.Join(shelf_history_dir, shelf_history_logger_dir)
 }
 
 // GenerateHistoryRecords generates history records files for the given group
@@ -136,63 +82,61 @@ func GenerateHistoryRecords(group string, recordsPerPage int) error {
 		return fmt.Errorf("GenerateHistoryRecords(%s): %w", group, err)
 	}
 
-	if err := os.RemoveAll(record.Dir); err != nil {
+	if err := deleteRefs(group, historyDir()); err != nil {
 		return fmt.Errorf("GenerateHistoryRecords(%s): %w", group, err)
 	}
-	if err := os.MkdirAll(record.Dir, os.ModePerm); err != nil {
+
+	pages, err := historyPages(group, string(head.Reference), recordsPerPage)
+	if err != nil {
 		return fmt.Errorf("GenerateHistoryRecords(%s): %w", group, err)
 	}
 
-	reference := string(head.Reference)
-	prevMark := LabelMark{}
-filesLoop:
-	for {
-		record.NewFile()
-		defer record.File.Close()
+	for _, page := range pages {
+		if err := writeCompressedRef(group, record.name(), page); err != nil {
+			return fmt.Errorf("GenerateHistoryRecords(%s): %w", group, err)
+		}
+		record.Index++
+	}
 
-		w := zlib.NewWriter(record.File)
-		defer w.Close()
+	return nil
+}
 
-		if record.Index != 0 {
-			prev := fmt.Sprintf("%s%s %s %v\n", SHELF_HISTORY_LOGS_PREV, prevMark.Hash[:8], prevMark.LogType, prevMark.TimeStamp.Format(time.RFC3339))
-			_, err := w.Write([]byte(prev))
-			if err != nil {
-				return fmt.Errorf("GenerateHistoryRecords(%s): %w", group, err)
-			}
+// historyPages generates content of history records files by walking through
+// the label marks starting from the given reference, each page contains at most
+// recordsPerPage records with links to previous and next pages.
+func historyPages(group, reference string, recordsPerPage int) ([][]byte, error) {
+	pages := [][]byte{}
+	prevMark := LabelMark{}
+	for index := 0; ; index++ {
+		var page bytes.Buffer
+
+		if index != 0 {
+			fmt.Fprintf(&page, "%s%s %s %v\n", SHELF_HISTORY_LOGS_PREV, prevMark.Hash[:8], prevMark.LogType, prevMark.TimeStamp.Format(time.RFC3339))
 		}
 		for i := 0; i < recordsPerPage; i++ {
 			mark, err := ReadMark(group, reference)
 			if err != nil {
-				return fmt.Errorf("GenerateHistoryRecords(%s): %w", group, err)
+				return nil, fmt.Errorf("historyPages: %w", err)
 			}
 
-			_, err = w.Write([]byte(fmt.Sprintf("%s %s %v\n", mark.Hash, mark.LogType, mark.TimeStamp.Format(time.RFC3339))))
-			if err != nil {
-				return fmt.Errorf("GenerateHistoryRecords(%s): %w", group, err)
-			}
+			fmt.Fprintf(&page, "%s %s %v\n", mark.Hash, mark.LogType, mark.TimeStamp.Format(time.RFC3339))
 
 			if mark.Parent == "nil" {
-				break filesLoop
+				return append(pages, page.Bytes()), nil
 			} else if i == recordsPerPage-1 {
 				prevMark = *mark
 				reference = mark.Parent
 				tmpMark, err := ReadMark(group, reference)
 				if err != nil {
-					return fmt.Errorf("GenerateHistoryRecords(%s): %w", group, err)
-				}
-				next := fmt.Sprintf("%s%s %s %v\n", tmpMark.Hash[:8], SHELF_HISTORY_LOGS_NEXT, tmpMark.LogType, tmpMark.TimeStamp.Format(time.RFC3339))
-				_, err = w.Write([]byte(next))
-				if err != nil {
-					return fmt.Errorf("GenerateHistoryRecords(%s): %w", group, err)
+					return nil, fmt.Errorf("historyPages: %w", err)
 				}
+				fmt.Fprintf(&page, "%s%s %s %v\n", tmpMark.Hash[:8], SHELF_HISTORY_LOGS_NEXT, tmpMark.LogType, tmpMark.TimeStamp.Format(time.RFC3339))
 			} else {
 				reference = mark.Parent
 			}
 		}
-		record.Index++
+		pages = append(pages, page.Bytes())
 	}
-
-	return nil
 }
 
 // GenerateHistoryPointers generates history pointers files for the given group.
@@ -229,11 +173,7 @@ func GenerateHistoryPointers(group string) error {
 	}
 	defer ptrFileLock.Unlock()
 
-	ptrDir, err := pointerDir(group)
-	if err != nil {
-		return fmt.Errorf("GenerateHistoryPointers(%s): %w", group, err)
-	}
-	if err := os.RemoveAll(ptrDir); err != nil {
+	if err := deleteRefs(group, pointerDir()); err != nil {
 		return fmt.Errorf("GenerateHistoryPointers(%s): %w", group, err)
 	}
 
@@ -273,96 +213,28 @@ func NewHistoryPointer(group, parentHash, currentHash string) HistoryPointer {
 // WriteNextMap writes the parent sha and current sha to the history pointer file
 // in the format of: <parent sha> <current sha>.
 func (hp HistoryPointer) WriteNextMap() error {
-	file, err := hp.filepath()
-	if err != nil {
-		return fmt.Errorf("writeNextMap: get pointer file: %w", err)
-	}
-	if err := os.MkdirAll(filepath.Dir(file), os.ModePerm); err != nil {
-		return fmt.Errorf("writeNextMap: create dir: %w", err)
-	}
-
-	var content []byte
-	if _, err := os.Stat(file); !os.IsNotExist(err) {
-		content, err = hp.readFile()
-		if err != nil {
-			return fmt.Errorf("writeNextMap: read pointer content: %w", err)
-		}
+	content, err := readCompressedRef(hp.Group, hp.name())
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("writeNextMap: read pointer content: %w", err)
 	}
 	content = append(
 		content,
 		[]byte(fmt.Sprintf("%s %s\n", hp.ParentHash, hp.CurrentHash))...)
 
-	f, err := os.Create(file)
-	if err != nil {
-		return fmt.Errorf("writeNextMap: create pointer file: %w", err)
+	if err := writeCompressedRef(hp.Group, hp.name(), content); err != nil {
+		return fmt.Errorf("writeNextMap: %w", err)
 	}
-	defer f.Close()
-
-	w := zlib.NewWriter(f)
-	_, err = w.Write(content)
-	if err != nil {
-		return fmt.Errorf("writeNextMap: zlib write: %w", err)
-	}
-	defer w.Close()
 
 	return nil
 }
 
-// readFile reads the history pointer file and returns the content as a byte slice
-func (hp HistoryPointer) readFile() ([]byte, error) {
-	file, err := hp.filepath()
-	if err != nil {
-		return nil, fmt.Errorf("hp.readPointerFile: get pointer file: %w", err)
-	}
-
-	f, err := os.Open(file)
-	if err != nil {
-		return nil, fmt.Errorf("hp.readPointerFile: open pointer file: %w", err)
-	}
-	defer f.Close()
-
-	r, err := zlib.NewReader(f)
-	if err != nil {
-		return nil, fmt.Errorf("hp.readPointerFile: zlib read: %w", err)
-	}
-	defer r.Close()
-
-	content, err := io.ReadAll(r)
-	if err != nil {
-		return nil, fmt.Errorf("hp.readPointerFile: read pointer content: %w", err)
-	}
-
-	return content, nil
-}
-
-// filepath returns the file path to store the history pointers of HistoryPointer struct
-func (hp HistoryPointer) filepath() (string, error) {
-	dir, err := pointerDir(hp.Group)
-	if err != nil {
-		return "", fmt.Errorf("hp.pointerFile: %w", err)
-	}
-
-	return filepath.Join(
-		dir,
-		hp.ParentHash[:2],
-		shelf_history_pointer_file,
-	), nil
+// name returns the reference name of the history pointer file of HistoryPointer struct
+// using the first two characters of the parent sha as the subdirectory
+func (hp HistoryPointer) name() string {
+	return path.Join(pointerDir(), hp.ParentHash[:2], shelf_history_pointer_file)
 }
 
-// pointerDir returns the directory path to store the history pointers for the given group and sha
-// using the first two characters of the sha as the subdirectory
-func pointerDir(group string) (string, error) {
-	execPath, err := os.Executable()
-	if err != nil {
-		return "", fmt.Errorf("PointerDir: get executable: %w", err)
-	}
-
-	return filepath.Join(
-		filepath.Dir(execPath),
-		shelf_dir,
-		group,
-		shelf_ref_dir,
-		shelf_history_dir,
-		shelf_history_pointer_dir,
-	), nil
+// pointerDir returns the reference directory storing the history pointers
+func pointerDir() string {
+	return path.Join(shelf_history_dir, shelf_history_pointer_dir)
 }
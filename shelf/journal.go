@@ -0,0 +1,242 @@
+package shelf
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/liuminhaw/mist-miner/paths"
+	"github.com/liuminhaw/mist-miner/store"
+)
+
+// A mine run records the HEAD of every mined group in the journal before writing
+// objects, and the new label marks once all of them are written. HEADs are only moved
+// after the journal is committed, and the journal is removed when the run completes.
+// A journal left by an interrupted run is recovered on the next run: a committed
+// journal is rolled forward so every group advances, otherwise HEADs moved by the run
+// are restored and objects written by the run are left unreachable for gc.
+const (
+	JOURNAL_BEGIN          = "begin"
+	JOURNAL_COMMIT         = "commit"
+	JOURNAL_ROLLED_BACK    = "rolled back"
+	JOURNAL_ROLLED_FORWARD = "rolled forward"
+
+	mine_journal_file = "mine.journal"
+)
+
+type JournalEntry struct {
+	Group string `json:"group"`
+	// HEAD of the group before the run, "nil" if the group has no label mark
+	Head string `json:"head"`
+	// Label mark written by the run, empty if the run has not committed or the group
+	// has nothing mined
+	Mark string `json:"mark,omitempty"`
+}
+
+type MineJournal struct {
+	State   string         `json:"state"`
+	Entries []JournalEntry `json:"entries"`
+	path    string
+}
+
+// JournalRecovery is the result of recovering a journal left by an interrupted run,
+// Action is JOURNAL_ROLLED_BACK or JOURNAL_ROLLED_FORWARD, empty if there is no journal.
+type JournalRecovery struct {
+	Action string
+	Groups []string
+}
+
+// BeginMineJournal records the HEAD of given groups in a new journal. The caller holds
+// the objects lock until the journal is closed, and recovers any previous journal
+// by RecoverMineJournal first.
+func BeginMineJournal(groups []string) (*MineJournal, error) {
+	journalPath, err := mineJournalPath()
+	if err != nil {
+		return nil, fmt.Errorf("BeginMineJournal(): %w", err)
+	}
+	if _, err := os.Stat(journalPath); err == nil {
+		return nil, fmt.Errorf("BeginMineJournal(): %s: %w", journalPath, ErrJournalPending)
+	}
+
+	journal := MineJournal{State: JOURNAL_BEGIN, Entries: []JournalEntry{}, path: journalPath}
+	for _, group := range groups {
+		head, err := NewRefMark(SHELF_MARK_FILE, group)
+		entry := JournalEntry{Group: group, Head: "nil"}
+		if err == nil {
+			entry.Head = string(head.Reference)
+		} else if !errors.Is(err, ErrRefHeadNotFound) {
+			return nil, fmt.Errorf("BeginMineJournal(): %w", err)
+		}
+		journal.Entries = append(journal.Entries, entry)
+	}
+
+	if err := journal.write(); err != nil {
+		return nil, fmt.Errorf("BeginMineJournal(): %w", err)
+	}
+	return &journal, nil
+}
+
+// Commit records the label marks written by the run, keyed by group. Label marks and
+// the objects they refer to must be written before commit, since a committed journal
+// is rolled forward on recovery.
+func (j *MineJournal) Commit(marks map[string]string) error {
+	for i, entry := range j.Entries {
+		j.Entries[i].Mark = marks[entry.Group]
+	}
+	j.State = JOURNAL_COMMIT
+	if err := j.write(); err != nil {
+		return fmt.Errorf("mine journal commit: %w", err)
+	}
+	return nil
+}
+
+// Apply moves HEAD of every group to the label mark recorded by Commit.
+func (j *MineJournal) Apply() error {
+	if j.State != JOURNAL_COMMIT {
+		return fmt.Errorf("mine journal apply: journal state %s", j.State)
+	}
+	for _, entry := range j.Entries {
+		if entry.Mark == "" {
+			continue
+		}
+		if err := moveHead(entry.Group, entry.Mark); err != nil {
+			return fmt.Errorf("mine journal apply: %w", err)
+		}
+	}
+	return nil
+}
+
+// Close removes the journal, the run is complete.
+func (j *MineJournal) Close() error {
+	if err := os.Remove(j.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("mine journal close: %w", err)
+	}
+	if err := store.SyncDir(filepath.Dir(j.path)); err != nil {
+		return fmt.Errorf("mine journal close: %w", err)
+	}
+	return nil
+}
+
+// Groups returns the groups advanced by the run
+func (j *MineJournal) Groups() []string {
+	groups := []string{}
+	for _, entry := range j.Entries {
+		if entry.Mark != "" {
+			groups = append(groups, entry.Group)
+		}
+	}
+	return groups
+}
+
+// RecoverMineJournal finishes a journal left by an interrupted mine run. A committed
+// journal with every label mark present is rolled forward, otherwise HEADs moved to
+// label marks of the run are restored. History records of the returned groups are to be regenerated
+// once the objects lock is released. The caller holds the objects lock.
+func RecoverMineJournal() (JournalRecovery, error) {
+	journalPath, err := mineJournalPath()
+	if err != nil {
+		return JournalRecovery{}, fmt.Errorf("RecoverMineJournal(): %w", err)
+	}
+	content, err := os.ReadFile(journalPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return JournalRecovery{}, nil
+	} else if err != nil {
+		return JournalRecovery{}, fmt.Errorf("RecoverMineJournal(): %w", err)
+	}
+
+	journal := MineJournal{path: journalPath}
+	if err := json.Unmarshal(content, &journal); err != nil {
+		return JournalRecovery{}, fmt.Errorf("RecoverMineJournal(): %w: %v", ErrCorruptJournal, err)
+	}
+
+	recovery := JournalRecovery{Action: JOURNAL_ROLLED_BACK, Groups: []string{}}
+	if journal.State == JOURNAL_COMMIT && journal.marksExist() {
+		if err := journal.Apply(); err != nil {
+			return JournalRecovery{}, fmt.Errorf("RecoverMineJournal(): %w", err)
+		}
+		recovery.Action = JOURNAL_ROLLED_FORWARD
+	} else if journal.State == JOURNAL_BEGIN || journal.State == JOURNAL_COMMIT {
+		for _, entry := range journal.Entries {
+			if err := entry.restoreHead(); err != nil {
+				return JournalRecovery{}, fmt.Errorf("RecoverMineJournal(): %w", err)
+			}
+		}
+	} else {
+		return JournalRecovery{}, fmt.Errorf("RecoverMineJournal(): state %s: %w", journal.State, ErrCorruptJournal)
+	}
+
+	for _, entry := range journal.Entries {
+		recovery.Groups = append(recovery.Groups, entry.Group)
+	}
+	if err := journal.Close(); err != nil {
+		return JournalRecovery{}, fmt.Errorf("RecoverMineJournal(): %w", err)
+	}
+	return recovery, nil
+}
+
+// marksExist checks if every label mark recorded by commit is written
+func (j *MineJournal) marksExist() bool {
+	for _, entry := range j.Entries {
+		if entry.Mark != "" && !NewObjectRecord(entry.Group, entry.Mark).Exist() {
+			return false
+		}
+	}
+	return true
+}
+
+func (j *MineJournal) write() error {
+	content, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return fmt.Errorf("write journal: %w", err)
+	}
+	if err := store.WriteFileAtomic(j.path, content); err != nil {
+		return fmt.Errorf("write journal: %w", err)
+	}
+	return nil
+}
+
+// moveHead points HEAD of the group to the label mark of given hash
+func moveHead(group, hash string) error {
+	head := RefMark{Name: SHELF_MARK_FILE, Group: group, Reference: []byte(hash)}
+	if err := head.write(); err != nil {
+		return fmt.Errorf("move head: %w", err)
+	}
+	return nil
+}
+
+// restoreHead points HEAD of the group back to the HEAD before the run if it was moved
+// to the label mark of the run, HEAD is removed if the group had no label mark.
+// HEAD moved by other commands since the run is left as is.
+func (e JournalEntry) restoreHead() error {
+	if e.Mark == "" {
+		return nil
+	}
+	current, err := NewRefMark(SHELF_MARK_FILE, e.Group)
+	if errors.Is(err, ErrRefHeadNotFound) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("restore head: %w", err)
+	}
+	if string(current.Reference) != e.Mark {
+		return nil
+	}
+
+	if e.Head != "nil" {
+		return moveHead(e.Group, e.Head)
+	}
+	if err := deleteRef(e.Group, SHELF_MARK_FILE); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("restore head: %w", err)
+	}
+	return nil
+}
+
+func mineJournalPath() (string, error) {
+	shelfDir, err := paths.ShelfDir()
+	if err != nil {
+		return "", fmt.Errorf("mine journal path: %w", err)
+	}
+	return filepath.Join(shelfDir, mine_journal_file), nil
+}
@@ -0,0 +1,163 @@
+package shelf
+
+import (
+	"errors"
+	"os"
+	"slices"
+	"testing"
+)
+
+// headOf returns HEAD of the group, "nil" if the group has no label mark
+func headOf(t *testing.T, group string) string {
+	t.Helper()
+
+	head, err := NewRefMark(SHELF_MARK_FILE, group)
+	if errors.Is(err, ErrRefHeadNotFound) {
+		return "nil"
+	} else if err != nil {
+		t.Fatalf("NewRefMark(%s): %v", group, err)
+	}
+	return string(head.Reference)
+}
+
+func assertJournalRemoved(t *testing.T) {
+	t.Helper()
+
+	journalPath, err := mineJournalPath()
+	if err != nil {
+		t.Fatalf("mineJournalPath: %v", err)
+	}
+	if _, err := os.Stat(journalPath); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("journal stat error = %v, want journal removed", err)
+	}
+}
+
+func TestRecoverMineJournalBegin(t *testing.T) {
+	useMemoryStore(t)
+
+	first := writeTestMark(t, "grp", "aws-iam", testResource("alice", `{"a":1}`))
+	if _, err := BeginMineJournal([]string{"grp", "new"}); err != nil {
+		t.Fatalf("BeginMineJournal: %v", err)
+	}
+	if _, err := BeginMineJournal([]string{"grp"}); !errors.Is(err, ErrJournalPending) {
+		t.Errorf("BeginMineJournal error = %v, want %v", err, ErrJournalPending)
+	}
+
+	// The run writes its label mark but is interrupted before commit, HEAD is
+	// only moved after commit.
+	writeTestMark(t, "grp", "aws-iam", testResource("alice", `{"a":2}`))
+	if err := moveHead("grp", first); err != nil {
+		t.Fatalf("moveHead: %v", err)
+	}
+
+	recovery, err := RecoverMineJournal()
+	if err != nil {
+		t.Fatalf("RecoverMineJournal: %v", err)
+	}
+	if recovery.Action != JOURNAL_ROLLED_BACK || !slices.Equal(recovery.Groups, []string{"grp", "new"}) {
+		t.Errorf("RecoverMineJournal = %+v, want grp and new rolled back", recovery)
+	}
+	if head := headOf(t, "grp"); head != first {
+		t.Errorf("HEAD of grp = %s, want %s", head, first)
+	}
+	if head := headOf(t, "new"); head != "nil" {
+		t.Errorf("HEAD of new = %s, want no label mark", head)
+	}
+	assertJournalRemoved(t)
+
+	if recovery, err := RecoverMineJournal(); err != nil || recovery.Action != "" {
+		t.Errorf("RecoverMineJournal without journal = %+v, %v, want nothing to recover", recovery, err)
+	}
+}
+
+func TestRecoverMineJournalCommit(t *testing.T) {
+	useMemoryStore(t)
+
+	first := writeTestMark(t, "grp", "aws-iam", testResource("alice", `{"a":1}`))
+	journal, err := BeginMineJournal([]string{"grp", "new"})
+	if err != nil {
+		t.Fatalf("BeginMineJournal: %v", err)
+	}
+
+	// Every label mark is written and the journal committed, but the run is
+	// interrupted before moving any HEAD.
+	second := writeTestMark(t, "grp", "aws-iam", testResource("alice", `{"a":2}`))
+	created := writeTestMark(t, "new", "aws-iam", testResource("bob", `{"b":1}`))
+	if err := moveHead("grp", first); err != nil {
+		t.Fatalf("moveHead: %v", err)
+	}
+	if err := deleteRef("new", SHELF_MARK_FILE); err != nil {
+		t.Fatalf("deleteRef: %v", err)
+	}
+	if err := journal.Commit(map[string]string{"grp": second, "new": created}); err != nil {
+		t.Fatalf("MineJournal.Commit: %v", err)
+	}
+
+	recovery, err := RecoverMineJournal()
+	if err != nil {
+		t.Fatalf("RecoverMineJournal: %v", err)
+	}
+	if recovery.Action != JOURNAL_ROLLED_FORWARD || !slices.Equal(recovery.Groups, []string{"grp", "new"}) {
+		t.Errorf("RecoverMineJournal = %+v, want grp and new rolled forward", recovery)
+	}
+	for group, want := range map[string]string{"grp": second, "new": created} {
+		if head := headOf(t, group); head != want {
+			t.Errorf("HEAD of %s = %s, want %s", group, head, want)
+		}
+	}
+	assertJournalRemoved(t)
+}
+
+func TestRecoverMineJournalCommitMissingMark(t *testing.T) {
+	useMemoryStore(t)
+
+	first := writeTestMark(t, "grp", "aws-iam", testResource("alice", `{"a":1}`))
+	journal, err := BeginMineJournal([]string{"grp", "new", "other"})
+	if err != nil {
+		t.Fatalf("BeginMineJournal: %v", err)
+	}
+
+	// The journal records label marks which were never written, HEADs moved to
+	// them are restored while HEAD moved elsewhere since the run is kept.
+	missing := "0123456789abcdef0123456789abcdef01234567"
+	other := writeTestMark(t, "other", "aws-iam", testResource("carol", `{"c":1}`))
+	if err := journal.Commit(map[string]string{"grp": missing, "new": missing, "other": missing}); err != nil {
+		t.Fatalf("MineJournal.Commit: %v", err)
+	}
+	for _, group := range []string{"grp", "new"} {
+		if err := moveHead(group, missing); err != nil {
+			t.Fatalf("moveHead: %v", err)
+		}
+	}
+
+	recovery, err := RecoverMineJournal()
+	if err != nil {
+		t.Fatalf("RecoverMineJournal: %v", err)
+	}
+	if recovery.Action != JOURNAL_ROLLED_BACK {
+		t.Errorf("RecoverMineJournal action = %s, want %s", recovery.Action, JOURNAL_ROLLED_BACK)
+	}
+	for group, want := range map[string]string{"grp": first, "new": "nil", "other": other} {
+		if head := headOf(t, group); head != want {
+			t.Errorf("HEAD of %s = %s, want %s", group, head, want)
+		}
+	}
+	assertJournalRemoved(t)
+}
+
+func TestRecoverMineJournalCorrupt(t *testing.T) {
+	useMemoryStore(t)
+
+	journalPath, err := mineJournalPath()
+	if err != nil {
+		t.Fatalf("mineJournalPath: %v", err)
+	}
+	for _, content := range []string{"not json", `{"state":"unknown","entries":[]}`} {
+		if err := os.WriteFile(journalPath, []byte(content), 0o644); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+		if _, err := RecoverMineJournal(); !errors.Is(err, ErrCorruptJournal) {
+			t.Errorf("RecoverMineJournal(%q) error = %v, want %v", content, err, ErrCorruptJournal)
+		}
+	}
+}
@@ -3,12 +3,9 @@ package shelf
 import (
 	"bufio"
 	"bytes"
-	"compress/zlib"
-	"crypto/sha256"
 	"errors"
 	"fmt"
 	"os"
-	"path/filepath"
 	"slices"
 	"strings"
 	"time"
@@ -80,46 +77,32 @@ func (ihm *IdentifierHashMaps) Sort() {
 	})
 }
 
-func (lhm *IdentifierHashMaps) Write() error {
+// Write writes the identifier hash maps and returns the message of the result
+func (lhm *IdentifierHashMaps) Write() (string, error) {
 	err := lhm.calcHash()
 	if err != nil {
-		return fmt.Errorf("identifier hash maps write: calc hash: %w", err)
+		return "", fmt.Errorf("identifier hash maps write: calc hash: %w", err)
 	}
 
-	mapFile, err := NewObjectRecord(lhm.Group, lhm.Hash).RecordFile()
+	record := NewObjectRecord(lhm.Group, lhm.Hash)
+	mapFile, err := record.RecordFile()
 	if err != nil {
-		return fmt.Errorf("identifier hash maps write: %w", err)
+		return "", fmt.Errorf("identifier hash maps write: %w", err)
 	}
-	if _, err := os.Stat(mapFile); !errors.Is(err, os.ErrNotExist) {
-		fmt.Printf("Identifier hash maps file already exists: %s\n", mapFile)
-		return nil
+	if record.Exist() {
+		return fmt.Sprintf("Identifier hash maps file already exists: %s\n", mapFile), nil
 	}
 
-	err = os.MkdirAll(filepath.Dir(mapFile), os.ModePerm)
-	if err != nil {
-		return fmt.Errorf("identifier hash maps write: mkdir: %w", err)
+	if err := writeObject(lhm.Group, lhm.Hash, OBJECT_TYPE_IDMAPS, lhm.buffer.Bytes()); err != nil {
+		return "", fmt.Errorf("identifier hash maps write: %w", err)
 	}
 
-	f, err := os.Create(mapFile)
-	if err != nil {
-		return fmt.Errorf("identifier hash maps write: create file: %w", err)
-	}
-	defer f.Close()
-
-	w := zlib.NewWriter(f)
-	_, err = w.Write(lhm.buffer.Bytes())
-	if err != nil {
-		return fmt.Errorf("identifier hash maps write: write file: %w", err)
-	}
-	defer w.Close()
-
-	fmt.Printf("Identifier hash maps file written: %s\n", mapFile)
-	return nil
+	return fmt.Sprintf("Identifier hash maps file written: %s\n", mapFile), nil
 }
 
 // calcHash calculates the hash of Maps in IdentifierHashMaps.
 // Maps is first write to a buffer with content "hash identifier"
-// and then the buffer is hashed with sha256 to get the hash value.
+// and then the buffer is hashed with the group hash algorithm to get the hash value.
 func (lhm *IdentifierHashMaps) calcHash() error {
 	for _, m := range lhm.Maps {
 		if m.Alias != "" {
@@ -129,12 +112,11 @@ func (lhm *IdentifierHashMaps) calcHash() error {
 		}
 	}
 
-	h := sha256.New()
-	_, err := h.Write(lhm.buffer.Bytes())
+	hash, err := objectHash(lhm.Group, lhm.buffer.Bytes())
 	if err != nil {
 		return fmt.Errorf("calc hash: %w", err)
 	}
-	lhm.Hash = fmt.Sprintf("%x", h.Sum(nil))
+	lhm.Hash = hash
 
 	return nil
 }
@@ -160,27 +142,10 @@ func NewRefMark(name, group string) (RefMark, error) {
 // write writes the reference to the HEAD file.
 // reference is the hash of the latest label mark.
 func (m *RefMark) write() error {
-	RefFile, err := RefFile(m.Group, m.Name)
-	if err != nil {
+	if err := writeRef(m.Group, m.Name, []byte(fmt.Sprintf("%s\n", m.Reference))); err != nil {
 		return fmt.Errorf("ref mark write: %w", err)
 	}
 
-	err = os.MkdirAll(filepath.Dir(RefFile), os.ModePerm)
-	if err != nil {
-		return fmt.Errorf("ref mark write: mkdir: %w", err)
-	}
-
-	f, err := os.Create(RefFile)
-	if err != nil {
-		return fmt.Errorf("ref mark write: create file: %w", err)
-	}
-	defer f.Close()
-
-	_, err = f.WriteString(fmt.Sprintf("%s\n", m.Reference))
-	if err != nil {
-		return fmt.Errorf("ref mark write: write file: %w", err)
-	}
-
 	return nil
 }
 
@@ -188,13 +153,10 @@ func (m *RefMark) write() error {
 // Will use flock to prevent writing while reading,
 // return locks.ErrIsLocked if file lock is not acquired.
 func (m *RefMark) getReference() error {
-	file, err := RefFile(m.Group, m.Name)
-	if err != nil {
-		return fmt.Errorf("getReference(): %w", err)
-	}
-
-	if _, err := os.Stat(file); errors.Is(err, os.ErrNotExist) {
+	if ok, err := refExist(m.Group, m.Name); err != nil {
 		return fmt.Errorf("getReference(): %w", err)
+	} else if !ok {
+		return fmt.Errorf("getReference(): %s: %w", m.Name, os.ErrNotExist)
 	}
 
 	fileLock, err := locks.NewLock(m.Group, locks.REF_MARK_LOCKFILE)
@@ -209,25 +171,20 @@ func (m *RefMark) getReference() error {
 	}
 	defer fileLock.Unlock()
 
-	f, err := os.Open(file)
+	content, err := readRef(m.Group, m.Name)
 	if err != nil {
 		return fmt.Errorf("getReference(): %w", err)
 	}
-	defer f.Close()
 
-	b := make([]byte, sha256.BlockSize)
-	_, err = f.Read(b)
-	if err != nil {
-		return fmt.Errorf("gerReference: %w", err)
-	}
-
-	m.Reference = b
+	m.Reference = bytes.TrimSpace(content)
 	return nil
 }
 
 type MarkMapping struct {
 	Module string
 	Hash   string
+	// Version of the plugin, empty if the plugin does not report it
+	Version string
 }
 
 type LabelMark struct {
@@ -308,13 +265,21 @@ func ReadMark(group, mapHash string) (*LabelMark, error) {
 	for scanner.Scan() {
 		line := scanner.Text()
 		fields := strings.Fields(line)
-		if len(fields) != 2 {
+		switch len(fields) {
+		case 2:
+			mark.Mappings = append(mark.Mappings, MarkMapping{
+				Hash:   fields[0],
+				Module: fields[1],
+			})
+		case 3:
+			mark.Mappings = append(mark.Mappings, MarkMapping{
+				Hash:    fields[0],
+				Module:  fields[1],
+				Version: fields[2],
+			})
+		default:
 			return nil, fmt.Errorf("read label mark: invalid mapping: %s", line)
 		}
-		mark.Mappings = append(mark.Mappings, MarkMapping{
-			Hash:   fields[0],
-			Module: fields[1],
-		})
 	}
 	if err := scanner.Err(); err != nil {
 		return nil, fmt.Errorf("read label mark: %w", err)
@@ -323,65 +288,65 @@ func ReadMark(group, mapHash string) (*LabelMark, error) {
 	return &mark, nil
 }
 
-// AddMapping adds a new mark mapping to the label mark.
-func (lm *LabelMark) AddMapping(module, hash string) {
+// AddMapping adds a new mark mapping to the label mark, version is the version of the
+// plugin reported by Describe, empty if unknown.
+func (lm *LabelMark) AddMapping(module, hash, version string) {
 	lm.Mappings = append(lm.Mappings, MarkMapping{
-		Module: module,
-		Hash:   hash,
+		Module:  module,
+		Hash:    hash,
+		Version: version,
 	})
 }
 
-// Update writes the label mark to a file in format:
+// Update writes the label mark by Write
+// and also updates the HEAD reference to the hash of the latest label mark.
+func (lm *LabelMark) Update() error {
+	if err := lm.Write(); err != nil {
+		return fmt.Errorf("label mark update: %w", err)
+	}
+
+	// Update the HEAD reference.
+	head := RefMark{
+		Name:      "HEAD",
+		Group:     lm.Group,
+		Reference: []byte(lm.Hash),
+	}
+	if err := head.write(); err != nil {
+		return fmt.Errorf("label mark update: head write: %w", err)
+	}
+
+	return nil
+}
+
+// Write writes the label mark to a file in format:
 // timestamp
 // parent
 // label map hash
 //
-// And also updates the HEAD reference to the hash of the latest label mark.
-func (lm *LabelMark) Update() error {
-	// Write the label mark to a file.
+// The label mark is signed if a signing key is set, HEAD reference is not updated.
+func (lm *LabelMark) Write() error {
 	err := lm.calcHash()
 	if err != nil {
-		return fmt.Errorf("label mark update: %w", err)
+		return fmt.Errorf("label mark write: %w", err)
 	}
 
-	markFile, err := NewObjectRecord(lm.Group, lm.Hash).RecordFile()
+	record := NewObjectRecord(lm.Group, lm.Hash)
+	markFile, err := record.RecordFile()
 	if err != nil {
-		return fmt.Errorf("label mark update: %w", err)
+		return fmt.Errorf("label mark write: %w", err)
 	}
-	if _, err := os.Stat(markFile); !errors.Is(err, os.ErrNotExist) {
+	if record.Exist() {
 		return fmt.Errorf("Label mark file already exists, there maybe a collision: %s\n", markFile)
 	}
 
-	markDir := filepath.Dir(markFile)
-	err = os.MkdirAll(markDir, os.ModePerm)
-	if err != nil {
-		return fmt.Errorf("label mark update: mkdir: %w", err)
-	}
-
 	fmt.Printf("Label mark buffer: %s\n", lm.buffer.String())
 
-	f, err := os.Create(markFile)
-	if err != nil {
-		return fmt.Errorf("label mark update: create file: %w", err)
+	if err := writeObject(lm.Group, lm.Hash, OBJECT_TYPE_MARK, lm.buffer.Bytes()); err != nil {
+		return fmt.Errorf("label mark write: %w", err)
 	}
-	defer f.Close()
-
-	w := zlib.NewWriter(f)
-	_, err = w.Write(lm.buffer.Bytes())
-	if err != nil {
-		return fmt.Errorf("label mark update: write file: %w", err)
-	}
-	defer w.Close()
 	fmt.Printf("Label mark file written: %s\n", markFile)
-
-	// Update the HEAD reference.
-	head := RefMark{
-		Name:      "HEAD",
-		Group:     lm.Group,
-		Reference: []byte(lm.Hash),
-	}
-	if err := head.write(); err != nil {
-		return fmt.Errorf("label mark update: head write: %w", err)
+	if err := signMark(lm.Group, lm.Hash); err != nil {
+		return fmt.Errorf("label mark write: %w", err)
 	}
 
 	return nil
@@ -404,15 +369,18 @@ func (lm *LabelMark) calcHash() error {
 
 	lm.sort()
 	for _, m := range lm.Mappings {
-		fmt.Fprintf(&lm.buffer, "%s %s\n", m.Hash, m.Module)
+		if m.Version == "" {
+			fmt.Fprintf(&lm.buffer, "%s %s\n", m.Hash, m.Module)
+		} else {
+			fmt.Fprintf(&lm.buffer, "%s %s %s\n", m.Hash, m.Module, m.Version)
+		}
 	}
 
-	h := sha256.New()
-	_, err := h.Write(lm.buffer.Bytes())
+	hash, err := objectHash(lm.Group, lm.buffer.Bytes())
 	if err != nil {
 		return fmt.Errorf("calc hash: %w", err)
 	}
-	lm.Hash = fmt.Sprintf("%x", h.Sum(nil))
+	lm.Hash = hash
 
 	return nil
 }
@@ -0,0 +1,91 @@
+package shelf
+
+import (
+	"fmt"
+
+	"github.com/liuminhaw/mist-miner/shared"
+)
+
+// MarkedResource is a resource of a plugin found in a label mark
+// together with its attached diary.
+type MarkedResource struct {
+	Mark       string
+	Plugin     string
+	Identifier string
+	Alias      string
+	// Hash of the StuffOutline linking resource and diary
+	OutlineHash  string
+	ResourceHash string
+	DiaryHash    string
+	Resource     *shared.MinerResource
+	Diary        *shared.MinerDiary
+	// Markdown content of the diary note, empty if no note is written
+	Note string
+}
+
+// LookupResource resolves the identifier of the plugin through the label mark,
+// its IdentifierHashMaps and StuffOutline, and reads the resource with its diary.
+// Only IdentifierHashMaps of the plugin are read.
+// Return ErrResourceNotFound if the mark has no resource of the identifier.
+func LookupResource(group, markHash, plugin, identifier string) (*MarkedResource, error) {
+	idHashMap, err := lookupIdentifier(group, markHash, plugin, identifier)
+	if err != nil {
+		return nil, fmt.Errorf("LookupResource(%s, %s, %s): %w", group, plugin, identifier, err)
+	}
+	outline, err := ReadStuffOutline(group, idHashMap.Hash)
+	if err != nil {
+		return nil, fmt.Errorf("LookupResource(%s, %s, %s): %w", group, plugin, identifier, err)
+	}
+
+	found := MarkedResource{
+		Mark:         markHash,
+		Plugin:       plugin,
+		Identifier:   identifier,
+		Alias:        idHashMap.Alias,
+		OutlineHash:  idHashMap.Hash,
+		ResourceHash: outline.ResourceHash,
+		DiaryHash:    outline.DiaryHash,
+	}
+	if found.Resource, err = ReadResource(group, found.ResourceHash); err != nil {
+		return nil, fmt.Errorf("LookupResource(%s, %s, %s): %w", group, plugin, identifier, err)
+	}
+	if found.Diary, err = ReadMinerDiary(group, found.DiaryHash); err != nil {
+		return nil, fmt.Errorf("LookupResource(%s, %s, %s): %w", group, plugin, identifier, err)
+	}
+	if found.Diary.Hash != "" {
+		if found.Note, err = NewObjectRecord(group, found.Diary.Hash).RecordRead(); err != nil {
+			return nil, fmt.Errorf("LookupResource(%s, %s, %s): %w", group, plugin, identifier, err)
+		}
+	}
+
+	return &found, nil
+}
+
+// lookupIdentifier returns the identifier hash map of the resource in IdentifierHashMaps
+// of the plugin in the label mark, ErrResourceNotFound is returned if not found.
+func lookupIdentifier(group, markHash, plugin, identifier string) (IdentifierHashMap, error) {
+	if markHash == "" || markHash == "nil" {
+		return IdentifierHashMap{}, ErrResourceNotFound
+	}
+	mark, err := ReadMark(group, markHash)
+	if err != nil {
+		return IdentifierHashMap{}, fmt.Errorf("lookup identifier: %w", err)
+	}
+
+	for _, mapping := range mark.Mappings {
+		if mapping.Module != plugin {
+			continue
+		}
+		idHashMaps, err := ReadIdentifierHashMaps(group, mapping.Hash)
+		if err != nil {
+			return IdentifierHashMap{}, fmt.Errorf("lookup identifier: %w", err)
+		}
+		for _, idHashMap := range idHashMaps.Maps {
+			if idHashMap.Identifier == identifier {
+				return idHashMap, nil
+			}
+		}
+	}
+
+	return IdentifierHashMap{}, ErrResourceNotFound
+}
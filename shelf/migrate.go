@@ -0,0 +1,142 @@
+package shelf
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/liuminhaw/mist-miner/locks"
+)
+
+type MigrateResult struct {
+	// Legacy objects rewritten with typed header, or to be rewritten in dry run
+	Migrated []string
+	// Resource blobs rewritten as resource trees, or to be rewritten in dry run
+	Trees []string
+	// Objects which already have typed header
+	Typed int
+	// Legacy objects not reachable from any reference, their type cannot be decided
+	Skipped []string
+}
+
+// MigrateObjects rewrites legacy objects of the group without type header into
+// typed objects. Object types are decided by walking from each reference through
+// LabelMark -> IdentifierHashMaps -> StuffOutline -> resource / diary -> diary note,
+// unreachable legacy objects are left untouched. Reachable resources stored as one
+// json blob are split into resource trees of property objects. Object hashes do not
+// change since they are calculated from payload only, and trees are stored under the
+// hash of the resource they represent. Only loose objects are rewritten, packs never
+// hold legacy objects and packed resource blobs are left as is. Nothing is written
+// if dryRun is set.
+// Will use flock on objects to prevent racing with mining,
+// return locks.ErrIsLocked if file lock is not acquired.
+func MigrateObjects(group string, dryRun bool) (MigrateResult, error) {
+	objFileLock, err := locks.NewLock("", locks.OBJECTS_LOCKFILE)
+	if err != nil {
+		return MigrateResult{}, fmt.Errorf("MigrateObjects(%s): %w", group, err)
+	}
+	if err := objFileLock.TryLock(); err != nil {
+		if errors.Is(err, locks.ErrIsLocked) {
+			return MigrateResult{}, err
+		}
+		return MigrateResult{}, fmt.Errorf("MigrateObjects(%s): %w", group, err)
+	}
+	defer objFileLock.Unlock()
+
+	walker := newObjectWalker(group)
+	if err := walker.walkRefs(); err != nil {
+		return MigrateResult{}, fmt.Errorf("MigrateObjects(%s): %w", group, err)
+	}
+
+	objects, err := listLooseObjects(group)
+	if err != nil {
+		return MigrateResult{}, fmt.Errorf("MigrateObjects(%s): %w", group, err)
+	}
+
+	result := MigrateResult{Migrated: []string{}, Trees: []string{}, Skipped: []string{}}
+	for _, object := range objects {
+		record := NewObjectRecord(group, object.Hash)
+		header, err := record.RecordHeader()
+		if err != nil {
+			return result, fmt.Errorf("MigrateObjects(%s): %w", group, err)
+		}
+
+		if walker.seen[object.Hash] == OBJECT_TYPE_RESOURCE {
+			converted, err := migrateResourceTree(record, dryRun)
+			if err != nil {
+				return result, fmt.Errorf("MigrateObjects(%s): %w", group, err)
+			}
+			if converted {
+				result.Trees = append(result.Trees, object.Hash)
+				continue
+			}
+		}
+
+		if !header.Legacy {
+			result.Typed++
+			continue
+		}
+
+		objType, ok := walker.seen[object.Hash]
+		if !ok {
+			result.Skipped = append(result.Skipped, object.Hash)
+			continue
+		}
+
+		if !dryRun {
+			if err := migrateObject(record, objType); err != nil {
+				return result, fmt.Errorf("MigrateObjects(%s): %w", group, err)
+			}
+		}
+		result.Migrated = append(result.Migrated, object.Hash)
+	}
+
+	return result, nil
+}
+
+// migrateObject rewrites the legacy object with typed header of objType. The storage
+// backend replaces the object atomically, so it is never left partially written.
+func migrateObject(record ShelfRecord, objType string) error {
+	r, err := record.RecordReadCloser()
+	if err != nil {
+		return fmt.Errorf("migrate object %s: %w", record.Hash, err)
+	}
+	payload, err := io.ReadAll(r)
+	r.Close()
+	if err != nil {
+		return fmt.Errorf("migrate object %s: %w", record.Hash, err)
+	}
+
+	if err := writeObject(record.Group, record.Hash, objType, payload); err != nil {
+		return fmt.Errorf("migrate object %s: %w", record.Hash, err)
+	}
+
+	return nil
+}
+
+// migrateResourceTree rewrites the resource blob as a resource tree, the tree replaces
+// the object atomically in the storage backend. Resources which
+// do not encode back to their hash are left as is and false is returned, since the
+// tree would load as different content. Nothing is written if dryRun is set.
+func migrateResourceTree(record ShelfRecord, dryRun bool) (bool, error) {
+	resource, err := ReadResource(record.Group, record.Hash)
+	if err != nil {
+		return false, fmt.Errorf("migrate resource tree %s: %w", record.Hash, err)
+	}
+	stuff, err := NewStuff(record.Group, resource)
+	if err != nil {
+		return false, fmt.Errorf("migrate resource tree %s: %w", record.Hash, err)
+	}
+	if stuff.Hash != record.Hash {
+		return false, nil
+	}
+	if dryRun {
+		return true, nil
+	}
+
+	if err := writeResourceTree(record.Group, record.Hash, resource); err != nil {
+		return false, fmt.Errorf("migrate resource tree %s: %w", record.Hash, err)
+	}
+
+	return true, nil
+}
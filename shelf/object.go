@@ -0,0 +1,107 @@
+package shelf
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Types of objects in the shelf, written in the header of each object.
+const (
+	OBJECT_TYPE_MARK     = "mark"
+	OBJECT_TYPE_IDMAPS   = "idmaps"
+	OBJECT_TYPE_OUTLINE  = "outline"
+	OBJECT_TYPE_RESOURCE = "resource"
+	OBJECT_TYPE_TREE     = "tree"
+	OBJECT_TYPE_PROPERTY = "property"
+	OBJECT_TYPE_DIARY    = "diary"
+	OBJECT_TYPE_NOTE     = "note"
+
+	// Longest possible header is the longest type name, a space,
+	// 19 digits of int64 size and the NUL separator.
+	object_header_max_len = 32
+)
+
+var objectTypes = []string{
+	OBJECT_TYPE_MARK,
+	OBJECT_TYPE_IDMAPS,
+	OBJECT_TYPE_OUTLINE,
+	OBJECT_TYPE_RESOURCE,
+	OBJECT_TYPE_TREE,
+	OBJECT_TYPE_PROPERTY,
+	OBJECT_TYPE_DIARY,
+	OBJECT_TYPE_NOTE,
+}
+
+// ObjectHeader is the header of an object in form "<type> <size>\x00" preceding
+// the payload in decompressed object content. Object hash is calculated from
+// the payload only. Objects written before typed headers were introduced have
+// no header and are marked as Legacy, with unknown Type and Size.
+type ObjectHeader struct {
+	Type   string
+	Size   int64
+	Legacy bool
+}
+
+// encodeObjectHeader returns the header bytes for payload of given type and size.
+func encodeObjectHeader(objType string, size int) []byte {
+	return []byte(fmt.Sprintf("%s %d\x00", objType, size))
+}
+
+// parseObjectHeader reads the object header from r and leaves r at the start of payload.
+// Nothing is consumed from r if the content has no valid header (legacy object).
+func parseObjectHeader(r *bufio.Reader) (ObjectHeader, error) {
+	peek, err := r.Peek(object_header_max_len)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return ObjectHeader{}, fmt.Errorf("parse object header: %w", err)
+	}
+
+	legacy := ObjectHeader{Legacy: true}
+	end := bytes.IndexByte(peek, 0)
+	if end < 0 {
+		return legacy, nil
+	}
+	fields := strings.Split(string(peek[:end]), " ")
+	if len(fields) != 2 || !slices.Contains(objectTypes, fields[0]) {
+		return legacy, nil
+	}
+	size, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil || size < 0 {
+		return legacy, nil
+	}
+
+	if _, err := r.Discard(end + 1); err != nil {
+		return ObjectHeader{}, fmt.Errorf("parse object header: %w", err)
+	}
+	return ObjectHeader{Type: fields[0], Size: size}, nil
+}
+
+// writeObject writes payload prefixed by its typed header as the object of given hash
+// to the storage backend, the content is compressed as recorded in the group format.
+func writeObject(group, hash, objType string, payload []byte) error {
+	f, err := objectFormat(group)
+	if err != nil {
+		return fmt.Errorf("write object: %w", err)
+	}
+	content, err := f.compress(append(encodeObjectHeader(objType, len(payload)), payload...))
+	if err != nil {
+		return fmt.Errorf("write object: %w", err)
+	}
+	if content, err = seal(content); err != nil {
+		return fmt.Errorf("write object: %w", err)
+	}
+
+	s, err := shelfStore()
+	if err != nil {
+		return fmt.Errorf("write object: %w", err)
+	}
+	if err := s.PutObject(group, hash, content); err != nil {
+		return fmt.Errorf("write object: %w", err)
+	}
+	return nil
+}
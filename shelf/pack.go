@@ -0,0 +1,570 @@
+package shelf
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/liuminhaw/mist-miner/locks"
+	"github.com/liuminhaw/mist-miner/store"
+)
+
+// Packfiles consolidate objects of a group into objects/pack/pack-<hash>.pack with
+// an index pack-<hash>.idx, hash is the sha256 of the pack content. The pack starts
+// with pack_signature followed by one stream per object, compressed as recorded in the
+// group format and encrypted if an encryption key is set:
+//
+//	full object:   "<type> <size>\x00<payload>", same as a loose object
+//	delta object:  "<type> <size>\x00<delta instructions>", see encodeDelta
+//
+// Each index line is "<hash> <offset> <length> <base>" sorted by hash, base is the
+// hash of the object the delta applies to in the same pack, or - for full objects.
+// The index is written after the pack, readers only look for packs through indexes.
+const (
+	shelf_pack_dir  = "pack"
+	pack_file_ext   = ".pack"
+	pack_index_ext  = ".idx"
+	pack_name_pref  = "pack-"
+	pack_signature  = "mist-miner pack 1\n"
+	pack_full_entry = "-"
+
+	// Longest chain of deltas to rebuild an object from a full object
+	pack_max_delta_depth = 10
+)
+
+type PackResult struct {
+	// Name of the written pack, empty if nothing is packed
+	Pack string
+	// Objects written to the pack, or to be written in dry run
+	Objects int
+	// Objects stored as delta of another object in the pack
+	Deltas int
+	// Size of the written pack file in bytes
+	Size int64
+	// Legacy objects without type header which are left loose
+	Skipped []string
+}
+
+// packEntry locates an object in a pack file
+type packEntry struct {
+	offset int64
+	length int64
+	base   string
+}
+
+// packIndex is the loaded index of a pack file
+type packIndex struct {
+	name    string
+	path    string
+	entries map[string]packEntry
+}
+
+// packedObject is an object stored in a pack of a group
+type packedObject struct {
+	Hash string
+	Pack string
+	Size int64
+}
+
+// Loaded pack indexes by index file path. Packs are named by hash of their content and
+// never change, so indexes are kept as long as the index file exists.
+var packIndexes = struct {
+	sync.Mutex
+	loaded map[string]*packIndex
+}{loaded: make(map[string]*packIndex)}
+
+// PackObjects writes loose objects of the group into a new pack and removes them.
+// Older versions of resources and properties along the HEAD label mark chain are
+// stored as delta of their next version if delta is set. Legacy objects without
+// type header are left loose, run shelf migrate before packing them.
+// Will use flock on objects to prevent racing with mining,
+// return locks.ErrIsLocked if file lock is not acquired.
+func PackObjects(group string, delta bool) (PackResult, error) {
+	objFileLock, err := locks.NewLock("", locks.OBJECTS_LOCKFILE)
+	if err != nil {
+		return PackResult{}, fmt.Errorf("PackObjects(%s): %w", group, err)
+	}
+	if err := objFileLock.TryLock(); err != nil {
+		if errors.Is(err, locks.ErrIsLocked) {
+			return PackResult{}, err
+		}
+		return PackResult{}, fmt.Errorf("PackObjects(%s): %w", group, err)
+	}
+	defer objFileLock.Unlock()
+
+	if _, err := packDir(group); err != nil {
+		return PackResult{}, fmt.Errorf("PackObjects(%s): %w", group, err)
+	}
+
+	objects, err := listLooseObjects(group)
+	if err != nil {
+		return PackResult{}, fmt.Errorf("PackObjects(%s): %w", group, err)
+	}
+	hashes := make([]string, 0, len(objects))
+	for _, object := range objects {
+		hashes = append(hashes, object.Hash)
+	}
+
+	result, packed, err := writePack(group, hashes, delta, false)
+	if err != nil {
+		return result, fmt.Errorf("PackObjects(%s): %w", group, err)
+	}
+	for _, object := range objects {
+		if !packed[object.Hash] {
+			continue
+		}
+		if err := deleteObject(group, object.Hash); err != nil {
+			return result, fmt.Errorf("PackObjects(%s): %w", group, err)
+		}
+	}
+
+	return result, nil
+}
+
+// repackObjects writes the given objects into one new pack, then removes all other
+// packs and the packed loose objects. Caller should hold the objects lock.
+func repackObjects(group string, hashes []string, delta, dryRun bool) (PackResult, error) {
+	oldPacks, err := loadPackIndexes(group)
+	if err != nil {
+		return PackResult{}, fmt.Errorf("repack objects: %w", err)
+	}
+	objects, err := listLooseObjects(group)
+	if err != nil {
+		return PackResult{}, fmt.Errorf("repack objects: %w", err)
+	}
+
+	result, packed, err := writePack(group, hashes, delta, dryRun)
+	if err != nil || dryRun {
+		return result, err
+	}
+
+	for _, idx := range oldPacks {
+		if idx.name == result.Pack {
+			continue
+		}
+		if err := removePack(idx); err != nil {
+			return result, fmt.Errorf("repack objects: %w", err)
+		}
+	}
+	for _, object := range objects {
+		if !packed[object.Hash] {
+			continue
+		}
+		if err := deleteObject(group, object.Hash); err != nil {
+			return result, fmt.Errorf("repack objects: %w", err)
+		}
+	}
+
+	return result, nil
+}
+
+// writePack writes the objects of given hashes into a new pack and returns the packed
+// hashes. Legacy objects are skipped, nothing is written if dryRun is set.
+func writePack(group string, hashes []string, delta, dryRun bool) (PackResult, map[string]bool, error) {
+	result := PackResult{Skipped: []string{}}
+	packed := make(map[string]bool)
+
+	headers := make(map[string]ObjectHeader)
+	for _, hash := range hashes {
+		header, err := NewObjectRecord(group, hash).RecordHeader()
+		if err != nil {
+			return result, nil, fmt.Errorf("write pack: %w", err)
+		}
+		if header.Legacy {
+			result.Skipped = append(result.Skipped, hash)
+			continue
+		}
+		headers[hash] = header
+		packed[hash] = true
+	}
+	result.Objects = len(packed)
+	if len(packed) == 0 || dryRun {
+		return result, packed, nil
+	}
+
+	bases := make(map[string]string)
+	if delta {
+		var err error
+		if bases, err = deltaBases(group); err != nil {
+			return result, nil, fmt.Errorf("write pack: %w", err)
+		}
+	}
+
+	dir, err := packDir(group)
+	if err != nil {
+		return result, nil, fmt.Errorf("write pack: %w", err)
+	}
+	format, err := objectFormat(group)
+	if err != nil {
+		return result, nil, fmt.Errorf("write pack: %w", err)
+	}
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return result, nil, fmt.Errorf("write pack: mkdir: %w", err)
+	}
+	tmpPack, err := os.CreateTemp(dir, ".tmp-pack-")
+	if err != nil {
+		return result, nil, fmt.Errorf("write pack: %w", err)
+	}
+	defer os.Remove(tmpPack.Name())
+	defer tmpPack.Close()
+
+	h := sha256.New()
+	w := io.MultiWriter(tmpPack, h)
+	if _, err := io.WriteString(w, pack_signature); err != nil {
+		return result, nil, fmt.Errorf("write pack: %w", err)
+	}
+	offset := int64(len(pack_signature))
+
+	sorted := make([]string, 0, len(packed))
+	for hash := range packed {
+		sorted = append(sorted, hash)
+	}
+	sort.Strings(sorted)
+
+	entries := make(map[string]packEntry)
+	for _, hash := range sorted {
+		payload, err := readObjectPayload(group, hash)
+		if err != nil {
+			return result, nil, fmt.Errorf("write pack: %w", err)
+		}
+
+		entry := packEntry{offset: offset, base: pack_full_entry}
+		if base, ok := bases[hash]; ok && packed[base] {
+			basePayload, err := readObjectPayload(group, base)
+			if err != nil {
+				return result, nil, fmt.Errorf("write pack: %w", err)
+			}
+			// Only keep deltas saving at least half of the payload
+			if d := encodeDelta(basePayload, payload); len(d) < len(payload)/2 {
+				entry.base = base
+				payload = d
+				result.Deltas++
+			}
+		}
+
+		compressed, err := format.compress(append(encodeObjectHeader(headers[hash].Type, int(headers[hash].Size)), payload...))
+		if err != nil {
+			return result, nil, fmt.Errorf("write pack: %w", err)
+		}
+		if compressed, err = seal(compressed); err != nil {
+			return result, nil, fmt.Errorf("write pack: %w", err)
+		}
+		if _, err := w.Write(compressed); err != nil {
+			return result, nil, fmt.Errorf("write pack: %w", err)
+		}
+		entry.length = int64(len(compressed))
+		entries[hash] = entry
+		offset += entry.length
+	}
+	if err := tmpPack.Sync(); err != nil {
+		return result, nil, fmt.Errorf("write pack: sync: %w", err)
+	}
+	if err := tmpPack.Close(); err != nil {
+		return result, nil, fmt.Errorf("write pack: %w", err)
+	}
+
+	result.Pack = fmt.Sprintf("%s%x", pack_name_pref, h.Sum(nil))
+	result.Size = offset
+	packPath := filepath.Join(dir, result.Pack+pack_file_ext)
+	if err := os.Rename(tmpPack.Name(), packPath); err != nil {
+		return result, nil, fmt.Errorf("write pack: %w", err)
+	}
+
+	var index bytes.Buffer
+	for _, hash := range sorted {
+		entry := entries[hash]
+		fmt.Fprintf(&index, "%s %d %d %s\n", hash, entry.offset, entry.length, entry.base)
+	}
+	// The pack directory is synced with the index, covering the renamed pack as well
+	indexPath := filepath.Join(dir, result.Pack+pack_index_ext)
+	if err := store.WriteFileAtomic(indexPath, index.Bytes()); err != nil {
+		return result, nil, fmt.Errorf("write pack: %w", err)
+	}
+
+	return result, packed, nil
+}
+
+// deltaBases returns the next version of older resources and properties along
+// the HEAD label mark chain, keyed by hash of the older version. Resources are
+// matched by plugin and identifier, properties of resource trees by type, label
+// and position among properties of the same label.
+func deltaBases(group string) (map[string]string, error) {
+	bases := make(map[string]string)
+	head, err := NewRefMark(SHELF_MARK_FILE, group)
+	if errors.Is(err, ErrRefHeadNotFound) {
+		return bases, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("delta bases: %w", err)
+	}
+
+	depth := make(map[string]int)
+	newer := make(map[string]string)
+	version := func(key, hash string) {
+		if _, ok := depth[hash]; !ok {
+			depth[hash] = 0
+			if base, ok := newer[key]; ok && base != hash && depth[base] < pack_max_delta_depth {
+				bases[hash] = base
+				depth[hash] = depth[base] + 1
+			}
+		}
+		newer[key] = hash
+	}
+
+	for hash := string(head.Reference); hash != "" && hash != "nil"; {
+		mark, err := ReadMark(group, hash)
+		if err != nil {
+			return nil, fmt.Errorf("delta bases: %w", err)
+		}
+		resources, err := readMarkResources(group, hash)
+		if err != nil {
+			return nil, fmt.Errorf("delta bases: %w", err)
+		}
+
+		for plugin, byIdentifier := range resources {
+			for identifier, res := range byIdentifier {
+				key := plugin + "\x00" + identifier
+				version(key, res.resourceHash)
+
+				header, err := NewObjectRecord(group, res.resourceHash).RecordHeader()
+				if err != nil {
+					return nil, fmt.Errorf("delta bases: %w", err)
+				}
+				if header.Type != OBJECT_TYPE_TREE {
+					continue
+				}
+				tree, err := ReadResourceTree(group, res.resourceHash)
+				if err != nil {
+					return nil, fmt.Errorf("delta bases: %w", err)
+				}
+				position := make(map[string]int)
+				for _, prop := range tree.Properties {
+					propKey := fmt.Sprintf("%s\x00%s\x00%s", key, prop.Type, prop.Label.Name)
+					version(fmt.Sprintf("%s\x00%d", propKey, position[propKey]), prop.Hash)
+					position[propKey]++
+				}
+			}
+		}
+
+		hash = mark.Parent
+	}
+
+	return bases, nil
+}
+
+// readObjectPayload reads the whole payload of the object, resource trees are
+// read as the manifest.
+func readObjectPayload(group, hash string) ([]byte, error) {
+	_, r, err := NewObjectRecord(group, hash).ObjectReadCloser()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return io.ReadAll(r)
+}
+
+// packDir returns the directory path storing the packs of the given group,
+// returns ErrPacksUnsupported if the storage backend is not a filesystem.
+func packDir(group string) (string, error) {
+	s, err := shelfStore()
+	if err != nil {
+		return "", fmt.Errorf("packDir(%s): %w", group, err)
+	}
+	fsStore, ok := s.(*store.FS)
+	if !ok {
+		return "", fmt.Errorf("packDir(%s): %w", group, ErrPacksUnsupported)
+	}
+
+	return filepath.Join(fsStore.ObjectDir(group), shelf_pack_dir), nil
+}
+
+// loadPackIndexes returns indexes of all packs of the group sorted by pack name.
+func loadPackIndexes(group string) ([]*packIndex, error) {
+	dir, err := packDir(group)
+	if errors.Is(err, ErrPacksUnsupported) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("load pack indexes: %w", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("load pack indexes: %w", err)
+	}
+
+	indexes := []*packIndex{}
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), pack_index_ext)
+		if entry.IsDir() || !ok || !strings.HasPrefix(name, pack_name_pref) {
+			continue
+		}
+		idx, err := loadPackIndex(filepath.Join(dir, entry.Name()), name)
+		if err != nil {
+			return nil, fmt.Errorf("load pack indexes: %w", err)
+		}
+		indexes = append(indexes, idx)
+	}
+
+	return indexes, nil
+}
+
+func loadPackIndex(path, name string) (*packIndex, error) {
+	packIndexes.Lock()
+	defer packIndexes.Unlock()
+	if idx, ok := packIndexes.loaded[path]; ok {
+		return idx, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("load pack index %s: %w", name, err)
+	}
+	defer f.Close()
+
+	idx := &packIndex{
+		name:    name,
+		path:    strings.TrimSuffix(path, pack_index_ext) + pack_file_ext,
+		entries: make(map[string]packEntry),
+	}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("load pack index %s: invalid line: %s", name, scanner.Text())
+		}
+		offset, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("load pack index %s: %w", name, err)
+		}
+		length, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("load pack index %s: %w", name, err)
+		}
+		entry := packEntry{offset: offset, length: length}
+		if fields[3] != pack_full_entry {
+			entry.base = fields[3]
+		}
+		idx.entries[fields[0]] = entry
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("load pack index %s: %w", name, err)
+	}
+
+	packIndexes.loaded[path] = idx
+	return idx, nil
+}
+
+// findPacked returns the index of the pack containing the object, nil if not packed.
+func findPacked(group, hash string) (*packIndex, error) {
+	indexes, err := loadPackIndexes(group)
+	if err != nil {
+		return nil, err
+	}
+	for _, idx := range indexes {
+		if _, ok := idx.entries[hash]; ok {
+			return idx, nil
+		}
+	}
+	return nil, nil
+}
+
+// listPackedObjects returns all objects stored in packs of the group, objects stored
+// in more than one pack are listed once.
+func listPackedObjects(group string) ([]packedObject, error) {
+	indexes, err := loadPackIndexes(group)
+	if err != nil {
+		return nil, fmt.Errorf("listPackedObjects(%s): %w", group, err)
+	}
+
+	objects := []packedObject{}
+	seen := make(map[string]bool)
+	for _, idx := range indexes {
+		for hash, entry := range idx.entries {
+			if seen[hash] {
+				continue
+			}
+			seen[hash] = true
+			objects = append(objects, packedObject{Hash: hash, Pack: idx.name, Size: entry.length})
+		}
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Hash < objects[j].Hash })
+
+	return objects, nil
+}
+
+// readPacked returns the header and payload reader of the object in the pack,
+// delta objects are rebuilt from their base.
+func readPacked(group string, idx *packIndex, hash string) (ObjectHeader, io.ReadCloser, error) {
+	entry := idx.entries[hash]
+	f, err := os.Open(idx.path)
+	if err != nil {
+		return ObjectHeader{}, nil, fmt.Errorf("read packed %s: %w", hash, err)
+	}
+
+	zr, err := openReader(io.NewSectionReader(f, entry.offset, entry.length))
+	if err != nil {
+		f.Close()
+		return ObjectHeader{}, nil, fmt.Errorf("read packed %s: %w", hash, err)
+	}
+	br := bufio.NewReader(zr)
+	header, err := parseObjectHeader(br)
+	if err == nil && header.Legacy {
+		err = fmt.Errorf("object has no type header")
+	}
+	if err != nil {
+		zr.Close()
+		f.Close()
+		return ObjectHeader{}, nil, fmt.Errorf("read packed %s: %w", hash, err)
+	}
+	r := &recordReader{Reader: br, decompressor: zr, file: f}
+	if entry.base == "" {
+		return header, r, nil
+	}
+
+	delta, err := io.ReadAll(r)
+	r.Close()
+	if err != nil {
+		return ObjectHeader{}, nil, fmt.Errorf("read packed %s: %w", hash, err)
+	}
+	base, err := readObjectPayload(group, entry.base)
+	if err != nil {
+		return ObjectHeader{}, nil, fmt.Errorf("read packed %s: base: %w", hash, err)
+	}
+	payload, err := applyDelta(base, delta)
+	if err != nil {
+		return ObjectHeader{}, nil, fmt.Errorf("read packed %s: %w", hash, err)
+	}
+	if int64(len(payload)) != header.Size {
+		return ObjectHeader{}, nil, fmt.Errorf("read packed %s: %w", hash, errInvalidDelta)
+	}
+
+	return header, io.NopCloser(bytes.NewReader(payload)), nil
+}
+
+// removePack removes the pack and its index, the index is removed first
+// so readers never find an index without pack.
+func removePack(idx *packIndex) error {
+	indexPath := strings.TrimSuffix(idx.path, pack_file_ext) + pack_index_ext
+	if err := os.Remove(indexPath); err != nil {
+		return fmt.Errorf("remove pack %s: %w", idx.name, err)
+	}
+	if err := os.Remove(idx.path); err != nil {
+		return fmt.Errorf("remove pack %s: %w", idx.name, err)
+	}
+
+	packIndexes.Lock()
+	delete(packIndexes.loaded, indexPath)
+	packIndexes.Unlock()
+	return nil
+}
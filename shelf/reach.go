@@ -0,0 +1,270 @@
+package shelf
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/liuminhaw/mist-miner/shared"
+)
+
+// ListRefs returns names of all references in the group, ex. HEAD.
+// History logger and pointer files under the refs directory, label mark signatures
+// and FORMAT are not included.
+func ListRefs(group string) ([]string, error) {
+	names, err := listRefs(group, "")
+	if err != nil {
+		return nil, fmt.Errorf("ListRefs(%s): %w", group, err)
+	}
+
+	refs := []string{}
+	for _, name := range names {
+		if strings.HasPrefix(name, shelf_history_dir+"/") ||
+			strings.HasPrefix(name, shelf_signature_dir+"/") ||
+			name == SHELF_FORMAT_FILE {
+			continue
+		}
+		refs = append(refs, name)
+	}
+
+	return refs, nil
+}
+
+// objectWalker walks through objects reachable from label marks following
+// LabelMark -> IdentifierHashMaps -> StuffOutline -> resource / diary -> diary note,
+// resources stored as tree are followed to their property objects.
+type objectWalker struct {
+	group string
+	// reachable objects found in walk, hash to object kind
+	seen map[string]string
+	// missing is called when a referenced object does not exist in the shelf,
+	// walk continues if nil error is returned.
+	missing func(hash, kind, referrer string) error
+	// broken is called when a reachable object cannot be parsed, walk continues
+	// without following references of the object if nil error is returned.
+	broken func(hash, kind string, err error) error
+}
+
+func newObjectWalker(group string) *objectWalker {
+	return &objectWalker{
+		group: group,
+		seen:  make(map[string]string),
+		missing: func(hash, kind, referrer string) error {
+			return fmt.Errorf("%s object %s referenced by %s: %w", kind, hash, referrer, os.ErrNotExist)
+		},
+		broken: func(hash, kind string, err error) error {
+			return fmt.Errorf("%s object %s: %w", kind, hash, err)
+		},
+	}
+}
+
+// walkRefs walks objects reachable from every reference of the group.
+func (w *objectWalker) walkRefs() error {
+	refs, err := ListRefs(w.group)
+	if err != nil {
+		return fmt.Errorf("walk refs: %w", err)
+	}
+
+	for _, ref := range refs {
+		mark, err := NewRefMark(ref, w.group)
+		if err != nil {
+			return fmt.Errorf("walk refs: %w", err)
+		}
+		if err := w.walkMarks(string(mark.Reference), "ref "+ref); err != nil {
+			return fmt.Errorf("walk refs: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// walkMarks walks objects reachable from the label mark of given hash and all its ancestors.
+func (w *objectWalker) walkMarks(hash, referrer string) error {
+	for hash != "" && hash != "nil" {
+		if _, ok := w.seen[hash]; ok {
+			return nil
+		}
+		if !w.exist(hash) {
+			return w.missing(hash, OBJECT_TYPE_MARK, referrer)
+		}
+		w.seen[hash] = OBJECT_TYPE_MARK
+
+		mark, err := ReadMark(w.group, hash)
+		if err != nil {
+			return w.broken(hash, OBJECT_TYPE_MARK, err)
+		}
+		for _, mapping := range mark.Mappings {
+			if err := w.walkIdMaps(mapping.Hash, hash); err != nil {
+				return fmt.Errorf("walk marks: %w", err)
+			}
+		}
+
+		referrer = hash
+		hash = mark.Parent
+	}
+
+	return nil
+}
+
+func (w *objectWalker) walkIdMaps(hash, referrer string) error {
+	if _, ok := w.seen[hash]; ok {
+		return nil
+	}
+	if !w.exist(hash) {
+		return w.missing(hash, OBJECT_TYPE_IDMAPS, referrer)
+	}
+	w.seen[hash] = OBJECT_TYPE_IDMAPS
+
+	idHashMaps, err := ReadIdentifierHashMaps(w.group, hash)
+	if err != nil {
+		return w.broken(hash, OBJECT_TYPE_IDMAPS, err)
+	}
+	for _, m := range idHashMaps.Maps {
+		if err := w.walkOutline(m.Hash, hash); err != nil {
+			return fmt.Errorf("walk identifier hash maps: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (w *objectWalker) walkOutline(hash, referrer string) error {
+	if _, ok := w.seen[hash]; ok {
+		return nil
+	}
+	if !w.exist(hash) {
+		return w.missing(hash, OBJECT_TYPE_OUTLINE, referrer)
+	}
+	w.seen[hash] = OBJECT_TYPE_OUTLINE
+
+	outline, err := ReadStuffOutline(w.group, hash)
+	if err != nil {
+		return w.broken(hash, OBJECT_TYPE_OUTLINE, err)
+	}
+	if err := w.walkResource(outline.ResourceHash, hash); err != nil {
+		return fmt.Errorf("walk stuff outline: %w", err)
+	}
+	if err := w.walkDiary(outline.DiaryHash, hash); err != nil {
+		return fmt.Errorf("walk stuff outline: %w", err)
+	}
+
+	return nil
+}
+
+// walkResource walks the resource object, which is either a resource blob or
+// a resource tree referring to property objects.
+func (w *objectWalker) walkResource(hash, referrer string) error {
+	if _, ok := w.seen[hash]; ok {
+		return nil
+	}
+	if !w.exist(hash) {
+		return w.missing(hash, OBJECT_TYPE_RESOURCE, referrer)
+	}
+	header, err := NewObjectRecord(w.group, hash).RecordHeader()
+	if err != nil {
+		return w.broken(hash, OBJECT_TYPE_RESOURCE, err)
+	}
+	if header.Type != OBJECT_TYPE_TREE {
+		w.seen[hash] = OBJECT_TYPE_RESOURCE
+		return nil
+	}
+	w.seen[hash] = OBJECT_TYPE_TREE
+
+	tree, err := ReadResourceTree(w.group, hash)
+	if err != nil {
+		return w.broken(hash, OBJECT_TYPE_TREE, err)
+	}
+	for _, prop := range tree.Properties {
+		if err := w.walkLeaf(prop.Hash, OBJECT_TYPE_PROPERTY, hash); err != nil {
+			return fmt.Errorf("walk resource tree: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (w *objectWalker) walkDiary(hash, referrer string) error {
+	if _, ok := w.seen[hash]; ok {
+		return nil
+	}
+	if !w.exist(hash) {
+		return w.missing(hash, OBJECT_TYPE_DIARY, referrer)
+	}
+	w.seen[hash] = OBJECT_TYPE_DIARY
+
+	diary, err := ReadMinerDiary(w.group, hash)
+	if err != nil {
+		return w.broken(hash, OBJECT_TYPE_DIARY, err)
+	}
+	if diary.Hash != "" {
+		if err := w.walkLeaf(diary.Hash, OBJECT_TYPE_NOTE, hash); err != nil {
+			return fmt.Errorf("walk diary: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (w *objectWalker) walkLeaf(hash, kind, referrer string) error {
+	if _, ok := w.seen[hash]; ok {
+		return nil
+	}
+	if !w.exist(hash) {
+		return w.missing(hash, kind, referrer)
+	}
+	w.seen[hash] = kind
+
+	return nil
+}
+
+func (w *objectWalker) exist(hash string) bool {
+	return NewObjectRecord(w.group, hash).Exist()
+}
+
+// looseObject is an object stored unpacked in the storage backend of a group
+type looseObject struct {
+	Hash string
+	Size int64
+}
+
+// listLooseObjects returns all unpacked objects stored for the group.
+func listLooseObjects(group string) ([]looseObject, error) {
+	s, err := shelfStore()
+	if err != nil {
+		return nil, fmt.Errorf("listLooseObjects(%s): %w", group, err)
+	}
+	infos, err := s.ListObjects(group)
+	if err != nil {
+		return nil, fmt.Errorf("listLooseObjects(%s): %w", group, err)
+	}
+
+	objects := make([]looseObject, 0, len(infos))
+	for _, info := range infos {
+		objects = append(objects, looseObject{Hash: info.Hash, Size: info.Size})
+	}
+
+	return objects, nil
+}
+
+// ReadMinerDiary reads the MinerDiary object of the given group and hash.
+func ReadMinerDiary(group, hash string) (*shared.MinerDiary, error) {
+	r, err := NewObjectRecord(group, hash).RecordReadCloser()
+	if err != nil {
+		return nil, fmt.Errorf("read miner diary: %w", err)
+	}
+	defer r.Close()
+
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("read miner diary: %w", err)
+	}
+
+	diary := shared.MinerDiary{}
+	if err := json.Unmarshal(content, &diary); err != nil {
+		return nil, fmt.Errorf("read miner diary: decode: %w", err)
+	}
+
+	return &diary, nil
+}
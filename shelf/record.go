@@ -1,12 +1,13 @@
 package shelf
 
 import (
+	"bufio"
 	"bytes"
-	"compress/zlib"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
 )
@@ -43,6 +44,27 @@ func (sr ShelfRecord) RecordFile() (string, error) {
 	return filepath.Join(dir, sr.Hash[2:]), nil
 }
 
+// PlainFile returns the file path of the shelf record if it is a loose object stored
+// uncompressed and unencrypted in the shelf directory, so the file can be opened as is.
+// False is returned for compressed, encrypted, packed or remotely stored objects.
+func (sr ShelfRecord) PlainFile() (string, bool) {
+	path, err := sr.RecordFile()
+	if err != nil {
+		return "", false
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", false
+	}
+	defer f.Close()
+
+	header, err := parseObjectHeader(bufio.NewReader(f))
+	if err != nil || header.Legacy {
+		return "", false
+	}
+	return path, true
+}
+
 // RecordRead returns the entire content of the shelf record, and will try to
 // prettify the content if it is a JSON
 func (sr ShelfRecord) RecordRead() (string, error) {
@@ -66,54 +88,118 @@ func (sr ShelfRecord) RecordRead() (string, error) {
 }
 
 // RecordReadCloser returns a io.ReaderCloser of the shelf record for more control
-// on the reading process
+// on the reading process, the object header is skipped so only payload is read.
+// Resource trees are loaded transparently and read as the resource json.
 func (sr ShelfRecord) RecordReadCloser() (io.ReadCloser, error) {
-	path, err := sr.RecordFile()
+	header, r, err := sr.ObjectReadCloser()
 	if err != nil {
 		return nil, fmt.Errorf("recordReader(): %w", err)
 	}
+	if header.Type != OBJECT_TYPE_TREE {
+		return r, nil
+	}
 
-	if _, err := os.Stat(path); os.IsNotExist(err) {
+	defer r.Close()
+	content, err := loadResourceTree(sr.Group, r)
+	if err != nil {
 		return nil, fmt.Errorf("recordReader(): %w", err)
 	}
+	return io.NopCloser(bytes.NewReader(content)), nil
+}
 
-	f, err := os.Open(path)
+// RecordHeader returns the object header of the shelf record.
+func (sr ShelfRecord) RecordHeader() (ObjectHeader, error) {
+	header, r, err := sr.ObjectReadCloser()
 	if err != nil {
-		return nil, fmt.Errorf("recordReader(): %w", err)
+		return ObjectHeader{}, fmt.Errorf("RecordHeader(): %w", err)
 	}
-	defer f.Close()
+	r.Close()
 
-	r, err := zlib.NewReader(f)
+	return header, nil
+}
+
+// ObjectReadCloser returns the object header and a io.ReadCloser of the payload
+// of the shelf record. Legacy objects without header are read as a whole.
+// Objects not found as loose objects are read from packs.
+func (sr ShelfRecord) ObjectReadCloser() (ObjectHeader, io.ReadCloser, error) {
+	s, err := shelfStore()
 	if err != nil {
-		return nil, fmt.Errorf("recordReader(): %w", err)
+		return ObjectHeader{}, nil, fmt.Errorf("objectReader(): %w", err)
+	}
+
+	f, err := s.GetObject(sr.Group, sr.Hash)
+	if errors.Is(err, fs.ErrNotExist) {
+		idx, packErr := findPacked(sr.Group, sr.Hash)
+		if packErr != nil {
+			return ObjectHeader{}, nil, fmt.Errorf("objectReader(): %w", packErr)
+		} else if idx == nil {
+			return ObjectHeader{}, nil, fmt.Errorf("objectReader(): %w", err)
+		}
+		header, r, err := readPacked(sr.Group, idx, sr.Hash)
+		if err != nil {
+			return ObjectHeader{}, nil, fmt.Errorf("objectReader(): %w", err)
+		}
+		return header, r, nil
+	} else if err != nil {
+		return ObjectHeader{}, nil, fmt.Errorf("objectReader(): %w", err)
 	}
-	defer r.Close()
 
-	return r, nil
+	zr, err := openReader(f)
+	if err != nil {
+		f.Close()
+		return ObjectHeader{}, nil, fmt.Errorf("objectReader(): %w", err)
+	}
+
+	br := bufio.NewReader(zr)
+	header, err := parseObjectHeader(br)
+	if err != nil {
+		zr.Close()
+		f.Close()
+		return ObjectHeader{}, nil, fmt.Errorf("objectReader(): %w", err)
+	}
+
+	return header, &recordReader{Reader: br, decompressor: zr, file: f}, nil
 }
 
-// Exists checks if the shelf record exists
+// recordReader reads the decompressed content of a stored object,
+// closing it closes both the decompressor and the underlying object reader.
+type recordReader struct {
+	io.Reader
+	decompressor io.ReadCloser
+	file         io.Closer
+}
+
+func (r *recordReader) Close() error {
+	err := r.decompressor.Close()
+	if fErr := r.file.Close(); err == nil {
+		err = fErr
+	}
+	return err
+}
+
+// Exists checks if the shelf record exists, either as a loose object or in a pack
 func (sr ShelfRecord) Exist() bool {
-	path, err := sr.RecordFile()
+	s, err := shelfStore()
 	if err != nil {
 		return false
 	}
 
-	_, err = os.Stat(path)
-	return !errors.Is(err, os.ErrNotExist)
+	if ok, err := s.HasObject(sr.Group, sr.Hash); err == nil && ok {
+		return true
+	}
+	idx, err := findPacked(sr.Group, sr.Hash)
+	return err == nil && idx != nil
 }
 
 // recordDir returns the directory path of the shelf record
 func (sr ShelfRecord) recordDir() (string, error) {
-	execPath, err := os.Executable()
+	dir, err := groupDir(sr.Group)
 	if err != nil {
-		return "", fmt.Errorf("RecordDir(): get executable: %w", err)
+		return "", fmt.Errorf("RecordDir(): %w", err)
 	}
 
 	return filepath.Join(
-		filepath.Dir(execPath),
-		shelf_dir,
-		sr.Group,
+		dir,
 		sr.Type,
 		sr.Hash[:2],
 	), nil
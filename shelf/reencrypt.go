@@ -0,0 +1,182 @@
+package shelf
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/liuminhaw/mist-miner/locks"
+)
+
+type ReencryptResult struct {
+	// Loose objects rewritten with the current key, or to be rewritten in dry run
+	Objects int
+	// Loose objects already encrypted with the current key
+	Current int
+	// Packs rewritten into one pack, or to be rewritten in dry run
+	Packs int
+	// History records, pointers, timelines and search index segments rewritten
+	Refs int
+}
+
+// ReencryptObjects rewrites objects and history records of the group which are not
+// encrypted with the current key, so a previous key can be retired after rotation.
+// Content encrypted with previous keys is decrypted with keys added by AddDecryptionKey.
+// Without an encryption key set, encrypted content is rewritten as plaintext. Packs
+// with any entry to rewrite are repacked into one pack. Object hashes do not change
+// since they are calculated from plaintext payload. Nothing is written if dryRun is set.
+// Will use flock on objects to prevent racing with mining,
+// return locks.ErrIsLocked if file lock is not acquired.
+func ReencryptObjects(group string, dryRun bool) (ReencryptResult, error) {
+	objFileLock, err := locks.NewLock("", locks.OBJECTS_LOCKFILE)
+	if err != nil {
+		return ReencryptResult{}, fmt.Errorf("ReencryptObjects(%s): %w", group, err)
+	}
+	if err := objFileLock.TryLock(); err != nil {
+		if errors.Is(err, locks.ErrIsLocked) {
+			return ReencryptResult{}, err
+		}
+		return ReencryptResult{}, fmt.Errorf("ReencryptObjects(%s): %w", group, err)
+	}
+	defer objFileLock.Unlock()
+
+	s, err := shelfStore()
+	if err != nil {
+		return ReencryptResult{}, fmt.Errorf("ReencryptObjects(%s): %w", group, err)
+	}
+	result := ReencryptResult{}
+
+	names, err := listRefs(group, shelf_history_dir)
+	if err != nil {
+		return result, fmt.Errorf("ReencryptObjects(%s): %w", group, err)
+	}
+	for _, name := range names {
+		content, err := readRef(group, name)
+		if err != nil {
+			return result, fmt.Errorf("ReencryptObjects(%s): %w", group, err)
+		}
+		resealed, ok, err := reseal(content)
+		if err != nil {
+			return result, fmt.Errorf("ReencryptObjects(%s): %s: %w", group, name, err)
+		} else if !ok {
+			continue
+		}
+		if !dryRun {
+			if err := writeRef(group, name, resealed); err != nil {
+				return result, fmt.Errorf("ReencryptObjects(%s): %w", group, err)
+			}
+		}
+		result.Refs++
+	}
+
+	objects, err := listLooseObjects(group)
+	if err != nil {
+		return result, fmt.Errorf("ReencryptObjects(%s): %w", group, err)
+	}
+	for _, object := range objects {
+		content, err := readRawObject(group, object.Hash)
+		if err != nil {
+			return result, fmt.Errorf("ReencryptObjects(%s): %w", group, err)
+		}
+		resealed, ok, err := reseal(content)
+		if err != nil {
+			return result, fmt.Errorf("ReencryptObjects(%s): object %s: %w", group, object.Hash, err)
+		} else if !ok {
+			result.Current++
+			continue
+		}
+		if !dryRun {
+			if err := s.PutObject(group, object.Hash, resealed); err != nil {
+				return result, fmt.Errorf("ReencryptObjects(%s): %w", group, err)
+			}
+		}
+		result.Objects++
+	}
+
+	packs, err := loadPackIndexes(group)
+	if err != nil {
+		return result, fmt.Errorf("ReencryptObjects(%s): %w", group, err)
+	}
+	hashes := []string{}
+	delta := false
+	for _, idx := range packs {
+		current, err := packSealedByCurrent(idx)
+		if err != nil {
+			return result, fmt.Errorf("ReencryptObjects(%s): %w", group, err)
+		}
+		if !current {
+			result.Packs++
+		}
+		for hash, entry := range idx.entries {
+			hashes = append(hashes, hash)
+			delta = delta || entry.base != ""
+		}
+	}
+	// Repacking removes every other pack, so all packed objects are written to the new pack
+	if result.Packs > 0 && !dryRun {
+		if _, err := repackObjects(group, hashes, delta, false); err != nil {
+			return result, fmt.Errorf("ReencryptObjects(%s): %w", group, err)
+		}
+	}
+
+	return result, nil
+}
+
+// reseal decrypts the content and encrypts it again with the current key, false is
+// returned if the content is already encrypted with the current key.
+func reseal(content []byte) ([]byte, bool, error) {
+	if sealedByCurrent(content) {
+		return nil, false, nil
+	}
+	plain, err := unseal(content)
+	if err != nil {
+		return nil, false, fmt.Errorf("reseal: %w", err)
+	}
+	resealed, err := seal(plain)
+	if err != nil {
+		return nil, false, fmt.Errorf("reseal: %w", err)
+	}
+	return resealed, true, nil
+}
+
+// readRawObject returns the object content as stored in the storage backend
+func readRawObject(group, hash string) ([]byte, error) {
+	s, err := shelfStore()
+	if err != nil {
+		return nil, fmt.Errorf("read raw object %s: %w", hash, err)
+	}
+	r, err := s.GetObject(group, hash)
+	if err != nil {
+		return nil, fmt.Errorf("read raw object %s: %w", hash, err)
+	}
+	defer r.Close()
+
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("read raw object %s: %w", hash, err)
+	}
+	return content, nil
+}
+
+// packSealedByCurrent reports whether every entry of the pack is encrypted with the
+// current key, or is plaintext when no key is set.
+func packSealedByCurrent(idx *packIndex) (bool, error) {
+	f, err := os.Open(idx.path)
+	if err != nil {
+		return false, fmt.Errorf("pack sealed by current %s: %w", idx.name, err)
+	}
+	defer f.Close()
+
+	prefix := make([]byte, len(encryption_magic)+1+encryption_id_len)
+	for hash, entry := range idx.entries {
+		n, err := f.ReadAt(prefix[:min(int64(len(prefix)), entry.length)], entry.offset)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return false, fmt.Errorf("pack sealed by current %s: %s: %w", idx.name, hash, err)
+		}
+		if !sealedByCurrent(prefix[:n]) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,189 @@
+package shelf
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Shortest hash prefix accepted as a revision
+const min_hash_prefix_len = 4
+
+// ResolveMark returns the label mark hash referenced by rev. rev can be
+//   - a reference name, ex. HEAD
+//   - a tag name
+//   - a full label mark hash or a unique prefix of it
+//
+// followed by an optional ~N suffix selecting the Nth ancestor, ex. HEAD~2.
+// A single ~ is the same as ~1.
+func ResolveMark(group, rev string) (string, error) {
+	base, generations, err := splitRevAncestry(rev)
+	if err != nil {
+		return "", fmt.Errorf("ResolveMark(%s, %s): %w", group, rev, err)
+	}
+
+	hash, err := resolveRevBase(group, base)
+	if err != nil {
+		return "", fmt.Errorf("ResolveMark(%s, %s): %w", group, rev, err)
+	}
+	if _, err := ReadMark(group, hash); err != nil {
+		return "", fmt.Errorf("ResolveMark(%s, %s): %s is not a label mark: %w", group, rev, hash, err)
+	}
+
+	for i := 0; i < generations; i++ {
+		hash, err = ParentMark(group, hash)
+		if errors.Is(err, ErrNoParentMark) {
+			return "", fmt.Errorf(
+				"ResolveMark(%s, %s): %s has only %d ancestors: %w",
+				group, rev, base, i, ErrRevNotFound,
+			)
+		} else if err != nil {
+			return "", fmt.Errorf("ResolveMark(%s, %s): %w", group, rev, err)
+		}
+	}
+
+	return hash, nil
+}
+
+// ResolveObject returns the object hash referenced by rev, rev is either
+// a unique prefix of any object hash or a label mark revision accepted by ResolveMark.
+func ResolveObject(group, rev string) (string, error) {
+	if isHexString(rev) && len(rev) >= min_hash_prefix_len {
+		hash, err := resolveHashPrefix(group, rev)
+		if err == nil {
+			return hash, nil
+		} else if !errors.Is(err, ErrRevNotFound) {
+			return "", fmt.Errorf("ResolveObject(%s, %s): %w", group, rev, err)
+		}
+	}
+
+	hash, err := ResolveMark(group, rev)
+	if err != nil {
+		return "", fmt.Errorf("ResolveObject(%s, %s): %w", group, rev, err)
+	}
+	return hash, nil
+}
+
+// splitRevAncestry splits rev into base revision and number of generations
+// in ~N suffix, ex. HEAD~2 is split to HEAD and 2.
+func splitRevAncestry(rev string) (string, int, error) {
+	base, suffix, found := strings.Cut(rev, "~")
+	if !found {
+		return rev, 0, nil
+	}
+	if suffix == "" {
+		return base, 1, nil
+	}
+
+	generations, err := strconv.Atoi(suffix)
+	if err != nil || generations < 0 {
+		return "", 0, fmt.Errorf("invalid ancestry suffix ~%s: %w", suffix, ErrRevNotFound)
+	}
+	return base, generations, nil
+}
+
+// resolveRevBase returns the hash referenced by a revision without ancestry suffix,
+// references and tags take precedence over hash prefixes.
+func resolveRevBase(group, base string) (string, error) {
+	if base == "" {
+		return "", ErrRevNotFound
+	}
+
+	// Reference name, ex. HEAD or tags/<name>
+	if validRefName(base) {
+		ref, err := NewRefMark(base, group)
+		if err == nil {
+			return string(ref.Reference), nil
+		} else if !errors.Is(err, ErrRefHeadNotFound) {
+			return "", err
+		}
+	}
+
+	// Tag name
+	if validTagName(base) == nil {
+		ref, err := NewRefMark(tagRef(base), group)
+		if err == nil {
+			return string(ref.Reference), nil
+		} else if !errors.Is(err, ErrRefHeadNotFound) {
+			return "", err
+		}
+	}
+
+	if isHexString(base) && len(base) >= min_hash_prefix_len {
+		return resolveHashPrefix(group, base)
+	}
+
+	return "", fmt.Errorf("%s: %w", base, ErrRevNotFound)
+}
+
+// resolveHashPrefix returns the only object hash starting with prefix,
+// return ErrAmbiguousRev if more than one object matches.
+func resolveHashPrefix(group, prefix string) (string, error) {
+	prefix = strings.ToLower(prefix)
+	if NewObjectRecord(group, prefix).Exist() {
+		return prefix, nil
+	}
+
+	objects, err := listLooseObjects(group)
+	if err != nil {
+		return "", fmt.Errorf("resolve hash prefix %s: %w", prefix, err)
+	}
+
+	packed, err := listPackedObjects(group)
+	if err != nil {
+		return "", fmt.Errorf("resolve hash prefix %s: %w", prefix, err)
+	}
+
+	matches := []string{}
+	for _, object := range objects {
+		if strings.HasPrefix(object.Hash, prefix) {
+			matches = append(matches, object.Hash)
+		}
+	}
+	for _, object := range packed {
+		if strings.HasPrefix(object.Hash, prefix) && !slices.Contains(matches, object.Hash) {
+			matches = append(matches, object.Hash)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("%s: %w", prefix, ErrRevNotFound)
+	case 1:
+		return matches[0], nil
+	default:
+		return "", fmt.Errorf(
+			"%s matches %s: %w",
+			prefix,
+			strings.Join(matches, ", "),
+			ErrAmbiguousRev,
+		)
+	}
+}
+
+// validRefName checks if name can be a reference file under refs directory,
+// the tags directory and history records stored in the refs directory are not references.
+func validRefName(name string) bool {
+	if name == "" || strings.HasPrefix(name, "/") || strings.HasPrefix(name, ".") {
+		return false
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == "" || part == "." || part == ".." {
+			return false
+		}
+	}
+	return name != shelf_tag_dir &&
+		name != shelf_history_dir &&
+		!strings.HasPrefix(name, shelf_history_dir+"/")
+}
+
+func isHexString(s string) bool {
+	for _, r := range s {
+		if !(r >= '0' && r <= '9' || r >= 'a' && r <= 'f' || r >= 'A' && r <= 'F') {
+			return false
+		}
+	}
+	return s != ""
+}
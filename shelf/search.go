@@ -0,0 +1,882 @@
+package shelf
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/liuminhaw/mist-miner/locks"
+	"github.com/liuminhaw/mist-miner/shared"
+)
+
+// Paths of resource fields recorded in search index, property paths are
+// written as <type>/<label> followed by JSONPath of json leaves, ex.
+// detail/UserDetail $.Tags[0].Value
+const (
+	SEARCH_PATH_IDENTIFIER = "identifier"
+	SEARCH_PATH_ALIAS      = "alias"
+	SEARCH_PATH_DIARY      = "diary"
+)
+
+// SearchResult is a resource matching every term of a search query
+type SearchResult struct {
+	Plugin     string
+	Identifier string
+	Alias      string
+	Outline    string
+	// Newest label mark containing the matching resource
+	Mark string
+	// Paths of the fields matching the query
+	Paths []string
+}
+
+// searchDoc is a resource indexed for searching, identified by its stuff outline
+// so that resource and diary changes are indexed as new documents.
+type searchDoc struct {
+	plugin     string
+	identifier string
+	alias      string
+	outline    string
+}
+
+type searchPosting struct {
+	// Index of the document in searchIndex.docs
+	doc  int
+	path string
+}
+
+// searchIndex is a segment of the search index, or segments merged together
+type searchIndex struct {
+	docs []searchDoc
+	// Postings keyed by token, postings of a token are sorted by document
+	postings map[string][]searchPosting
+}
+
+// searchSegment is a search index segment as read from the shelf, postings are
+// decoded only for tokens looked up.
+type searchSegment struct {
+	docs []searchDoc
+	// Sorted tokens with the encoded postings of each token at the same index
+	tokens   []string
+	postings []string
+}
+
+// searchManifest records the segments of the search index and the label mark
+// the index is updated to.
+type searchManifest struct {
+	mark     string
+	segments []searchSegmentInfo
+}
+
+type searchSegmentInfo struct {
+	number int
+	docs   int
+}
+
+type searchTerm struct {
+	token  string
+	prefix bool
+}
+
+// Search looks up resources matching every term of the query in the search index.
+// Terms are separated by spaces and matched case-insensitively against words of
+// identifiers, aliases, property labels, json leaf values and diary notes, a term
+// ending with * matches words with the prefix. Only resources in HEAD are searched
+// unless all is set, in which case every indexed version of resources is searched
+// and the newest label mark containing it is reported.
+// Return ErrSearchIndexNotFound if the index has not been generated.
+func Search(group, query string, all bool) ([]SearchResult, error) {
+	terms := parseSearchQuery(query)
+	if len(terms) == 0 {
+		return nil, fmt.Errorf("Search(%s, %q): %w", group, query, ErrEmptySearchQuery)
+	}
+
+	head, err := NewRefMark(SHELF_MARK_FILE, group)
+	if err != nil {
+		return nil, fmt.Errorf("Search(%s, %q): %w", group, query, err)
+	}
+
+	docs, matches, err := lookupSearchIndex(group, terms)
+	if err != nil {
+		return nil, fmt.Errorf("Search(%s, %q): %w", group, query, err)
+	}
+
+	var headResources map[string]map[string]markResource
+	if !all {
+		if headResources, err = readMarkResources(group, string(head.Reference)); err != nil {
+			return nil, fmt.Errorf("Search(%s, %q): %w", group, query, err)
+		}
+	}
+
+	results := []SearchResult{}
+	// A resource changed back to an earlier version is indexed again, only the newest
+	// document of an outline is reported
+	reported := make(map[string]bool)
+	// Newer documents first so that the latest version of a resource is listed first
+	for docIdx := len(docs) - 1; docIdx >= 0; docIdx-- {
+		termPaths, ok := matches[docIdx]
+		if !ok || !matchAllTerms(termPaths) {
+			continue
+		}
+		doc := docs[docIdx]
+		if reported[doc.plugin+" "+doc.outline] {
+			continue
+		}
+		reported[doc.plugin+" "+doc.outline] = true
+
+		result := SearchResult{
+			Plugin:     doc.plugin,
+			Identifier: doc.identifier,
+			Alias:      doc.alias,
+			Outline:    doc.outline,
+			Paths:      mergeSearchPaths(termPaths),
+		}
+		if all {
+			mark, found, err := newestMarkOf(group, doc, string(head.Reference))
+			if err != nil {
+				return nil, fmt.Errorf("Search(%s, %q): %w", group, query, err)
+			}
+			if !found {
+				continue
+			}
+			result.Mark = mark
+		} else {
+			res, ok := headResources[doc.plugin][doc.identifier]
+			if !ok || res.outlineHash != doc.outline {
+				continue
+			}
+			result.Mark = string(head.Reference)
+		}
+		results = append(results, result)
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Plugin == results[j].Plugin {
+			return results[i].Identifier < results[j].Identifier
+		}
+		return results[i].Plugin < results[j].Plugin
+	})
+
+	return results, nil
+}
+
+// UpdateSearchIndex indexes resources changed in label marks from the mark the index is
+// updated to, up to the given label mark, as a new segment. Segments are merged as the
+// index grows. The whole index is generated if it does not exist yet, for shelves mined
+// before the index was introduced, or if the indexed mark is not in the chain of the
+// given mark. An update skipped, ex. by a concurrent search holding the lock, is
+// caught up on the next update.
+// Will use flock to prevent writing simultaneously. Return locks.ErrIsLocked if file lock is not acquired.
+func UpdateSearchIndex(group, markHash string) error {
+	searchLock, err := locks.NewLock(group, locks.HISTORY_SEARCH_LOCKFILE)
+	if err != nil {
+		return fmt.Errorf("UpdateSearchIndex(%s): %w", group, err)
+	}
+	if err := searchLock.TryLock(); err != nil {
+		if errors.Is(err, locks.ErrIsLocked) {
+			return err
+		}
+		return fmt.Errorf("UpdateSearchIndex(%s): %w", group, err)
+	}
+	defer searchLock.Unlock()
+
+	manifest, err := readSearchManifest(group)
+	if errors.Is(err, fs.ErrNotExist) {
+		if err := writeSearchIndex(group, markHash); err != nil {
+			return fmt.Errorf("UpdateSearchIndex(%s): %w", group, err)
+		}
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("UpdateSearchIndex(%s): %w", group, err)
+	}
+	if manifest.mark == markHash {
+		return nil
+	}
+
+	// Label marks not indexed yet, from the newest
+	pending := []string{}
+	for hash := markHash; hash != manifest.mark; {
+		if hash == "nil" {
+			if err := writeSearchIndex(group, markHash); err != nil {
+				return fmt.Errorf("UpdateSearchIndex(%s): %w", group, err)
+			}
+			return nil
+		}
+		mark, err := ReadMark(group, hash)
+		if err != nil {
+			return fmt.Errorf("UpdateSearchIndex(%s): %w", group, err)
+		}
+		pending = append(pending, hash)
+		hash = mark.Parent
+	}
+
+	// Resources of the indexed mark are indexed already
+	indexed := make(map[string]bool)
+	resources, err := readMarkResources(group, manifest.mark)
+	if err != nil {
+		return fmt.Errorf("UpdateSearchIndex(%s): %w", group, err)
+	}
+	for plugin, res := range resources {
+		for _, r := range res {
+			indexed[plugin+" "+r.outlineHash] = true
+		}
+	}
+
+	marks := []map[string]map[string]markResource{}
+	for i := len(pending) - 1; i >= 0; i-- {
+		resources, err := readMarkResources(group, pending[i])
+		if err != nil {
+			return fmt.Errorf("UpdateSearchIndex(%s): %w", group, err)
+		}
+		marks = append(marks, resources)
+	}
+	index, err := buildSearchIndex(group, marks, indexed)
+	if err != nil {
+		return fmt.Errorf("UpdateSearchIndex(%s): %w", group, err)
+	}
+
+	manifest.mark = markHash
+	if len(index.docs) > 0 {
+		next := 0
+		if len(manifest.segments) > 0 {
+			next = manifest.segments[len(manifest.segments)-1].number + 1
+		}
+		if err := writeSearchSegment(group, searchSegmentName(next), index); err != nil {
+			return fmt.Errorf("UpdateSearchIndex(%s): %w", group, err)
+		}
+		manifest.segments = append(manifest.segments, searchSegmentInfo{number: next, docs: len(index.docs)})
+	}
+	if err := writeSearchManifest(group, manifest); err != nil {
+		return fmt.Errorf("UpdateSearchIndex(%s): %w", group, err)
+	}
+	if err := compactSearchIndex(group, manifest); err != nil {
+		return fmt.Errorf("UpdateSearchIndex(%s): %w", group, err)
+	}
+
+	return nil
+}
+
+// GenerateSearchIndex regenerates the search index of the group from the label mark
+// chain of HEAD into a single segment.
+// Will use flock to prevent writing simultaneously. Return locks.ErrIsLocked if file lock is not acquired.
+func GenerateSearchIndex(group string) error {
+	head, err := NewRefMark(SHELF_MARK_FILE, group)
+	if err != nil {
+		return fmt.Errorf("GenerateSearchIndex(%s): %w", group, err)
+	}
+
+	searchLock, err := locks.NewLock(group, locks.HISTORY_SEARCH_LOCKFILE)
+	if err != nil {
+		return fmt.Errorf("GenerateSearchIndex(%s): %w", group, err)
+	}
+	if err := searchLock.TryLock(); err != nil {
+		if errors.Is(err, locks.ErrIsLocked) {
+			return err
+		}
+		return fmt.Errorf("GenerateSearchIndex(%s): %w", group, err)
+	}
+	defer searchLock.Unlock()
+
+	if err := writeSearchIndex(group, string(head.Reference)); err != nil {
+		return fmt.Errorf("GenerateSearchIndex(%s): %w", group, err)
+	}
+
+	return nil
+}
+
+// writeSearchIndex replaces the search index with the resources of every label mark
+// in the chain starting from reference.
+func writeSearchIndex(group, reference string) error {
+	chain := []map[string]map[string]markResource{}
+	for hash := reference; hash != "nil"; {
+		mark, err := ReadMark(group, hash)
+		if err != nil {
+			return fmt.Errorf("write search index: %w", err)
+		}
+		resources, err := readMarkResources(group, hash)
+		if err != nil {
+			return fmt.Errorf("write search index: %w", err)
+		}
+		chain = append(chain, resources)
+		hash = mark.Parent
+	}
+	// Index from the root mark so that older documents come first
+	for i, j := 0, len(chain)-1; i < j; i, j = i+1, j-1 {
+		chain[i], chain[j] = chain[j], chain[i]
+	}
+
+	index, err := buildSearchIndex(group, chain, make(map[string]bool))
+	if err != nil {
+		return fmt.Errorf("write search index: %w", err)
+	}
+
+	if err := deleteRefs(group, searchDir()); err != nil {
+		return fmt.Errorf("write search index: %w", err)
+	}
+	if err := writeSearchSegment(group, searchSegmentName(0), index); err != nil {
+		return fmt.Errorf("write search index: %w", err)
+	}
+	manifest := searchManifest{
+		mark:     reference,
+		segments: []searchSegmentInfo{{number: 0, docs: len(index.docs)}},
+	}
+	if err := writeSearchManifest(group, manifest); err != nil {
+		return fmt.Errorf("write search index: %w", err)
+	}
+
+	return nil
+}
+
+// compactSearchIndex merges the two newest segments while the older one holds less than
+// search_merge_factor times the documents of the newer one, so the number of segments
+// grows logarithmically with the number of documents. The merged segment is written
+// before the manifest refers to it, replaced segments are removed afterwards.
+func compactSearchIndex(group string, manifest searchManifest) error {
+	for n := len(manifest.segments); n >= 2; n = len(manifest.segments) {
+		older, newer := manifest.segments[n-2], manifest.segments[n-1]
+		if older.docs >= search_merge_factor*newer.docs {
+			return nil
+		}
+
+		merged := searchIndex{docs: []searchDoc{}, postings: make(map[string][]searchPosting)}
+		for _, info := range []searchSegmentInfo{older, newer} {
+			segment, err := readSearchSegment(group, searchSegmentName(info.number))
+			if err != nil {
+				return fmt.Errorf("compact search index: %w", err)
+			}
+			index, err := segment.index()
+			if err != nil {
+				return fmt.Errorf("compact search index: %w", err)
+			}
+			merged.merge(index)
+		}
+
+		mergedInfo := searchSegmentInfo{number: newer.number + 1, docs: len(merged.docs)}
+		if err := writeSearchSegment(group, searchSegmentName(mergedInfo.number), merged); err != nil {
+			return fmt.Errorf("compact search index: %w", err)
+		}
+		manifest.segments = append(manifest.segments[:n-2], mergedInfo)
+		if err := writeSearchManifest(group, manifest); err != nil {
+			return fmt.Errorf("compact search index: %w", err)
+		}
+		for _, info := range []searchSegmentInfo{older, newer} {
+			if err := deleteRef(group, searchSegmentName(info.number)); err != nil {
+				return fmt.Errorf("compact search index: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// buildSearchIndex indexes resources of the label marks, skipping outlines recorded
+// in indexed by key "<plugin> <outline>". indexed is updated with the new documents.
+func buildSearchIndex(
+	group string,
+	marks []map[string]map[string]markResource,
+	indexed map[string]bool,
+) (searchIndex, error) {
+	index := searchIndex{docs: []searchDoc{}, postings: make(map[string][]searchPosting)}
+
+	for _, resources := range marks {
+		plugins := make([]string, 0, len(resources))
+		for plugin := range resources {
+			plugins = append(plugins, plugin)
+		}
+		sort.Strings(plugins)
+
+		for _, plugin := range plugins {
+			identifiers := make([]string, 0, len(resources[plugin]))
+			for id := range resources[plugin] {
+				identifiers = append(identifiers, id)
+			}
+			sort.Strings(identifiers)
+
+			for _, id := range identifiers {
+				res := resources[plugin][id]
+				if indexed[plugin+" "+res.outlineHash] {
+					continue
+				}
+				indexed[plugin+" "+res.outlineHash] = true
+
+				fields, err := searchFields(group, res)
+				if err != nil {
+					return searchIndex{}, fmt.Errorf("build search index: %w", err)
+				}
+				docIdx := len(index.docs)
+				index.docs = append(index.docs, searchDoc{
+					plugin:     plugin,
+					identifier: id,
+					alias:      res.alias,
+					outline:    res.outlineHash,
+				})
+
+				seen := make(map[[2]string]bool)
+				for _, field := range fields {
+					for _, token := range searchTokens(field[1], true) {
+						if seen[[2]string{token, field[0]}] {
+							continue
+						}
+						seen[[2]string{token, field[0]}] = true
+						index.postings[token] = append(
+							index.postings[token],
+							searchPosting{doc: docIdx, path: field[0]},
+						)
+					}
+				}
+			}
+		}
+	}
+
+	return index, nil
+}
+
+// merge appends documents and postings of other after the documents of the index
+func (i *searchIndex) merge(other searchIndex) {
+	offset := len(i.docs)
+	i.docs = append(i.docs, other.docs...)
+	for token, postings := range other.postings {
+		for _, posting := range postings {
+			posting.doc += offset
+			i.postings[token] = append(i.postings[token], posting)
+		}
+	}
+}
+
+// searchFields returns the searchable text of a resource in pairs of path and text
+func searchFields(group string, res markResource) ([][2]string, error) {
+	resource, err := ReadResource(group, res.resourceHash)
+	if err != nil {
+		return nil, fmt.Errorf("search fields: %w", err)
+	}
+
+	fields := [][2]string{
+		{SEARCH_PATH_IDENTIFIER, resource.Identifier},
+		{SEARCH_PATH_ALIAS, resource.Alias},
+	}
+	for _, prop := range resource.Properties {
+		propPath := prop.Type + "/" + prop.Label.Name
+		fields = append(fields, [2]string{propPath, prop.Type + " " + prop.Label.Name})
+
+		if prop.Content.Format == shared.FormatJson {
+			leaves, err := shared.JsonLeaves(prop.Content.Value)
+			if err == nil {
+				for _, leaf := range leaves {
+					fields = append(fields, [2]string{propPath + " " + leaf.Path, searchLeafText(leaf.Value)})
+				}
+				continue
+			}
+		}
+		fields = append(fields, [2]string{propPath, prop.Content.Value})
+	}
+
+	diary, err := ReadMinerDiary(group, res.diaryHash)
+	if err != nil {
+		return nil, fmt.Errorf("search fields: %w", err)
+	}
+	if diary.Hash != "" {
+		note, err := NewObjectRecord(group, diary.Hash).RecordRead()
+		if err != nil {
+			return nil, fmt.Errorf("search fields: %w", err)
+		}
+		fields = append(fields, [2]string{SEARCH_PATH_DIARY, note})
+	}
+
+	return fields, nil
+}
+
+func searchLeafText(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case nil:
+		return "null"
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprintf("%v", v)
+		}
+		return string(b)
+	}
+}
+
+// searchTokens splits text into lower cased words. A word is a run of letters, digits
+// and the characters ._-/:@ so that values like 10.0.0.0/8 or ARNs are kept whole.
+// If withParts is set, alphanumeric parts and tails after each separator of a word
+// are returned as well, ex. arn:aws:iam::1:user/a gives arn, aws, iam, 1, user, a,
+// aws:iam::1:user/a, iam::1:user/a, 1:user/a and user/a.
+func searchTokens(text string, withParts bool) []string {
+	isWordRune := func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("._-/:@", r)
+	}
+	isPartRune := func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsDigit(r)
+	}
+
+	tokens := []string{}
+	seen := make(map[string]bool)
+	add := func(token string) {
+		if token != "" && !seen[token] {
+			seen[token] = true
+			tokens = append(tokens, token)
+		}
+	}
+
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool { return !isWordRune(r) })
+	for _, word := range words {
+		add(strings.Trim(word, "._-/:@"))
+		if !withParts {
+			continue
+		}
+		for _, part := range strings.FieldsFunc(word, func(r rune) bool { return !isPartRune(r) }) {
+			add(part)
+		}
+		for i, r := range word {
+			if !isPartRune(r) {
+				add(strings.Trim(word[i:], "._-/:@"))
+			}
+		}
+	}
+
+	return tokens
+}
+
+// parseSearchQuery splits query into terms, a trailing * of a term matches by prefix
+func parseSearchQuery(query string) []searchTerm {
+	terms := []searchTerm{}
+	for _, field := range strings.Fields(query) {
+		prefix := strings.HasSuffix(field, "*")
+		for _, token := range searchTokens(strings.TrimRight(field, "*"), false) {
+			terms = append(terms, searchTerm{token: token, prefix: prefix})
+		}
+	}
+	return terms
+}
+
+func matchAllTerms(termPaths [][]string) bool {
+	for _, paths := range termPaths {
+		if len(paths) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// mergeSearchPaths returns the unique paths of all terms in the order of appearance
+func mergeSearchPaths(termPaths [][]string) []string {
+	paths := []string{}
+	seen := make(map[string]bool)
+	for _, termPath := range termPaths {
+		for _, path := range termPath {
+			if !seen[path] {
+				seen[path] = true
+				paths = append(paths, path)
+			}
+		}
+	}
+	return paths
+}
+
+// newestMarkOf finds the newest label mark containing the document by the resource
+// timeline, which is the parent of the mark where the resource changed next, or head
+// if the resource is unchanged since. found is false if the timeline has no record
+// of the document, ex. the document is indexed from marks no longer in HEAD chain.
+func newestMarkOf(group string, doc searchDoc, head string) (string, bool, error) {
+	timeline, err := ReadTimeline(group, doc.plugin, doc.identifier)
+	if errors.Is(err, ErrTimelineNotFound) {
+		return "", false, nil
+	} else if err != nil {
+		return "", false, fmt.Errorf("newest mark of %s: %w", doc.outline, err)
+	}
+
+	for i := len(timeline.Entries) - 1; i >= 0; i-- {
+		if timeline.Entries[i].Outline != doc.outline {
+			continue
+		}
+		if i == len(timeline.Entries)-1 {
+			return head, true, nil
+		}
+		mark, err := ParentMark(group, timeline.Entries[i+1].Mark)
+		if err != nil {
+			return "", false, fmt.Errorf("newest mark of %s: %w", doc.outline, err)
+		}
+		return mark, true, nil
+	}
+
+	return "", false, nil
+}
+
+// lookupSearchIndex returns the documents of every segment of the search index, and
+// the matching paths of each term keyed by document index. Only postings of tokens
+// matching the terms are decoded.
+// Return ErrSearchIndexNotFound if the index does not exist.
+func lookupSearchIndex(group string, terms []searchTerm) ([]searchDoc, map[int][][]string, error) {
+	searchLock, err := locks.NewLock(group, locks.HISTORY_SEARCH_LOCKFILE)
+	if err != nil {
+		return nil, nil, fmt.Errorf("lookupSearchIndex(%s): %w", group, err)
+	}
+	if err := searchLock.TryRLock(); err != nil {
+		if errors.Is(err, locks.ErrIsLocked) {
+			return nil, nil, err
+		}
+		return nil, nil, fmt.Errorf("lookupSearchIndex(%s): %w", group, err)
+	}
+	defer searchLock.Unlock()
+
+	manifest, err := readSearchManifest(group)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, ErrSearchIndexNotFound
+	} else if err != nil {
+		return nil, nil, fmt.Errorf("lookupSearchIndex(%s): %w", group, err)
+	}
+
+	docs := []searchDoc{}
+	matches := make(map[int][][]string)
+	for _, info := range manifest.segments {
+		segment, err := readSearchSegment(group, searchSegmentName(info.number))
+		if err != nil {
+			return nil, nil, fmt.Errorf("lookupSearchIndex(%s): %w", group, err)
+		}
+		offset := len(docs)
+		docs = append(docs, segment.docs...)
+
+		for i, term := range terms {
+			postings, err := segment.lookup(term)
+			if err != nil {
+				return nil, nil, fmt.Errorf("lookupSearchIndex(%s): %w", group, err)
+			}
+			for _, posting := range postings {
+				doc := posting.doc + offset
+				if _, ok := matches[doc]; !ok {
+					matches[doc] = make([][]string, len(terms))
+				}
+				matches[doc][i] = append(matches[doc][i], posting.path)
+			}
+		}
+	}
+
+	return docs, matches, nil
+}
+
+// lookup returns postings of tokens matching the term, tokens are found by binary search
+func (s searchSegment) lookup(term searchTerm) ([]searchPosting, error) {
+	postings := []searchPosting{}
+	for i := sort.SearchStrings(s.tokens, term.token); i < len(s.tokens); i++ {
+		if s.tokens[i] != term.token && !(term.prefix && strings.HasPrefix(s.tokens[i], term.token)) {
+			break
+		}
+		decoded, err := decodeSearchPostings(s.postings[i], len(s.docs))
+		if err != nil {
+			return nil, fmt.Errorf("lookup %s: %w", s.tokens[i], err)
+		}
+		postings = append(postings, decoded...)
+	}
+	return postings, nil
+}
+
+// index decodes postings of every token of the segment
+func (s searchSegment) index() (searchIndex, error) {
+	index := searchIndex{docs: s.docs, postings: make(map[string][]searchPosting)}
+	for i, token := range s.tokens {
+		postings, err := decodeSearchPostings(s.postings[i], len(s.docs))
+		if err != nil {
+			return searchIndex{}, fmt.Errorf("index %s: %w", token, err)
+		}
+		index.postings[token] = postings
+	}
+	return index, nil
+}
+
+// encodeSearchSegment encodes the index in lines of format
+//
+//	doc <plugin> <outline> <quoted identifier> <quoted alias>
+//	tok <token> <document number in segment> <quoted path> [<document number> <quoted path>]...
+//
+// with one tok line per token, sorted by token.
+func encodeSearchSegment(index searchIndex) []byte {
+	var buf bytes.Buffer
+	for _, doc := range index.docs {
+		fmt.Fprintf(
+			&buf,
+			"doc %s %s %s %s\n",
+			doc.plugin,
+			doc.outline,
+			strconv.Quote(doc.identifier),
+			strconv.Quote(doc.alias),
+		)
+	}
+
+	tokens := make([]string, 0, len(index.postings))
+	for token := range index.postings {
+		tokens = append(tokens, token)
+	}
+	sort.Strings(tokens)
+	for _, token := range tokens {
+		fmt.Fprintf(&buf, "tok %s", token)
+		for _, posting := range index.postings[token] {
+			fmt.Fprintf(&buf, " %d %s", posting.doc, strconv.Quote(posting.path))
+		}
+		buf.WriteString("\n")
+	}
+	return buf.Bytes()
+}
+
+func readSearchSegment(group, name string) (searchSegment, error) {
+	content, err := readCompressedRef(group, name)
+	if err != nil {
+		return searchSegment{}, fmt.Errorf("read search segment: %w", err)
+	}
+
+	segment := searchSegment{docs: []searchDoc{}, tokens: []string{}, postings: []string{}}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(content)+1)
+	for scanner.Scan() {
+		line := scanner.Text()
+		kind, rest, _ := strings.Cut(line, " ")
+		switch kind {
+		case "doc":
+			plugin, rest, _ := strings.Cut(rest, " ")
+			outline, rest, _ := strings.Cut(rest, " ")
+			identifier, rest, err := unquotePrefix(rest)
+			if err != nil {
+				return searchSegment{}, fmt.Errorf("read search segment: invalid entry: %s", line)
+			}
+			alias, _, err := unquotePrefix(strings.TrimPrefix(rest, " "))
+			if err != nil {
+				return searchSegment{}, fmt.Errorf("read search segment: invalid entry: %s", line)
+			}
+			segment.docs = append(segment.docs, searchDoc{
+				plugin:     plugin,
+				identifier: identifier,
+				alias:      alias,
+				outline:    outline,
+			})
+		case "tok":
+			token, postings, _ := strings.Cut(rest, " ")
+			if n := len(segment.tokens); n > 0 && segment.tokens[n-1] >= token {
+				return searchSegment{}, fmt.Errorf("read search segment: unsorted token: %s", token)
+			}
+			segment.tokens = append(segment.tokens, token)
+			segment.postings = append(segment.postings, postings)
+		default:
+			return searchSegment{}, fmt.Errorf("read search segment: invalid entry: %s", line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return searchSegment{}, fmt.Errorf("read search segment: %w", err)
+	}
+
+	return segment, nil
+}
+
+// decodeSearchPostings decodes the postings of a tok line, document numbers are
+// checked against the number of documents in the segment.
+func decodeSearchPostings(encoded string, docs int) ([]searchPosting, error) {
+	postings := []searchPosting{}
+	for rest := encoded; rest != ""; rest = strings.TrimPrefix(rest, " ") {
+		num, after, _ := strings.Cut(rest, " ")
+		doc, err := strconv.Atoi(num)
+		if err != nil || doc < 0 || doc >= docs {
+			return nil, fmt.Errorf("decode search postings: invalid document: %s", num)
+		}
+		path, after, err := unquotePrefix(after)
+		if err != nil {
+			return nil, fmt.Errorf("decode search postings: invalid path: %s", after)
+		}
+		postings = append(postings, searchPosting{doc: doc, path: path})
+		rest = after
+	}
+	return postings, nil
+}
+
+func writeSearchSegment(group, name string, index searchIndex) error {
+	if err := writeCompressedRef(group, name, encodeSearchSegment(index)); err != nil {
+		return fmt.Errorf("write search segment: %w", err)
+	}
+	return nil
+}
+
+// readSearchManifest reads the manifest of the search index, fs.ErrNotExist is returned
+// if the index is not generated or is written before the manifest was introduced.
+func readSearchManifest(group string) (searchManifest, error) {
+	content, err := readCompressedRef(group, searchManifestName())
+	if err != nil {
+		return searchManifest{}, fmt.Errorf("read search manifest: %w", err)
+	}
+
+	manifest := searchManifest{segments: []searchSegmentInfo{}}
+	for _, line := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 2 && fields[0] == "mark":
+			manifest.mark = fields[1]
+		case len(fields) == 3 && fields[0] == "segment":
+			number, numErr := strconv.Atoi(fields[1])
+			docs, docsErr := strconv.Atoi(fields[2])
+			if numErr != nil || docsErr != nil {
+				return searchManifest{}, fmt.Errorf("read search manifest: invalid entry: %s", line)
+			}
+			manifest.segments = append(manifest.segments, searchSegmentInfo{number: number, docs: docs})
+		default:
+			return searchManifest{}, fmt.Errorf("read search manifest: invalid entry: %s", line)
+		}
+	}
+	if manifest.mark == "" {
+		return searchManifest{}, fmt.Errorf("read search manifest: missing mark")
+	}
+
+	return manifest, nil
+}
+
+// writeSearchManifest writes the manifest in lines of format
+//
+//	mark <label mark hash>
+//	segment <segment number> <number of documents>
+//
+// with segments sorted from the oldest.
+func writeSearchManifest(group string, manifest searchManifest) error {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "mark %s\n", manifest.mark)
+	for _, info := range manifest.segments {
+		fmt.Fprintf(&buf, "segment %d %d\n", info.number, info.docs)
+	}
+	if err := writeCompressedRef(group, searchManifestName(), buf.Bytes()); err != nil {
+		return fmt.Errorf("write search manifest: %w", err)
+	}
+	return nil
+}
+
+// unquotePrefix unquotes the quoted string at the start of s and returns the rest
+func unquotePrefix(s string) (string, string, error) {
+	quoted, err := strconv.QuotedPrefix(s)
+	if err != nil {
+		return "", "", err
+	}
+	value, err := strconv.Unquote(quoted)
+	if err != nil {
+		return "", "", err
+	}
+	return value, s[len(quoted):], nil
+}
+
+// searchDir returns the reference directory storing the search index
+func searchDir() string {
+	return path.Join(shelf_history_dir, shelf_history_search_dir)
+}
+
+// searchSegmentName returns the reference name of the search index segment
+func searchSegmentName(segment int) string {
+	return path.Join(searchDir(), fmt.Sprintf("%s.%d", shelf_search_segment_file, segment))
+}
+
+// searchManifestName returns the reference name of the search index manifest
+func searchManifestName() string {
+	return path.Join(searchDir(), shelf_search_manifest_file)
+}
@@ -0,0 +1,232 @@
+package shelf
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/liuminhaw/mist-miner/paths"
+	"github.com/liuminhaw/mist-miner/shared"
+	"github.com/liuminhaw/mist-miner/store"
+)
+
+// useMemoryStore runs the test on a shelf kept in memory, lock files and the mine
+// journal are kept under a temporary shelf directory.
+func useMemoryStore(t *testing.T) *store.Memory {
+	t.Helper()
+
+	paths.Set(paths.Settings{ShelfDir: t.TempDir()})
+	s := store.NewMemory()
+	SetStore(s)
+	resetFormats()
+	t.Cleanup(func() {
+		SetStore(nil)
+		paths.Set(paths.Settings{})
+		resetFormats()
+	})
+	return s
+}
+
+// resetFormats forgets formats of groups loaded from the previous store
+func resetFormats() {
+	formats.Lock()
+	defer formats.Unlock()
+	formats.groups = make(map[string]groupFormatState)
+}
+
+func testResource(identifier, value string) shared.MinerResource {
+	return shared.MinerResource{
+		Identifier: identifier,
+		Alias:      "alias-" + identifier,
+		Properties: []shared.MinerProperty{{
+			Type:    "detail",
+			Label:   shared.MinerPropertyLabel{Name: "Detail", Unique: true},
+			Content: shared.MinerPropertyContent{Format: shared.FormatJson, Value: value},
+		}},
+	}
+}
+
+// writeTestMark writes the resources of the plugin the way mine does and moves HEAD
+// of the group to the new label mark, whose hash is returned.
+func writeTestMark(t *testing.T, group, plugin string, resources ...shared.MinerResource) string {
+	t.Helper()
+
+	write := func(a any) string {
+		stuff, err := NewStuff(group, a)
+		if err != nil {
+			t.Fatalf("NewStuff: %v", err)
+		}
+		var se *StuffAlreadyExistsError
+		if _, err := stuff.Write(); err != nil && !errors.As(err, &se) {
+			t.Fatalf("Stuff.Write: %v", err)
+		}
+		return stuff.Hash
+	}
+
+	idMaps := IdentifierHashMaps{Group: group, Maps: []IdentifierHashMap{}}
+	for _, resource := range resources {
+		resource.Sort()
+		outline, err := NewStuffOutline(group, write(&resource), write(&shared.MinerDiary{}))
+		if err != nil {
+			t.Fatalf("NewStuffOutline: %v", err)
+		}
+		if _, err := outline.Write(); err != nil {
+			t.Fatalf("StuffOutline.Write: %v", err)
+		}
+		idMaps.Maps = append(idMaps.Maps, IdentifierHashMap{
+			Identifier: resource.Identifier,
+			Alias:      resource.Alias,
+			Hash:       outline.Hash,
+		})
+	}
+	idMaps.Sort()
+	if _, err := idMaps.Write(); err != nil {
+		t.Fatalf("IdentifierHashMaps.Write: %v", err)
+	}
+
+	mark, err := NewMark(group, "mine")
+	if err != nil {
+		t.Fatalf("NewMark: %v", err)
+	}
+	mark.AddMapping(plugin, idMaps.Hash, "1.0.0")
+	if err := mark.Update(); err != nil {
+		t.Fatalf("LabelMark.Update: %v", err)
+	}
+	return mark.Hash
+}
+
+func TestMemoryStoreShelf(t *testing.T) {
+	s := useMemoryStore(t)
+
+	first := writeTestMark(t, "grp", "aws-iam", testResource("alice", `{"a":1}`), testResource("bob", `{"b":1}`))
+	second := writeTestMark(t, "grp", "aws-iam", testResource("alice", `{"a":2}`), testResource("carol", `{"c":1}`))
+
+	if objects, err := s.ListObjects("grp"); err != nil || len(objects) == 0 {
+		t.Fatalf("ListObjects = %v, %v, want objects in the memory store", objects, err)
+	}
+
+	for rev, want := range map[string]string{"HEAD": second, "HEAD~1": first, first[:8]: first} {
+		if got, err := ResolveMark("grp", rev); err != nil || got != want {
+			t.Errorf("ResolveMark(%s) = %s, %v, want %s", rev, got, err, want)
+		}
+	}
+
+	found, err := LookupResource("grp", first, "aws-iam", "alice")
+	if err != nil {
+		t.Fatalf("LookupResource: %v", err)
+	}
+	if found.Alias != "alias-alice" || found.Resource.Properties[0].Content.Value != `{"a":1}` {
+		t.Errorf("LookupResource = %+v, want alice at the first mark", found.Resource)
+	}
+	if _, err := LookupResource("grp", second, "aws-iam", "bob"); !errors.Is(err, ErrResourceNotFound) {
+		t.Errorf("LookupResource(bob) error = %v, want %v", err, ErrResourceNotFound)
+	}
+
+	diff, err := DiffMarks("grp", first, second)
+	if err != nil {
+		t.Fatalf("DiffMarks: %v", err)
+	}
+	statuses := make(map[string]string)
+	for _, change := range diff.Plugins[0].Resources {
+		statuses[change.Identifier] = change.Status
+	}
+	want := map[string]string{
+		"alice": DIFF_STATUS_MODIFIED,
+		"bob":   DIFF_STATUS_REMOVED,
+		"carol": DIFF_STATUS_ADDED,
+	}
+	for id, status := range want {
+		if statuses[id] != status {
+			t.Errorf("DiffMarks status of %s = %s, want %s", id, statuses[id], status)
+		}
+	}
+
+	if err := CreateTag("grp", "v1", first); err != nil {
+		t.Fatalf("CreateTag: %v", err)
+	}
+	if got, err := ResolveMark("grp", "v1"); err != nil || got != first {
+		t.Errorf("ResolveMark(v1) = %s, %v, want %s", got, err, first)
+	}
+	if err := CreateTag("grp", "v1", second); !errors.Is(err, ErrTagExists) {
+		t.Errorf("CreateTag twice error = %v, want %v", err, ErrTagExists)
+	}
+}
+
+func TestMemoryStoreSearch(t *testing.T) {
+	useMemoryStore(t)
+
+	writeTestMark(t, "grp", "aws-iam", testResource("alice", `{"team":"ops"}`))
+	if err := GenerateSearchIndex("grp"); err != nil {
+		t.Fatalf("GenerateSearchIndex: %v", err)
+	}
+	head := writeTestMark(t, "grp", "aws-iam", testResource("alice", `{"team":"dev"}`))
+	// Marks of older versions are found through timelines, updated before the index by mine
+	if err := GenerateTimeline("grp"); err != nil {
+		t.Fatalf("GenerateTimeline: %v", err)
+	}
+	if err := UpdateSearchIndex("grp", head); err != nil {
+		t.Fatalf("UpdateSearchIndex: %v", err)
+	}
+
+	if results, err := Search("grp", "dev", false); err != nil || len(results) != 1 || results[0].Mark != head {
+		t.Errorf("Search(dev) = %+v, %v, want alice at HEAD", results, err)
+	}
+	if results, err := Search("grp", "ops", false); err != nil || len(results) != 0 {
+		t.Errorf("Search(ops) = %+v, %v, want nothing in HEAD", results, err)
+	}
+	if results, err := Search("grp", "ops", true); err != nil || len(results) != 1 {
+		t.Errorf("Search(ops, all) = %+v, %v, want the older version of alice", results, err)
+	}
+}
+
+func TestMemoryStoreCollectGarbage(t *testing.T) {
+	s := useMemoryStore(t)
+
+	writeTestMark(t, "grp", "aws-iam", testResource("alice", `{"a":1}`))
+	// Objects not referenced by any label mark
+	unreachable, err := NewStuff("grp", &shared.MinerDiary{Hash: "dangling"})
+	if err != nil {
+		t.Fatalf("NewStuff: %v", err)
+	}
+	if _, err := unreachable.Write(); err != nil {
+		t.Fatalf("Stuff.Write: %v", err)
+	}
+
+	result, err := CollectGarbage("grp", false)
+	if err != nil {
+		t.Fatalf("CollectGarbage: %v", err)
+	}
+	if len(result.Removed) != 1 || result.Removed[0] != unreachable.Hash {
+		t.Errorf("CollectGarbage removed %v, want %s", result.Removed, unreachable.Hash)
+	}
+	if ok, err := s.HasObject("grp", unreachable.Hash); err != nil || ok {
+		t.Errorf("HasObject(%s) = %t, %v, want removed", unreachable.Hash, ok, err)
+	}
+}
+
+func TestReloadFormat(t *testing.T) {
+	useMemoryStore(t)
+
+	writeTestMark(t, "grp", "aws-iam", testResource("alice", `{"a":1}`))
+	loaded, err := ReadFormat("grp")
+	if err != nil || loaded.Hash != HASH_SHA256 {
+		t.Fatalf("ReadFormat = %+v, %v, want sha256", loaded, err)
+	}
+
+	// FORMAT recorded by another process, ex. shelf upgrade, is only read once
+	// the format loaded in this process is dropped
+	upgraded, err := NewFormat(COMPRESSION_ZSTD, HASH_BLAKE3)
+	if err != nil {
+		t.Fatalf("NewFormat: %v", err)
+	}
+	if err := writeFormat("grp", upgraded); err != nil {
+		t.Fatalf("writeFormat: %v", err)
+	}
+	useFormat("grp", loaded)
+	if f, _ := ReadFormat("grp"); f.Hash != HASH_SHA256 {
+		t.Errorf("ReadFormat before reload = %+v, want the loaded sha256 format", f)
+	}
+	ReloadFormat("grp")
+	if f, err := ReadFormat("grp"); err != nil || f.Hash != HASH_BLAKE3 || f.Compression != COMPRESSION_ZSTD {
+		t.Errorf("ReadFormat after reload = %+v, %v, want %+v", f, err, upgraded)
+	}
+}
@@ -0,0 +1,210 @@
+package shelf
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"sync"
+)
+
+// Label marks are optionally signed with ed25519 when written. The signature of a mark
+// is stored in the reference signatures/<mark hash> as
+//
+//	ed25519 <public key> <signature>
+//
+// with base64 encoded public key and signature of signatureMessage. Marks refer to
+// their objects and parent by hash, so a valid signature with all reachable objects
+// hashing to their hashes proves the snapshot was not altered after signing.
+const (
+	MARK_SIGNED          = "signed"
+	MARK_UNSIGNED        = "unsigned"
+	MARK_UNTRUSTED       = "untrusted key"
+	MARK_BAD_SIGNATURE   = "bad signature"
+	shelf_signature_dir  = "signatures"
+	signature_algorithm  = "ed25519"
+	signature_pem_type   = "PRIVATE KEY"
+	signature_pub_type   = "PUBLIC KEY"
+	signature_msg_format = "mist-miner label mark\ngroup %s\nhash %s\n"
+)
+
+// Key signing label marks written from now on and public keys trusted by verification
+var signing = struct {
+	sync.RWMutex
+	key     ed25519.PrivateKey
+	trusted []ed25519.PublicKey
+}{}
+
+// SetSigningKey sets the key signing label marks written from now on, a nil key
+// leaves label marks unsigned.
+func SetSigningKey(key ed25519.PrivateKey) {
+	signing.Lock()
+	defer signing.Unlock()
+	signing.key = key
+}
+
+// SetTrustedKeys sets the public keys accepted when verifying label mark signatures.
+func SetTrustedKeys(keys []ed25519.PublicKey) {
+	signing.Lock()
+	defer signing.Unlock()
+	signing.trusted = keys
+}
+
+// ReadSigningKeyFile reads the ed25519 private key stored as PKCS #8 PEM in the file,
+// ex. generated by openssl genpkey -algorithm ed25519
+func ReadSigningKeyFile(path string) (ed25519.PrivateKey, error) {
+	block, err := readPemFile(path, signature_pem_type)
+	if err != nil {
+		return nil, fmt.Errorf("ReadSigningKeyFile(%s): %w", path, err)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("ReadSigningKeyFile(%s): %w: %v", path, ErrInvalidSigningKey, err)
+	}
+	priv, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("ReadSigningKeyFile(%s): %T: %w", path, key, ErrInvalidSigningKey)
+	}
+	return priv, nil
+}
+
+// ReadPublicKeyFile reads the ed25519 public key stored as PKIX PEM in the file,
+// ex. generated by openssl pkey -pubout
+func ReadPublicKeyFile(path string) (ed25519.PublicKey, error) {
+	block, err := readPemFile(path, signature_pub_type)
+	if err != nil {
+		return nil, fmt.Errorf("ReadPublicKeyFile(%s): %w", path, err)
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("ReadPublicKeyFile(%s): %w: %v", path, ErrInvalidSigningKey, err)
+	}
+	pub, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("ReadPublicKeyFile(%s): %T: %w", path, key, ErrInvalidSigningKey)
+	}
+	return pub, nil
+}
+
+func readPemFile(path, pemType string) (*pem.Block, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read pem file: %w", err)
+	}
+	block, _ := pem.Decode(content)
+	if block == nil || block.Type != pemType {
+		return nil, fmt.Errorf("read pem file: no %s block: %w", pemType, ErrInvalidSigningKey)
+	}
+	return block, nil
+}
+
+// MarkVerification is the signature status of a label mark, one of MARK_SIGNED,
+// MARK_UNSIGNED, MARK_UNTRUSTED or MARK_BAD_SIGNATURE. Signer is the base64 encoded
+// public key of the signature.
+type MarkVerification struct {
+	Hash   string
+	Status string
+	Signer string
+}
+
+// signMark signs the label mark of given hash with the signing key,
+// nothing is written if no signing key is set.
+func signMark(group, hash string) error {
+	signing.RLock()
+	key := signing.key
+	signing.RUnlock()
+	if key == nil {
+		return nil
+	}
+
+	signature := ed25519.Sign(key, signatureMessage(group, hash))
+	content := fmt.Sprintf(
+		"%s %s %s\n",
+		signature_algorithm,
+		base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey)),
+		base64.StdEncoding.EncodeToString(signature),
+	)
+	if err := writeRef(group, signatureName(hash), []byte(content)); err != nil {
+		return fmt.Errorf("sign mark %s: %w", hash, err)
+	}
+	return nil
+}
+
+// verifyMark checks the signature of the label mark of given hash against the trusted keys
+func verifyMark(group, hash string) (MarkVerification, error) {
+	result := MarkVerification{Hash: hash, Status: MARK_UNSIGNED}
+	content, err := readRef(group, signatureName(hash))
+	if errors.Is(err, fs.ErrNotExist) {
+		return result, nil
+	} else if err != nil {
+		return result, fmt.Errorf("verify mark %s: %w", hash, err)
+	}
+
+	result.Status = MARK_BAD_SIGNATURE
+	fields := strings.Fields(string(content))
+	if len(fields) != 3 || fields[0] != signature_algorithm {
+		return result, nil
+	}
+	result.Signer = fields[1]
+	pub, err := base64.StdEncoding.DecodeString(fields[1])
+	if err != nil || len(pub) != ed25519.PublicKeySize {
+		return result, nil
+	}
+	signature, err := base64.StdEncoding.DecodeString(fields[2])
+	if err != nil {
+		return result, nil
+	}
+
+	signing.RLock()
+	trusted := false
+	for _, key := range signing.trusted {
+		if bytes.Equal(key, pub) {
+			trusted = true
+			break
+		}
+	}
+	signing.RUnlock()
+	if !trusted {
+		result.Status = MARK_UNTRUSTED
+		return result, nil
+	}
+	if ed25519.Verify(ed25519.PublicKey(pub), signatureMessage(group, hash), signature) {
+		result.Status = MARK_SIGNED
+	}
+	return result, nil
+}
+
+// pruneSignatures removes signatures of label marks for which keep returns false
+func pruneSignatures(group string, keep func(hash string) bool) error {
+	names, err := listRefs(group, shelf_signature_dir)
+	if err != nil {
+		return fmt.Errorf("prune signatures: %w", err)
+	}
+	for _, name := range names {
+		if keep(path.Base(name)) {
+			continue
+		}
+		if err := deleteRef(group, name); err != nil {
+			return fmt.Errorf("prune signatures: %w", err)
+		}
+	}
+	return nil
+}
+
+// signatureName returns the reference name of the signature of the label mark
+func signatureName(hash string) string {
+	return path.Join(shelf_signature_dir, hash)
+}
+
+// signatureMessage returns the message signed for the label mark, the group is
+// included so a signature cannot be moved to another group.
+func signatureMessage(group, hash string) []byte {
+	return []byte(fmt.Sprintf(signature_msg_format, group, hash))
+}
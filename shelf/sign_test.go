@@ -0,0 +1,193 @@
+package shelf
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"testing"
+)
+
+// testSigningKey sets a new signing key trusted by verification, signing keys are
+// unset after the test.
+func testSigningKey(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+
+	pub, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	SetSigningKey(key)
+	SetTrustedKeys([]ed25519.PublicKey{pub})
+	t.Cleanup(func() {
+		SetSigningKey(nil)
+		SetTrustedKeys(nil)
+	})
+	return key
+}
+
+func TestSignVerifyMark(t *testing.T) {
+	useMemoryStore(t)
+	key := testSigningKey(t)
+
+	first := writeTestMark(t, "grp", "aws-iam", testResource("alice", `{"a":1}`))
+	if verification, err := verifyMark("grp", first); err != nil || verification.Status != MARK_SIGNED {
+		t.Errorf("verifyMark = %+v, %v, want %s", verification, err, MARK_SIGNED)
+	}
+
+	SetSigningKey(nil)
+	second := writeTestMark(t, "grp", "aws-iam", testResource("alice", `{"a":2}`))
+	if verification, err := verifyMark("grp", second); err != nil || verification.Status != MARK_UNSIGNED {
+		t.Errorf("verifyMark unsigned = %+v, %v, want %s", verification, err, MARK_UNSIGNED)
+	}
+
+	// Untrusted key
+	other, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	SetTrustedKeys([]ed25519.PublicKey{other})
+	if verification, err := verifyMark("grp", first); err != nil || verification.Status != MARK_UNTRUSTED {
+		t.Errorf("verifyMark untrusted = %+v, %v, want %s", verification, err, MARK_UNTRUSTED)
+	}
+	SetTrustedKeys([]ed25519.PublicKey{key.Public().(ed25519.PublicKey)})
+
+	// Signature moved to another label mark or group
+	signature, err := readRef("grp", signatureName(first))
+	if err != nil {
+		t.Fatalf("readRef: %v", err)
+	}
+	if err := writeRef("grp", signatureName(second), signature); err != nil {
+		t.Fatalf("writeRef: %v", err)
+	}
+	if verification, err := verifyMark("grp", second); err != nil || verification.Status != MARK_BAD_SIGNATURE {
+		t.Errorf("verifyMark moved signature = %+v, %v, want %s", verification, err, MARK_BAD_SIGNATURE)
+	}
+	if err := writeRef("other", signatureName(first), signature); err != nil {
+		t.Fatalf("writeRef: %v", err)
+	}
+	if verification, err := verifyMark("other", first); err != nil || verification.Status != MARK_BAD_SIGNATURE {
+		t.Errorf("verifyMark signature of other group = %+v, %v, want %s", verification, err, MARK_BAD_SIGNATURE)
+	}
+	if err := writeRef("grp", signatureName(second), []byte("ed25519 garbage\n")); err != nil {
+		t.Fatalf("writeRef: %v", err)
+	}
+	if verification, err := verifyMark("grp", second); err != nil || verification.Status != MARK_BAD_SIGNATURE {
+		t.Errorf("verifyMark malformed signature = %+v, %v, want %s", verification, err, MARK_BAD_SIGNATURE)
+	}
+}
+
+func TestVerifyModifiedMark(t *testing.T) {
+	useMemoryStore(t)
+	testSigningKey(t)
+
+	mark := writeTestMark(t, "grp", "aws-iam", testResource("alice", `{"a":1}`))
+	if report, err := VerifyMark("grp", mark); err != nil || len(report.Issues) != 0 {
+		t.Fatalf("VerifyMark = %+v, %v, want no issue", report, err)
+	}
+
+	// Label mark rewritten in place, its signature still names the old hash
+	labelMark, err := ReadMark("grp", mark)
+	if err != nil {
+		t.Fatalf("ReadMark: %v", err)
+	}
+	labelMark.Mappings[0].Module = "aws-s3"
+	if err := labelMark.calcHash(); err != nil {
+		t.Fatalf("calcHash: %v", err)
+	}
+	if err := writeObject("grp", mark, OBJECT_TYPE_MARK, labelMark.buffer.Bytes()); err != nil {
+		t.Fatalf("writeObject: %v", err)
+	}
+
+	report, err := VerifyMark("grp", mark)
+	if err != nil {
+		t.Fatalf("VerifyMark: %v", err)
+	}
+	if len(report.Marks) != 1 || report.Marks[0].Status != MARK_SIGNED {
+		t.Errorf("VerifyMark marks = %+v, want the signature of the hash to verify", report.Marks)
+	}
+	if len(report.Issues) != 1 || report.Issues[0].Target != mark || report.Issues[0].Problem != FSCK_HASH_MISMATCH {
+		t.Errorf("VerifyMark issues = %+v, want hash mismatch of %s", report.Issues, mark)
+	}
+}
+
+func TestUpgradeSignedShelf(t *testing.T) {
+	useMemoryStore(t)
+	testSigningKey(t)
+
+	writeTestMark(t, "grp", "aws-iam", testResource("alice", `{"a":1}`))
+	writeTestMark(t, "grp", "aws-iam", testResource("alice", `{"a":2}`))
+	target, err := NewFormat(COMPRESSION_ZSTD, HASH_BLAKE3)
+	if err != nil {
+		t.Fatalf("NewFormat: %v", err)
+	}
+
+	if _, err := UpgradeShelf("grp", target, false); err != nil {
+		t.Fatalf("UpgradeShelf: %v", err)
+	}
+	head, err := ResolveMark("grp", "HEAD")
+	if err != nil {
+		t.Fatalf("ResolveMark: %v", err)
+	}
+	report, err := VerifyMark("grp", head)
+	if err != nil || len(report.Issues) != 0 {
+		t.Fatalf("VerifyMark after upgrade = %+v, %v, want no issue", report, err)
+	}
+	for _, verification := range report.Marks {
+		if verification.Status != MARK_SIGNED {
+			t.Errorf("upgraded label mark %s is %s, want %s", verification.Hash, verification.Status, MARK_SIGNED)
+		}
+	}
+}
+
+func TestUpgradeUnverifiedShelf(t *testing.T) {
+	target, err := NewFormat(COMPRESSION_ZSTD, HASH_BLAKE3)
+	if err != nil {
+		t.Fatalf("NewFormat: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(t *testing.T, mark string)
+	}{
+		{
+			name: "untrusted key",
+			modify: func(t *testing.T, mark string) {
+				SetTrustedKeys(nil)
+			},
+		},
+		{
+			name: "modified resource",
+			modify: func(t *testing.T, mark string) {
+				found, err := LookupResource("grp", mark, "aws-iam", "alice")
+				if err != nil {
+					t.Fatalf("LookupResource: %v", err)
+				}
+				found.Resource.Properties[0].Content.Value = `{"a":"altered"}`
+				stuff, err := NewStuff("grp", found.Resource)
+				if err != nil {
+					t.Fatalf("NewStuff: %v", err)
+				}
+				if err := writeObject("grp", found.ResourceHash, OBJECT_TYPE_RESOURCE, stuff.Resource); err != nil {
+					t.Fatalf("writeObject: %v", err)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		useMemoryStore(t)
+		testSigningKey(t)
+		mark := writeTestMark(t, "grp", "aws-iam", testResource("alice", `{"a":1}`))
+		tt.modify(t, mark)
+
+		if _, err := UpgradeShelf("grp", target, false); !errors.Is(err, ErrUnverifiedMark) {
+			t.Errorf("UpgradeShelf with %s error = %v, want %v", tt.name, err, ErrUnverifiedMark)
+		}
+		if head, err := ResolveMark("grp", "HEAD"); err != nil || head != mark {
+			t.Errorf("HEAD after refused upgrade with %s = %s, %v, want %s", tt.name, head, err, mark)
+		}
+		if format, err := ReadFormat("grp"); err != nil || format.Hash != HASH_SHA256 {
+			t.Errorf("format after refused upgrade with %s = %+v, %v, want sha256", tt.name, format, err)
+		}
+	}
+}
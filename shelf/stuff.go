@@ -1,15 +1,12 @@
 package shelf
 
 import (
-	"compress/zlib"
-	"crypto/sha256"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
-	"os"
-	"path/filepath"
 	"strings"
+
+	"github.com/liuminhaw/mist-miner/shared"
 )
 
 func ReadStuffOutline(group, hash string) (*StuffOutline, error) {
@@ -33,6 +30,22 @@ func ReadStuffOutline(group, hash string) (*StuffOutline, error) {
 	}, nil
 }
 
+// ReadResource reads the MinerResource object of the given group and hash.
+func ReadResource(group, hash string) (*shared.MinerResource, error) {
+	r, err := NewObjectRecord(group, hash).RecordReadCloser()
+	if err != nil {
+		return nil, fmt.Errorf("read resource: %w", err)
+	}
+	defer r.Close()
+
+	resource := shared.MinerResource{}
+	if err := json.NewDecoder(r).Decode(&resource); err != nil {
+		return nil, fmt.Errorf("read resource: decode: %w", err)
+	}
+
+	return &resource, nil
+}
+
 type StuffOutline struct {
 	Hash         string
 	Group        string
@@ -41,84 +54,96 @@ type StuffOutline struct {
 	Content      []byte
 }
 
-func NewStuffOutline(group, resourceHash, diaryHash string) StuffOutline {
+func NewStuffOutline(group, resourceHash, diaryHash string) (StuffOutline, error) {
 	content := fmt.Sprintf("%s %s", resourceHash, diaryHash)
 
-	h := sha256.New()
-	h.Write([]byte(content))
+	hash, err := objectHash(group, []byte(content))
+	if err != nil {
+		return StuffOutline{}, fmt.Errorf("new stuff outline: %w", err)
+	}
 
 	return StuffOutline{
-		Hash:         fmt.Sprintf("%x", h.Sum(nil)),
+		Hash:         hash,
 		Group:        group,
 		ResourceHash: resourceHash,
 		DiaryHash:    diaryHash,
 		Content:      []byte(content),
-	}
+	}, nil
 }
 
-func (s *StuffOutline) Write() error {
-	outlineFile, err := NewObjectRecord(s.Group, s.Hash).RecordFile()
-	if err != nil {
-		return fmt.Errorf("stuff outline write: %w", err)
-	}
-	if _, err := os.Stat(outlineFile); !errors.Is(err, os.ErrNotExist) {
-		fmt.Printf("Stuff outline file already exists: %s\n", outlineFile)
-		return nil
-	}
-
-	err = os.MkdirAll(filepath.Dir(outlineFile), os.ModePerm)
+// Write writes the stuff outline and returns the message of the result
+func (s *StuffOutline) Write() (string, error) {
+	record := NewObjectRecord(s.Group, s.Hash)
+	outlineFile, err := record.RecordFile()
 	if err != nil {
-		return fmt.Errorf("stuff outline write: mkdir: %w", err)
+		return "", fmt.Errorf("stuff outline write: %w", err)
 	}
-
-	f, err := os.Create(outlineFile)
-	if err != nil {
-		return fmt.Errorf("stuff outline write: create file: %w", err)
+	if record.Exist() {
+		return fmt.Sprintf("Stuff outline file already exists: %s\n", outlineFile), nil
 	}
-	defer f.Close()
 
-	w := zlib.NewWriter(f)
-	_, err = w.Write(s.Content)
-	if err != nil {
-		return fmt.Errorf("stuff outline write: write file: %w", err)
+	if err := writeObject(s.Group, s.Hash, OBJECT_TYPE_OUTLINE, s.Content); err != nil {
+		return "", fmt.Errorf("stuff outline write: %w", err)
 	}
-	defer w.Close()
 
-	fmt.Printf("Stuff outline file written: %s\n", outlineFile)
-	return nil
+	return fmt.Sprintf("Stuff outline file written: %s\n", outlineFile), nil
 }
 
 type Stuff struct {
-	Hash     string
-	Group    string
+	Hash  string
+	Group string
+	// Object type of the stuff, either OBJECT_TYPE_RESOURCE or OBJECT_TYPE_DIARY
+	Type     string
 	Resource []byte
+	// Resource to be written as a tree, nil for diaries
+	tree *shared.MinerResource
 }
 
-// NewStuff creates a new Stuff from a plugin name and a MinerResource
+// NewStuff creates a new Stuff from a plugin name and a MinerResource or MinerDiary
 func NewStuff(group string, a any) (*Stuff, error) {
+	var objType string
+	var tree *shared.MinerResource
+	switch v := a.(type) {
+	case shared.MinerResource:
+		objType = OBJECT_TYPE_RESOURCE
+		tree = &v
+	case *shared.MinerResource:
+		objType = OBJECT_TYPE_RESOURCE
+		tree = v
+	case shared.MinerDiary, *shared.MinerDiary:
+		objType = OBJECT_TYPE_DIARY
+	default:
+		return nil, fmt.Errorf("new blob: unsupported stuff type %T", a)
+	}
+
 	b, err := json.Marshal(a)
 	if err != nil {
 		return nil, fmt.Errorf("new blob: marshal: %w", err)
 	}
 
-	h := sha256.New()
-	h.Write(b)
+	hash, err := objectHash(group, b)
+	if err != nil {
+		return nil, fmt.Errorf("new blob: %w", err)
+	}
 
 	return &Stuff{
-		Hash:     fmt.Sprintf("%x", h.Sum(nil)),
+		Hash:     hash,
 		Group:    group,
+		Type:     objType,
 		Resource: b,
+		tree:     tree,
 	}, nil
 }
 
 // Write writes the Stuff resource content to a file
 // func (s *Stuff) Write() error {
 func (s *Stuff) Write() (string, error) {
-	stuffFile, err := NewObjectRecord(s.Group, s.Hash).RecordFile()
+	record := NewObjectRecord(s.Group, s.Hash)
+	stuffFile, err := record.RecordFile()
 	if err != nil {
 		return "", fmt.Errorf("stuff write: %w", err)
 	}
-	if _, err := os.Stat(stuffFile); !errors.Is(err, os.ErrNotExist) {
+	if record.Exist() {
 		return fmt.Sprintf(
 				"Stuff file already exists: %s\n",
 				stuffFile,
@@ -130,23 +155,14 @@ func (s *Stuff) Write() (string, error) {
 		// return nil
 	}
 
-	err = os.MkdirAll(filepath.Dir(stuffFile), os.ModePerm)
-	if err != nil {
-		return "", fmt.Errorf("stuff write: mkdir: %w", err)
-	}
-
-	f, err := os.Create(stuffFile)
-	if err != nil {
-		return "", fmt.Errorf("stuff write: create file: %w", err)
-	}
-	defer f.Close()
-
-	w := zlib.NewWriter(f)
-	_, err = w.Write(s.Resource)
-	if err != nil {
-		return "", fmt.Errorf("stuff write: write file: %w", err)
+	// Resources are stored as a tree of property objects under the resource hash
+	if s.tree != nil {
+		if err := writeResourceTree(s.Group, s.Hash, s.tree); err != nil {
+			return "", fmt.Errorf("stuff write: %w", err)
+		}
+	} else if err := writeObject(s.Group, s.Hash, s.Type, s.Resource); err != nil {
+		return "", fmt.Errorf("stuff write: %w", err)
 	}
-	defer w.Close()
 
 	return fmt.Sprintf("Stuff file written: %s\n", stuffFile), nil
 	// fmt.Printf("Stuff file written: %s\n", stuffFile)
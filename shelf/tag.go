@@ -0,0 +1,128 @@
+package shelf
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/liuminhaw/mist-miner/locks"
+)
+
+type Tag struct {
+	Name string
+	// Hash of the tagged label mark
+	Mark string
+}
+
+// CreateTag creates a tag of given name under refs/tags pointing to the label mark hash.
+// Return ErrTagExists if the tag already exists, ErrInvalidTagName if name cannot be
+// used as a tag. Will use flock on references to prevent concurrent writing,
+// return locks.ErrIsLocked if file lock is not acquired.
+func CreateTag(group, name, markHash string) error {
+	if err := validTagName(name); err != nil {
+		return fmt.Errorf("CreateTag(%s, %s): %w", group, name, err)
+	}
+	if _, err := ReadMark(group, markHash); err != nil {
+		return fmt.Errorf("CreateTag(%s, %s): %w", group, name, err)
+	}
+
+	fileLock, err := locks.NewLock(group, locks.REF_MARK_LOCKFILE)
+	if err != nil {
+		return fmt.Errorf("CreateTag(%s, %s): %w", group, name, err)
+	}
+	if err := fileLock.TryLock(); err != nil {
+		if errors.Is(err, locks.ErrIsLocked) {
+			return err
+		}
+		return fmt.Errorf("CreateTag(%s, %s): %w", group, name, err)
+	}
+	defer fileLock.Unlock()
+
+	if exist, err := refExist(group, tagRef(name)); err != nil {
+		return fmt.Errorf("CreateTag(%s, %s): %w", group, name, err)
+	} else if exist {
+		return fmt.Errorf("CreateTag(%s, %s): %w", group, name, ErrTagExists)
+	}
+
+	tag := RefMark{Name: tagRef(name), Group: group, Reference: []byte(markHash)}
+	if err := tag.write(); err != nil {
+		return fmt.Errorf("CreateTag(%s, %s): %w", group, name, err)
+	}
+
+	return nil
+}
+
+// DeleteTag removes the tag of given name, return ErrTagNotFound if the tag does not exist.
+// Will use flock on references to prevent concurrent writing,
+// return locks.ErrIsLocked if file lock is not acquired.
+func DeleteTag(group, name string) error {
+	if err := validTagName(name); err != nil {
+		return fmt.Errorf("DeleteTag(%s, %s): %w", group, name, err)
+	}
+
+	fileLock, err := locks.NewLock(group, locks.REF_MARK_LOCKFILE)
+	if err != nil {
+		return fmt.Errorf("DeleteTag(%s, %s): %w", group, name, err)
+	}
+	if err := fileLock.TryLock(); err != nil {
+		if errors.Is(err, locks.ErrIsLocked) {
+			return err
+		}
+		return fmt.Errorf("DeleteTag(%s, %s): %w", group, name, err)
+	}
+	defer fileLock.Unlock()
+
+	if err := deleteRef(group, tagRef(name)); errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("DeleteTag(%s, %s): %w", group, name, ErrTagNotFound)
+	} else if err != nil {
+		return fmt.Errorf("DeleteTag(%s, %s): %w", group, name, err)
+	}
+
+	return nil
+}
+
+// ListTags returns all tags of the group sorted by name.
+func ListTags(group string) ([]Tag, error) {
+	refs, err := ListRefs(group)
+	if err != nil {
+		return nil, fmt.Errorf("ListTags(%s): %w", group, err)
+	}
+
+	tags := []Tag{}
+	for _, ref := range refs {
+		name, ok := strings.CutPrefix(ref, shelf_tag_dir+"/")
+		if !ok {
+			continue
+		}
+		mark, err := NewRefMark(ref, group)
+		if err != nil {
+			return nil, fmt.Errorf("ListTags(%s): %w", group, err)
+		}
+		tags = append(tags, Tag{Name: name, Mark: string(mark.Reference)})
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Name < tags[j].Name })
+
+	return tags, nil
+}
+
+// tagRef returns the reference name of the tag, ex. tags/quarterly-audit
+func tagRef(name string) string {
+	return path.Join(shelf_tag_dir, name)
+}
+
+// validTagName checks if name can be used as a tag, names which could be taken as
+// another revision form (HEAD, HEAD~N) or a path are not allowed.
+func validTagName(name string) error {
+	switch {
+	case name == "", name == SHELF_MARK_FILE:
+		return ErrInvalidTagName
+	case strings.HasPrefix(name, "."), strings.HasPrefix(name, "-"):
+		return ErrInvalidTagName
+	case strings.ContainsAny(name, "/\\~^: \t\n"):
+		return ErrInvalidTagName
+	}
+	return nil
+}
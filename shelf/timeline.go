@@ -0,0 +1,396 @@
+package shelf
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/liuminhaw/mist-miner/locks"
+)
+
+// Events of a resource in a label mark recorded in timeline
+const (
+	TIMELINE_EVENT_ADDED    = "added"
+	TIMELINE_EVENT_MODIFIED = "modified"
+	TIMELINE_EVENT_DIARY    = "diary"
+	TIMELINE_EVENT_REMOVED  = "removed"
+
+	// First line of a timeline file recording the identifier of the resource
+	timeline_identifier_prefix = "identifier "
+)
+
+// TimelineEntry is a change of a resource in a label mark
+type TimelineEntry struct {
+	Mark string
+	// Stuff outline of the resource in the mark, "nil" if the resource is removed
+	Outline string
+	Events  []string
+}
+
+// Timeline is the history of a resource across label marks, only marks where the
+// resource is added, removed, or its resource or diary hash changed are recorded.
+// Entries are sorted from the oldest to the newest.
+type Timeline struct {
+	Plugin     string
+	Identifier string
+	Entries    []TimelineEntry
+}
+
+type timelineKey struct {
+	plugin     string
+	identifier string
+}
+
+// ReadTimeline reads the timeline of a resource identified by plugin and identifier,
+// return ErrTimelineNotFound if the resource has no timeline.
+func ReadTimeline(group, plugin, identifier string) (Timeline, error) {
+	recorded, entries, err := readTimelineFile(group, timelineFile(plugin, identifier))
+	if errors.Is(err, fs.ErrNotExist) {
+		return Timeline{}, ErrTimelineNotFound
+	} else if err != nil {
+		return Timeline{}, fmt.Errorf("ReadTimeline(%s, %s): %w", plugin, identifier, err)
+	}
+	if recorded != identifier {
+		return Timeline{}, ErrTimelineNotFound
+	}
+
+	return Timeline{Plugin: plugin, Identifier: identifier, Entries: entries}, nil
+}
+
+// FindTimelines returns timelines of resources with the identifier in every plugin,
+// sorted by plugin name. Return ErrTimelineNotFound if no plugin has the resource.
+func FindTimelines(group, identifier string) ([]Timeline, error) {
+	names, err := listRefs(group, timelineDir())
+	if err != nil {
+		return nil, fmt.Errorf("FindTimelines(%s): %w", identifier, err)
+	}
+
+	timelines := []Timeline{}
+	for _, name := range names {
+		plugin, file := path.Split(strings.TrimPrefix(name, timelineDir()+"/"))
+		if plugin == "" || file != timelineFileName(identifier) {
+			continue
+		}
+		timeline, err := ReadTimeline(group, strings.TrimSuffix(plugin, "/"), identifier)
+		if err != nil {
+			return nil, fmt.Errorf("FindTimelines(%s): %w", identifier, err)
+		}
+		timelines = append(timelines, timeline)
+	}
+	if len(timelines) == 0 {
+		return nil, ErrTimelineNotFound
+	}
+
+	return timelines, nil
+}
+
+// UpdateTimeline appends resource changes of the label mark compared with its parent
+// to the timeline index of the group. The whole index is generated if it does not exist
+// yet or is in the layout named by encoded identifiers, for shelves mined before.
+// Will use flock to prevent writing simultaneously. Return locks.ErrIsLocked if file lock is not acquired.
+func UpdateTimeline(group, markHash string) error {
+	timelineLock, err := locks.NewLock(group, locks.HISTORY_TIMELINE_LOCKFILE)
+	if err != nil {
+		return fmt.Errorf("UpdateTimeline(%s): %w", group, err)
+	}
+	if err := timelineLock.TryLock(); err != nil {
+		if errors.Is(err, locks.ErrIsLocked) {
+			return err
+		}
+		return fmt.Errorf("UpdateTimeline(%s): %w", group, err)
+	}
+	defer timelineLock.Unlock()
+
+	names, err := listRefs(group, timelineDir())
+	if err != nil {
+		return fmt.Errorf("UpdateTimeline(%s): %w", group, err)
+	}
+	legacy, err := legacyTimelines(group, names)
+	if err != nil {
+		return fmt.Errorf("UpdateTimeline(%s): %w", group, err)
+	}
+	if len(names) == 0 || legacy {
+		if err := writeTimelines(group, markHash); err != nil {
+			return fmt.Errorf("UpdateTimeline(%s): %w", group, err)
+		}
+		return nil
+	}
+
+	mark, err := ReadMark(group, markHash)
+	if err != nil {
+		return fmt.Errorf("UpdateTimeline(%s): %w", group, err)
+	}
+	prev, err := readMarkResources(group, mark.Parent)
+	if err != nil {
+		return fmt.Errorf("UpdateTimeline(%s): %w", group, err)
+	}
+	curr, err := readMarkResources(group, markHash)
+	if err != nil {
+		return fmt.Errorf("UpdateTimeline(%s): %w", group, err)
+	}
+
+	for key, entry := range timelineChanges(markHash, prev, curr) {
+		file := timelineFile(key.plugin, key.identifier)
+		_, entries, err := readTimelineFile(group, file)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("UpdateTimeline(%s): %w", group, err)
+		}
+		// Skip if the mark is already recorded
+		if len(entries) > 0 && entries[len(entries)-1].Mark == markHash {
+			continue
+		}
+		if err := writeTimelineFile(group, file, key.identifier, append(entries, entry)); err != nil {
+			return fmt.Errorf("UpdateTimeline(%s): %w", group, err)
+		}
+	}
+
+	return nil
+}
+
+// GenerateTimeline regenerates the timeline index of the group from the label mark chain of HEAD.
+// Will use flock to prevent writing simultaneously. Return locks.ErrIsLocked if file lock is not acquired.
+func GenerateTimeline(group string) error {
+	head, err := NewRefMark(SHELF_MARK_FILE, group)
+	if err != nil {
+		return fmt.Errorf("GenerateTimeline(%s): %w", group, err)
+	}
+
+	timelineLock, err := locks.NewLock(group, locks.HISTORY_TIMELINE_LOCKFILE)
+	if err != nil {
+		return fmt.Errorf("GenerateTimeline(%s): %w", group, err)
+	}
+	if err := timelineLock.TryLock(); err != nil {
+		if errors.Is(err, locks.ErrIsLocked) {
+			return err
+		}
+		return fmt.Errorf("GenerateTimeline(%s): %w", group, err)
+	}
+	defer timelineLock.Unlock()
+
+	if err := writeTimelines(group, string(head.Reference)); err != nil {
+		return fmt.Errorf("GenerateTimeline(%s): %w", group, err)
+	}
+
+	return nil
+}
+
+// writeTimelines replaces the timeline index with timelines built from the label mark
+// chain starting from reference.
+func writeTimelines(group, reference string) error {
+	timelines, err := buildTimelines(group, reference)
+	if err != nil {
+		return fmt.Errorf("write timelines: %w", err)
+	}
+
+	if err := deleteRefs(group, timelineDir()); err != nil {
+		return fmt.Errorf("write timelines: %w", err)
+	}
+
+	for key, entries := range timelines {
+		if err := writeTimelineFile(group, timelineFile(key.plugin, key.identifier), key.identifier, entries); err != nil {
+			return fmt.Errorf("write timelines: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// buildTimelines walks the label mark chain from the root to reference and
+// returns the timeline entries of every resource ever appeared.
+func buildTimelines(group, reference string) (map[timelineKey][]TimelineEntry, error) {
+	chain := []string{}
+	for hash := reference; hash != "nil"; {
+		mark, err := ReadMark(group, hash)
+		if err != nil {
+			return nil, fmt.Errorf("build timelines: %w", err)
+		}
+		chain = append(chain, hash)
+		hash = mark.Parent
+	}
+
+	timelines := make(map[timelineKey][]TimelineEntry)
+	prev := make(map[string]map[string]markResource)
+	for i := len(chain) - 1; i >= 0; i-- {
+		curr, err := readMarkResources(group, chain[i])
+		if err != nil {
+			return nil, fmt.Errorf("build timelines: %w", err)
+		}
+		for key, entry := range timelineChanges(chain[i], prev, curr) {
+			timelines[key] = append(timelines[key], entry)
+		}
+		prev = curr
+	}
+
+	return timelines, nil
+}
+
+// timelineChanges compares resources of a label mark with resources of its parent
+// and returns the timeline entry of each changed resource.
+func timelineChanges(
+	markHash string,
+	prev, curr map[string]map[string]markResource,
+) map[timelineKey]TimelineEntry {
+	changes := make(map[timelineKey]TimelineEntry)
+
+	for plugin, resources := range curr {
+		for id, res := range resources {
+			entry := TimelineEntry{Mark: markHash, Outline: res.outlineHash, Events: []string{}}
+			prevRes, ok := prev[plugin][id]
+			if !ok {
+				entry.Events = append(entry.Events, TIMELINE_EVENT_ADDED)
+			} else {
+				if prevRes.resourceHash != res.resourceHash {
+					entry.Events = append(entry.Events, TIMELINE_EVENT_MODIFIED)
+				}
+				if prevRes.diaryHash != res.diaryHash {
+					entry.Events = append(entry.Events, TIMELINE_EVENT_DIARY)
+				}
+			}
+			if len(entry.Events) > 0 {
+				changes[timelineKey{plugin: plugin, identifier: id}] = entry
+			}
+		}
+	}
+	for plugin, resources := range prev {
+		for id := range resources {
+			if _, ok := curr[plugin][id]; ok {
+				continue
+			}
+			changes[timelineKey{plugin: plugin, identifier: id}] = TimelineEntry{
+				Mark:    markHash,
+				Outline: "nil",
+				Events:  []string{TIMELINE_EVENT_REMOVED},
+			}
+		}
+	}
+
+	return changes
+}
+
+// encodeTimeline encodes the quoted identifier in the first line, followed by entries
+// in lines of format: <mark> <outline> <event,...>
+func encodeTimeline(identifier string, entries []TimelineEntry) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s%s\n", timeline_identifier_prefix, strconv.Quote(identifier))
+	for _, entry := range entries {
+		fmt.Fprintf(&buf, "%s %s %s\n", entry.Mark, entry.Outline, strings.Join(entry.Events, ","))
+	}
+	return buf.Bytes()
+}
+
+// readTimelineFile returns the identifier and entries of the timeline file, identifier
+// is empty for files written before identifiers are recorded.
+func readTimelineFile(group, name string) (string, []TimelineEntry, error) {
+	content, err := readCompressedRef(group, name)
+	if err != nil {
+		return "", nil, fmt.Errorf("read timeline file: %w", err)
+	}
+
+	identifier := ""
+	entries := []TimelineEntry{}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for line := 0; scanner.Scan(); line++ {
+		if quoted, ok := strings.CutPrefix(scanner.Text(), timeline_identifier_prefix); ok && line == 0 {
+			if identifier, err = strconv.Unquote(quoted); err != nil {
+				return "", nil, fmt.Errorf("read timeline file: invalid identifier: %s", quoted)
+			}
+			continue
+		}
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 3 {
+			return "", nil, fmt.Errorf("read timeline file: invalid entry: %s", scanner.Text())
+		}
+		entries = append(entries, TimelineEntry{
+			Mark:    fields[0],
+			Outline: fields[1],
+			Events:  strings.Split(fields[2], ","),
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return "", nil, fmt.Errorf("read timeline file: %w", err)
+	}
+
+	return identifier, entries, nil
+}
+
+func writeTimelineFile(group, name, identifier string, entries []TimelineEntry) error {
+	if err := writeCompressedRef(group, name, encodeTimeline(identifier, entries)); err != nil {
+		return fmt.Errorf("write timeline file: %w", err)
+	}
+	return nil
+}
+
+// readTimelineFiles returns decompressed content of all timeline files of the group,
+// keyed by file name relative to the timeline directory.
+func readTimelineFiles(group string) (map[string][]byte, error) {
+	names, err := listRefs(group, timelineDir())
+	if err != nil {
+		return nil, fmt.Errorf("readTimelineFiles(%s): %w", group, err)
+	}
+
+	files := make(map[string][]byte)
+	for _, name := range names {
+		identifier, entries, err := readTimelineFile(group, name)
+		if err != nil {
+			return nil, fmt.Errorf("readTimelineFiles(%s): %w", group, err)
+		}
+		files[strings.TrimPrefix(name, timelineDir()+"/")] = encodeTimeline(identifier, entries)
+	}
+
+	return files, nil
+}
+
+// legacyTimelines checks if the timeline files of given names are written before
+// identifiers are recorded, files were named by the encoded identifier. Every file
+// is written in the same layout, so only the first one is read.
+func legacyTimelines(group string, names []string) (bool, error) {
+	if len(names) == 0 {
+		return false, nil
+	}
+	identifier, _, err := readTimelineFile(group, names[0])
+	if err != nil {
+		return false, fmt.Errorf("legacy timelines: %w", err)
+	}
+	return identifier == "", nil
+}
+
+// timelineDir returns the reference directory storing the timeline index
+func timelineDir() string {
+	return path.Join(shelf_history_dir, shelf_history_timeline_dir)
+}
+
+// timelineFile returns the reference name of the timeline of a resource. Files are named by
+// the sha256 of the identifier, since identifiers can contain characters not allowed in
+// file names or exceed the file name length limit, ex. ARNs. The identifier is recorded
+// in the file.
+func timelineFile(plugin, identifier string) string {
+	return path.Join(timelineDir(), plugin, timelineFileName(identifier))
+}
+
+func timelineFileName(identifier string) string {
+	sum := sha256.Sum256([]byte(identifier))
+	return hex.EncodeToString(sum[:])
+}
+
+// sortedTimelineKeys returns keys of timelines sorted by plugin and identifier
+func sortedTimelineKeys(timelines map[timelineKey][]TimelineEntry) []timelineKey {
+	keys := make([]timelineKey, 0, len(timelines))
+	for key := range timelines {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].plugin == keys[j].plugin {
+			return keys[i].identifier < keys[j].identifier
+		}
+		return keys[i].plugin < keys[j].plugin
+	})
+	return keys
+}
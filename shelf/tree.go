@@ -0,0 +1,165 @@
+package shelf
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/liuminhaw/mist-miner/shared"
+)
+
+// ResourceTree is the manifest of a resource stored as a tree. Content of each
+// property is stored as a separate property object so identical values are shared
+// across resources and label marks, the manifest lists property hashes in order.
+//
+// A tree is stored under the hash of the resource json it represents, so outlines
+// refer to resources the same way regardless of how they are stored. Reading the
+// tree object by RecordReadCloser gives the resource json back.
+type ResourceTree struct {
+	Identifier string         `json:"identifier"`
+	Alias      string         `json:"alias"`
+	LogType    string         `json:"logType"`
+	Properties []TreeProperty `json:"properties"`
+}
+
+// TreeProperty is a property entry of ResourceTree, Hash is the property object
+// holding the property content.
+type TreeProperty struct {
+	Type  string                    `json:"type"`
+	Label shared.MinerPropertyLabel `json:"label"`
+	Hash  string                    `json:"hash"`
+}
+
+// propertyObject is the payload and hash of a property object
+type propertyObject struct {
+	Hash    string
+	Payload []byte
+}
+
+// newResourceTree splits the resource into the tree manifest payload and
+// property objects of its contents.
+func newResourceTree(group string, resource *shared.MinerResource) ([]byte, []propertyObject, error) {
+	tree := ResourceTree{
+		Identifier: resource.Identifier,
+		Alias:      resource.Alias,
+		LogType:    resource.LogType,
+	}
+	// Keep a nil property list nil so the resource json is loaded back as it is
+	if resource.Properties != nil {
+		tree.Properties = []TreeProperty{}
+	}
+	properties := []propertyObject{}
+	for _, prop := range resource.Properties {
+		payload, err := json.Marshal(prop.Content)
+		if err != nil {
+			return nil, nil, fmt.Errorf("new resource tree: marshal property: %w", err)
+		}
+		hash, err := objectHash(group, payload)
+		if err != nil {
+			return nil, nil, fmt.Errorf("new resource tree: %w", err)
+		}
+
+		tree.Properties = append(tree.Properties, TreeProperty{
+			Type:  prop.Type,
+			Label: prop.Label,
+			Hash:  hash,
+		})
+		properties = append(properties, propertyObject{Hash: hash, Payload: payload})
+	}
+
+	manifest, err := json.Marshal(tree)
+	if err != nil {
+		return nil, nil, fmt.Errorf("new resource tree: marshal: %w", err)
+	}
+	return manifest, properties, nil
+}
+
+// writeResourceTree writes property objects of the resource which do not exist yet,
+// then the tree manifest as the object of given hash. Properties are written first so a tree never
+// refers to missing property objects.
+func writeResourceTree(group, hash string, resource *shared.MinerResource) error {
+	manifest, properties, err := newResourceTree(group, resource)
+	if err != nil {
+		return fmt.Errorf("write resource tree: %w", err)
+	}
+
+	for _, prop := range properties {
+		record := NewObjectRecord(group, prop.Hash)
+		if record.Exist() {
+			continue
+		}
+		if err := writeObject(group, prop.Hash, OBJECT_TYPE_PROPERTY, prop.Payload); err != nil {
+			return fmt.Errorf("write resource tree: %w", err)
+		}
+	}
+
+	if err := writeObject(group, hash, OBJECT_TYPE_TREE, manifest); err != nil {
+		return fmt.Errorf("write resource tree: %w", err)
+	}
+	return nil
+}
+
+// ReadResourceTree reads the tree manifest object of the given group and hash.
+func ReadResourceTree(group, hash string) (*ResourceTree, error) {
+	_, r, err := NewObjectRecord(group, hash).ObjectReadCloser()
+	if err != nil {
+		return nil, fmt.Errorf("read resource tree: %w", err)
+	}
+	defer r.Close()
+
+	tree := ResourceTree{}
+	if err := json.NewDecoder(r).Decode(&tree); err != nil {
+		return nil, fmt.Errorf("read resource tree: decode: %w", err)
+	}
+	return &tree, nil
+}
+
+// ReadPropertyContent reads the property object of the given group and hash.
+func ReadPropertyContent(group, hash string) (*shared.MinerPropertyContent, error) {
+	r, err := NewObjectRecord(group, hash).RecordReadCloser()
+	if err != nil {
+		return nil, fmt.Errorf("read property content: %w", err)
+	}
+	defer r.Close()
+
+	content := shared.MinerPropertyContent{}
+	if err := json.NewDecoder(r).Decode(&content); err != nil {
+		return nil, fmt.Errorf("read property content: decode: %w", err)
+	}
+	return &content, nil
+}
+
+// loadResourceTree assembles the resource json from the tree manifest read from r,
+// the result is encoded the same way as NewStuff so it hashes to the tree hash.
+func loadResourceTree(group string, r io.Reader) ([]byte, error) {
+	tree := ResourceTree{}
+	if err := json.NewDecoder(r).Decode(&tree); err != nil {
+		return nil, fmt.Errorf("load resource tree: decode: %w", err)
+	}
+
+	resource := shared.MinerResource{
+		Identifier: tree.Identifier,
+		Alias:      tree.Alias,
+		LogType:    tree.LogType,
+	}
+	if tree.Properties != nil {
+		resource.Properties = []shared.MinerProperty{}
+	}
+	for _, prop := range tree.Properties {
+		content, err := ReadPropertyContent(group, prop.Hash)
+		if err != nil {
+			return nil, fmt.Errorf("load resource tree: %w", err)
+		}
+		resource.Properties = append(resource.Properties, shared.MinerProperty{
+			Type:    prop.Type,
+			Label:   prop.Label,
+			Content: *content,
+		})
+	}
+
+	b, err := json.Marshal(&resource)
+	if err != nil {
+		return nil, fmt.Errorf("load resource tree: marshal: %w", err)
+	}
+	return b, nil
+}
@@ -0,0 +1,436 @@
+package shelf
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/liuminhaw/mist-miner/locks"
+	"github.com/liuminhaw/mist-miner/shared"
+)
+
+type UpgradeResult struct {
+	From Format
+	To   Format
+	// Reachable objects rewritten in the target format, or to be rewritten in dry run
+	Rewritten int
+	// Objects removed after rewriting, unreachable objects are dropped as well
+	Removed int
+	// References pointed to the rewritten label marks
+	Refs []string
+}
+
+// UpgradeShelf rewrites the objects of the group reachable from any reference in the
+// target format. Objects are rewritten from the oldest label mark, each object is
+// encoded the same way with the hashes of the objects it refers to replaced by their
+// rewritten hashes, so label marks keep their timestamps, log types and parents and
+// diaries keep their log links. References are then moved to the rewritten label
+// marks and the target format is recorded in FORMAT, an interrupted upgrade can be
+// run again. Objects not written in the target format, including unreachable and
+// packed objects, are removed at last with signatures of the old label marks. Rewritten
+// label marks are signed again if they were signed and a signing key is set, so unsigned
+// label marks never become signed by upgrading. Nothing is rewritten unless signatures of
+// signed label marks verify against the trusted keys and every reachable object hashes to
+// its hash, return ErrUnverifiedMark otherwise. History records, timelines and the
+// search index refer to old hashes and have to be regenerated. Nothing is written if dryRun is set.
+// Will use flock on objects to prevent racing with mining,
+// return locks.ErrIsLocked if file lock is not acquired.
+func UpgradeShelf(group string, target Format, dryRun bool) (UpgradeResult, error) {
+	objFileLock, err := locks.NewLock("", locks.OBJECTS_LOCKFILE)
+	if err != nil {
+		return UpgradeResult{}, fmt.Errorf("UpgradeShelf(%s): %w", group, err)
+	}
+	if err := objFileLock.TryLock(); err != nil {
+		if errors.Is(err, locks.ErrIsLocked) {
+			return UpgradeResult{}, err
+		}
+		return UpgradeResult{}, fmt.Errorf("UpgradeShelf(%s): %w", group, err)
+	}
+	defer objFileLock.Unlock()
+
+	if err := target.validate(); err != nil {
+		return UpgradeResult{}, fmt.Errorf("UpgradeShelf(%s): %w", group, err)
+	}
+	current, err := ReadFormat(group)
+	if err != nil {
+		return UpgradeResult{}, fmt.Errorf("UpgradeShelf(%s): %w", group, err)
+	}
+	result := UpgradeResult{From: current, To: target, Refs: []string{}}
+
+	// Objects are encoded the same way in both formats, only FORMAT is recorded
+	if current.Compression == target.Compression && current.Hash == target.Hash {
+		if current.Version != target.Version && !dryRun {
+			if err := writeFormat(group, target); err != nil {
+				return result, fmt.Errorf("UpgradeShelf(%s): %w", group, err)
+			}
+		}
+		return result, nil
+	}
+
+	// Any missing object stops the walk, the rewritten shelf would be missing it too
+	walker := newObjectWalker(group)
+	if err := walker.walkRefs(); err != nil {
+		return result, fmt.Errorf("UpgradeShelf(%s): %w", group, err)
+	}
+	refs, err := ListRefs(group)
+	if err != nil {
+		return result, fmt.Errorf("UpgradeShelf(%s): %w", group, err)
+	}
+	// Checked in the current format, before signing content altered after signing
+	if err := verifySignedMarks(group, walker); err != nil {
+		return result, fmt.Errorf("UpgradeShelf(%s): %w", group, err)
+	}
+
+	// Hashes and objects written from now on are of the target format
+	useFormat(group, target)
+	u := newUpgrader(group, dryRun)
+	marks := make(map[string]string, len(refs))
+	for _, ref := range refs {
+		mark, err := NewRefMark(ref, group)
+		if err != nil {
+			forgetFormat(group)
+			return result, fmt.Errorf("UpgradeShelf(%s): %w", group, err)
+		}
+		marks[ref], err = u.markChain(string(mark.Reference))
+		if err != nil {
+			forgetFormat(group)
+			return result, fmt.Errorf("UpgradeShelf(%s): %w", group, err)
+		}
+	}
+	result.Rewritten = len(u.hashes)
+	if dryRun {
+		forgetFormat(group)
+		result.Refs = refs
+		return result, nil
+	}
+
+	for _, ref := range refs {
+		mark := RefMark{Name: ref, Group: group, Reference: []byte(marks[ref])}
+		if err := mark.write(); err != nil {
+			forgetFormat(group)
+			return result, fmt.Errorf("UpgradeShelf(%s): %w", group, err)
+		}
+		result.Refs = append(result.Refs, ref)
+	}
+	if err := writeFormat(group, target); err != nil {
+		forgetFormat(group)
+		return result, fmt.Errorf("UpgradeShelf(%s): %w", group, err)
+	}
+
+	written := make(map[string]bool, len(u.hashes))
+	for _, hash := range u.hashes {
+		written[hash] = true
+	}
+	objects, err := listLooseObjects(group)
+	if err != nil {
+		return result, fmt.Errorf("UpgradeShelf(%s): %w", group, err)
+	}
+	for _, object := range objects {
+		if written[object.Hash] {
+			continue
+		}
+		if err := deleteObject(group, object.Hash); err != nil {
+			return result, fmt.Errorf("UpgradeShelf(%s): %w", group, err)
+		}
+		result.Removed++
+	}
+	packs, err := loadPackIndexes(group)
+	if err != nil {
+		return result, fmt.Errorf("UpgradeShelf(%s): %w", group, err)
+	}
+	for _, idx := range packs {
+		if err := removePack(idx); err != nil {
+			return result, fmt.Errorf("UpgradeShelf(%s): %w", group, err)
+		}
+		result.Removed += len(idx.entries)
+	}
+	rewritten := func(hash string) bool {
+		return written[hash]
+	}
+	if err := pruneSignatures(group, rewritten); err != nil {
+		return result, fmt.Errorf("UpgradeShelf(%s): %w", group, err)
+	}
+
+	return result, nil
+}
+
+// upgrader rewrites objects of a group in the format in use for the group, hashes
+// maps hashes of rewritten objects to their hashes in the new format.
+type upgrader struct {
+	group  string
+	dryRun bool
+	hashes map[string]string
+}
+
+func newUpgrader(group string, dryRun bool) *upgrader {
+	return &upgrader{group: group, dryRun: dryRun, hashes: make(map[string]string)}
+}
+
+// markChain rewrites the label mark of given hash with its ancestors, from the oldest
+// not yet rewritten one so parents are always rewritten before their children. The
+// rewritten hash of the label mark is returned.
+func (u *upgrader) markChain(hash string) (string, error) {
+	chain := []*LabelMark{}
+	for hash != "" && hash != "nil" {
+		if _, ok := u.hashes[hash]; ok {
+			break
+		}
+		mark, err := ReadMark(u.group, hash)
+		if err != nil {
+			return "", fmt.Errorf("upgrade mark chain: %w", err)
+		}
+		chain = append(chain, mark)
+		hash = mark.Parent
+	}
+
+	for i := len(chain) - 1; i >= 0; i-- {
+		if err := u.mark(chain[i]); err != nil {
+			return "", fmt.Errorf("upgrade mark chain: %w", err)
+		}
+	}
+	if len(chain) == 0 {
+		return u.hashes[hash], nil
+	}
+	return u.hashes[chain[0].Hash], nil
+}
+
+func (u *upgrader) mark(mark *LabelMark) error {
+	upgraded := LabelMark{
+		TimeStamp: mark.TimeStamp,
+		LogType:   mark.LogType,
+		Parent:    u.mapped(mark.Parent),
+		Mappings:  make([]MarkMapping, 0, len(mark.Mappings)),
+		Group:     u.group,
+	}
+	for _, m := range mark.Mappings {
+		hash, err := u.idMaps(m.Hash)
+		if err != nil {
+			return fmt.Errorf("upgrade mark %s: %w", mark.Hash, err)
+		}
+		upgraded.Mappings = append(upgraded.Mappings, MarkMapping{Module: m.Module, Hash: hash, Version: m.Version})
+	}
+	if err := upgraded.calcHash(); err != nil {
+		return fmt.Errorf("upgrade mark %s: %w", mark.Hash, err)
+	}
+
+	if err := u.write(mark.Hash, upgraded.Hash, OBJECT_TYPE_MARK, upgraded.buffer.Bytes()); err != nil {
+		return err
+	}
+	if u.dryRun {
+		return nil
+	}
+	signed, err := refExist(u.group, signatureName(mark.Hash))
+	if err != nil {
+		return fmt.Errorf("upgrade mark %s: %w", mark.Hash, err)
+	}
+	if signed {
+		if err := signMark(u.group, upgraded.Hash); err != nil {
+			return fmt.Errorf("upgrade mark %s: %w", mark.Hash, err)
+		}
+	}
+	return nil
+}
+
+// verifySignedMarks checks signatures of label marks reached by the walker against the
+// trusted keys. If any label mark is signed, every reached object is re-hashed as well
+// since the signatures only cover hashes of label marks.
+func verifySignedMarks(group string, w *objectWalker) error {
+	signed := false
+	for hash, kind := range w.seen {
+		if kind != OBJECT_TYPE_MARK {
+			continue
+		}
+		verification, err := verifyMark(group, hash)
+		if err != nil {
+			return fmt.Errorf("verify signed marks: %w", err)
+		}
+		switch verification.Status {
+		case MARK_SIGNED:
+			signed = true
+		case MARK_UNSIGNED:
+		default:
+			return fmt.Errorf("verify signed marks: label mark %s: %s: %w", hash, verification.Status, ErrUnverifiedMark)
+		}
+	}
+	if !signed {
+		return nil
+	}
+
+	for hash := range w.seen {
+		if _, issue, ok := checkObjectContent(group, hash); !ok {
+			return fmt.Errorf("verify signed marks: object %s: %s: %w", hash, issue.Detail, ErrUnverifiedMark)
+		}
+	}
+	return nil
+}
+
+func (u *upgrader) idMaps(hash string) (string, error) {
+	if upgraded, ok := u.hashes[hash]; ok {
+		return upgraded, nil
+	}
+	idHashMaps, err := ReadIdentifierHashMaps(u.group, hash)
+	if err != nil {
+		return "", fmt.Errorf("upgrade identifier hash maps %s: %w", hash, err)
+	}
+
+	upgraded := IdentifierHashMaps{Group: u.group, Maps: make([]IdentifierHashMap, 0, len(idHashMaps.Maps))}
+	for _, m := range idHashMaps.Maps {
+		outlineHash, err := u.outline(m.Hash)
+		if err != nil {
+			return "", fmt.Errorf("upgrade identifier hash maps %s: %w", hash, err)
+		}
+		m.Hash = outlineHash
+		upgraded.Maps = append(upgraded.Maps, m)
+	}
+	if err := upgraded.calcHash(); err != nil {
+		return "", fmt.Errorf("upgrade identifier hash maps %s: %w", hash, err)
+	}
+
+	if err := u.write(hash, upgraded.Hash, OBJECT_TYPE_IDMAPS, upgraded.buffer.Bytes()); err != nil {
+		return "", err
+	}
+	return upgraded.Hash, nil
+}
+
+func (u *upgrader) outline(hash string) (string, error) {
+	if upgraded, ok := u.hashes[hash]; ok {
+		return upgraded, nil
+	}
+	outline, err := ReadStuffOutline(u.group, hash)
+	if err != nil {
+		return "", fmt.Errorf("upgrade stuff outline %s: %w", hash, err)
+	}
+
+	resourceHash, err := u.resource(outline.ResourceHash)
+	if err != nil {
+		return "", fmt.Errorf("upgrade stuff outline %s: %w", hash, err)
+	}
+	diaryHash, err := u.diary(outline.DiaryHash)
+	if err != nil {
+		return "", fmt.Errorf("upgrade stuff outline %s: %w", hash, err)
+	}
+	upgraded, err := NewStuffOutline(u.group, resourceHash, diaryHash)
+	if err != nil {
+		return "", fmt.Errorf("upgrade stuff outline %s: %w", hash, err)
+	}
+
+	if err := u.write(hash, upgraded.Hash, OBJECT_TYPE_OUTLINE, upgraded.Content); err != nil {
+		return "", err
+	}
+	return upgraded.Hash, nil
+}
+
+// resource rewrites a resource blob as it is, and a resource tree with its property
+// objects under the hash of the resource it represents.
+func (u *upgrader) resource(hash string) (string, error) {
+	if upgraded, ok := u.hashes[hash]; ok {
+		return upgraded, nil
+	}
+	header, err := NewObjectRecord(u.group, hash).RecordHeader()
+	if err != nil {
+		return "", fmt.Errorf("upgrade resource %s: %w", hash, err)
+	}
+	if header.Type != OBJECT_TYPE_TREE {
+		return u.payload(hash, OBJECT_TYPE_RESOURCE)
+	}
+
+	tree, err := ReadResourceTree(u.group, hash)
+	if err != nil {
+		return "", fmt.Errorf("upgrade resource %s: %w", hash, err)
+	}
+	resource, err := ReadResource(u.group, hash)
+	if err != nil {
+		return "", fmt.Errorf("upgrade resource %s: %w", hash, err)
+	}
+	stuff, err := NewStuff(u.group, resource)
+	if err != nil {
+		return "", fmt.Errorf("upgrade resource %s: %w", hash, err)
+	}
+	manifest, properties, err := newResourceTree(u.group, resource)
+	if err != nil {
+		return "", fmt.Errorf("upgrade resource %s: %w", hash, err)
+	}
+	for i, prop := range properties {
+		if err := u.write(tree.Properties[i].Hash, prop.Hash, OBJECT_TYPE_PROPERTY, prop.Payload); err != nil {
+			return "", err
+		}
+	}
+
+	if err := u.write(hash, stuff.Hash, OBJECT_TYPE_TREE, manifest); err != nil {
+		return "", err
+	}
+	return stuff.Hash, nil
+}
+
+// diary rewrites the MinerDiary object and its diary note, log links to label marks
+// which are rewritten already are replaced by their rewritten hashes.
+func (u *upgrader) diary(hash string) (string, error) {
+	if upgraded, ok := u.hashes[hash]; ok {
+		return upgraded, nil
+	}
+	diary, err := ReadMinerDiary(u.group, hash)
+	if err != nil {
+		return "", fmt.Errorf("upgrade diary %s: %w", hash, err)
+	}
+
+	noteHash := diary.Hash
+	if noteHash != "" {
+		if noteHash, err = u.payload(diary.Hash, OBJECT_TYPE_NOTE); err != nil {
+			return "", fmt.Errorf("upgrade diary %s: %w", hash, err)
+		}
+	}
+	upgraded := shared.NewMinerDiary(noteHash, u.mapped(diary.Logs.Curr), u.mapped(diary.Logs.Prev))
+	stuff, err := NewStuff(u.group, &upgraded)
+	if err != nil {
+		return "", fmt.Errorf("upgrade diary %s: %w", hash, err)
+	}
+
+	if err := u.write(hash, stuff.Hash, OBJECT_TYPE_DIARY, stuff.Resource); err != nil {
+		return "", err
+	}
+	return stuff.Hash, nil
+}
+
+// payload rewrites an object without references to other objects as it is
+func (u *upgrader) payload(hash, objType string) (string, error) {
+	if upgraded, ok := u.hashes[hash]; ok {
+		return upgraded, nil
+	}
+	payload, err := readObjectPayload(u.group, hash)
+	if err != nil {
+		return "", fmt.Errorf("upgrade %s %s: %w", objType, hash, err)
+	}
+	upgraded, err := objectHash(u.group, payload)
+	if err != nil {
+		return "", fmt.Errorf("upgrade %s %s: %w", objType, hash, err)
+	}
+
+	if err := u.write(hash, upgraded, objType, payload); err != nil {
+		return "", err
+	}
+	return upgraded, nil
+}
+
+// write writes the rewritten object unless it is written already or in dry run,
+// and records its hash mapping.
+func (u *upgrader) write(hash, upgraded, objType string, payload []byte) error {
+	if _, ok := u.hashes[hash]; ok {
+		return nil
+	}
+	if !u.dryRun {
+		if err := writeObject(u.group, upgraded, objType, payload); err != nil {
+			return fmt.Errorf("upgrade %s %s: %w", objType, hash, err)
+		}
+	}
+	u.hashes[hash] = upgraded
+
+	return nil
+}
+
+// mapped returns the rewritten hash of the object, or the hash as it is if the
+// object is not rewritten, ex. "nil" parent of the first label mark.
+func (u *upgrader) mapped(hash string) string {
+	if upgraded, ok := u.hashes[hash]; ok {
+		return upgraded
+	}
+	return hash
+}
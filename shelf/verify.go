@@ -0,0 +1,100 @@
+package shelf
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/liuminhaw/mist-miner/locks"
+)
+
+type VerifyReport struct {
+	// Signature status of the label mark and its ancestors, newest first
+	Marks []MarkVerification
+	// Objects reachable from the label mark which are re-hashed
+	Objects int
+	Issues  []FsckIssue
+}
+
+// VerifyMark checks signatures of the label mark of given hash and its ancestors against
+// the trusted keys, then re-hashes every object reachable from the label mark the same
+// way as Fsck. Signatures only cover hashes of label marks, the snapshot is proved
+// unaltered if every mark is signed and no issue is found.
+// Will use flock on objects to prevent mining while checking,
+// return locks.ErrIsLocked if file lock is not acquired.
+func VerifyMark(group, hash string) (VerifyReport, error) {
+	objFileLock, err := locks.NewLock("", locks.OBJECTS_LOCKFILE)
+	if err != nil {
+		return VerifyReport{}, fmt.Errorf("VerifyMark(%s, %s): %w", group, hash, err)
+	}
+	if err := objFileLock.TryRLock(); err != nil {
+		if errors.Is(err, locks.ErrIsLocked) {
+			return VerifyReport{}, err
+		}
+		return VerifyReport{}, fmt.Errorf("VerifyMark(%s, %s): %w", group, hash, err)
+	}
+	defer objFileLock.Unlock()
+
+	report := VerifyReport{Marks: []MarkVerification{}, Issues: []FsckIssue{}}
+
+	walker := newObjectWalker(group)
+	walker.missing = func(missing, kind, referrer string) error {
+		problem := FSCK_DANGLING_REFERENCE
+		if kind == OBJECT_TYPE_MARK {
+			problem = FSCK_MISSING_PARENT
+		}
+		report.Issues = append(report.Issues, FsckIssue{
+			Target:  missing,
+			Problem: problem,
+			Detail:  fmt.Sprintf("%s object referenced by %s does not exist", kind, referrer),
+		})
+		return nil
+	}
+	walker.broken = func(broken, kind string, err error) error {
+		report.Issues = append(report.Issues, FsckIssue{
+			Target:  broken,
+			Problem: FSCK_BROKEN_OBJECT,
+			Detail:  fmt.Sprintf("cannot parse %s object: %s", kind, err),
+		})
+		return nil
+	}
+	if err := walker.walkMarks(hash, "verify"); err != nil {
+		return report, fmt.Errorf("VerifyMark(%s, %s): %w", group, hash, err)
+	}
+
+	for markHash := hash; markHash != "" && markHash != "nil"; {
+		verification, err := verifyMark(group, markHash)
+		if err != nil {
+			return report, fmt.Errorf("VerifyMark(%s, %s): %w", group, hash, err)
+		}
+		report.Marks = append(report.Marks, verification)
+
+		mark, err := ReadMark(group, markHash)
+		if err != nil {
+			// Reported by the walker as missing or broken
+			break
+		}
+		markHash = mark.Parent
+	}
+
+	for objHash, kind := range walker.seen {
+		report.Objects++
+		header, issue, ok := checkObjectContent(group, objHash)
+		if !ok {
+			report.Issues = append(report.Issues, issue)
+			continue
+		}
+		if !header.Legacy && header.Type != kind {
+			report.Issues = append(report.Issues, FsckIssue{
+				Target:  objHash,
+				Problem: FSCK_TYPE_MISMATCH,
+				Detail:  fmt.Sprintf("%s object is referenced as %s", header.Type, kind),
+			})
+			continue
+		}
+		if issue, ok := checkObjectEncoding(group, objHash, kind); !ok {
+			report.Issues = append(report.Issues, issue)
+		}
+	}
+
+	return report, nil
+}
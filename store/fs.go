@@ -0,0 +1,212 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// FS stores the shelf in a local directory, objects in <dir>/<group>/objects/<xx>/<rest>
+// and references in <dir>/<group>/refs/<name>.
+type FS struct {
+	Dir string
+}
+
+func NewFS(dir string) *FS {
+	return &FS{Dir: dir}
+}
+
+// ObjectDir returns the directory storing objects of the group
+func (s *FS) ObjectDir(group string) string {
+	return filepath.Join(s.Dir, group, objects_dir)
+}
+
+func (s *FS) GetObject(group, hash string) (io.ReadCloser, error) {
+	f, err := os.Open(s.path(objectKey(group, hash)))
+	if err != nil {
+		return nil, fmt.Errorf("GetObject(%s, %s): %w", group, hash, err)
+	}
+	return f, nil
+}
+
+func (s *FS) PutObject(group, hash string, content []byte) error {
+	if err := WriteFileAtomic(s.path(objectKey(group, hash)), content); err != nil {
+		return fmt.Errorf("PutObject(%s, %s): %w", group, hash, err)
+	}
+	return nil
+}
+
+func (s *FS) HasObject(group, hash string) (bool, error) {
+	_, err := os.Stat(s.path(objectKey(group, hash)))
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("HasObject(%s, %s): %w", group, hash, err)
+	}
+	return true, nil
+}
+
+// ListObjects returns objects in fan out directories of the group, other entries
+// such as packs and temporary files are skipped.
+func (s *FS) ListObjects(group string) ([]ObjectInfo, error) {
+	dir := s.ObjectDir(group)
+	objects := []ObjectInfo{}
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return objects, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("ListObjects(%s): %w", group, err)
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() || len(entry.Name()) != 2 {
+			continue
+		}
+
+		files, err := os.ReadDir(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("ListObjects(%s): %w", group, err)
+		}
+		for _, file := range files {
+			if file.IsDir() || strings.HasPrefix(file.Name(), ".") {
+				continue
+			}
+			info, err := file.Info()
+			if err != nil {
+				return nil, fmt.Errorf("ListObjects(%s): %w", group, err)
+			}
+			objects = append(objects, ObjectInfo{Hash: entry.Name() + file.Name(), Size: info.Size()})
+		}
+	}
+
+	return objects, nil
+}
+
+// DeleteObject removes the object file, and its fan out directory if it becomes empty.
+func (s *FS) DeleteObject(group, hash string) error {
+	path := s.path(objectKey(group, hash))
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("DeleteObject(%s, %s): %w", group, hash, err)
+	}
+	// Error is ignored since the directory may still contain other objects
+	os.Remove(filepath.Dir(path))
+	return nil
+}
+
+func (s *FS) ReadRef(group, name string) ([]byte, error) {
+	content, err := os.ReadFile(s.path(refKey(group, name)))
+	if err != nil {
+		return nil, fmt.Errorf("ReadRef(%s, %s): %w", group, name, err)
+	}
+	return content, nil
+}
+
+func (s *FS) WriteRef(group, name string, content []byte) error {
+	if err := WriteFileAtomic(s.path(refKey(group, name)), content); err != nil {
+		return fmt.Errorf("WriteRef(%s, %s): %w", group, name, err)
+	}
+	return nil
+}
+
+func (s *FS) DeleteRef(group, name string) error {
+	if err := os.Remove(s.path(refKey(group, name))); err != nil {
+		return fmt.Errorf("DeleteRef(%s, %s): %w", group, name, err)
+	}
+	return nil
+}
+
+func (s *FS) ListRefs(group, prefix string) ([]string, error) {
+	base := s.path(refKey(group, ""))
+	names := []string{}
+	err := filepath.WalkDir(filepath.Join(base, filepath.FromSlash(prefix)), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".") {
+			return nil
+		}
+
+		name, err := filepath.Rel(base, path)
+		if err != nil {
+			return err
+		}
+		names = append(names, filepath.ToSlash(name))
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return names, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("ListRefs(%s, %s): %w", group, prefix, err)
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+func (s *FS) path(key string) string {
+	return filepath.Join(s.Dir, filepath.FromSlash(key))
+}
+
+// WriteFileAtomic writes content to a temporary file next to path, flushes it to disk
+// then renames it over path, so the file is never left partially written. The directory
+// is synced after the rename so the new file survives a crash, with its parent if the
+// directory is created.
+func WriteFileAtomic(path string, content []byte) error {
+	dir := filepath.Dir(path)
+	_, err := os.Stat(dir)
+	created := errors.Is(err, fs.ErrNotExist)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return fmt.Errorf("write file: mkdir: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("write file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write file: sync: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("write file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("write file: %w", err)
+	}
+
+	if err := SyncDir(dir); err != nil {
+		return fmt.Errorf("write file: %w", err)
+	}
+	if created {
+		if err := SyncDir(filepath.Dir(dir)); err != nil {
+			return fmt.Errorf("write file: %w", err)
+		}
+	}
+	return nil
+}
+
+// SyncDir flushes the directory entries of dir to disk, so files created, renamed or
+// removed in it survive a crash.
+func SyncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("sync dir: %w", err)
+	}
+	defer d.Close()
+
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("sync dir %s: %w", dir, err)
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFS(t *testing.T) {
+	s := NewFS(t.TempDir())
+	testStore(t, s)
+
+	// Packs and temporary files next to objects and references are not listed
+	os.MkdirAll(filepath.Join(s.ObjectDir("grp"), "pack"), os.ModePerm)
+	os.WriteFile(filepath.Join(s.ObjectDir("grp"), "aa", ".0123.tmp"), nil, 0o644)
+	os.WriteFile(filepath.Join(s.Dir, "grp", refs_dir, ".HEAD.tmp"), nil, 0o644)
+	if objects, err := s.ListObjects("grp"); err != nil || len(objects) != 2 {
+		t.Errorf("ListObjects = %+v, %v, want 2 objects", objects, err)
+	}
+	if names, err := s.ListRefs("grp", ""); err != nil || len(names) != 4 {
+		t.Errorf("ListRefs = %q, %v, want 4 references", names, err)
+	}
+}
@@ -0,0 +1,142 @@
+package store
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Memory stores the shelf in memory, content is lost when the process exits.
+// It is meant for testing shelf logic without touching the filesystem.
+type Memory struct {
+	mu      sync.RWMutex
+	objects map[string][]byte
+	refs    map[string][]byte
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		objects: make(map[string][]byte),
+		refs:    make(map[string][]byte),
+	}
+}
+
+func (s *Memory) GetObject(group, hash string) (io.ReadCloser, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	content, ok := s.objects[objectKey(group, hash)]
+	if !ok {
+		return nil, fmt.Errorf("GetObject(%s, %s): %w", group, hash, fs.ErrNotExist)
+	}
+	return io.NopCloser(bytes.NewReader(content)), nil
+}
+
+func (s *Memory) PutObject(group, hash string, content []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.objects[objectKey(group, hash)] = bytes.Clone(content)
+	return nil
+}
+
+func (s *Memory) HasObject(group, hash string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, ok := s.objects[objectKey(group, hash)]
+	return ok, nil
+}
+
+func (s *Memory) ListObjects(group string) ([]ObjectInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	prefix := fmt.Sprintf("%s/%s/", group, objects_dir)
+	objects := []ObjectInfo{}
+	for key, content := range s.objects {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		hash := strings.ReplaceAll(strings.TrimPrefix(key, prefix), "/", "")
+		objects = append(objects, ObjectInfo{Hash: hash, Size: int64(len(content))})
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Hash < objects[j].Hash })
+
+	return objects, nil
+}
+
+func (s *Memory) DeleteObject(group, hash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := objectKey(group, hash)
+	if _, ok := s.objects[key]; !ok {
+		return fmt.Errorf("DeleteObject(%s, %s): %w", group, hash, fs.ErrNotExist)
+	}
+	delete(s.objects, key)
+	return nil
+}
+
+func (s *Memory) ReadRef(group, name string) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	content, ok := s.refs[refKey(group, name)]
+	if !ok {
+		return nil, fmt.Errorf("ReadRef(%s, %s): %w", group, name, fs.ErrNotExist)
+	}
+	return bytes.Clone(content), nil
+}
+
+func (s *Memory) WriteRef(group, name string, content []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.refs[refKey(group, name)] = bytes.Clone(content)
+	return nil
+}
+
+func (s *Memory) DeleteRef(group, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := refKey(group, name)
+	if _, ok := s.refs[key]; !ok {
+		return fmt.Errorf("DeleteRef(%s, %s): %w", group, name, fs.ErrNotExist)
+	}
+	delete(s.refs, key)
+	return nil
+}
+
+func (s *Memory) ListRefs(group, prefix string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	base := refKey(group, "")
+	names := []string{}
+	for key := range s.refs {
+		name, ok := strings.CutPrefix(key, base)
+		if !ok || !underPrefix(name, prefix) {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// underPrefix reports whether the record name is the prefix itself or lies under
+// the prefix directory.
+func underPrefix(name, prefix string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if prefix == "" || name == prefix {
+		return true
+	}
+	return strings.HasPrefix(name, prefix+"/")
+}
@@ -0,0 +1,7 @@
+package store
+
+import "testing"
+
+func TestMemory(t *testing.T) {
+	testStore(t, NewMemory())
+}
@@ -0,0 +1,500 @@
+package store
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	s3_default_region = "us-east-1"
+	s3_service        = "s3"
+	s3_algorithm      = "AWS4-HMAC-SHA256"
+	s3_time_format    = "20060102T150405Z"
+	s3_date_format    = "20060102"
+)
+
+var ErrS3Config = errors.New("invalid s3 config")
+
+// S3Config configures an S3 compatible backend, ex. AWS S3 or MinIO. Credentials
+// fall back to AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN
+// environment variables when not set.
+type S3Config struct {
+	// Endpoint URL of the service, ex. https://s3.us-east-1.amazonaws.com or
+	// http://localhost:9000. Buckets are addressed path style.
+	Endpoint string
+	// Region is s3_default_region if empty
+	Region string
+	Bucket string
+	// Prefix of all keys written by the shelf inside the bucket
+	Prefix       string
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+}
+
+// S3 stores the shelf in a bucket of an S3 compatible service, using the same key
+// layout as the filesystem backend below the configured prefix.
+type S3 struct {
+	cfg      S3Config
+	endpoint *url.URL
+	client   *http.Client
+	// now returns the signing time, replaced when verifying signatures
+	now func() time.Time
+}
+
+func NewS3(cfg S3Config) (*S3, error) {
+	if cfg.Endpoint == "" || cfg.Bucket == "" {
+		return nil, fmt.Errorf("NewS3: endpoint and bucket are required: %w", ErrS3Config)
+	}
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("NewS3: endpoint %q: %w", cfg.Endpoint, ErrS3Config)
+	}
+
+	if cfg.Region == "" {
+		cfg.Region = s3_default_region
+	}
+	if cfg.AccessKey == "" && cfg.SecretKey == "" {
+		cfg.AccessKey = os.Getenv("AWS_ACCESS_KEY_ID")
+		cfg.SecretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+		if cfg.SessionToken == "" {
+			cfg.SessionToken = os.Getenv("AWS_SESSION_TOKEN")
+		}
+	}
+	if cfg.AccessKey == "" || cfg.SecretKey == "" {
+		return nil, fmt.Errorf("NewS3: missing credentials: %w", ErrS3Config)
+	}
+	cfg.Prefix = strings.Trim(cfg.Prefix, "/")
+
+	return &S3{
+		cfg:      cfg,
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: 60 * time.Second},
+		now:      time.Now,
+	}, nil
+}
+
+func (s *S3) GetObject(group, hash string) (io.ReadCloser, error) {
+	content, err := s.get(objectKey(group, hash))
+	if err != nil {
+		return nil, fmt.Errorf("GetObject(%s, %s): %w", group, hash, err)
+	}
+	return io.NopCloser(bytes.NewReader(content)), nil
+}
+
+// PutObject uploads the object in a single request, S3 replaces keys atomically.
+func (s *S3) PutObject(group, hash string, content []byte) error {
+	if err := s.put(objectKey(group, hash), content); err != nil {
+		return fmt.Errorf("PutObject(%s, %s): %w", group, hash, err)
+	}
+	return nil
+}
+
+func (s *S3) HasObject(group, hash string) (bool, error) {
+	resp, err := s.do(http.MethodHead, s.key(objectKey(group, hash)), nil, nil, nil)
+	if err != nil {
+		return false, fmt.Errorf("HasObject(%s, %s): %w", group, hash, err)
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("HasObject(%s, %s): %s", group, hash, resp.Status)
+	}
+}
+
+func (s *S3) ListObjects(group string) ([]ObjectInfo, error) {
+	prefix := fmt.Sprintf("%s/%s/", group, objects_dir)
+	entries, err := s.list(prefix)
+	if err != nil {
+		return nil, fmt.Errorf("ListObjects(%s): %w", group, err)
+	}
+
+	objects := []ObjectInfo{}
+	for _, entry := range entries {
+		fanout, rest, ok := strings.Cut(entry.key, "/")
+		if !ok || len(fanout) != 2 || strings.Contains(rest, "/") {
+			continue
+		}
+		objects = append(objects, ObjectInfo{Hash: fanout + rest, Size: entry.size})
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Hash < objects[j].Hash })
+
+	return objects, nil
+}
+
+func (s *S3) DeleteObject(group, hash string) error {
+	if err := s.delete(objectKey(group, hash)); err != nil {
+		return fmt.Errorf("DeleteObject(%s, %s): %w", group, hash, err)
+	}
+	return nil
+}
+
+func (s *S3) ReadRef(group, name string) ([]byte, error) {
+	content, err := s.get(refKey(group, name))
+	if err != nil {
+		return nil, fmt.Errorf("ReadRef(%s, %s): %w", group, name, err)
+	}
+	return content, nil
+}
+
+func (s *S3) WriteRef(group, name string, content []byte) error {
+	if err := s.put(refKey(group, name), content); err != nil {
+		return fmt.Errorf("WriteRef(%s, %s): %w", group, name, err)
+	}
+	return nil
+}
+
+func (s *S3) DeleteRef(group, name string) error {
+	if err := s.delete(refKey(group, name)); err != nil {
+		return fmt.Errorf("DeleteRef(%s, %s): %w", group, name, err)
+	}
+	return nil
+}
+
+func (s *S3) ListRefs(group, prefix string) ([]string, error) {
+	dir := refKey(group, "")
+	if p := strings.Trim(prefix, "/"); p != "" {
+		dir += p
+	}
+	entries, err := s.list(dir)
+	if err != nil {
+		return nil, fmt.Errorf("ListRefs(%s, %s): %w", group, prefix, err)
+	}
+
+	names := []string{}
+	for _, entry := range entries {
+		name := strings.TrimPrefix(dir+entry.key, refKey(group, ""))
+		if !underPrefix(name, prefix) {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// TryLock writes the lock with a conditional request failing if the key exists,
+// which needs a service supporting conditional writes (AWS S3, MinIO).
+func (s *S3) TryLock(name, owner string) (string, error) {
+	resp, err := s.do(
+		http.MethodPut,
+		s.key(lockKey(name)),
+		nil,
+		http.Header{"If-None-Match": {"*"}},
+		[]byte(owner),
+	)
+	if err != nil {
+		return "", fmt.Errorf("TryLock(%s): %w", name, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusPreconditionFailed, http.StatusConflict:
+		holder, err := s.get(lockKey(name))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return "", fmt.Errorf("TryLock(%s): %w", name, err)
+		}
+		return string(holder), fmt.Errorf("TryLock(%s): %w", name, ErrLocked)
+	}
+	if err := checkResponse(resp); err != nil {
+		return "", fmt.Errorf("TryLock(%s): %w", name, err)
+	}
+	return "", nil
+}
+
+func (s *S3) Unlock(name, owner string) error {
+	holder, err := s.get(lockKey(name))
+	if err != nil {
+		return fmt.Errorf("Unlock(%s): %w", name, err)
+	}
+	if string(holder) != owner {
+		return fmt.Errorf("Unlock(%s): held by %s: %w", name, holder, ErrLocked)
+	}
+	if err := s.delete(lockKey(name)); err != nil {
+		return fmt.Errorf("Unlock(%s): %w", name, err)
+	}
+	return nil
+}
+
+// key returns the bucket key of the store relative key
+func (s *S3) key(key string) string {
+	if s.cfg.Prefix == "" {
+		return key
+	}
+	return s.cfg.Prefix + "/" + key
+}
+
+func (s *S3) get(key string) ([]byte, error) {
+	resp, err := s.do(http.MethodGet, s.key(key), nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(resp); err != nil {
+		return nil, err
+	}
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read body: %w", err)
+	}
+	return content, nil
+}
+
+func (s *S3) put(key string, content []byte) error {
+	resp, err := s.do(http.MethodPut, s.key(key), nil, nil, content)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return checkResponse(resp)
+}
+
+// delete removes the key, S3 reports success for missing keys so existence is
+// checked first to report fs.ErrNotExist like other backends.
+func (s *S3) delete(key string) error {
+	resp, err := s.do(http.MethodHead, s.key(key), nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if err := checkResponse(resp); err != nil {
+		return err
+	}
+
+	resp, err = s.do(http.MethodDelete, s.key(key), nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return checkResponse(resp)
+}
+
+type s3Entry struct {
+	// key relative to the listed prefix
+	key  string
+	size int64
+}
+
+type listBucketResult struct {
+	Contents []struct {
+		Key  string `xml:"Key"`
+		Size int64  `xml:"Size"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// list returns all keys under the store relative prefix by ListObjectsV2 requests,
+// following continuation tokens.
+func (s *S3) list(prefix string) ([]s3Entry, error) {
+	full := s.key(prefix)
+	entries := []s3Entry{}
+	token := ""
+	for {
+		query := url.Values{}
+		query.Set("list-type", "2")
+		query.Set("prefix", full)
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		resp, err := s.do(http.MethodGet, "", query, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkResponse(resp); err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+		var result listBucketResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("list objects: decode: %w", err)
+		}
+
+		for _, content := range result.Contents {
+			entries = append(entries, s3Entry{
+				key:  strings.TrimPrefix(content.Key, full),
+				size: content.Size,
+			})
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		token = result.NextContinuationToken
+	}
+
+	return entries, nil
+}
+
+// do sends a signed request for the bucket key with extra headers, an empty key
+// addresses the bucket.
+func (s *S3) do(
+	method, key string,
+	query url.Values,
+	header http.Header,
+	body []byte,
+) (*http.Response, error) {
+	u := *s.endpoint
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + s.cfg.Bucket
+	if key != "" {
+		u.Path += "/" + key
+	}
+	u.RawPath = ""
+	u.RawQuery = ""
+	if query != nil {
+		u.RawQuery = canonicalQuery(query)
+	}
+
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", method, key, err)
+	}
+	req.ContentLength = int64(len(body))
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	s.sign(req, body)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", method, key, err)
+	}
+	return resp, nil
+}
+
+// sign adds AWS signature version 4 headers to the request.
+func (s *S3) sign(req *http.Request, body []byte) {
+	t := s.now().UTC()
+	payloadHash := sha256Hex(body)
+	req.Header.Set("X-Amz-Date", t.Format(s3_time_format))
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if s.cfg.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.cfg.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if name == "host" || name == "content-type" || name == "range" || strings.HasPrefix(name, "x-amz-") {
+			headers[name] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&canonicalHeaders, "%s:%s\n", name, headers[name])
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := fmt.Sprintf("%s/%s/%s/aws4_request", t.Format(s3_date_format), s.cfg.Region, s3_service)
+	stringToSign := strings.Join([]string{
+		s3_algorithm,
+		t.Format(s3_time_format),
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.cfg.SecretKey), []byte(t.Format(s3_date_format)))
+	key = hmacSHA256(key, []byte(s.cfg.Region))
+	key = hmacSHA256(key, []byte(s3_service))
+	key = hmacSHA256(key, []byte("aws4_request"))
+	signature := hex.EncodeToString(hmacSHA256(key, []byte(stringToSign)))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s3_algorithm, s.cfg.AccessKey, scope, signedHeaders, signature,
+	))
+}
+
+// canonicalQuery encodes the query sorted by key with RFC 3986 escaping as required
+// by signature version 4.
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	params := []string{}
+	for _, k := range keys {
+		values := append([]string{}, query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			params = append(params, uriEncode(k)+"="+uriEncode(v))
+		}
+	}
+	return strings.Join(params, "&")
+}
+
+func uriEncode(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+// checkResponse maps a failed response to an error, not found keys are reported
+// by errors wrapping fs.ErrNotExist.
+func checkResponse(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%s: %w", resp.Status, fs.ErrNotExist)
+	}
+
+	var s3Err struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if err := xml.NewDecoder(io.LimitReader(resp.Body, 1<<16)).Decode(&s3Err); err == nil && s3Err.Code != "" {
+		return fmt.Errorf("%s: %s: %s", resp.Status, s3Err.Code, s3Err.Message)
+	}
+	return fmt.Errorf("%s", resp.Status)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key, data []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
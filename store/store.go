@@ -0,0 +1,121 @@
+// Package store provides storage backends of the shelf. Objects are stored
+// compressed by group and hash, references and history records by slash separated
+// names relative to the refs directory of a group, ex. HEAD, tags/v1 or
+// history/logger/logger.0.
+package store
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Storage backends selectable in config
+const (
+	BACKEND_FS = "fs"
+	BACKEND_S3 = "s3"
+	// Shelf kept in memory of the process, meant for tests
+	BACKEND_MEMORY = "memory"
+
+	// Directories of a group holding objects and references
+	objects_dir = "objects"
+	refs_dir    = "refs"
+	// Directory of the store root holding locks, outside of any group
+	locks_dir = ".locks"
+)
+
+var (
+	ErrUnknownBackend = errors.New("unknown storage backend")
+	ErrLocked         = errors.New("lock is held by another owner")
+)
+
+// ObjectStore stores compressed objects of shelf groups addressed by hash.
+// Missing objects are reported by errors wrapping fs.ErrNotExist.
+type ObjectStore interface {
+	// GetObject returns a reader of the object content
+	GetObject(group, hash string) (io.ReadCloser, error)
+	// PutObject writes the object content, an existing object is replaced atomically
+	PutObject(group, hash string, content []byte) error
+	HasObject(group, hash string) (bool, error)
+	// ListObjects returns all objects of the group sorted by hash
+	ListObjects(group string) ([]ObjectInfo, error)
+	DeleteObject(group, hash string) error
+}
+
+// RefStore stores references and history records of shelf groups by name.
+// Missing records are reported by errors wrapping fs.ErrNotExist.
+type RefStore interface {
+	ReadRef(group, name string) ([]byte, error)
+	// WriteRef writes the record content, an existing record is replaced atomically
+	WriteRef(group, name string, content []byte) error
+	DeleteRef(group, name string) error
+	// ListRefs returns names of records under the prefix directory sorted by name,
+	// all records of the group are returned if prefix is empty.
+	ListRefs(group, prefix string) ([]string, error)
+}
+
+// Store is a storage backend holding both objects and references.
+type Store interface {
+	ObjectStore
+	RefStore
+}
+
+// Locker is implemented by backends shared between machines, where file locks of
+// the local host do not keep other machines from writing. Locks are named by slash
+// separated names and hold an owner description.
+type Locker interface {
+	// TryLock takes the lock for owner, it returns the current owner with an error
+	// wrapping ErrLocked if the lock is held.
+	TryLock(name, owner string) (string, error)
+	// Unlock releases the lock if it is held by owner
+	Unlock(name, owner string) error
+}
+
+type ObjectInfo struct {
+	Hash string
+	// Size of the stored object content in bytes
+	Size int64
+}
+
+// Config selects and configures the storage backend
+type Config struct {
+	// Backend is BACKEND_FS if empty
+	Backend string
+	// Shelf directory of the filesystem backend
+	Dir string
+	S3  S3Config
+}
+
+// New returns the storage backend of the config.
+func New(cfg Config) (Store, error) {
+	switch cfg.Backend {
+	case "", BACKEND_FS:
+		return NewFS(cfg.Dir), nil
+	case BACKEND_MEMORY:
+		return NewMemory(), nil
+	case BACKEND_S3:
+		s, err := NewS3(cfg.S3)
+		if err != nil {
+			return nil, fmt.Errorf("new store: %w", err)
+		}
+		return s, nil
+	default:
+		return nil, fmt.Errorf("new store: %s: %w", cfg.Backend, ErrUnknownBackend)
+	}
+}
+
+// objectKey returns the slash separated path of the object relative to the store root,
+// objects are fanned out by the first two characters of their hash.
+func objectKey(group, hash string) string {
+	return fmt.Sprintf("%s/%s/%s/%s", group, objects_dir, hash[:2], hash[2:])
+}
+
+// refKey returns the slash separated path of the reference relative to the store root.
+func refKey(group, name string) string {
+	return fmt.Sprintf("%s/%s/%s", group, refs_dir, name)
+}
+
+// lockKey returns the slash separated path of the lock relative to the store root.
+func lockKey(name string) string {
+	return fmt.Sprintf("%s/%s", locks_dir, name)
+}
@@ -0,0 +1,168 @@
+package store
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"reflect"
+	"testing"
+)
+
+const (
+	test_hash_a = "aa0123456789"
+	test_hash_b = "ab0123456789"
+	test_hash_c = "ff0123456789"
+)
+
+// testStore checks the behavior shared by every backend on an empty store.
+func testStore(t *testing.T, s Store) {
+	t.Helper()
+
+	// Objects
+	for _, hash := range []string{test_hash_c, test_hash_a, test_hash_b} {
+		if err := s.PutObject("grp", hash, []byte("content "+hash)); err != nil {
+			t.Fatalf("PutObject(%s): %v", hash, err)
+		}
+	}
+	if err := s.PutObject("grp", test_hash_a, []byte("replaced")); err != nil {
+		t.Fatalf("PutObject(%s): %v", test_hash_a, err)
+	}
+	if err := s.PutObject("other", test_hash_a, []byte("other")); err != nil {
+		t.Fatalf("PutObject(other): %v", err)
+	}
+
+	r, err := s.GetObject("grp", test_hash_a)
+	if err != nil {
+		t.Fatalf("GetObject: %v", err)
+	}
+	content, err := io.ReadAll(r)
+	r.Close()
+	if err != nil || string(content) != "replaced" {
+		t.Errorf("GetObject content = %q, %v, want %q", content, err, "replaced")
+	}
+	if _, err := s.GetObject("grp", "00missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("GetObject missing error = %v, want %v", err, fs.ErrNotExist)
+	}
+
+	if ok, err := s.HasObject("grp", test_hash_b); err != nil || !ok {
+		t.Errorf("HasObject(%s) = %t, %v, want true", test_hash_b, ok, err)
+	}
+	if ok, err := s.HasObject("grp", "00missing"); err != nil || ok {
+		t.Errorf("HasObject missing = %t, %v, want false", ok, err)
+	}
+
+	objects, err := s.ListObjects("grp")
+	if err != nil {
+		t.Fatalf("ListObjects: %v", err)
+	}
+	wantObjects := []ObjectInfo{
+		{Hash: test_hash_a, Size: int64(len("replaced"))},
+		{Hash: test_hash_b, Size: int64(len("content " + test_hash_b))},
+		{Hash: test_hash_c, Size: int64(len("content " + test_hash_c))},
+	}
+	if !reflect.DeepEqual(objects, wantObjects) {
+		t.Errorf("ListObjects = %+v, want %+v", objects, wantObjects)
+	}
+
+	if err := s.DeleteObject("grp", test_hash_b); err != nil {
+		t.Fatalf("DeleteObject: %v", err)
+	}
+	if err := s.DeleteObject("grp", test_hash_b); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("DeleteObject twice error = %v, want %v", err, fs.ErrNotExist)
+	}
+	if objects, err := s.ListObjects("grp"); err != nil || len(objects) != 2 {
+		t.Errorf("ListObjects after delete = %+v, %v, want 2 objects", objects, err)
+	}
+	if objects, err := s.ListObjects("empty"); err != nil || len(objects) != 0 {
+		t.Errorf("ListObjects(empty) = %+v, %v, want none", objects, err)
+	}
+
+	// References
+	for _, name := range []string{"HEAD", "tags/v1", "tags/v2", "history/logger/logger.0", "historyX"} {
+		if err := s.WriteRef("grp", name, []byte(name)); err != nil {
+			t.Fatalf("WriteRef(%s): %v", name, err)
+		}
+	}
+	if err := s.WriteRef("grp", "HEAD", []byte("moved")); err != nil {
+		t.Fatalf("WriteRef(HEAD): %v", err)
+	}
+	if err := s.WriteRef("other", "tags/v3", []byte("other")); err != nil {
+		t.Fatalf("WriteRef(other): %v", err)
+	}
+
+	if content, err := s.ReadRef("grp", "HEAD"); err != nil || string(content) != "moved" {
+		t.Errorf("ReadRef(HEAD) = %q, %v, want %q", content, err, "moved")
+	}
+	if _, err := s.ReadRef("grp", "tags/missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("ReadRef missing error = %v, want %v", err, fs.ErrNotExist)
+	}
+
+	refTests := []struct {
+		prefix string
+		want   []string
+	}{
+		{prefix: "", want: []string{"HEAD", "history/logger/logger.0", "historyX", "tags/v1", "tags/v2"}},
+		{prefix: "tags", want: []string{"tags/v1", "tags/v2"}},
+		{prefix: "tags/", want: []string{"tags/v1", "tags/v2"}},
+		{prefix: "history", want: []string{"history/logger/logger.0"}},
+		{prefix: "HEAD", want: []string{"HEAD"}},
+		{prefix: "missing", want: []string{}},
+	}
+	for _, tt := range refTests {
+		names, err := s.ListRefs("grp", tt.prefix)
+		if err != nil {
+			t.Errorf("ListRefs(%s): %v", tt.prefix, err)
+			continue
+		}
+		if !reflect.DeepEqual(names, tt.want) {
+			t.Errorf("ListRefs(%s) = %q, want %q", tt.prefix, names, tt.want)
+		}
+	}
+
+	if err := s.DeleteRef("grp", "tags/v1"); err != nil {
+		t.Fatalf("DeleteRef: %v", err)
+	}
+	if err := s.DeleteRef("grp", "tags/v1"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("DeleteRef twice error = %v, want %v", err, fs.ErrNotExist)
+	}
+	if names, err := s.ListRefs("grp", "tags"); err != nil || !reflect.DeepEqual(names, []string{"tags/v2"}) {
+		t.Errorf("ListRefs(tags) after delete = %q, %v, want [tags/v2]", names, err)
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		cfg  Config
+		want any
+	}{
+		{cfg: Config{Dir: t.TempDir()}, want: &FS{}},
+		{cfg: Config{Backend: BACKEND_FS, Dir: t.TempDir()}, want: &FS{}},
+		{cfg: Config{Backend: BACKEND_MEMORY}, want: &Memory{}},
+		{
+			cfg: Config{Backend: BACKEND_S3, S3: S3Config{
+				Endpoint:  "http://localhost:9000",
+				Bucket:    "bucket",
+				AccessKey: "access",
+				SecretKey: "secret",
+			}},
+			want: &S3{},
+		},
+	}
+	for _, tt := range tests {
+		s, err := New(tt.cfg)
+		if err != nil {
+			t.Errorf("New(%s): %v", tt.cfg.Backend, err)
+			continue
+		}
+		if reflect.TypeOf(s) != reflect.TypeOf(tt.want) {
+			t.Errorf("New(%s) = %T, want %T", tt.cfg.Backend, s, tt.want)
+		}
+	}
+
+	if _, err := New(Config{Backend: "ftp"}); !errors.Is(err, ErrUnknownBackend) {
+		t.Errorf("New(ftp) error = %v, want %v", err, ErrUnknownBackend)
+	}
+	if _, err := New(Config{Backend: BACKEND_S3}); !errors.Is(err, ErrS3Config) {
+		t.Errorf("New(s3) without bucket error = %v, want %v", err, ErrS3Config)
+	}
+}
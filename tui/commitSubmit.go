@@ -12,6 +12,7 @@ import (
 	"github.com/charmbracelet/bubbles/spinner"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/liuminhaw/mist-miner/locks"
 	"github.com/liuminhaw/mist-miner/shared"
 	"github.com/liuminhaw/mist-miner/shelf"
 )
@@ -22,11 +23,22 @@ type commitCache struct {
 	groupIdHashMaps map[string]shelf.IdentifierHashMaps
 	stuffOutline    shelf.StuffOutline
 	isCached        bool
+	// Objects lock held from the first object written until HEAD is moved, so gc
+	// and shelf upgrade do not run in between
+	objLock *locks.Lock
+}
+
+// unlock releases the objects lock if it is held
+func (c *commitCache) unlock() {
+	if c.objLock != nil {
+		c.objLock.Unlock()
+		c.objLock = nil
+	}
 }
 
 type commitSubmitModel struct {
 	diaries  []commitDiaryItem
-	cache    commitCache
+	cache    *commitCache
 	index    int
 	width    int
 	height   int
@@ -71,7 +83,7 @@ func InitCommitSubmitModel(items []list.Item) (tea.Model, error) {
 	s.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("63"))
 	return commitSubmitModel{
 		diaries: diaryItems,
-		cache: commitCache{
+		cache: &commitCache{
 			groupIdHashMaps: make(map[string]shelf.IdentifierHashMaps),
 		},
 		spinner:  s,
@@ -80,7 +92,7 @@ func InitCommitSubmitModel(items []list.Item) (tea.Model, error) {
 }
 
 func (m commitSubmitModel) Init() tea.Cmd {
-	return tea.Batch(updateDiaryLog(m.diaries[m.index], &m.cache), m.spinner.Tick)
+	return tea.Batch(updateDiaryLog(m.diaries[m.index], m.cache), m.spinner.Tick)
 }
 
 func (m commitSubmitModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -90,10 +102,12 @@ func (m commitSubmitModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tea.KeyMsg:
 		switch msg.String() {
 		case "ctrl+c", "q":
+			m.cache.unlock()
 			return m, tea.Quit
 		}
 	case updateDiaryLogMsg:
 		if msg.err != nil {
+			m.cache.unlock()
 			return m, tea.Sequence(
 				tea.Printf(msg.err.Error()),
 				tea.Quit,
@@ -101,11 +115,30 @@ func (m commitSubmitModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 
 		if m.index >= len(m.diaries)-1 {
+			// HEAD is read under the objects lock, it is not expected to move before the
+			// new label mark is written
+			head, err := shelf.NewRefMark(shelf.SHELF_MARK_FILE, m.cache.labelMark.Group)
+			if err != nil {
+				m.cache.unlock()
+				return m, tea.Sequence(
+					tea.Printf("Failed to read HEAD: %s", err),
+					tea.Quit,
+				)
+			}
+			if string(head.Reference) != string(m.cache.head.Reference) {
+				m.cache.unlock()
+				return m, tea.Sequence(
+					tea.Printf("Failed to update label mark: HEAD of group %s moved during commit", m.cache.labelMark.Group),
+					tea.Quit,
+				)
+			}
+
 			// Write identifier hash maps from cache to file
 			for _, idHashMaps := range m.cache.groupIdHashMaps {
 				originIdMapsHash := idHashMaps.Hash
 				idHashMaps.Sort()
-				if err := idHashMaps.Write(); err != nil {
+				if _, err := idHashMaps.Write(); err != nil {
+					m.cache.unlock()
 					return m, tea.Sequence(
 						tea.Printf("Failed to write identifier hash maps: %s", err),
 						tea.Quit,
@@ -129,7 +162,9 @@ func (m commitSubmitModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.cache.labelMark.TimeStamp = time.Now()
 			m.cache.labelMark.LogType = shelf.LOG_TYPE_DIARY
 			m.cache.labelMark.Parent = string(m.cache.head.Reference)
-			if err := m.cache.labelMark.Update(); err != nil {
+			err = m.cache.labelMark.Update()
+			m.cache.unlock()
+			if err != nil {
 				return m, tea.Sequence(
 					tea.Printf("Failed to update label mark: %s", err),
 					tea.Quit,
@@ -151,6 +186,18 @@ func (m commitSubmitModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					tea.Quit,
 				)
 			}
+			if err := shelf.UpdateTimeline(m.cache.labelMark.Group, m.cache.labelMark.Hash); err != nil {
+				return m, tea.Sequence(
+					tea.Printf("Failed to update resource timeline: %s", err),
+					tea.Quit,
+				)
+			}
+			// Diary is committed already, a failed update is caught up by the next
+			// mine, diary commit or log reload
+			searchMsg := ""
+			if err := shelf.UpdateSearchIndex(m.cache.labelMark.Group, m.cache.labelMark.Hash); err != nil {
+				searchMsg = fmt.Sprintf("Search index is not updated, caught up on next update: %s", err)
+			}
 
 			// Remove temp files after submit success
 			for _, diary := range m.diaries {
@@ -164,6 +211,9 @@ func (m commitSubmitModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 			// Everything's been processed
 			m.done = true
+			if searchMsg != "" {
+				return m, tea.Sequence(tea.Printf(msg.msg), tea.Printf("%s", searchMsg), tea.Quit)
+			}
 			return m, tea.Sequence(
 				// tea.Printf("%s Group: %s, Plugin: %s, Id: %s", checkMark, diary.group, diary.plugin, diary.Title()),
 				tea.Printf(msg.msg),
@@ -179,7 +229,7 @@ func (m commitSubmitModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			// tea.Printf("%s %s", checkMark, diary.Title()),
 			// tea.Printf("%s Group: %s, Plugin: %s, Id: %s", checkMark, diary.group, diary.plugin, diary.Title()),
 			tea.Printf(msg.msg),
-			updateDiaryLog(m.diaries[m.index], &m.cache),
+			updateDiaryLog(m.diaries[m.index], m.cache),
 		)
 	case spinner.TickMsg:
 		var cmd tea.Cmd
@@ -227,6 +277,26 @@ type updateDiaryLogMsg struct {
 func updateDiaryLog(item commitDiaryItem, cache *commitCache) tea.Cmd {
 	// Read and fill cache from log record if cache does not exist
 	if !cache.isCached {
+		// Objects of the commit are unreachable until HEAD is moved to its label mark
+		objLock, err := locks.NewLock("", locks.OBJECTS_LOCKFILE)
+		if err != nil {
+			return func() tea.Msg {
+				return updateDiaryLogMsg{
+					err: fmt.Errorf("Update diary: failed to create objects lock: %w", err),
+				}
+			}
+		}
+		if err := objLock.TryLock(); err != nil {
+			return func() tea.Msg {
+				return updateDiaryLogMsg{
+					err: fmt.Errorf("Update diary: %w", err),
+				}
+			}
+		}
+		cache.objLock = &objLock
+		// Read objects in the format recorded by an upgrade run since the list was loaded
+		shelf.ReloadFormat(item.group)
+
 		head, err := shelf.NewRefMark(shelf.SHELF_MARK_FILE, item.group)
 		if err != nil {
 			return func() tea.Msg {
@@ -350,8 +420,15 @@ mappingsLoop:
 			}
 
 			// Update stuff outline record
-			newOutline := shelf.NewStuffOutline(item.group, outline.ResourceHash, diaryResource.Hash)
-			if err := newOutline.Write(); err != nil {
+			newOutline, err := shelf.NewStuffOutline(item.group, outline.ResourceHash, diaryResource.Hash)
+			if err != nil {
+				return func() tea.Msg {
+					return updateDiaryLogMsg{
+						err: fmt.Errorf("Update diary: failed to generate stuff outline: %w", err),
+					}
+				}
+			}
+			if _, err := newOutline.Write(); err != nil {
 				return func() tea.Msg {
 					return updateDiaryLogMsg{
 						err: fmt.Errorf("Update diary: failed to write stuff outline: %w", err),
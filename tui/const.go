@@ -15,6 +15,7 @@ const (
 type (
 	prevPageMsg     struct{}
 	reloadDetailMsg struct{}
+	toggleDiffMsg   struct{}
 )
 
 var (
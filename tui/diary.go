@@ -62,6 +62,8 @@ type diaryModel struct {
 	list   list.Model
 	width  int
 	height int
+	// Diaries of past label marks are shown without editing
+	readOnly bool
 
 	// editor diaryEditor
 
@@ -69,29 +71,53 @@ type diaryModel struct {
 }
 
 func InitDiaryModel(group, plugin string) (tea.Model, error) {
-	list, err := readDiaryItems(group, plugin)
+	head, err := shelf.NewRefMark(shelf.SHELF_MARK_FILE, group)
 	if err != nil {
 		return nil, fmt.Errorf("InitDiaryModel(%s): %w", group, err)
 	}
 
-	model := diaryModel{
-		group:  group,
-		plugin: plugin,
-		list:   list,
+	model, err := newDiaryModel(group, plugin, string(head.Reference), false)
+	if err != nil {
+		return nil, fmt.Errorf("InitDiaryModel(%s): %w", group, err)
 	}
 	model.list.Title = fmt.Sprintf("Diary for group %s", group)
 
+	return model, nil
+}
+
+// InitDiaryModelAt initializes a read-only diary model with resources of the label mark.
+func InitDiaryModelAt(group, plugin, markHash string) (tea.Model, error) {
+	model, err := newDiaryModel(group, plugin, markHash, true)
+	if err != nil {
+		return nil, fmt.Errorf("InitDiaryModelAt(%s, %s): %w", group, markHash, err)
+	}
+	model.list.Title = fmt.Sprintf("Diary for group %s at %s (read-only)", group, markHash[:12])
+
+	return model, nil
+}
+
+func newDiaryModel(group, plugin, markHash string, readOnly bool) (diaryModel, error) {
+	list, err := readDiaryItems(group, plugin, markHash)
+	if err != nil {
+		return diaryModel{}, err
+	}
+
+	model := diaryModel{
+		group:    group,
+		plugin:   plugin,
+		list:     list,
+		readOnly: readOnly,
+	}
+
+	helpKeys := []key.Binding{customKeys.quit}
+	if !readOnly {
+		helpKeys = append(helpKeys, customKeys.editor)
+	}
 	model.list.AdditionalShortHelpKeys = func() []key.Binding {
-		return []key.Binding{
-			customKeys.quit,
-			customKeys.editor,
-		}
+		return helpKeys
 	}
 	model.list.AdditionalFullHelpKeys = func() []key.Binding {
-		return []key.Binding{
-			customKeys.quit,
-			customKeys.editor,
-		}
+		return helpKeys
 	}
 
 	model.list.SetStatusBarItemName("resource", "resources")
@@ -117,6 +143,9 @@ func (m diaryModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case "ctrl+c", "q":
 			return m, tea.Quit
 		case "e":
+			if m.readOnly {
+				return m, m.list.NewStatusMessage("Read-only: diary of a past label mark cannot be edited")
+			}
 			selectedItem := m.list.SelectedItem().(diaryItem)
 			mDiary, err := readMinerDiary(m.group, selectedItem.identifierHash)
 			if err != nil {
@@ -194,17 +223,12 @@ func (m diaryModel) View() string {
 	return listStyle.Render(m.list.View())
 }
 
-// readDiaryItems reads resources information from plugin in the group
+// readDiaryItems reads resources information from plugin in the label mark of the group
 // and returns a list model for tui view with the items.
-func readDiaryItems(group, plugin string) (list.Model, error) {
+func readDiaryItems(group, plugin, markHash string) (list.Model, error) {
 	items := []list.Item{}
 
-	head, err := shelf.NewRefMark(shelf.SHELF_MARK_FILE, group)
-	if err != nil {
-		return list.Model{}, fmt.Errorf("readDiaryItems(%s, %s): %w", group, plugin, err)
-	}
-
-	mark, err := shelf.ReadMark(group, string(head.Reference))
+	mark, err := shelf.ReadMark(group, markHash)
 	if err != nil {
 		return list.Model{}, fmt.Errorf("readDiaryItems(%s, %s): %w", group, plugin, err)
 	}
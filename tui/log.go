@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"errors"
 	"fmt"
+	"io/fs"
 	"strings"
 	"time"
 
@@ -80,6 +81,37 @@ func InitLogModel(group string, logIdx int) (tea.Model, error) {
 	return model, nil
 }
 
+// InitLogModelAt initializes the log model at the history page containing the
+// label mark, with the mark selected.
+func InitLogModelAt(group, markHash string) (tea.Model, error) {
+	for logIdx := 0; ; logIdx++ {
+		items, err := readLogItems(group, logIdx)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf(
+				"InitLogModelAt(%s, %s): mark not in history logs, try log reload: %w",
+				group,
+				markHash,
+				shelf.ErrRevNotFound,
+			)
+		} else if err != nil {
+			return nil, fmt.Errorf("InitLogModelAt(%s, %s): %w", group, markHash, err)
+		}
+
+		for i, item := range items.Items() {
+			if item.(logItem).hash != markHash {
+				continue
+			}
+			model, err := InitLogModel(group, logIdx)
+			if err != nil {
+				return nil, fmt.Errorf("InitLogModelAt(%s, %s): %w", group, markHash, err)
+			}
+			logModel := model.(logModel)
+			logModel.list.Select(i)
+			return logModel, nil
+		}
+	}
+}
+
 func (m logModel) Init() tea.Cmd {
 	return nil
 }
@@ -145,7 +177,6 @@ func readLogItems(group string, logIdx int) (list.Model, error) {
 	if err != nil {
 		return list.Model{}, fmt.Errorf("readLogItems(%s, %d): %w", group, logIdx, err)
 	}
-	defer record.CloseFile()
 	defer recordReader.Close()
 
 	scanner := bufio.NewScanner(recordReader)
@@ -88,7 +88,7 @@ func (m markModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m.prevModel.Update(tuiWindowSize)
 		case "enter":
 			selectedItem := m.list.SelectedItem().(markItem)
-			resource, _ := InitResourceModel(m.group, selectedItem.hash, m)
+			resource, _ := InitResourceModel(m.group, m.hash, selectedItem.plugin, selectedItem.hash, m)
 			return resource.Update(tuiWindowSize)
 		}
 	case markReadMsg:
@@ -1,6 +1,7 @@
 package tui
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/charmbracelet/bubbles/key"
@@ -13,6 +14,8 @@ type resourceItem struct {
 	alias      string
 	hash       string
 	identifier string
+	// resource hash of the same identifier in parent mark
+	prevHash string
 }
 
 func (i resourceItem) Title() string { return i.identifier }
@@ -38,8 +41,11 @@ type resourceModel struct {
 	prevModel tea.Model
 }
 
-func InitResourceModel(group, resourceHash string, prev tea.Model) (tea.Model, error) {
-	list, err := readResourceItems(group, resourceHash)
+func InitResourceModel(
+	group, markHash, plugin, resourceHash string,
+	prev tea.Model,
+) (tea.Model, error) {
+	list, err := readResourceItems(group, markHash, plugin, resourceHash)
 	if err != nil {
 		return nil, fmt.Errorf("InitResourceModel(%s, %s): %w", group, resourceHash, err)
 	}
@@ -93,7 +99,13 @@ func (m resourceModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m.prevModel.Update(tuiWindowSize)
 		case "enter":
 			selectedItem := m.list.SelectedItem().(resourceItem)
-			detail, _ := InitResourceDetailModel(m.group, selectedItem.hash, m)
+			detail, _ := InitResourceDetailModel(
+				m.group,
+				selectedItem.hash,
+				selectedItem.prevHash,
+				false,
+				m,
+			)
 			return detail.Update(tuiWindowSize)
 		}
 	case markReadMsg:
@@ -109,12 +121,26 @@ func (m resourceModel) View() string {
 	return listStyle.Render(m.list.View())
 }
 
-func readResourceItems(group, hash string) (list.Model, error) {
+// readResourceItems reads resources in the identifier hash maps of given hash,
+// resources are paired with the resource of the same plugin and identifier
+// in parent of the given mark for later comparison.
+func readResourceItems(group, markHash, plugin, hash string) (list.Model, error) {
 	idHashMaps, err := shelf.ReadIdentifierHashMaps(group, hash)
 	if err != nil {
 		return list.Model{}, fmt.Errorf("readResourceItems(%s, %s): %w", group, hash, err)
 	}
 
+	prevResources := map[string]string{}
+	parent, err := shelf.ParentMark(group, markHash)
+	if err == nil {
+		prevResources, err = shelf.MarkResources(group, parent, plugin)
+		if err != nil {
+			return list.Model{}, fmt.Errorf("readResourceItems(%s, %s): %w", group, hash, err)
+		}
+	} else if !errors.Is(err, shelf.ErrNoParentMark) {
+		return list.Model{}, fmt.Errorf("readResourceItems(%s, %s): %w", group, hash, err)
+	}
+
 	items := []list.Item{}
 	for _, m := range idHashMaps.Maps {
 		outline, err := shelf.ReadStuffOutline(group, m.Hash)
@@ -124,7 +150,12 @@ func readResourceItems(group, hash string) (list.Model, error) {
 
 		items = append(
 			items,
-			resourceItem{alias: m.Alias, hash: outline.ResourceHash, identifier: m.Identifier},
+			resourceItem{
+				alias:      m.Alias,
+				hash:       outline.ResourceHash,
+				identifier: m.Identifier,
+				prevHash:   prevResources[m.Identifier],
+			},
 		)
 	}
 
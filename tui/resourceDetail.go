@@ -1,7 +1,6 @@
 package tui
 
 import (
-	"encoding/json"
 	"fmt"
 	"strings"
 
@@ -24,6 +23,8 @@ import (
 type resourceDetailModel struct {
 	group           string
 	hash            string
+	prevHash        string
+	showDiff        bool
 	content         string
 	ready           bool
 	viewport        viewport.Model
@@ -32,27 +33,40 @@ type resourceDetailModel struct {
 	prevModel tea.Model
 }
 
+// InitResourceDetailModel creates the resource detail view of given resource hash,
+// property changes against prevHash resource are shown instead if showDiff is set.
+// prevHash can be empty if there is no previous version of the resource.
 func InitResourceDetailModel(
-	group, hash string,
+	group, hash, prevHash string,
+	showDiff bool,
 	prev tea.Model,
 ) (tea.Model, error) {
-	content, err := shelf.NewObjectRecord(group, hash).RecordRead()
-	if err != nil {
-		return nil, fmt.Errorf("InitResourceDetailModel(%s, %s): %w", group, hash, err)
-	}
-
-	resource := shared.MinerResource{}
-	if err := json.Unmarshal([]byte(content), &resource); err != nil {
-		return nil, fmt.Errorf("InitResourceDetailModel(%s, %s): %w", group, hash, err)
-	}
-	resourceMd, err := resource.RenderMarkdown()
-	if err != nil {
-		return nil, fmt.Errorf("InitResourceDetailModel(%s, %s): %w", group, hash, err)
+	var resourceMd string
+	if showDiff {
+		diffs, err := shelf.DiffResources(group, prevHash, hash)
+		if err != nil {
+			return nil, fmt.Errorf("InitResourceDetailModel(%s, %s): %w", group, hash, err)
+		}
+		resourceMd, err = shared.RenderDiffMarkdown(diffs)
+		if err != nil {
+			return nil, fmt.Errorf("InitResourceDetailModel(%s, %s): %w", group, hash, err)
+		}
+	} else {
+		resource, err := shelf.ReadResource(group, hash)
+		if err != nil {
+			return nil, fmt.Errorf("InitResourceDetailModel(%s, %s): %w", group, hash, err)
+		}
+		resourceMd, err = resource.RenderMarkdown()
+		if err != nil {
+			return nil, fmt.Errorf("InitResourceDetailModel(%s, %s): %w", group, hash, err)
+		}
 	}
 
 	model := resourceDetailModel{
-		group: group,
-		hash:  hash,
+		group:    group,
+		hash:     hash,
+		prevHash: prevHash,
+		showDiff: showDiff,
 		// content:   content,
 		content:         resourceMd,
 		ready:           false,
@@ -128,11 +142,20 @@ func (m resourceDetailModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case "b":
 			cmds = append(cmds, tea.ClearScrollArea, func() tea.Msg { return prevPageMsg{} })
 			return m, tea.Batch(cmds...)
+		case "d":
+			cmds = append(cmds, tea.ClearScrollArea, func() tea.Msg { return toggleDiffMsg{} })
+			return m, tea.Sequence(cmds...)
 		}
 	case prevPageMsg:
 		return m.prevModel.Update(tuiWindowSize)
 	case reloadDetailMsg:
-		detail, _ := InitResourceDetailModel(m.group, m.hash, m.prevModel)
+		detail, _ := InitResourceDetailModel(m.group, m.hash, m.prevHash, m.showDiff, m.prevModel)
+		return detail.Update(tuiWindowSize)
+	case toggleDiffMsg:
+		detail, err := InitResourceDetailModel(m.group, m.hash, m.prevHash, !m.showDiff, m.prevModel)
+		if err != nil {
+			return m, nil
+		}
 		return detail.Update(tuiWindowSize)
 	}
 
@@ -153,7 +176,17 @@ func (m resourceDetailModel) View() string {
 }
 
 func (m resourceDetailModel) headerView() string {
-	title := detailTitleStyle.Render(fmt.Sprintf("Resource: %s", m.hash[:12]))
+	var title string
+	switch {
+	case m.showDiff && m.prevHash == "":
+		title = detailTitleStyle.Render(fmt.Sprintf("Resource: %s (new, d: back)", m.hash[:12]))
+	case m.showDiff:
+		title = detailTitleStyle.Render(
+			fmt.Sprintf("Resource: %s (diff from %s, d: back)", m.hash[:12], m.prevHash[:12]),
+		)
+	default:
+		title = detailTitleStyle.Render(fmt.Sprintf("Resource: %s (d: diff)", m.hash[:12]))
+	}
 	style := lipgloss.NewStyle().Foreground(lipgloss.Color(colorTitleBackground)).Margin(1, 0, 1, 0)
 	line := style.Render(strings.Repeat("─", max(0, m.viewport.Width-lipgloss.Width(title))))
 	return lipgloss.JoinHorizontal(lipgloss.Center, title, line)